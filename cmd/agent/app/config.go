@@ -70,6 +70,12 @@ func initRuntimeSettings() error {
 	if err := commonsettings.RegisterRuntimeSetting(commonsettings.LogPayloadsRuntimeSetting{}); err != nil {
 		return err
 	}
+	if err := commonsettings.RegisterRuntimeSetting(settings.NewForwarderWorkersRuntimeSetting()); err != nil {
+		return err
+	}
+	if err := commonsettings.RegisterRuntimeSetting(settings.NewChecksMinCollectionIntervalRuntimeSetting()); err != nil {
+		return err
+	}
 	if err := commonsettings.RegisterRuntimeSetting(commonsettings.ProfilingGoroutines("internal_profiling_goroutines")); err != nil {
 		return err
 	}
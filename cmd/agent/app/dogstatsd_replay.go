@@ -28,9 +28,14 @@ import (
 )
 
 var (
-	dsdReplayFilePath   string
-	dsdVerboseReplay    bool
-	dsdReplayIterations int
+	dsdReplayFilePath    string
+	dsdVerboseReplay     bool
+	dsdReplayIterations  int
+	dsdReplaySpeed       float64
+	dsdReplayMetrics     []string
+	dsdReplayOrigins     []string
+	dsdReplayExportPath  string
+	dsdReplayExportState string
 )
 
 const (
@@ -41,7 +46,12 @@ func init() {
 	AgentCmd.AddCommand(dogstatsdReplayCmd)
 	dogstatsdReplayCmd.Flags().StringVarP(&dsdReplayFilePath, "file", "f", "", "Input file with TCP traffic to replay.")
 	dogstatsdReplayCmd.Flags().BoolVarP(&dsdVerboseReplay, "verbose", "v", false, "Verbose replay.")
-	dogstatsdReplayCmd.Flags().IntVarP(&dsdReplayIterations, "loops", "l", defaultIterations, "Number of iterationsi to replay.")
+	dogstatsdReplayCmd.Flags().IntVarP(&dsdReplayIterations, "loops", "l", defaultIterations, "Number of iterations to replay, 0 to loop until interrupted.")
+	dogstatsdReplayCmd.Flags().Float64VarP(&dsdReplaySpeed, "speed", "s", 1, "Replay speed relative to the capture, 0 to replay as fast as possible.")
+	dogstatsdReplayCmd.Flags().StringSliceVarP(&dsdReplayMetrics, "metric", "m", nil, "Only replay the metrics matching these glob patterns, events and service checks are skipped.")
+	dogstatsdReplayCmd.Flags().StringSliceVarP(&dsdReplayOrigins, "origin", "o", nil, "Only replay the traffic of these container IDs or PIDs.")
+	dogstatsdReplayCmd.Flags().StringVarP(&dsdReplayExportPath, "export", "", "", "Export the capture as plain-text statsd lines to this file instead of replaying it.")
+	dogstatsdReplayCmd.Flags().StringVarP(&dsdReplayExportState, "export-state", "", "", "Export the tagger state of the capture as JSON to this file instead of replaying it.")
 }
 
 var dogstatsdReplayCmd = &cobra.Command{
@@ -69,7 +79,64 @@ var dogstatsdReplayCmd = &cobra.Command{
 	},
 }
 
+// dogstatsdReplayExport exports the capture to text files, without contacting the agent
+func dogstatsdReplayExport(reader *replay.TrafficCaptureReader, filter *replay.Filter) error {
+	if dsdReplayExportPath != "" {
+		f, err := os.Create(dsdReplayExportPath)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		count, err := reader.ExportText(f, filter)
+		if err != nil {
+			return fmt.Errorf("could not export the capture: %v", err)
+		}
+		fmt.Printf("%d messages exported to %s\n", count, dsdReplayExportPath)
+	}
+
+	if dsdReplayExportState != "" {
+		f, err := os.Create(dsdReplayExportState)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := reader.ExportState(f); err != nil {
+			return fmt.Errorf("could not export the tagger state: %v", err)
+		}
+		fmt.Printf("Tagger state exported to %s\n", dsdReplayExportState)
+	}
+	return nil
+}
+
 func dogstatsdReplay() error {
+	depth := 10
+	reader, err := replay.NewTrafficCaptureReader(dsdReplayFilePath, depth)
+	if reader != nil {
+		defer reader.Close()
+	}
+
+	if err != nil {
+		fmt.Printf("could not open: %s\n", dsdReplayFilePath)
+		return err
+	}
+	reader.SetSpeed(dsdReplaySpeed)
+
+	// let's read state before proceeding
+	pidmap, state, err := reader.ReadState()
+	if err != nil {
+		fmt.Printf("Unable to load state from file, tag enrichment will be unavailable for this capture: %v\n", err)
+	}
+
+	filter, err := replay.NewFilter(dsdReplayMetrics, dsdReplayOrigins, pidmap)
+	if err != nil {
+		return err
+	}
+
+	if dsdReplayExportPath != "" || dsdReplayExportState != "" {
+		return dogstatsdReplayExport(reader, filter)
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -116,17 +183,6 @@ func dogstatsdReplay() error {
 
 	cli := pb.NewAgentSecureClient(apiconn)
 
-	depth := 10
-	reader, err := replay.NewTrafficCaptureReader(dsdReplayFilePath, depth)
-	if reader != nil {
-		defer reader.Close()
-	}
-
-	if err != nil {
-		fmt.Printf("could not open: %s\n", dsdReplayFilePath)
-		return err
-	}
-
 	s := config.Datadog.GetString("dogstatsd_socket")
 	if s == "" {
 		return fmt.Errorf("Dogstatsd UNIX socket disabled")
@@ -156,12 +212,6 @@ func dogstatsdReplay() error {
 	}
 	defer conn.Close()
 
-	// let's read state before proceeding
-	pidmap, state, err := reader.ReadState()
-	if err != nil {
-		fmt.Printf("Unable to load state from file, tag enrichment will be unavailable for this capture: %v\n", err)
-	}
-
 	resp, err := cli.DogstatsdSetTaggerState(ctx, &pb.TaggerState{State: state, PidMap: pidmap})
 	if err != nil {
 		fmt.Printf("Unable to load state API error, tag enrichment will be unavailable for this capture: %v\n", err)
@@ -169,6 +219,23 @@ func dogstatsdReplay() error {
 		fmt.Printf("API refused to set the tagger state, tag enrichment will be unavailable for this capture.\n")
 	}
 
+	send := func(msg *pb.UnixDogstatsdMsg) error {
+		payload := filter.Apply(msg)
+		if payload == nil {
+			return nil
+		}
+		n, oobn, err := conn.(*net.UnixConn).WriteMsgUnix(
+			payload, replay.GetUcredsForPid(msg.Pid), addr)
+		if err != nil {
+			return err
+		}
+
+		if dsdVerboseReplay {
+			fmt.Printf("Sent Payload: %d bytes, and OOB: %d bytes\n", n, oobn)
+		}
+		return nil
+	}
+
 	breaker := false
 	for i := 0; (i < dsdReplayIterations || dsdReplayIterations == 0) && !breaker; i++ {
 
@@ -185,16 +252,16 @@ func dogstatsdReplay() error {
 			case msg := <-reader.Traffic:
 				// The cadence is enforced by the reader. The reader will only write to
 				// the traffic channel when it estimates the payload should be submitted.
-				n, oobn, err := conn.(*net.UnixConn).WriteMsgUnix(
-					msg.Payload[:msg.PayloadSize], replay.GetUcredsForPid(msg.Pid), addr)
-				if err != nil {
+				if err := send(msg); err != nil {
 					return err
 				}
-
-				if dsdVerboseReplay {
-					fmt.Printf("Sent Payload: %d bytes, and OOB: %d bytes\n", n, oobn)
-				}
 			case <-reader.Done:
+				// send the packets still buffered, there can be many when replaying fast
+				for len(reader.Traffic) > 0 {
+					if err := send(<-reader.Traffic); err != nil {
+						return err
+					}
+				}
 				break replay
 			case <-done:
 				breaker = true
@@ -15,7 +15,10 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var explainHostname bool
+
 func init() {
+	getHostnameCommand.Flags().BoolVarP(&explainHostname, "explain", "e", false, "explain how the hostname is resolved by each provider")
 	AgentCmd.AddCommand(getHostnameCommand)
 }
 
@@ -41,6 +44,11 @@ func doGetHostname(cmd *cobra.Command, args []string) error {
 		return err
 	}
 
+	if explainHostname {
+		fmt.Print(util.ExplainHostname(context.TODO()))
+		return nil
+	}
+
 	hname, err := util.GetHostname(context.TODO())
 	if err != nil {
 		return fmt.Errorf("Error getting the hostname: %v", err)
@@ -11,8 +11,11 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
+	"strings"
 	"syscall"
+	"time"
 
 	_ "expvar" // Blank import used because this isn't directly used in this file
 
@@ -31,6 +34,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/collector/corechecks/embed/jmx"
 	"github.com/DataDog/datadog-agent/pkg/config"
 	remoteconfig "github.com/DataDog/datadog-agent/pkg/config/remote/service"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
 	"github.com/DataDog/datadog-agent/pkg/config/settings"
 	"github.com/DataDog/datadog-agent/pkg/dogstatsd"
 	"github.com/DataDog/datadog-agent/pkg/epforwarder"
@@ -41,6 +45,7 @@ import (
 	orchcfg "github.com/DataDog/datadog-agent/pkg/orchestrator/config"
 	"github.com/DataDog/datadog-agent/pkg/otlp"
 	"github.com/DataDog/datadog-agent/pkg/pidfile"
+	"github.com/DataDog/datadog-agent/pkg/secrets"
 	"github.com/DataDog/datadog-agent/pkg/serializer"
 	"github.com/DataDog/datadog-agent/pkg/snmp/traps"
 	"github.com/DataDog/datadog-agent/pkg/status/health"
@@ -316,6 +321,9 @@ func StartAgent() error {
 		return log.Errorf("Error while getting hostname, exiting: %v", err)
 	}
 	log.Infof("Hostname is: %s", hostname)
+	if err := util.PersistHostnameDecision(); err != nil {
+		log.Debugf("Unable to persist the hostname: %v", err)
+	}
 
 	// HACK: init host metadata module (CPU) early to avoid any
 	//       COM threading model conflict with the python checks
@@ -369,6 +377,7 @@ func StartAgent() error {
 	// Enable core agent specific features like persistence-to-disk
 	options := forwarder.NewOptions(keysPerDomain)
 	options.EnabledFeatures = forwarder.SetFeature(options.EnabledFeatures, forwarder.CoreFeatures)
+	options.Sinks = forwarder.NewSinksFromConfig()
 
 	common.Forwarder = forwarder.NewDefaultForwarder(options)
 	log.Debugf("Starting forwarder")
@@ -451,6 +460,25 @@ func StartAgent() error {
 	// start the autoconfig, this will immediately run any configured check
 	common.StartAutoConfig()
 
+	// propagate the rotated secrets to the API keys of the forwarder and to the checks
+	secrets.RegisterRefreshHandler(func(handles []string) {
+		keysPerDomain, err := config.GetMultipleEndpoints()
+		if err != nil {
+			log.Errorf("Unable to update the API keys after the rotation of their secrets: %s", err)
+		} else if updated := resolver.UpdateAPIKeys(options.DomainResolvers, keysPerDomain); len(updated) != 0 {
+			log.Infof("API keys of %s updated after the rotation of their secrets", strings.Join(updated, ", "))
+		}
+		common.AC.ProcessRotatedSecrets(handles)
+	})
+	secrets.StartRefresh(time.Duration(config.Datadog.GetInt("secret_backend_refresh_interval")) * time.Second)
+
+	// apply the runtime settings persisted with `agent config set --persist`, once the
+	// components they change are started
+	settings.SetOverridesFile(filepath.Join(config.Datadog.GetString("run_path"), "runtime_settings.yaml"))
+	if err := settings.LoadPersistedOverrides(); err != nil {
+		log.Errorf("Unable to apply the persisted runtime settings: %v", err)
+	}
+
 	// check for common misconfigurations and report them to log
 	misconfig.ToLog()
 
@@ -485,6 +513,7 @@ func StopAgent() {
 
 	// gracefully shut down any component
 	common.MainCtxCancel()
+	secrets.StopRefresh()
 
 	if common.DSD != nil {
 		common.DSD.Stop()
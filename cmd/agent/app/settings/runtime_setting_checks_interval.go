@@ -0,0 +1,30 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package settings
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/common"
+	"github.com/DataDog/datadog-agent/pkg/config/settings"
+)
+
+// NewChecksMinCollectionIntervalRuntimeSetting returns the runtime setting changing the minimum
+// interval, in seconds, between two runs of the checks
+func NewChecksMinCollectionIntervalRuntimeSetting() settings.RuntimeSetting {
+	return settings.NewIntConfigRuntimeSetting("checks_min_collection_interval",
+		"Minimum interval in seconds between two runs of the checks, 0 to run them at their own interval.",
+		func(interval int) error {
+			if interval < 0 {
+				return fmt.Errorf("the interval cannot be negative")
+			}
+			if common.Coll == nil {
+				return fmt.Errorf("the collector is not running")
+			}
+			return common.Coll.SetMinCollectionInterval(time.Duration(interval) * time.Second)
+		})
+}
@@ -0,0 +1,27 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package settings
+
+import (
+	"fmt"
+
+	"github.com/DataDog/datadog-agent/cmd/agent/common"
+	"github.com/DataDog/datadog-agent/pkg/config/settings"
+)
+
+// NewForwarderWorkersRuntimeSetting returns the runtime setting changing the number of workers
+// of the forwarder for every domain
+func NewForwarderWorkersRuntimeSetting() settings.RuntimeSetting {
+	return settings.NewIntConfigRuntimeSetting("forwarder_num_workers",
+		"Number of concurrent HTTP requests made by the forwarder for each domain.",
+		func(numberOfWorkers int) error {
+			fwd, ok := common.Forwarder.(interface{ SetNumberOfWorkers(int) error })
+			if !ok {
+				return fmt.Errorf("the forwarder doesn't support changing its number of workers")
+			}
+			return fwd.SetNumberOfWorkers(numberOfWorkers)
+		})
+}
@@ -7,6 +7,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config/settings"
 
@@ -41,12 +42,15 @@ func listRuntime(getClient settings.ClientBuilder) *cobra.Command {
 
 // set returns a cobra command to set a config value at runtime.
 func set(getClient settings.ClientBuilder) *cobra.Command {
-	return &cobra.Command{
+	var persist bool
+	cmd := &cobra.Command{
 		Use:   "set [setting] [value]",
 		Short: "Set, for the current runtime, the value of a given configuration setting",
 		Long:  ``,
-		RunE:  func(_ *cobra.Command, args []string) error { return setConfigValue(getClient, args) },
+		RunE:  func(_ *cobra.Command, args []string) error { return setConfigValue(getClient, args, persist) },
 	}
+	cmd.Flags().BoolVar(&persist, "persist", false, "keep the value across restarts")
+	return cmd
 }
 
 // get returns a cobra command to get a runtime config value.
@@ -72,9 +76,32 @@ func showRuntimeConfiguration(getClient settings.ClientBuilder) error {
 
 	fmt.Println(runtimeConfig)
 
+	overrides, err := c.Overrides()
+	if err != nil {
+		return err
+	}
+	printOverrides(overrides)
+
 	return nil
 }
 
+// printOverrides prints the settings changed at runtime as YAML comments, so that the output
+// can still be parsed
+func printOverrides(overrides []settings.RuntimeSettingOverride) {
+	if len(overrides) == 0 {
+		return
+	}
+
+	fmt.Println("# Settings changed at runtime, which differ from the configuration files:")
+	for _, o := range overrides {
+		details := fmt.Sprintf("set by %s at %s", o.Source, o.Time.Format(time.RFC3339))
+		if o.Persisted {
+			details += ", persisted"
+		}
+		fmt.Printf("#   %s: %v -> %v (%s)\n", o.Setting, o.ConfigValue, o.Value, details)
+	}
+}
+
 func listRuntimeConfigurableValue(getClient settings.ClientBuilder) error {
 	c, err := getClient()
 	if err != nil {
@@ -96,7 +123,7 @@ func listRuntimeConfigurableValue(getClient settings.ClientBuilder) error {
 	return nil
 }
 
-func setConfigValue(getClient settings.ClientBuilder, args []string) error {
+func setConfigValue(getClient settings.ClientBuilder, args []string, persist bool) error {
 	if len(args) != 2 {
 		return fmt.Errorf("exactly two parameters are required: the setting name and its value")
 	}
@@ -106,7 +133,12 @@ func setConfigValue(getClient settings.ClientBuilder, args []string) error {
 		return err
 	}
 
-	hidden, err := c.Set(args[0], args[1])
+	var hidden bool
+	if persist {
+		hidden, err = c.SetPersistent(args[0], args[1])
+	} else {
+		hidden, err = c.Set(args[0], args[1])
+	}
 	if err != nil {
 		return err
 	}
@@ -116,6 +148,9 @@ func setConfigValue(getClient settings.ClientBuilder, args []string) error {
 	}
 
 	fmt.Printf("Configuration setting %s is now set to: %s\n", args[0], args[1])
+	if persist {
+		fmt.Printf("The value of %s is kept across restarts\n", args[0])
+	}
 
 	return nil
 }
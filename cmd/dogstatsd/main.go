@@ -183,7 +183,9 @@ func runAgent(ctx context.Context) (err error) {
 	if err != nil {
 		log.Error("Misconfiguration of agent endpoints: ", err)
 	}
-	f := forwarder.NewDefaultForwarder(forwarder.NewOptions(keysPerDomain))
+	options := forwarder.NewOptions(keysPerDomain)
+	options.Sinks = forwarder.NewSinksFromConfig()
+	f := forwarder.NewDefaultForwarder(options)
 	f.Start() //nolint:errcheck
 	s := serializer.NewSerializer(f, nil)
 
@@ -10,6 +10,7 @@ import (
 func setupConfigHandlers(r *mux.Router) {
 	r.HandleFunc("/config", settingshttp.Server.GetFull(config.Namespace)).Methods("GET")
 	r.HandleFunc("/config/list-runtime", settingshttp.Server.ListConfigurable).Methods("GET")
+	r.HandleFunc("/config/overrides", settingshttp.Server.ListOverrides).Methods("GET")
 	r.HandleFunc("/config/{setting}", settingshttp.Server.GetValue).Methods("GET")
 	r.HandleFunc("/config/{setting}", settingshttp.Server.SetValue).Methods("POST")
 }
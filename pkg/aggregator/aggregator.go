@@ -174,6 +174,7 @@ func init() {
 	tagsetTlm = newTagsetTelemetry([]uint64{90, 100})
 
 	aggregatorExpvars.Set("MetricTags", expvar.Func(expMetricTags))
+	aggregatorExpvars.Set("ContextLimiter", expvar.Func(expContextLimiters))
 }
 
 // InitAggregator returns the Singleton instance
@@ -229,6 +230,10 @@ type BufferedAggregator struct {
 
 	statsdSampler          TimeSampler
 	checkSamplers          map[check.ID]*CheckSampler
+	noAggStreamWorker      *noAggregationStreamWorker
+	metricFilter           *metricFilter        // nil when no metric filter is configured, only used from the run goroutine
+	histogramOverrides     *histogramOverrides  // nil when no histogram override is configured
+	openMetricsExporter    *openMetricsExporter // nil when the OpenMetrics export is disabled
 	serviceChecks          metrics.ServiceChecks
 	events                 metrics.Events
 	flushInterval          time.Duration
@@ -266,6 +271,18 @@ func NewBufferedAggregator(s serializer.MetricSerializer, eventPlatformForwarder
 		agentName = flavor.HerokuAgent
 	}
 
+	metricSamplePool := metrics.NewMetricSamplePool(MetricSamplePoolBatchSize)
+
+	var filterRules *metricFilterRules
+	if filters, err := config.GetMetricFilters(); err != nil {
+		log.Errorf("Metric filters are disabled: %v", err)
+	} else if filterRules, err = newMetricFilterRules(filters); err != nil {
+		log.Errorf("Metric filters are disabled: %v", err)
+	}
+
+	noAggStreamWorker := newNoAggregationStreamWorker(bufferSize, config.Datadog.GetInt("dogstatsd_no_aggregation_pipeline_batch_size"), s, metricSamplePool)
+	noAggStreamWorker.metricFilter = newMetricFilter(filterRules)
+
 	aggregator := &BufferedAggregator{
 		bufferedMetricIn:       make(chan []metrics.MetricSample, bufferSize),
 		bufferedMetricInWithTs: make(chan []metrics.MetricSample, bufferSize),
@@ -282,10 +299,14 @@ func NewBufferedAggregator(s serializer.MetricSerializer, eventPlatformForwarder
 		orchestratorMetadataIn: make(chan senderOrchestratorMetadata, bufferSize),
 		eventPlatformIn:        make(chan senderEventPlatformEvent, bufferSize),
 
-		MetricSamplePool: metrics.NewMetricSamplePool(MetricSamplePoolBatchSize),
+		MetricSamplePool: metricSamplePool,
 
 		statsdSampler:           *NewTimeSampler(bucketSize),
 		checkSamplers:           make(map[check.ID]*CheckSampler),
+		noAggStreamWorker:       noAggStreamWorker,
+		metricFilter:            newMetricFilter(filterRules),
+		histogramOverrides:      readHistogramOverrides(),
+		openMetricsExporter:     newOpenMetricsExporter(),
 		flushInterval:           flushInterval,
 		serializer:              s,
 		eventPlatformForwarder:  eventPlatformForwarder,
@@ -301,6 +322,12 @@ func NewBufferedAggregator(s serializer.MetricSerializer, eventPlatformForwarder
 		ServerlessFlushDone:     make(chan struct{}),
 	}
 
+	dogstatsdLimiter := newContextLimiter(dogstatsdLimiterSource, readContextLimiterConfig(false))
+	dogstatsdLimiter.register()
+	aggregator.statsdSampler.setContextLimiter(dogstatsdLimiter)
+	aggregator.statsdSampler.setMetricFilter(aggregator.metricFilter)
+	aggregator.statsdSampler.setHistogramOverrides(aggregator.histogramOverrides)
+
 	return aggregator
 }
 
@@ -335,6 +362,12 @@ func (agg *BufferedAggregator) GetBufferedMetricsWithTsChannel() chan []metrics.
 	return agg.bufferedMetricInWithTs
 }
 
+// GetBufferedNoAggregationChannel returns the channel to send MetricSamples which must not be
+// aggregated: these samples must contain their own timestamp and are sent as-is to the serializer.
+func (agg *BufferedAggregator) GetBufferedNoAggregationChannel() chan []metrics.MetricSample {
+	return agg.noAggStreamWorker.samplesChan
+}
+
 // SetHostname sets the hostname that the aggregator uses by default on all the data it sends
 // Blocks until the main aggregator goroutine has finished handling the update
 func (agg *BufferedAggregator) SetHostname(hostname string) {
@@ -373,16 +406,25 @@ func (agg *BufferedAggregator) registerSender(id check.ID) error {
 	if _, ok := agg.checkSamplers[id]; ok {
 		return fmt.Errorf("Sender with ID '%s' has already been registered, will use existing sampler", id)
 	}
-	agg.checkSamplers[id] = newCheckSampler(
+	checkSampler := newCheckSampler(
 		config.Datadog.GetInt("check_sampler_bucket_commits_count_expiry"),
 		config.Datadog.GetBool("check_sampler_expire_metrics"),
 		config.Datadog.GetDuration("check_sampler_stateful_metric_expiration_time"),
 	)
+	checkLimiter := newContextLimiter(string(id), readContextLimiterConfig(true))
+	checkLimiter.register()
+	checkSampler.setContextLimiter(checkLimiter)
+	checkSampler.setMetricFilter(agg.metricFilter)
+	checkSampler.setHistogramOverrides(agg.histogramOverrides)
+	agg.checkSamplers[id] = checkSampler
 	return nil
 }
 
 func (agg *BufferedAggregator) deregisterSender(id check.ID) {
 	agg.mu.Lock()
+	if checkSampler, ok := agg.checkSamplers[id]; ok {
+		checkSampler.contextResolver.resolver.limiter.deregister()
+	}
 	delete(agg.checkSamplers, id)
 	agg.mu.Unlock()
 }
@@ -395,6 +437,9 @@ func (agg *BufferedAggregator) handleSenderSample(ss senderMetricSample) {
 		if ss.commit {
 			checkSampler.commit(timeNowNano())
 		} else {
+			if agg.metricFilter != nil && !agg.metricFilter.keep(ss.metricSample.Name) {
+				return
+			}
 			ss.metricSample.Tags = util.SortUniqInPlace(ss.metricSample.Tags)
 			checkSampler.addSample(ss.metricSample)
 		}
@@ -408,6 +453,9 @@ func (agg *BufferedAggregator) handleSenderBucket(checkBucket senderHistogramBuc
 	defer agg.mu.Unlock()
 
 	if checkSampler, ok := agg.checkSamplers[checkBucket.id]; ok {
+		if agg.metricFilter != nil && !agg.metricFilter.keep(checkBucket.bucket.Name) {
+			return
+		}
 		checkBucket.bucket.Tags = util.SortUniqInPlace(checkBucket.bucket.Tags)
 		checkSampler.addBucket(checkBucket.bucket)
 	} else {
@@ -454,6 +502,9 @@ func (agg *BufferedAggregator) addEvent(e metrics.Event) {
 
 // addSample adds the metric sample
 func (agg *BufferedAggregator) addSample(metricSample *metrics.MetricSample, timestamp float64) {
+	if agg.metricFilter != nil && !agg.metricFilter.keep(metricSample.Name) {
+		return
+	}
 	agg.statsdSampler.addSample(metricSample, timestamp)
 }
 
@@ -562,6 +613,10 @@ func (agg *BufferedAggregator) sendSeries(start time.Time, series metrics.Series
 
 	addFlushCount("Series", int64(len(series)))
 
+	if agg.openMetricsExporter != nil {
+		agg.openMetricsExporter.updateSeries(series)
+	}
+
 	// For debug purposes print out all metrics/tag combinations
 	if config.Datadog.GetBool("log_payloads") {
 		log.Debug("Flushing the following metrics:")
@@ -581,6 +636,9 @@ func (agg *BufferedAggregator) sendSketches(start time.Time, sketches metrics.Sk
 	// Serialize and forward sketches in a separate goroutine
 	addFlushCount("Sketches", int64(len(sketches)))
 	if len(sketches) != 0 {
+		if agg.openMetricsExporter != nil {
+			agg.openMetricsExporter.updateSketches(sketches)
+		}
 		if waitForSerializer {
 			agg.pushSketches(start, sketches)
 		} else {
@@ -744,10 +802,20 @@ func (agg *BufferedAggregator) run() {
 	// ensures event platform errors are logged at most once per flush
 	aggregatorEventPlatformErrorLogged := false
 
+	go agg.noAggStreamWorker.run()
+
+	if agg.openMetricsExporter != nil {
+		agg.openMetricsExporter.start()
+	}
+
 	for {
 		select {
 		case <-agg.stopChan:
 			log.Info("Stopping aggregator")
+			agg.noAggStreamWorker.stop()
+			if agg.openMetricsExporter != nil {
+				agg.openMetricsExporter.stop()
+			}
 			return
 		case <-agg.health.C:
 		case <-agg.TickerChan:
@@ -761,6 +829,7 @@ func (agg *BufferedAggregator) run() {
 			// flush the aggregator to have the serializer/forwarder send data to the backend.
 			// We add 10 seconds to the interval to ensure that we're getting the whole sketches bucket
 			agg.Flush(start.Add(time.Second*10), true)
+			agg.noAggStreamWorker.flushAndWait()
 			addFlushTime("MainFlushTime", int64(time.Since(start)))
 			aggregatorNumberOfFlush.Add(1)
 			aggregatorEventPlatformErrorLogged = false
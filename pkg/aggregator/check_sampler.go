@@ -24,6 +24,8 @@ type CheckSampler struct {
 	metrics         metrics.CheckMetrics
 	sketchMap       sketchMap
 	lastBucketValue map[ckey.ContextKey]int64
+	// histogramOverrides holds the per-metric settings of the histograms
+	histogramOverrides *histogramOverrides
 }
 
 // newCheckSampler returns a newly initialized CheckSampler
@@ -38,10 +40,35 @@ func newCheckSampler(expirationCount int, expireMetrics bool, statefulTimeout ti
 	}
 }
 
+// setContextLimiter sets the limiter applied to the new contexts of the sampler
+func (cs *CheckSampler) setContextLimiter(limiter *contextLimiter) {
+	cs.contextResolver.resolver.limiter = limiter
+}
+
+// setMetricFilter sets the filter applying the tag rules to the samples of the sampler
+func (cs *CheckSampler) setMetricFilter(filter *metricFilter) {
+	cs.contextResolver.resolver.metricFilter = filter
+}
+
+// setHistogramOverrides sets the per-metric settings of the histograms of the sampler
+func (cs *CheckSampler) setHistogramOverrides(overrides *histogramOverrides) {
+	cs.histogramOverrides = overrides
+}
+
 func (cs *CheckSampler) addSample(metricSample *metrics.MetricSample) {
-	contextKey := cs.contextResolver.trackContext(metricSample)
+	contextKey, ok := cs.contextResolver.trackContext(metricSample)
+	if !ok {
+		log.Tracef("Dropping sample '%s': over the contexts limits", metricSample.Name)
+		return
+	}
+
+	mtype, histogramSettings := cs.histogramOverrides.resolve(metricSample)
+	if mtype == metrics.DistributionType {
+		cs.sketchMap.insert(int64(metricSample.Timestamp), contextKey, metricSample.Value, metricSample.SampleRate)
+		return
+	}
 
-	if err := cs.metrics.AddSample(contextKey, metricSample, metricSample.Timestamp, 1); err != nil {
+	if err := cs.metrics.AddSample(contextKey, metricSample, metricSample.Timestamp, 1, histogramSettings); err != nil {
 		log.Debugf("Ignoring sample '%s' on host '%s' and tags '%s': %s", metricSample.Name, metricSample.Host, metricSample.Tags, err)
 	}
 }
@@ -79,7 +106,11 @@ func (cs *CheckSampler) addBucket(bucket *metrics.HistogramBucket) {
 		return
 	}
 
-	contextKey := cs.contextResolver.trackContext(bucket)
+	contextKey, ok := cs.contextResolver.trackContext(bucket)
+	if !ok {
+		log.Tracef("Dropping histogram bucket '%s': over the contexts limits", bucket.Name)
+		return
+	}
 
 	// if the bucket is monotonic and we have already seen the bucket we only send the delta
 	if bucket.Monotonic {
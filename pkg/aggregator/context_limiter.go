@@ -0,0 +1,454 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// limiterActionDrop drops the samples which would create a context over the limit
+	limiterActionDrop = "drop"
+	// limiterActionStripTags removes the tags responsible for the new context and
+	// aggregates the sample into the resulting context, if it already exists
+	limiterActionStripTags = "strip_tags"
+
+	// dogstatsdLimiterSource is the source name of the DogStatsD context limiter
+	dogstatsdLimiterSource = "dogstatsd"
+
+	// number of metrics and tag keys reported as top offenders
+	limiterTopOffendersCount = 10
+	// number of metric names whose samples over the limits are counted
+	limiterMaxTrackedOverflows = 100
+)
+
+var (
+	tlmContextLimiterOverflow = telemetry.NewCounter("aggregator", "context_limiter_overflow",
+		[]string{"source", "action"}, "Count of samples over the context limits, by source and action taken")
+
+	// contextLimiters holds the running context limiters, by source, to report their stats
+	contextLimiters     = make(map[string]*contextLimiter)
+	contextLimitersLock sync.Mutex
+)
+
+// contextLimiterConfig holds the limits enforced by a contextLimiter. A zero limit means no limit.
+type contextLimiterConfig struct {
+	maxContextsPerMetric int
+	maxContextsPerOrigin int
+	maxContexts          int
+	action               string
+}
+
+// readContextLimiterConfig reads the context limits from the configuration. The `max_contexts_per_check`
+// limit only applies to check samplers: a DogStatsD limiter is limited per metric name and per origin.
+func readContextLimiterConfig(checkSampler bool) contextLimiterConfig {
+	cfg := contextLimiterConfig{
+		maxContextsPerMetric: config.Datadog.GetInt("aggregator_context_limiter.max_contexts_per_metric"),
+		action:               config.Datadog.GetString("aggregator_context_limiter.overflow_action"),
+	}
+	if checkSampler {
+		cfg.maxContexts = config.Datadog.GetInt("aggregator_context_limiter.max_contexts_per_check")
+	} else {
+		cfg.maxContextsPerOrigin = config.Datadog.GetInt("aggregator_context_limiter.max_contexts_per_origin")
+	}
+
+	switch cfg.action {
+	case limiterActionDrop, limiterActionStripTags:
+	default:
+		log.Warnf("Invalid aggregator_context_limiter.overflow_action '%s', defaulting to '%s'", cfg.action, limiterActionDrop)
+		cfg.action = limiterActionDrop
+	}
+	return cfg
+}
+
+func (c contextLimiterConfig) enabled() bool {
+	return c.maxContextsPerMetric > 0 || c.maxContextsPerOrigin > 0 || c.maxContexts > 0
+}
+
+// metricCardinality tracks the contexts of a single metric name
+type metricCardinality struct {
+	contexts int
+	// number of tracked contexts carrying each tag
+	tagRefs map[string]int
+	// number of distinct values per tag key
+	valuesByKey map[string]int
+}
+
+// metricOverflow counts the samples of a metric name over the limits
+type metricOverflow struct {
+	dropped  uint64
+	stripped uint64
+}
+
+func (o *metricOverflow) total() uint64 {
+	return o.dropped + o.stripped
+}
+
+type limiterEntry struct {
+	name   string
+	origin string
+}
+
+// contextLimiter limits the number of contexts a context resolver can track, per metric
+// name, per origin and in total. It is used by the resolver when a new context is seen.
+// Not safe for concurrent use, except for the stats methods.
+type contextLimiter struct {
+	source string
+	config contextLimiterConfig
+
+	mu       sync.Mutex // protects the fields below, read when the stats are exported
+	total    int
+	byMetric map[string]*metricCardinality
+	byOrigin map[string]int
+	entries  map[ckey.ContextKey]limiterEntry
+	// overflows only keeps the limiterMaxTrackedOverflows metric names with the most samples over
+	// the limits, so that the names rejected by the limiter don't grow it without bound
+	overflows map[string]*metricOverflow
+}
+
+// newContextLimiter returns a contextLimiter for the given source, or nil if no limit is configured.
+func newContextLimiter(source string, cfg contextLimiterConfig) *contextLimiter {
+	if !cfg.enabled() {
+		return nil
+	}
+	return &contextLimiter{
+		source:    source,
+		config:    cfg,
+		byMetric:  make(map[string]*metricCardinality),
+		byOrigin:  make(map[string]int),
+		entries:   make(map[ckey.ContextKey]limiterEntry),
+		overflows: make(map[string]*metricOverflow),
+	}
+}
+
+// register makes the limiter stats available in the aggregator stats.
+func (l *contextLimiter) register() {
+	if l == nil {
+		return
+	}
+	contextLimitersLock.Lock()
+	defer contextLimitersLock.Unlock()
+	contextLimiters[l.source] = l
+}
+
+// deregister removes the limiter from the aggregator stats.
+func (l *contextLimiter) deregister() {
+	if l == nil {
+		return
+	}
+	contextLimitersLock.Lock()
+	defer contextLimitersLock.Unlock()
+	if contextLimiters[l.source] == l {
+		delete(contextLimiters, l.source)
+	}
+}
+
+// sampleOrigin returns the origin of the sample, only DogStatsD samples carry one.
+func sampleOrigin(metricSampleContext metrics.MetricSampleContext) string {
+	if sample, ok := metricSampleContext.(*metrics.MetricSample); ok {
+		if sample.K8sOriginID != "" {
+			return sample.K8sOriginID
+		}
+		return sample.OriginID
+	}
+	return ""
+}
+
+func tagKey(tag string) string {
+	if idx := strings.IndexByte(tag, ':'); idx >= 0 {
+		return tag[:idx]
+	}
+	return tag
+}
+
+// allow returns true if a new context can be created for this metric name and origin.
+func (l *contextLimiter) allow(name, origin string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.config.maxContexts > 0 && l.total >= l.config.maxContexts {
+		return false
+	}
+	if l.config.maxContextsPerOrigin > 0 && origin != "" && l.byOrigin[origin] >= l.config.maxContextsPerOrigin {
+		return false
+	}
+	if l.config.maxContextsPerMetric > 0 {
+		if m, found := l.byMetric[name]; found && m.contexts >= l.config.maxContextsPerMetric {
+			return false
+		}
+	}
+	return true
+}
+
+// stripTags removes from tb the tags responsible for a new context of the given metric: the
+// tags never seen on this metric or, if all of them were already seen, the tag whose key has
+// the most distinct values. Returns false if no tag could be removed.
+func (l *contextLimiter) stripTags(name string, tb *tagset.HashingTagsAccumulator) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m, found := l.byMetric[name]
+	if !found {
+		return false
+	}
+
+	tags := append([]string(nil), tb.Get()...)
+	kept := tags[:0]
+	for _, tag := range tags {
+		if m.tagRefs[tag] > 0 {
+			kept = append(kept, tag)
+		}
+	}
+
+	if len(kept) == len(tags) {
+		// every tag was already seen: remove the one with the highest cardinality key
+		worst, worstValues := -1, 0
+		for i, tag := range tags {
+			if values := m.valuesByKey[tagKey(tag)]; values > worstValues {
+				worst, worstValues = i, values
+			}
+		}
+		if worst == -1 {
+			return false
+		}
+		kept = append(tags[:worst], tags[worst+1:]...)
+	}
+
+	tb.Reset()
+	tb.Append(kept...)
+	return true
+}
+
+// strip records a sample aggregated into an existing context after its tags were stripped.
+func (l *contextLimiter) strip(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.overflow(name).stripped++
+	tlmContextLimiterOverflow.Inc(l.source, limiterActionStripTags)
+}
+
+// drop records a sample dropped because of the limits.
+func (l *contextLimiter) drop(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.overflow(name).dropped++
+	tlmContextLimiterOverflow.Inc(l.source, limiterActionDrop)
+}
+
+// overflow returns the overflow counters of a metric name. When limiterMaxTrackedOverflows names
+// are already counted, the name with the fewest samples over the limits is replaced and its
+// counts are inherited (the "space saving" algorithm): the names with the most samples over the
+// limits are kept, with counts which can be overestimated. Must be called with the lock held.
+func (l *contextLimiter) overflow(name string) *metricOverflow {
+	if o, found := l.overflows[name]; found {
+		return o
+	}
+	if len(l.overflows) < limiterMaxTrackedOverflows {
+		o := &metricOverflow{}
+		l.overflows[name] = o
+		return o
+	}
+	var minName string
+	var min *metricOverflow
+	for n, o := range l.overflows {
+		if min == nil || o.total() < min.total() {
+			minName, min = n, o
+		}
+	}
+	delete(l.overflows, minName)
+	l.overflows[name] = min
+	return min
+}
+
+func newMetricCardinality() *metricCardinality {
+	return &metricCardinality{
+		tagRefs:     make(map[string]int),
+		valuesByKey: make(map[string]int),
+	}
+}
+
+// track records a new context.
+func (l *contextLimiter) track(key ckey.ContextKey, name, origin string, tags []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	m, found := l.byMetric[name]
+	if !found {
+		m = newMetricCardinality()
+		l.byMetric[name] = m
+	}
+	m.contexts++
+	for _, tag := range tags {
+		if m.tagRefs[tag] == 0 {
+			m.valuesByKey[tagKey(tag)]++
+		}
+		m.tagRefs[tag]++
+	}
+
+	if origin != "" {
+		l.byOrigin[origin]++
+	}
+	l.total++
+	l.entries[key] = limiterEntry{name: name, origin: origin}
+}
+
+// remove forgets a context which is not tracked anymore by the resolver.
+func (l *contextLimiter) remove(key ckey.ContextKey, tags []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry, found := l.entries[key]
+	if !found {
+		return
+	}
+	delete(l.entries, key)
+	l.total--
+
+	if entry.origin != "" {
+		l.byOrigin[entry.origin]--
+		if l.byOrigin[entry.origin] <= 0 {
+			delete(l.byOrigin, entry.origin)
+		}
+	}
+
+	m, found := l.byMetric[entry.name]
+	if !found {
+		return
+	}
+	m.contexts--
+	for _, tag := range tags {
+		m.tagRefs[tag]--
+		if m.tagRefs[tag] <= 0 {
+			delete(m.tagRefs, tag)
+			k := tagKey(tag)
+			m.valuesByKey[k]--
+			if m.valuesByKey[k] <= 0 {
+				delete(m.valuesByKey, k)
+			}
+		}
+	}
+	if m.contexts <= 0 {
+		delete(l.byMetric, entry.name)
+	}
+}
+
+// tagKeyCardinality is the number of distinct values of a tag key on a metric
+type tagKeyCardinality struct {
+	Key    string `json:"key"`
+	Values int    `json:"values"`
+}
+
+// metricOffender is the stats of a metric reported as one of the top offenders of a limiter
+type metricOffender struct {
+	Name     string              `json:"name"`
+	Contexts int                 `json:"contexts"`
+	Dropped  uint64              `json:"dropped"`
+	Stripped uint64              `json:"stripped"`
+	TagKeys  []tagKeyCardinality `json:"tag_keys"`
+}
+
+// contextLimiterStats is the stats of a limiter exported in the aggregator expvar
+type contextLimiterStats struct {
+	Contexts     int              `json:"contexts"`
+	TopOffenders []metricOffender `json:"top_offenders"`
+}
+
+// stats returns the metrics having the most contexts or the most samples over the limits.
+func (l *contextLimiter) stats() contextLimiterStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	offenders := make([]metricOffender, 0, len(l.byMetric)+len(l.overflows))
+	for name, m := range l.byMetric {
+		offender := metricOffender{
+			Name:     name,
+			Contexts: m.contexts,
+		}
+		if o, found := l.overflows[name]; found {
+			offender.Dropped, offender.Stripped = o.dropped, o.stripped
+		}
+		offenders = append(offenders, offender)
+	}
+	for name, o := range l.overflows {
+		if _, found := l.byMetric[name]; !found {
+			offenders = append(offenders, metricOffender{
+				Name:     name,
+				Dropped:  o.dropped,
+				Stripped: o.stripped,
+			})
+		}
+	}
+	sort.Slice(offenders, func(i, j int) bool {
+		oi, oj := offenders[i].Dropped+offenders[i].Stripped, offenders[j].Dropped+offenders[j].Stripped
+		if oi != oj {
+			return oi > oj
+		}
+		if offenders[i].Contexts != offenders[j].Contexts {
+			return offenders[i].Contexts > offenders[j].Contexts
+		}
+		return offenders[i].Name < offenders[j].Name
+	})
+	if len(offenders) > limiterTopOffendersCount {
+		offenders = offenders[:limiterTopOffendersCount]
+	}
+
+	for i := range offenders {
+		m, found := l.byMetric[offenders[i].Name]
+		if !found {
+			offenders[i].TagKeys = []tagKeyCardinality{}
+			continue
+		}
+		keys := make([]tagKeyCardinality, 0, len(m.valuesByKey))
+		for k, values := range m.valuesByKey {
+			keys = append(keys, tagKeyCardinality{Key: k, Values: values})
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].Values != keys[j].Values {
+				return keys[i].Values > keys[j].Values
+			}
+			return keys[i].Key < keys[j].Key
+		})
+		if len(keys) > limiterTopOffendersCount {
+			keys = keys[:limiterTopOffendersCount]
+		}
+		offenders[i].TagKeys = keys
+	}
+
+	return contextLimiterStats{
+		Contexts:     l.total,
+		TopOffenders: offenders,
+	}
+}
+
+// expContextLimiters returns the stats of all the running limiters, by source.
+// Only the limiters having dropped or stripped samples are reported.
+func expContextLimiters() interface{} {
+	contextLimitersLock.Lock()
+	defer contextLimitersLock.Unlock()
+
+	stats := make(map[string]contextLimiterStats)
+	for source, l := range contextLimiters {
+		s := l.stats()
+		for _, o := range s.TopOffenders {
+			if o.Dropped > 0 || o.Stripped > 0 {
+				stats[source] = s
+				break
+			}
+		}
+	}
+	return stats
+}
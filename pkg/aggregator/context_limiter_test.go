@@ -0,0 +1,218 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package aggregator
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+func newLimitedContextResolver(cfg contextLimiterConfig) *contextResolver {
+	cr := newContextResolver()
+	cr.limiter = newContextLimiter("test", cfg)
+	return cr
+}
+
+func TestContextLimiterDisabled(t *testing.T) {
+	assert.Nil(t, newContextLimiter("test", contextLimiterConfig{action: limiterActionDrop}))
+}
+
+func TestContextLimiterPerMetricDrop(t *testing.T) {
+	cr := newLimitedContextResolver(contextLimiterConfig{maxContextsPerMetric: 2, action: limiterActionDrop})
+
+	_, ok := cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"request_id:1"}})
+	assert.True(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"request_id:2"}})
+	assert.True(t, ok)
+
+	// over the limit for foo
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"request_id:3"}})
+	assert.False(t, ok)
+
+	// existing contexts and other metrics are still accepted
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"request_id:1"}})
+	assert.True(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "bar", Tags: []string{"request_id:3"}})
+	assert.True(t, ok)
+
+	assert.Equal(t, 3, cr.length())
+
+	stats := cr.limiter.stats()
+	assert.Equal(t, 3, stats.Contexts)
+	require.Len(t, stats.TopOffenders, 2)
+	assert.Equal(t, "foo", stats.TopOffenders[0].Name)
+	assert.Equal(t, 2, stats.TopOffenders[0].Contexts)
+	assert.Equal(t, uint64(1), stats.TopOffenders[0].Dropped)
+	assert.Equal(t, []tagKeyCardinality{{Key: "request_id", Values: 2}}, stats.TopOffenders[0].TagKeys)
+}
+
+func TestContextLimiterPerOrigin(t *testing.T) {
+	cr := newLimitedContextResolver(contextLimiterConfig{maxContextsPerOrigin: 1, action: limiterActionDrop})
+
+	_, ok := cr.trackContext(&metrics.MetricSample{Name: "foo", OriginID: "container_id://a"})
+	assert.True(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "bar", OriginID: "container_id://a"})
+	assert.False(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "bar", OriginID: "container_id://b"})
+	assert.True(t, ok)
+	// samples without origin are not limited per origin
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "bar"})
+	assert.True(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "baz"})
+	assert.True(t, ok)
+}
+
+func TestContextLimiterTotal(t *testing.T) {
+	cr := newLimitedContextResolver(contextLimiterConfig{maxContexts: 2, action: limiterActionDrop})
+
+	_, ok := cr.trackContext(&metrics.MetricSample{Name: "foo"})
+	assert.True(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "bar"})
+	assert.True(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "baz"})
+	assert.False(t, ok)
+}
+
+func TestContextLimiterStripTags(t *testing.T) {
+	cr := newLimitedContextResolver(contextLimiterConfig{maxContextsPerMetric: 3, action: limiterActionStripTags})
+
+	_, ok := cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"env:prod", "request_id:1"}})
+	require.True(t, ok)
+	key2, ok := cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"env:prod", "request_id:2"}})
+	require.True(t, ok)
+	keyProd, ok := cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"env:prod"}})
+	require.True(t, ok)
+
+	// request_id:3 was never seen on foo: it is stripped
+	key, ok := cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"env:prod", "request_id:3"}})
+	require.True(t, ok)
+	assert.Equal(t, keyProd, key)
+
+	// all tags were already seen: the keys with the most values are stripped until
+	// an existing context is found
+	key, ok = cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"env:prod", "request_id:1", "request_id:2"}})
+	require.True(t, ok)
+	assert.Equal(t, key2, key)
+
+	// no existing context is left once the tags are stripped: the sample is dropped
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"env:staging", "request_id:4"}})
+	assert.False(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"request_id:1"}})
+	assert.False(t, ok)
+
+	assert.Equal(t, 3, cr.length())
+	stats := cr.limiter.stats()
+	require.Len(t, stats.TopOffenders, 1)
+	assert.Equal(t, uint64(2), stats.TopOffenders[0].Stripped)
+	assert.Equal(t, uint64(2), stats.TopOffenders[0].Dropped)
+}
+
+func TestContextLimiterStripTagsBounded(t *testing.T) {
+	cr := newLimitedContextResolver(contextLimiterConfig{maxContextsPerMetric: 2, action: limiterActionStripTags})
+
+	_, ok := cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"env:prod"}})
+	require.True(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"env:staging"}})
+	require.True(t, ok)
+
+	// every sample has a distinct set of tags once stripped of the unseen ones
+	for i := 0; i < 100; i++ {
+		cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{
+			fmt.Sprintf("env:prod_%d", i%10),
+			fmt.Sprintf("request_id:%d", i),
+		}})
+		cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{
+			"env:prod",
+			"env:staging",
+			fmt.Sprintf("request_id:%d", i),
+		}})
+	}
+	assert.Equal(t, 2, cr.length())
+	assert.Equal(t, 2, cr.limiter.stats().Contexts)
+}
+
+func TestContextLimiterExpiredContexts(t *testing.T) {
+	cr := newTimestampContextResolver()
+	cr.resolver.limiter = newContextLimiter("test", contextLimiterConfig{maxContextsPerMetric: 1, maxContextsPerOrigin: 1, action: limiterActionDrop})
+
+	_, ok := cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"a:1"}, OriginID: "origin"}, 1)
+	require.True(t, ok)
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"a:2"}, OriginID: "origin"}, 2)
+	require.False(t, ok)
+
+	// once the context expired, a new one can be tracked
+	cr.expireContexts(5)
+	assert.Equal(t, 0, cr.resolver.limiter.total)
+	assert.Len(t, cr.resolver.limiter.byOrigin, 0)
+
+	_, ok = cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"a:2"}, OriginID: "origin"}, 6)
+	assert.True(t, ok)
+}
+
+func TestContextLimiterTimeSamplerDrop(t *testing.T) {
+	sampler := NewTimeSampler(10)
+	sampler.setContextLimiter(newContextLimiter("test", contextLimiterConfig{maxContextsPerMetric: 1, action: limiterActionDrop}))
+
+	sampler.addSample(&metrics.MetricSample{Name: "foo", Value: 1, Mtype: metrics.GaugeType, Tags: []string{"a:1"}, SampleRate: 1}, 12345.0)
+	sampler.addSample(&metrics.MetricSample{Name: "foo", Value: 1, Mtype: metrics.GaugeType, Tags: []string{"a:2"}, SampleRate: 1}, 12345.0)
+
+	series, _ := sampler.flush(12360.0)
+	require.Len(t, series, 1)
+	assert.Equal(t, []string{"a:1"}, series[0].Tags)
+}
+
+func TestExpContextLimiters(t *testing.T) {
+	l := newContextLimiter("test_exp", contextLimiterConfig{maxContextsPerMetric: 1, action: limiterActionDrop})
+	l.register()
+	defer l.deregister()
+
+	stats := expContextLimiters().(map[string]contextLimiterStats)
+	assert.NotContains(t, stats, "test_exp")
+
+	cr := newContextResolver()
+	cr.limiter = l
+	cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"a:1"}})
+	cr.trackContext(&metrics.MetricSample{Name: "foo", Tags: []string{"a:2"}})
+
+	stats = expContextLimiters().(map[string]contextLimiterStats)
+	require.Contains(t, stats, "test_exp")
+	assert.Equal(t, uint64(1), stats["test_exp"].TopOffenders[0].Dropped)
+}
+
+func TestContextLimiterOverflowsBounded(t *testing.T) {
+	cr := newLimitedContextResolver(contextLimiterConfig{maxContexts: 1, action: limiterActionDrop})
+
+	_, ok := cr.trackContext(&metrics.MetricSample{Name: "foo"})
+	require.True(t, ok)
+	for i := 0; i < 3; i++ {
+		_, ok = cr.trackContext(&metrics.MetricSample{Name: "noisy"})
+		require.False(t, ok)
+	}
+	// every rejected name is distinct, only a bounded number of them is counted
+	for i := 0; i < 10*limiterMaxTrackedOverflows; i++ {
+		_, ok = cr.trackContext(&metrics.MetricSample{Name: fmt.Sprintf("name_%d", i)})
+		require.False(t, ok)
+		if i%5 == 0 {
+			_, ok = cr.trackContext(&metrics.MetricSample{Name: "noisy"})
+			require.False(t, ok)
+		}
+	}
+	assert.Len(t, cr.limiter.overflows, limiterMaxTrackedOverflows)
+	assert.Len(t, cr.limiter.byMetric, 1)
+
+	// the names with the most samples over the limits are kept
+	stats := cr.limiter.stats()
+	require.NotEmpty(t, stats.TopOffenders)
+	assert.Equal(t, "noisy", stats.TopOffenders[0].Name)
+	assert.Equal(t, uint64(3+2*limiterMaxTrackedOverflows), stats.TopOffenders[0].Dropped)
+}
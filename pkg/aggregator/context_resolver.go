@@ -27,6 +27,10 @@ type contextResolver struct {
 	// buffer slice allocated once per contextResolver to combine and sort
 	// tags, origin detection tags and k8s tags.
 	tagsBuffer *tagset.HashingTagsAccumulator
+	// limiter limits the number of contexts tracked, nil if there's no limit
+	limiter *contextLimiter
+	// metricFilter applies the tag rules to the tags of the samples, nil if there's no filter
+	metricFilter *metricFilter
 }
 
 // generateContextKey generates the contextKey associated with the context of the metricSample
@@ -42,24 +46,67 @@ func newContextResolver() *contextResolver {
 	}
 }
 
-// trackContext returns the contextKey associated with the context of the metricSample and tracks that context
-func (cr *contextResolver) trackContext(metricSampleContext metrics.MetricSampleContext) ckey.ContextKey {
-	metricSampleContext.GetTags(cr.tagsBuffer)               // tags here are not sorted and can contain duplicates
+// trackContext returns the contextKey associated with the context of the metricSample and tracks that context.
+// It returns false if the context is over the limits of the resolver and the sample must be dropped.
+func (cr *contextResolver) trackContext(metricSampleContext metrics.MetricSampleContext) (ckey.ContextKey, bool) {
+	metricSampleContext.GetTags(cr.tagsBuffer) // tags here are not sorted and can contain duplicates
+	if cr.metricFilter != nil {
+		// the tags of the tagger and of the origin detection are only known from here
+		cr.metricFilter.filterTags(metricSampleContext.GetName(), cr.tagsBuffer)
+	}
 	contextKey := cr.generateContextKey(metricSampleContext) // the generator will remove duplicates from cr.tagsBuffer (and doesn't mind the order)
 
 	if _, ok := cr.contextsByKey[contextKey]; !ok {
+		name := metricSampleContext.GetName()
+		origin := sampleOrigin(metricSampleContext)
+
+		if cr.limiter != nil && !cr.limiter.allow(name, origin) {
+			// the sample is either dropped or folded into an existing context without
+			// the offending tags: no context is created over the limits
+			var stripped bool
+			contextKey, stripped = cr.stripTags(metricSampleContext)
+			if !stripped {
+				cr.limiter.drop(name)
+				cr.tagsBuffer.Reset()
+				return 0, false
+			}
+			cr.limiter.strip(name)
+			cr.tagsBuffer.Reset()
+			return contextKey, true
+		}
+
 		// making a copy of tags for the context since tagsBuffer
 		// will be reused later. This allow us to allocate one slice
 		// per context instead of one per sample.
-		cr.contextsByKey[contextKey] = &Context{
-			Name: metricSampleContext.GetName(),
+		context := &Context{
+			Name: name,
 			Tags: cr.tagsBuffer.Copy(),
 			Host: metricSampleContext.GetHost(),
 		}
+		cr.contextsByKey[contextKey] = context
+		if cr.limiter != nil {
+			cr.limiter.track(contextKey, name, origin, context.Tags)
+		}
 	}
 
 	cr.tagsBuffer.Reset()
-	return contextKey
+	return contextKey, true
+}
+
+// stripTags removes the tags of the sample until its context is an existing one, when the
+// limiter action is to strip the tags. Returns false if no existing context was found.
+func (cr *contextResolver) stripTags(metricSampleContext metrics.MetricSampleContext) (ckey.ContextKey, bool) {
+	if cr.limiter.config.action != limiterActionStripTags {
+		return 0, false
+	}
+	name := metricSampleContext.GetName()
+	for cr.limiter.stripTags(name, cr.tagsBuffer) {
+		contextKey := cr.generateContextKey(metricSampleContext)
+		if _, ok := cr.contextsByKey[contextKey]; ok {
+			return contextKey, true
+		}
+	}
+	return 0, false
 }
 
 func (cr *contextResolver) get(key ckey.ContextKey) (*Context, bool) {
@@ -73,6 +120,11 @@ func (cr *contextResolver) length() int {
 
 func (cr *contextResolver) removeKeys(expiredContextKeys []ckey.ContextKey) {
 	for _, expiredContextKey := range expiredContextKeys {
+		if cr.limiter != nil {
+			if context, found := cr.contextsByKey[expiredContextKey]; found {
+				cr.limiter.remove(expiredContextKey, context.Tags)
+			}
+		}
 		delete(cr.contextsByKey, expiredContextKey)
 	}
 }
@@ -102,10 +154,12 @@ func (cr *timestampContextResolver) updateTrackedContext(contextKey ckey.Context
 }
 
 // trackContext returns the contextKey associated with the context of the metricSample and tracks that context
-func (cr *timestampContextResolver) trackContext(metricSampleContext metrics.MetricSampleContext, currentTimestamp float64) ckey.ContextKey {
-	contextKey := cr.resolver.trackContext(metricSampleContext)
-	cr.lastSeenByKey[contextKey] = currentTimestamp
-	return contextKey
+func (cr *timestampContextResolver) trackContext(metricSampleContext metrics.MetricSampleContext, currentTimestamp float64) (ckey.ContextKey, bool) {
+	contextKey, ok := cr.resolver.trackContext(metricSampleContext)
+	if ok {
+		cr.lastSeenByKey[contextKey] = currentTimestamp
+	}
+	return contextKey, ok
 }
 
 func (cr *timestampContextResolver) length() int {
@@ -157,10 +211,12 @@ func newCountBasedContextResolver(expireCountInterval int) *countBasedContextRes
 }
 
 // trackContext returns the contextKey associated with the context of the metricSample and tracks that context
-func (cr *countBasedContextResolver) trackContext(metricSampleContext metrics.MetricSampleContext) ckey.ContextKey {
-	contextKey := cr.resolver.trackContext(metricSampleContext)
-	cr.expireCountByKey[contextKey] = cr.expireCount
-	return contextKey
+func (cr *countBasedContextResolver) trackContext(metricSampleContext metrics.MetricSampleContext) (ckey.ContextKey, bool) {
+	contextKey, ok := cr.resolver.trackContext(metricSampleContext)
+	if ok {
+		cr.expireCountByKey[contextKey] = cr.expireCount
+	}
+	return contextKey, ok
 }
 
 func (cr *countBasedContextResolver) get(key ckey.ContextKey) (*Context, bool) {
@@ -0,0 +1,124 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// histogramOverridesCacheSize is the maximum number of metric names for which
+// histogramOverrides keeps the override to apply. The cache is reset when full.
+const histogramOverridesCacheSize = 10000
+
+// histogramOverride holds how the histograms of the metrics matching one of the patterns are handled
+type histogramOverride struct {
+	metrics []*regexp.Regexp
+	// settings of the histograms, nil for the default ones
+	settings *metrics.HistogramSettings
+	// distribution is true if the samples are aggregated in a distribution sketch instead
+	distribution bool
+}
+
+// histogramOverrides resolves the override of the histogram settings of a metric name, the
+// first matching override applies. It is shared by the samplers and safe for concurrent use.
+type histogramOverrides struct {
+	overrides []histogramOverride
+
+	mu    sync.Mutex
+	cache map[string]*histogramOverride
+}
+
+// newHistogramOverrides compiles the given overrides, it returns nil if no override is defined.
+func newHistogramOverrides(cfg []config.HistogramOverride) (*histogramOverrides, error) {
+	if len(cfg) == 0 {
+		return nil, nil
+	}
+
+	h := &histogramOverrides{cache: make(map[string]*histogramOverride)}
+	for i, o := range cfg {
+		if len(o.Metrics) == 0 {
+			return nil, fmt.Errorf("invalid histogram override #%d: no metric pattern", i+1)
+		}
+		patterns, err := compileMetricNamePatterns(o.Metrics)
+		if err != nil {
+			return nil, err
+		}
+
+		override := histogramOverride{metrics: patterns, distribution: o.Distribution}
+		if !o.Distribution && (o.Aggregates != nil || o.Percentiles != nil) {
+			override.settings = &metrics.HistogramSettings{Aggregates: o.Aggregates}
+			if o.Percentiles != nil {
+				override.settings.Percentiles = metrics.ParseHistogramPercentiles(o.Percentiles, "histogram_overrides")
+			}
+		}
+		h.overrides = append(h.overrides, override)
+	}
+	return h, nil
+}
+
+// get returns the override of the histograms of the given metric, or nil if the default
+// settings apply.
+func (h *histogramOverrides) get(name string) *histogramOverride {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if override, found := h.cache[name]; found {
+		return override
+	}
+
+	var override *histogramOverride
+	for i := range h.overrides {
+		if matchAny(h.overrides[i].metrics, name) {
+			override = &h.overrides[i]
+			break
+		}
+	}
+
+	if len(h.cache) >= histogramOverridesCacheSize {
+		h.cache = make(map[string]*histogramOverride)
+	}
+	h.cache[name] = override
+	return override
+}
+
+// resolve returns the metric type to use for the sample, and the settings of the histogram
+// to create if the sample is a histogram.
+func (h *histogramOverrides) resolve(sample *metrics.MetricSample) (metrics.MetricType, *metrics.HistogramSettings) {
+	if h == nil || sample.Mtype != metrics.HistogramType {
+		return sample.Mtype, nil
+	}
+
+	override := h.get(sample.Name)
+	switch {
+	case override == nil:
+		return sample.Mtype, nil
+	case override.distribution:
+		return metrics.DistributionType, nil
+	default:
+		return sample.Mtype, override.settings
+	}
+}
+
+// readHistogramOverrides returns the histogram overrides set in the configuration
+func readHistogramOverrides() *histogramOverrides {
+	cfg, err := config.GetHistogramOverrides()
+	if err != nil {
+		log.Errorf("Histogram overrides are disabled: %v", err)
+		return nil
+	}
+	overrides, err := newHistogramOverrides(cfg)
+	if err != nil {
+		log.Errorf("Histogram overrides are disabled: %v", err)
+		return nil
+	}
+	return overrides
+}
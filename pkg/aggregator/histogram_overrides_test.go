@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package aggregator
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+func newTestHistogramOverrides(t *testing.T, cfg []config.HistogramOverride) *histogramOverrides {
+	overrides, err := newHistogramOverrides(cfg)
+	require.NoError(t, err)
+	return overrides
+}
+
+func TestHistogramOverridesInvalid(t *testing.T) {
+	overrides, err := newHistogramOverrides(nil)
+	assert.NoError(t, err)
+	assert.Nil(t, overrides)
+
+	_, err = newHistogramOverrides([]config.HistogramOverride{{Aggregates: []string{"max"}}})
+	assert.Error(t, err)
+
+	_, err = newHistogramOverrides([]config.HistogramOverride{{Metrics: []string{"/(/"}}})
+	assert.Error(t, err)
+}
+
+func TestHistogramOverridesResolve(t *testing.T) {
+	overrides := newTestHistogramOverrides(t, []config.HistogramOverride{
+		{Metrics: []string{"*.latency"}, Percentiles: []string{"0.99", "0.5"}},
+		{Metrics: []string{"http.*"}, Distribution: true},
+		{Metrics: []string{"queue.*"}, Aggregates: []string{"max"}, Percentiles: []string{}},
+	})
+
+	mtype, settings := overrides.resolve(&metrics.MetricSample{Name: "http.latency", Mtype: metrics.HistogramType})
+	assert.Equal(t, metrics.HistogramType, mtype)
+	require.NotNil(t, settings)
+	assert.Nil(t, settings.Aggregates)
+	assert.Equal(t, []int{99, 50}, settings.Percentiles)
+
+	// the first matching override applies
+	mtype, settings = overrides.resolve(&metrics.MetricSample{Name: "http.requests", Mtype: metrics.HistogramType})
+	assert.Equal(t, metrics.DistributionType, mtype)
+	assert.Nil(t, settings)
+
+	mtype, settings = overrides.resolve(&metrics.MetricSample{Name: "queue.size", Mtype: metrics.HistogramType})
+	assert.Equal(t, metrics.HistogramType, mtype)
+	assert.Equal(t, &metrics.HistogramSettings{Aggregates: []string{"max"}, Percentiles: []int{}}, settings)
+
+	// only the histograms are overridden
+	mtype, settings = overrides.resolve(&metrics.MetricSample{Name: "http.requests", Mtype: metrics.GaugeType})
+	assert.Equal(t, metrics.GaugeType, mtype)
+	assert.Nil(t, settings)
+
+	mtype, settings = overrides.resolve(&metrics.MetricSample{Name: "other", Mtype: metrics.HistogramType})
+	assert.Equal(t, metrics.HistogramType, mtype)
+	assert.Nil(t, settings)
+
+	// no override configured
+	mtype, settings = (*histogramOverrides)(nil).resolve(&metrics.MetricSample{Name: "http.requests", Mtype: metrics.HistogramType})
+	assert.Equal(t, metrics.HistogramType, mtype)
+	assert.Nil(t, settings)
+}
+
+func TestHistogramOverridesTimeSampler(t *testing.T) {
+	sampler := NewTimeSampler(10)
+	sampler.setHistogramOverrides(newTestHistogramOverrides(t, []config.HistogramOverride{
+		{Metrics: []string{"my.latency"}, Aggregates: []string{"max"}, Percentiles: []string{"0.99"}},
+		{Metrics: []string{"my.size"}, Distribution: true},
+	}))
+
+	for i := 1; i <= 100; i++ {
+		sampler.addSample(&metrics.MetricSample{Name: "my.latency", Value: float64(i), Mtype: metrics.HistogramType, SampleRate: 1}, 12345.0)
+		sampler.addSample(&metrics.MetricSample{Name: "my.size", Value: float64(i), Mtype: metrics.HistogramType, SampleRate: 1}, 12345.0)
+	}
+
+	series, sketches := sampler.flush(12360.0)
+	names := make([]string, 0, len(series))
+	for _, serie := range series {
+		names = append(names, serie.Name)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"my.latency.99percentile", "my.latency.max"}, names)
+
+	require.Len(t, sketches, 1)
+	assert.Equal(t, "my.size", sketches[0].Name)
+	assert.Equal(t, int64(100), sketches[0].Points[0].Sketch.Basic.Cnt)
+}
+
+func TestHistogramOverridesCheckSampler(t *testing.T) {
+	checkSampler := newCheckSampler(1, true, 1*time.Second)
+	checkSampler.setHistogramOverrides(newTestHistogramOverrides(t, []config.HistogramOverride{
+		{Metrics: []string{"my.latency"}, Aggregates: []string{"min"}, Percentiles: []string{}},
+		{Metrics: []string{"my.size"}, Distribution: true},
+	}))
+
+	checkSampler.addSample(&metrics.MetricSample{Name: "my.latency", Value: 1, Mtype: metrics.HistogramType, SampleRate: 1, Timestamp: 12345.0})
+	checkSampler.addSample(&metrics.MetricSample{Name: "my.latency", Value: 2, Mtype: metrics.HistogramType, SampleRate: 1, Timestamp: 12345.0})
+	checkSampler.addSample(&metrics.MetricSample{Name: "my.size", Value: 3, Mtype: metrics.HistogramType, SampleRate: 1, Timestamp: 12345.0})
+
+	checkSampler.commit(12346.0)
+	series, sketches := checkSampler.flush()
+
+	require.Len(t, series, 1)
+	assert.Equal(t, "my.latency.min", series[0].Name)
+	assert.Equal(t, 1.0, series[0].Points[0].Value)
+
+	require.Len(t, sketches, 1)
+	assert.Equal(t, "my.size", sketches[0].Name)
+	assert.Equal(t, int64(1), sketches[0].Points[0].Sketch.Basic.Cnt)
+}
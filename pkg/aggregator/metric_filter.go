@@ -0,0 +1,240 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"expvar"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+)
+
+// metricFilterCacheSize is the maximum number of metric names for which a metricFilter
+// keeps the rules to apply. The cache is reset when full.
+const metricFilterCacheSize = 10000
+
+var (
+	metricFilterExpvars       = expvar.NewMap("metric_filter")
+	metricFilterDropped       = expvar.Int{}
+	metricFilterTagsRemoved   = expvar.Int{}
+	metricFilterTagsRewritten = expvar.Int{}
+
+	tlmMetricFilterMatches = telemetry.NewCounter("aggregator", "metric_filter_matches",
+		[]string{"action"}, "Count of the metric samples and tags matched by the metric filters, by action")
+	tlmMetricFilterDropped   = tlmMetricFilterMatches.WithValues("dropped")
+	tlmMetricFilterRemoved   = tlmMetricFilterMatches.WithValues("tag_removed")
+	tlmMetricFilterRewritten = tlmMetricFilterMatches.WithValues("tag_rewritten")
+)
+
+func init() {
+	metricFilterExpvars.Set("Dropped", &metricFilterDropped)
+	metricFilterExpvars.Set("TagsRemoved", &metricFilterTagsRemoved)
+	metricFilterExpvars.Set("TagsRewritten", &metricFilterTagsRewritten)
+}
+
+// compileMetricNamePattern compiles a metric name pattern: patterns surrounded by slashes
+// are regular expressions, the others are globs where `*` matches any sequence of characters
+// and `?` a single character.
+func compileMetricNamePattern(pattern string) (*regexp.Regexp, error) {
+	if len(pattern) >= 2 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+		return regexp.Compile(pattern[1 : len(pattern)-1])
+	}
+	expr := regexp.QuoteMeta(pattern)
+	expr = strings.ReplaceAll(expr, `\*`, ".*")
+	expr = strings.ReplaceAll(expr, `\?`, ".")
+	return regexp.Compile("^" + expr + "$")
+}
+
+func compileMetricNamePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := compileMetricNamePattern(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric name pattern '%s': %v", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+func matchAny(patterns []*regexp.Regexp, name string) bool {
+	for _, re := range patterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+type tagRewrite struct {
+	prefix      string // the tag key followed by ':'
+	match       *regexp.Regexp
+	replacement string
+}
+
+type tagRule struct {
+	metrics    []*regexp.Regexp
+	removeKeys map[string]struct{}
+	rewrites   []tagRewrite
+}
+
+// metricFilterRules holds the compiled metric filters. It is immutable and can be shared.
+type metricFilterRules struct {
+	include  []*regexp.Regexp
+	exclude  []*regexp.Regexp
+	tagRules []tagRule
+}
+
+// newMetricFilterRules compiles the given filters, it returns nil if no filter is defined.
+func newMetricFilterRules(filters config.MetricFilters) (*metricFilterRules, error) {
+	if len(filters.Include) == 0 && len(filters.Exclude) == 0 && len(filters.TagRules) == 0 {
+		return nil, nil
+	}
+
+	var err error
+	rules := &metricFilterRules{}
+	if rules.include, err = compileMetricNamePatterns(filters.Include); err != nil {
+		return nil, err
+	}
+	if rules.exclude, err = compileMetricNamePatterns(filters.Exclude); err != nil {
+		return nil, err
+	}
+
+	for _, r := range filters.TagRules {
+		rule := tagRule{removeKeys: make(map[string]struct{}, len(r.RemoveTags))}
+		if rule.metrics, err = compileMetricNamePatterns(r.Metrics); err != nil {
+			return nil, err
+		}
+		for _, key := range r.RemoveTags {
+			rule.removeKeys[key] = struct{}{}
+		}
+		for _, rw := range r.RewriteTags {
+			if rw.Key == "" {
+				return nil, fmt.Errorf("invalid tag rewrite: missing key")
+			}
+			re, err := regexp.Compile(rw.Match)
+			if err != nil {
+				return nil, fmt.Errorf("invalid tag rewrite pattern '%s' for key '%s': %v", rw.Match, rw.Key, err)
+			}
+			rule.rewrites = append(rule.rewrites, tagRewrite{prefix: rw.Key + ":", match: re, replacement: rw.Replace})
+		}
+		rules.tagRules = append(rules.tagRules, rule)
+	}
+
+	return rules, nil
+}
+
+// metricFilterDecision is what a metricFilter has to do with the samples of a metric name
+type metricFilterDecision struct {
+	keep     bool
+	tagRules []*tagRule
+}
+
+// metricFilter applies metric filter rules on samples. It caches the decision taken
+// for every metric name. Not safe for concurrent use.
+type metricFilter struct {
+	rules *metricFilterRules
+	cache map[string]metricFilterDecision
+}
+
+func newMetricFilter(rules *metricFilterRules) *metricFilter {
+	if rules == nil {
+		return nil
+	}
+	return &metricFilter{
+		rules: rules,
+		cache: make(map[string]metricFilterDecision),
+	}
+}
+
+func (f *metricFilter) decide(name string) metricFilterDecision {
+	if decision, found := f.cache[name]; found {
+		return decision
+	}
+
+	decision := metricFilterDecision{
+		keep: (len(f.rules.include) == 0 || matchAny(f.rules.include, name)) && !matchAny(f.rules.exclude, name),
+	}
+	if decision.keep {
+		for i := range f.rules.tagRules {
+			rule := &f.rules.tagRules[i]
+			if len(rule.metrics) == 0 || matchAny(rule.metrics, name) {
+				decision.tagRules = append(decision.tagRules, rule)
+			}
+		}
+	}
+
+	if len(f.cache) >= metricFilterCacheSize {
+		f.cache = make(map[string]metricFilterDecision)
+	}
+	f.cache[name] = decision
+	return decision
+}
+
+// keep returns false if the samples of the metric must be dropped.
+func (f *metricFilter) keep(name string) bool {
+	if !f.decide(name).keep {
+		metricFilterDropped.Add(1)
+		tlmMetricFilterDropped.Inc()
+		return false
+	}
+	return true
+}
+
+// filterTags applies the tag rules of the metric to the tags of tb. It is called once the
+// tags of the sample, of the tagger and of the origin detection are all in tb.
+func (f *metricFilter) filterTags(name string, tb *tagset.HashingTagsAccumulator) {
+	decision := f.decide(name)
+	if !decision.keep || len(decision.tagRules) == 0 {
+		return
+	}
+
+	tags := tb.Get()
+	var newTags []string
+	for i, tag := range tags {
+		newTag, keep := applyTagRules(decision.tagRules, tag)
+		if newTags == nil && (!keep || newTag != tag) {
+			// first modification: copy the tags seen so far
+			newTags = make([]string, i, len(tags))
+			copy(newTags, tags[:i])
+		}
+		if newTags != nil && keep {
+			newTags = append(newTags, newTag)
+		}
+	}
+	if newTags == nil {
+		return
+	}
+	tb.Reset()
+	tb.Append(newTags...)
+}
+
+func applyTagRules(rules []*tagRule, tag string) (string, bool) {
+	for _, rule := range rules {
+		if _, found := rule.removeKeys[tagKey(tag)]; found {
+			metricFilterTagsRemoved.Add(1)
+			tlmMetricFilterRemoved.Inc()
+			return "", false
+		}
+		for _, rw := range rule.rewrites {
+			if !strings.HasPrefix(tag, rw.prefix) {
+				continue
+			}
+			value := tag[len(rw.prefix):]
+			if !rw.match.MatchString(value) {
+				continue
+			}
+			tag = rw.prefix + rw.match.ReplaceAllString(value, rw.replacement)
+			metricFilterTagsRewritten.Add(1)
+			tlmMetricFilterRewritten.Inc()
+		}
+	}
+	return tag, true
+}
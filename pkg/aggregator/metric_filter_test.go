@@ -0,0 +1,149 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagger"
+	"github.com/DataDog/datadog-agent/pkg/tagger/local"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+func newTestMetricFilter(t *testing.T, filters config.MetricFilters) *metricFilter {
+	rules, err := newMetricFilterRules(filters)
+	require.NoError(t, err)
+	return newMetricFilter(rules)
+}
+
+func TestMetricFilterDisabled(t *testing.T) {
+	assert.Nil(t, newTestMetricFilter(t, config.MetricFilters{}))
+}
+
+func TestMetricFilterInvalidPattern(t *testing.T) {
+	_, err := newMetricFilterRules(config.MetricFilters{Exclude: []string{"/foo(/"}})
+	assert.Error(t, err)
+
+	_, err = newMetricFilterRules(config.MetricFilters{TagRules: []config.MetricTagRule{
+		{RewriteTags: []config.MetricTagRewrite{{Key: "env", Match: "("}}},
+	}})
+	assert.Error(t, err)
+}
+
+func TestMetricFilterIncludeExclude(t *testing.T) {
+	f := newTestMetricFilter(t, config.MetricFilters{
+		Include: []string{"my.app.*", "/^system\\.(cpu|mem)\\./"},
+		Exclude: []string{"my.app.debug.*", "my.app.tmp?"},
+	})
+
+	for name, expected := range map[string]bool{
+		"my.app.requests":    true,
+		"my.app.debug.count": false,
+		"my.app.tmp1":        false,
+		"my.app.tmp10":       true,
+		"system.cpu.user":    true,
+		"system.disk.used":   false,
+		"other":              false,
+	} {
+		assert.Equal(t, expected, f.keep(name), name)
+		// the cached decision is the same
+		assert.Equal(t, expected, f.keep(name), name)
+	}
+}
+
+func TestMetricFilterTags(t *testing.T) {
+	f := newTestMetricFilter(t, config.MetricFilters{
+		TagRules: []config.MetricTagRule{
+			{
+				Metrics:    []string{"http.*"},
+				RemoveTags: []string{"request_id"},
+				RewriteTags: []config.MetricTagRewrite{
+					{Key: "path", Match: "^/users/[0-9]+", Replace: "/users/:id"},
+				},
+			},
+			{
+				RemoveTags: []string{"pod_name"},
+			},
+		},
+	})
+
+	tags := []string{"env:prod", "path:/users/42/profile", "pod_name:web-1", "request_id:abc"}
+	original := append([]string{}, tags...)
+
+	tb := tagset.NewHashingTagsAccumulatorWithTags(tags)
+	f.filterTags("http.requests", tb)
+	assert.Equal(t, []string{"env:prod", "path:/users/:id/profile"}, tb.Get())
+	// the given slice is left untouched
+	assert.Equal(t, original, tags)
+
+	// only the rules without metric patterns apply to other metrics
+	tb = tagset.NewHashingTagsAccumulatorWithTags(tags)
+	f.filterTags("db.queries", tb)
+	assert.Equal(t, []string{"env:prod", "path:/users/42/profile", "request_id:abc"}, tb.Get())
+
+	// the tags are left as is when nothing changes
+	tb = tagset.NewHashingTagsAccumulatorWithTags([]string{"env:prod"})
+	hashes := tb.Hashes()
+	f.filterTags("http.requests", tb)
+	assert.Equal(t, []string{"env:prod"}, tb.Get())
+	assert.Equal(t, hashes, tb.Hashes())
+}
+
+func TestMetricFilterTimeSampler(t *testing.T) {
+	agg := NewBufferedAggregator(nil, nil, "hostname", DefaultFlushInterval)
+	agg.metricFilter = newTestMetricFilter(t, config.MetricFilters{
+		Exclude:  []string{"excluded"},
+		TagRules: []config.MetricTagRule{{RemoveTags: []string{"a"}}},
+	})
+	agg.statsdSampler.setMetricFilter(agg.metricFilter)
+
+	tags := []string{"a:1", "b:2"}
+	agg.addSample(&metrics.MetricSample{Name: "kept", Value: 1, Mtype: metrics.GaugeType, Tags: tags, SampleRate: 1}, 12345.0)
+	agg.addSample(&metrics.MetricSample{Name: "excluded", Value: 1, Mtype: metrics.GaugeType, Tags: tags, SampleRate: 1}, 12345.0)
+
+	series, _ := agg.statsdSampler.flush(12360.0)
+	require.Len(t, series, 1)
+	assert.Equal(t, "kept", series[0].Name)
+	assert.Equal(t, []string{"b:2"}, series[0].Tags)
+	assert.Equal(t, []string{"a:1", "b:2"}, tags)
+}
+
+func TestMetricFilterTaggerTags(t *testing.T) {
+	oldTagger := tagger.GetDefaultTagger()
+	defer tagger.SetDefaultTagger(oldTagger)
+
+	fakeTagger := local.NewFakeTagger()
+	tagger.SetDefaultTagger(fakeTagger)
+	fakeTagger.SetTags("container_id://abc", "fooSource", []string{"kube_namespace:default"}, []string{"pod_name:web-1"}, nil, nil)
+
+	agg := NewBufferedAggregator(nil, nil, "hostname", DefaultFlushInterval)
+	agg.metricFilter = newTestMetricFilter(t, config.MetricFilters{
+		TagRules: []config.MetricTagRule{{RemoveTags: []string{"pod_name"}}},
+	})
+	agg.statsdSampler.setMetricFilter(agg.metricFilter)
+
+	// the pod_name tag is only added by the origin detection
+	agg.addSample(&metrics.MetricSample{
+		Name:        "my.metric",
+		Value:       1,
+		Mtype:       metrics.GaugeType,
+		Tags:        []string{"env:prod"},
+		SampleRate:  1,
+		OriginID:    "container_id://abc",
+		Cardinality: "orchestrator",
+	}, 12345.0)
+
+	series, _ := agg.statsdSampler.flush(12360.0)
+	require.Len(t, series, 1)
+	assert.ElementsMatch(t, []string{"env:prod", "kube_namespace:default"}, series[0].Tags)
+}
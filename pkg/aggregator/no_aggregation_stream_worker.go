@@ -0,0 +1,198 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/serializer"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// noAggWorkerFlushInterval is the interval at which the no-aggregation
+// worker flushes the series it has buffered when the batch size is not reached.
+const noAggWorkerFlushInterval = 2 * time.Second
+
+var (
+	noAggExpvars                          = expvar.NewMap("no_aggregation")
+	expvarNoAggSamplesProcessedOk         = expvar.Int{}
+	expvarNoAggSamplesUnsupportedType     = expvar.Int{}
+	expvarNoAggSamplesInvalidTimestamp    = expvar.Int{}
+	expvarNoAggFlush                      = expvar.Int{}
+	tlmNoAggSamples                       = telemetry.NewCounter("no_aggregation", "samples", []string{"state"}, "Count the number of samples handled by the no-aggregation pipeline")
+	tlmNoAggSamplesOk                     = tlmNoAggSamples.WithValues("ok")
+	tlmNoAggSamplesDroppedUnsupportedType = tlmNoAggSamples.WithValues("unsupported_type")
+	tlmNoAggSamplesDroppedInvalidTs       = tlmNoAggSamples.WithValues("invalid_timestamp")
+	tlmNoAggFlush                         = telemetry.NewSimpleCounter("no_aggregation", "flush", "Count the number of flushes done by the no-aggregation pipeline")
+)
+
+func init() {
+	noAggExpvars.Set("ProcessedOk", &expvarNoAggSamplesProcessedOk)
+	noAggExpvars.Set("DroppedUnsupportedType", &expvarNoAggSamplesUnsupportedType)
+	noAggExpvars.Set("DroppedInvalidTimestamp", &expvarNoAggSamplesInvalidTimestamp)
+	noAggExpvars.Set("Flush", &expvarNoAggFlush)
+}
+
+// noAggregationStreamWorker receives samples already carrying their own timestamp
+// and converts them into series without going through the samplers. The
+// resulting series are sent to the serializer every time `maxBatchSize` series
+// have been buffered, or every `noAggWorkerFlushInterval`.
+// Only gauges and counts are supported, the other types are dropped.
+type noAggregationStreamWorker struct {
+	serializer       serializer.MetricSerializer
+	metricSamplePool *metrics.MetricSamplePool
+	maxBatchSize     int
+	metricFilter     *metricFilter
+
+	samplesChan chan []metrics.MetricSample
+	flushChan   chan chan struct{}
+	stopChan    chan chan struct{}
+
+	series     metrics.Series
+	tagsBuffer *tagset.HashingTagsAccumulator
+}
+
+func newNoAggregationStreamWorker(bufferSize, maxBatchSize int, serializer serializer.MetricSerializer, metricSamplePool *metrics.MetricSamplePool) *noAggregationStreamWorker {
+	if maxBatchSize <= 0 {
+		maxBatchSize = 1
+	}
+	return &noAggregationStreamWorker{
+		serializer:       serializer,
+		metricSamplePool: metricSamplePool,
+		maxBatchSize:     maxBatchSize,
+		samplesChan:      make(chan []metrics.MetricSample, bufferSize),
+		flushChan:        make(chan chan struct{}),
+		stopChan:         make(chan chan struct{}),
+		series:           make(metrics.Series, 0, maxBatchSize),
+		tagsBuffer:       tagset.NewHashingTagsAccumulator(),
+	}
+}
+
+// addSamples converts the given samples into series and buffers them.
+func (w *noAggregationStreamWorker) addSamples(samples []metrics.MetricSample) {
+	for i := range samples {
+		sample := &samples[i]
+
+		if sample.Timestamp <= 0 {
+			expvarNoAggSamplesInvalidTimestamp.Add(1)
+			tlmNoAggSamplesDroppedInvalidTs.Inc()
+			log.Debugf("Dropping sample '%s' from the no-aggregation pipeline: invalid timestamp %f", sample.Name, sample.Timestamp)
+			continue
+		}
+
+		if w.metricFilter != nil && !w.metricFilter.keep(sample.Name) {
+			continue
+		}
+
+		var mtype metrics.APIMetricType
+		value := sample.Value
+		switch sample.Mtype {
+		case metrics.GaugeType:
+			mtype = metrics.APIGaugeType
+		case metrics.CounterType:
+			mtype = metrics.APICountType
+			if sample.SampleRate > 0 && sample.SampleRate < 1 {
+				value = value / sample.SampleRate
+			}
+		default:
+			expvarNoAggSamplesUnsupportedType.Add(1)
+			tlmNoAggSamplesDroppedUnsupportedType.Inc()
+			log.Debugf("Dropping sample '%s' from the no-aggregation pipeline: unsupported metric type %s", sample.Name, sample.Mtype)
+			continue
+		}
+
+		// the samples tags are shared between samples and their slice
+		// is given back to the pool: the serie needs its own copy.
+		sample.GetTags(w.tagsBuffer)
+		if w.metricFilter != nil {
+			w.metricFilter.filterTags(sample.Name, w.tagsBuffer)
+		}
+		w.tagsBuffer.SortUniq()
+		tags := w.tagsBuffer.Copy()
+		w.tagsBuffer.Reset()
+
+		w.series = append(w.series, &metrics.Serie{
+			Name:   sample.Name,
+			Points: []metrics.Point{{Ts: sample.Timestamp, Value: value}},
+			Tags:   tags,
+			Host:   sample.Host,
+			MType:  mtype,
+		})
+
+		expvarNoAggSamplesProcessedOk.Add(1)
+		tlmNoAggSamplesOk.Inc()
+
+		if len(w.series) >= w.maxBatchSize {
+			w.flush()
+		}
+	}
+}
+
+// flush sends the buffered series to the serializer.
+func (w *noAggregationStreamWorker) flush() {
+	if len(w.series) == 0 {
+		return
+	}
+
+	series := w.series
+	w.series = make(metrics.Series, 0, w.maxBatchSize)
+
+	log.Debugf("Flushing %d series from the no-aggregation pipeline to the forwarder", len(series))
+	state := stateOk
+	if err := w.serializer.SendSeries(series); err != nil {
+		log.Warnf("Error flushing series from the no-aggregation pipeline: %v", err)
+		aggregatorSeriesFlushErrors.Add(1)
+		state = stateError
+	}
+	aggregatorSeriesFlushed.Add(int64(len(series)))
+	tlmFlush.Add(float64(len(series)), "series", state)
+	expvarNoAggFlush.Add(1)
+	tlmNoAggFlush.Inc()
+}
+
+// run processes the incoming samples until stop is called.
+func (w *noAggregationStreamWorker) run() {
+	log.Debug("Starting the no-aggregation pipeline worker")
+	ticker := time.NewTicker(noAggWorkerFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case done := <-w.stopChan:
+			w.flush()
+			done <- struct{}{}
+			log.Debug("Stopping the no-aggregation pipeline worker")
+			return
+		case done := <-w.flushChan:
+			w.flush()
+			done <- struct{}{}
+		case <-ticker.C:
+			w.flush()
+		case samples := <-w.samplesChan:
+			w.addSamples(samples)
+			w.metricSamplePool.PutBatch(samples)
+		}
+	}
+}
+
+// flushAndWait triggers a flush of the buffered series and blocks until it is done.
+// The worker must be running.
+func (w *noAggregationStreamWorker) flushAndWait() {
+	done := make(chan struct{})
+	w.flushChan <- done
+	<-done
+}
+
+// stop flushes the buffered series and stops the worker. The worker must be running.
+func (w *noAggregationStreamWorker) stop() {
+	done := make(chan struct{})
+	w.stopChan <- done
+	<-done
+}
@@ -0,0 +1,103 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package aggregator
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/serializer"
+)
+
+func TestNoAggregationStreamWorkerSeries(t *testing.T) {
+	s := &serializer.MockSerializer{}
+	w := newNoAggregationStreamWorker(10, 10, s, metrics.NewMetricSamplePool(MetricSamplePoolBatchSize))
+
+	w.addSamples([]metrics.MetricSample{
+		{Name: "my.gauge", Value: 1, Mtype: metrics.GaugeType, Tags: []string{"b", "a", "a"}, Host: "host", SampleRate: 1, Timestamp: 1000},
+		{Name: "my.count", Value: 2, Mtype: metrics.CounterType, Tags: []string{"a"}, Host: "host", SampleRate: 0.5, Timestamp: 1010},
+	})
+	require.Len(t, w.series, 2)
+
+	assert.Equal(t, &metrics.Serie{
+		Name:   "my.gauge",
+		Points: []metrics.Point{{Ts: 1000, Value: 1}},
+		Tags:   []string{"a", "b"},
+		Host:   "host",
+		MType:  metrics.APIGaugeType,
+	}, w.series[0])
+	assert.Equal(t, &metrics.Serie{
+		Name:   "my.count",
+		Points: []metrics.Point{{Ts: 1010, Value: 4}},
+		Tags:   []string{"a"},
+		Host:   "host",
+		MType:  metrics.APICountType,
+	}, w.series[1])
+
+	s.On("SendSeries", mock.Anything).Return(nil).Times(1)
+	w.flush()
+	s.AssertNumberOfCalls(t, "SendSeries", 1)
+	assert.Len(t, w.series, 0)
+
+	// nothing to flush: the serializer is not called
+	w.flush()
+	s.AssertNumberOfCalls(t, "SendSeries", 1)
+}
+
+func TestNoAggregationStreamWorkerDroppedSamples(t *testing.T) {
+	s := &serializer.MockSerializer{}
+	w := newNoAggregationStreamWorker(10, 10, s, metrics.NewMetricSamplePool(MetricSamplePoolBatchSize))
+
+	unsupported := expvarNoAggSamplesUnsupportedType.Value()
+	invalidTs := expvarNoAggSamplesInvalidTimestamp.Value()
+
+	w.addSamples([]metrics.MetricSample{
+		{Name: "my.histogram", Value: 1, Mtype: metrics.HistogramType, SampleRate: 1, Timestamp: 1000},
+		{Name: "my.distribution", Value: 1, Mtype: metrics.DistributionType, SampleRate: 1, Timestamp: 1000},
+		{Name: "my.gauge", Value: 1, Mtype: metrics.GaugeType, SampleRate: 1},
+	})
+
+	assert.Len(t, w.series, 0)
+	assert.Equal(t, unsupported+2, expvarNoAggSamplesUnsupportedType.Value())
+	assert.Equal(t, invalidTs+1, expvarNoAggSamplesInvalidTimestamp.Value())
+}
+
+func TestNoAggregationStreamWorkerBatchSize(t *testing.T) {
+	s := &serializer.MockSerializer{}
+	s.On("SendSeries", mock.Anything).Return(nil)
+	w := newNoAggregationStreamWorker(10, 2, s, metrics.NewMetricSamplePool(MetricSamplePoolBatchSize))
+
+	sample := metrics.MetricSample{Name: "my.gauge", Value: 1, Mtype: metrics.GaugeType, SampleRate: 1, Timestamp: 1000}
+	w.addSamples([]metrics.MetricSample{sample, sample, sample, sample, sample})
+
+	s.AssertNumberOfCalls(t, "SendSeries", 2)
+	assert.Len(t, w.series, 1)
+}
+
+func TestNoAggregationStreamWorkerRun(t *testing.T) {
+	s := &serializer.MockSerializer{}
+	s.On("SendSeries", mock.Anything).Return(nil)
+	pool := metrics.NewMetricSamplePool(MetricSamplePoolBatchSize)
+	// unbuffered channel: the samples are received before the flush is triggered
+	w := newNoAggregationStreamWorker(0, 100, s, pool)
+	go w.run()
+
+	samples := pool.GetBatch()
+	samples[0] = metrics.MetricSample{Name: "my.gauge", Value: 1, Mtype: metrics.GaugeType, SampleRate: 1, Timestamp: 1000}
+	w.samplesChan <- samples[:1]
+
+	w.flushAndWait()
+	s.AssertNumberOfCalls(t, "SendSeries", 1)
+
+	w.stop()
+	s.AssertNumberOfCalls(t, "SendSeries", 1)
+}
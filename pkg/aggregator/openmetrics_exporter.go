@@ -0,0 +1,410 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package aggregator
+
+import (
+	"bytes"
+	"context"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+	openMetricsGauge       = "gauge"
+	openMetricsSummary     = "summary"
+)
+
+// openMetricsQuantiles are the quantiles of the sketches exported in the summaries
+var openMetricsQuantiles = []float64{0.5, 0.75, 0.95, 0.99}
+
+var (
+	openMetricsExpvars        = expvar.NewMap("openmetrics_export")
+	openMetricsExportContexts = expvar.Int{}
+	openMetricsExportScrapes  = expvar.Int{}
+)
+
+func init() {
+	openMetricsExpvars.Set("Contexts", &openMetricsExportContexts)
+	openMetricsExpvars.Set("Scrapes", &openMetricsExportScrapes)
+}
+
+// openMetricsSample is the latest value of a context, or the latest summary of a sketch
+type openMetricsSample struct {
+	labels    string // rendered label set, without the braces
+	value     float64
+	quantiles []float64 // only for the summaries, matching openMetricsQuantiles
+	sum       float64   // only for the summaries
+	count     int64     // only for the summaries
+	ts        float64
+	updated   time.Time
+}
+
+type openMetricsFamily struct {
+	kind    string
+	samples map[string]*openMetricsSample
+}
+
+// openMetricsExporter keeps the latest series and sketches flushed by the aggregator and
+// serves them in the OpenMetrics text format so that they can be scraped by Prometheus.
+// It only keeps a copy of the flushed data: the series and sketches sent to the serializer
+// are never modified.
+type openMetricsExporter struct {
+	mu       sync.Mutex
+	families map[string]*openMetricsFamily
+
+	address     string
+	include     []*regexp.Regexp // all the metrics are exported when empty
+	exclude     []*regexp.Regexp
+	excludeTags map[string]struct{}
+	staleness   time.Duration // 0 means the contexts are kept until the agent stops
+
+	server *http.Server
+	now    func() time.Time // to ease testing
+}
+
+// newOpenMetricsExporter returns the exporter configured in `openmetrics_export`, or nil
+// when it is disabled or its configuration is invalid.
+func newOpenMetricsExporter() *openMetricsExporter {
+	if !config.Datadog.GetBool("openmetrics_export.enabled") {
+		return nil
+	}
+
+	include, err := compileMetricNamePatterns(config.Datadog.GetStringSlice("openmetrics_export.include_metrics"))
+	if err != nil {
+		log.Errorf("The OpenMetrics export is disabled: %v", err)
+		return nil
+	}
+	exclude, err := compileMetricNamePatterns(config.Datadog.GetStringSlice("openmetrics_export.exclude_metrics"))
+	if err != nil {
+		log.Errorf("The OpenMetrics export is disabled: %v", err)
+		return nil
+	}
+	excludeTags := make(map[string]struct{})
+	for _, key := range config.Datadog.GetStringSlice("openmetrics_export.exclude_tags") {
+		excludeTags[key] = struct{}{}
+	}
+
+	return &openMetricsExporter{
+		families:    make(map[string]*openMetricsFamily),
+		address:     net.JoinHostPort(config.Datadog.GetString("openmetrics_export.bind_host"), config.Datadog.GetString("openmetrics_export.port")),
+		include:     include,
+		exclude:     exclude,
+		excludeTags: excludeTags,
+		staleness:   config.Datadog.GetDuration("openmetrics_export.staleness_window") * time.Second,
+		now:         time.Now,
+	}
+}
+
+// start serves the exported metrics on `/metrics`
+func (e *openMetricsExporter) start() {
+	listener, err := net.Listen("tcp", e.address)
+	if err != nil {
+		log.Errorf("Could not start the OpenMetrics export on %s: %v", e.address, err)
+		return
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", e.handle)
+	e.server = &http.Server{Handler: mux}
+	log.Infof("Serving the flushed metrics in the OpenMetrics format on http://%s/metrics", listener.Addr())
+
+	go func() {
+		if err := e.server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			log.Errorf("The OpenMetrics export stopped: %v", err)
+		}
+	}()
+}
+
+func (e *openMetricsExporter) stop() {
+	if e.server == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	_ = e.server.Shutdown(ctx)
+}
+
+func (e *openMetricsExporter) exported(name string) bool {
+	if len(e.include) > 0 && !matchAny(e.include, name) {
+		return false
+	}
+	return !matchAny(e.exclude, name)
+}
+
+// updateSeries records the latest point of each of the flushed series
+func (e *openMetricsExporter) updateSeries(series metrics.Series) {
+	now := e.now()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, serie := range series {
+		if len(serie.Points) == 0 || !e.exported(serie.Name) {
+			continue
+		}
+		latest := serie.Points[0]
+		for _, point := range serie.Points[1:] {
+			if point.Ts >= latest.Ts {
+				latest = point
+			}
+		}
+		sample := e.sample(serie.Name, openMetricsGauge, serie.Host, serie.Tags, now)
+		if sample == nil {
+			continue
+		}
+		sample.value = latest.Value
+		sample.ts = latest.Ts
+	}
+	e.prune(now)
+}
+
+// updateSketches records a summary of each of the flushed sketches, merging the sketches of
+// the buckets flushed together.
+func (e *openMetricsExporter) updateSketches(sketches metrics.SketchSeriesList) {
+	now := e.now()
+	cfg := quantile.Default()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, serie := range sketches {
+		if len(serie.Points) == 0 || !e.exported(serie.Name) {
+			continue
+		}
+		merged := &quantile.Sketch{}
+		var ts int64
+		for _, point := range serie.Points {
+			if point.Sketch == nil {
+				continue
+			}
+			merged.Merge(cfg, point.Sketch)
+			if point.Ts > ts {
+				ts = point.Ts
+			}
+		}
+		if merged.Basic.Cnt == 0 {
+			continue
+		}
+
+		sample := e.sample(serie.Name, openMetricsSummary, serie.Host, serie.Tags, now)
+		if sample == nil {
+			continue
+		}
+		sample.quantiles = make([]float64, len(openMetricsQuantiles))
+		for i, q := range openMetricsQuantiles {
+			sample.quantiles[i] = merged.Quantile(cfg, q)
+		}
+		sample.sum = merged.Basic.Sum
+		sample.count = merged.Basic.Cnt
+		sample.ts = float64(ts)
+	}
+	e.prune(now)
+}
+
+// sample returns the sample of the given context, nil if the metric name is already exported
+// with another type. The caller must hold the lock.
+func (e *openMetricsExporter) sample(name, kind, host string, tags []string, now time.Time) *openMetricsSample {
+	name = openMetricsName(name)
+	family, found := e.families[name]
+	if !found {
+		family = &openMetricsFamily{kind: kind, samples: make(map[string]*openMetricsSample)}
+		e.families[name] = family
+	} else if family.kind != kind {
+		log.Debugf("Not exporting the %s %q, it is already exported as a %s", kind, name, family.kind)
+		return nil
+	}
+
+	labels := e.labels(host, tags)
+	sample, found := family.samples[labels]
+	if !found {
+		sample = &openMetricsSample{labels: labels}
+		family.samples[labels] = sample
+		openMetricsExportContexts.Add(1)
+	}
+	sample.updated = now
+	return sample
+}
+
+// prune removes the contexts which were not flushed during the staleness window. The caller
+// must hold the lock.
+func (e *openMetricsExporter) prune(now time.Time) {
+	if e.staleness <= 0 {
+		return
+	}
+	for name, family := range e.families {
+		for labels, sample := range family.samples {
+			if now.Sub(sample.updated) > e.staleness {
+				delete(family.samples, labels)
+				openMetricsExportContexts.Add(-1)
+			}
+		}
+		if len(family.samples) == 0 {
+			delete(e.families, name)
+		}
+	}
+}
+
+// labels renders the tags as labels: the values of the tags with the same key are joined
+// with commas and the tags without a value get the value `true`.
+func (e *openMetricsExporter) labels(host string, tags []string) string {
+	values := make(map[string][]string, len(tags)+1)
+	for _, tag := range tags {
+		key, value := tag, "true"
+		if i := strings.IndexByte(tag, ':'); i >= 0 {
+			key, value = tag[:i], tag[i+1:]
+		}
+		if _, excluded := e.excludeTags[key]; excluded {
+			continue
+		}
+		key = openMetricsLabelName(key)
+		values[key] = append(values[key], value)
+	}
+	if _, found := values["host"]; !found && host != "" {
+		values["host"] = []string{host}
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	for i, key := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		sort.Strings(values[key])
+		b.WriteString(key)
+		b.WriteString(`="`)
+		b.WriteString(openMetricsLabelValue(strings.Join(values[key], ",")))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func (e *openMetricsExporter) handle(w http.ResponseWriter, r *http.Request) {
+	openMetricsExportScrapes.Add(1)
+	w.Header().Set("Content-Type", openMetricsContentType)
+	_, _ = w.Write(e.render())
+}
+
+// render returns the exported metrics in the OpenMetrics text format
+func (e *openMetricsExporter) render() []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.prune(e.now())
+
+	names := make([]string, 0, len(e.families))
+	for name := range e.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b bytes.Buffer
+	for _, name := range names {
+		family := e.families[name]
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, family.kind)
+
+		labels := make([]string, 0, len(family.samples))
+		for l := range family.samples {
+			labels = append(labels, l)
+		}
+		sort.Strings(labels)
+
+		for _, l := range labels {
+			sample := family.samples[l]
+			if family.kind == openMetricsSummary {
+				for i, q := range openMetricsQuantiles {
+					writeOpenMetricsLine(&b, name, joinLabels(l, `quantile="`+strconv.FormatFloat(q, 'g', -1, 64)+`"`), sample.quantiles[i], sample.ts)
+				}
+				writeOpenMetricsLine(&b, name+"_sum", l, sample.sum, sample.ts)
+				writeOpenMetricsLine(&b, name+"_count", l, float64(sample.count), sample.ts)
+			} else {
+				writeOpenMetricsLine(&b, name, l, sample.value, sample.ts)
+			}
+		}
+	}
+	b.WriteString("# EOF\n")
+	return b.Bytes()
+}
+
+func writeOpenMetricsLine(b *bytes.Buffer, name, labels string, value, ts float64) {
+	b.WriteString(name)
+	if labels != "" {
+		b.WriteByte('{')
+		b.WriteString(labels)
+		b.WriteByte('}')
+	}
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	if ts > 0 {
+		b.WriteByte(' ')
+		b.WriteString(strconv.FormatFloat(ts, 'f', -1, 64))
+	}
+	b.WriteByte('\n')
+}
+
+func joinLabels(labels, label string) string {
+	if labels == "" {
+		return label
+	}
+	return labels + "," + label
+}
+
+// openMetricsName replaces the characters which are not allowed in the OpenMetrics metric
+// names, like the dots of the Datadog metric names, with underscores.
+func openMetricsName(name string) string {
+	return sanitizeOpenMetrics(name, true)
+}
+
+func openMetricsLabelName(name string) string {
+	name = sanitizeOpenMetrics(name, false)
+	if strings.HasPrefix(name, "__") {
+		// reserved for internal use
+		return "tag" + name
+	}
+	return name
+}
+
+func sanitizeOpenMetrics(name string, allowColon bool) string {
+	if name == "" {
+		return "_"
+	}
+	b := []byte(name)
+	for i, c := range b {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(c >= '0' && c <= '9') || (allowColon && c == ':')
+		if !valid {
+			b[i] = '_'
+		}
+	}
+	if b[0] >= '0' && b[0] <= '9' {
+		return "_" + string(b)
+	}
+	return string(b)
+}
+
+func openMetricsLabelValue(value string) string {
+	if !strings.ContainsAny(value, "\\\"\n") {
+		return value
+	}
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	return strings.ReplaceAll(value, "\n", `\n`)
+}
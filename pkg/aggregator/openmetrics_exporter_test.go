@@ -0,0 +1,222 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build test
+
+package aggregator
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/quantile"
+)
+
+func newTestOpenMetricsExporter(t *testing.T, settings map[string]interface{}) *openMetricsExporter {
+	mockConfig := config.Mock()
+	mockConfig.Set("openmetrics_export.enabled", true)
+	for key, value := range settings {
+		mockConfig.Set(key, value)
+	}
+	defer func() {
+		mockConfig.Set("openmetrics_export.enabled", false)
+		for key := range settings {
+			mockConfig.Set(key, nil)
+		}
+	}()
+
+	e := newOpenMetricsExporter()
+	require.NotNil(t, e)
+	return e
+}
+
+func TestOpenMetricsExporterDisabled(t *testing.T) {
+	config.Mock()
+	assert.Nil(t, newOpenMetricsExporter())
+}
+
+func TestOpenMetricsExporterSeries(t *testing.T) {
+	e := newTestOpenMetricsExporter(t, nil)
+
+	e.updateSeries(metrics.Series{
+		{
+			Name:   "my.metric",
+			Points: []metrics.Point{{Ts: 20, Value: 2}, {Ts: 10, Value: 1}},
+			Tags:   []string{"env:prod", "role:b", "role:a", "standalone"},
+			Host:   "myhost",
+			MType:  metrics.APIGaugeType,
+		},
+		{
+			Name:   "my.metric",
+			Points: []metrics.Point{{Ts: 20, Value: 3.5}},
+			Tags:   []string{"env:dev", "host:other", `path:C:\dir "a"`},
+			Host:   "myhost",
+			MType:  metrics.APICountType,
+		},
+		{
+			Name:   "1st-metric",
+			Points: []metrics.Point{{Ts: 20, Value: 1}},
+			MType:  metrics.APIRateType,
+		},
+		{
+			Name:  "no.points",
+			MType: metrics.APIGaugeType,
+		},
+	})
+
+	expected := `# TYPE _1st_metric gauge
+_1st_metric 1 20
+# TYPE my_metric gauge
+my_metric{env="dev",host="other",path="C:\\dir \"a\""} 3.5 20
+my_metric{env="prod",host="myhost",role="a,b",standalone="true"} 2 20
+# EOF
+`
+	assert.Equal(t, expected, string(e.render()))
+
+	// only the latest value is kept
+	e.updateSeries(metrics.Series{
+		{
+			Name:   "1st-metric",
+			Points: []metrics.Point{{Ts: 30, Value: 5}},
+			MType:  metrics.APIRateType,
+		},
+	})
+	assert.Contains(t, string(e.render()), "_1st_metric 5 30\n")
+}
+
+func TestOpenMetricsExporterSketches(t *testing.T) {
+	e := newTestOpenMetricsExporter(t, nil)
+
+	cfg := quantile.Default()
+	first := &quantile.Sketch{}
+	first.Insert(cfg, 1, 2, 3)
+	second := &quantile.Sketch{}
+	second.Insert(cfg, 4)
+
+	e.updateSketches(metrics.SketchSeriesList{
+		{
+			Name:   "my.distribution",
+			Tags:   []string{"env:prod"},
+			Host:   "myhost",
+			Points: []metrics.SketchPoint{{Sketch: first, Ts: 10}, {Sketch: second, Ts: 20}},
+		},
+	})
+
+	merged := &quantile.Sketch{}
+	merged.Insert(cfg, 1, 2, 3, 4)
+	output := string(e.render())
+	assert.Contains(t, output, "# TYPE my_distribution summary\n")
+	assert.Contains(t, output, `my_distribution{env="prod",host="myhost",quantile="0.5"} `)
+	assert.Contains(t, output, `my_distribution{env="prod",host="myhost",quantile="0.99"} `)
+	assert.Contains(t, output, "my_distribution_sum{env=\"prod\",host=\"myhost\"} 10 20\n")
+	assert.Contains(t, output, "my_distribution_count{env=\"prod\",host=\"myhost\"} 4 20\n")
+
+	// a series with the name of a summary is not exported
+	e.updateSeries(metrics.Series{
+		{Name: "my.distribution", Points: []metrics.Point{{Ts: 20, Value: 1}}},
+	})
+	assert.Equal(t, output, string(e.render()))
+}
+
+func TestOpenMetricsExporterFiltering(t *testing.T) {
+	e := newTestOpenMetricsExporter(t, map[string]interface{}{
+		"openmetrics_export.include_metrics": []string{"system.*", "/^app\\.(a|b)$/"},
+		"openmetrics_export.exclude_metrics": []string{"system.cpu.*"},
+		"openmetrics_export.exclude_tags":    []string{"container_id"},
+	})
+
+	var series metrics.Series
+	for _, name := range []string{"system.mem.used", "system.cpu.idle", "app.a", "app.c"} {
+		series = append(series, &metrics.Serie{
+			Name:   name,
+			Points: []metrics.Point{{Ts: 10, Value: 1}},
+			Tags:   []string{"container_id:abc", "env:prod"},
+		})
+	}
+	e.updateSeries(series)
+
+	expected := `# TYPE app_a gauge
+app_a{env="prod"} 1 10
+# TYPE system_mem_used gauge
+system_mem_used{env="prod"} 1 10
+# EOF
+`
+	assert.Equal(t, expected, string(e.render()))
+}
+
+func TestOpenMetricsExporterInvalidPattern(t *testing.T) {
+	mockConfig := config.Mock()
+	mockConfig.Set("openmetrics_export.enabled", true)
+	mockConfig.Set("openmetrics_export.include_metrics", []string{"/(/"})
+	defer mockConfig.Set("openmetrics_export.enabled", false)
+	defer mockConfig.Set("openmetrics_export.include_metrics", nil)
+
+	assert.Nil(t, newOpenMetricsExporter())
+}
+
+func TestOpenMetricsExporterStaleness(t *testing.T) {
+	e := newTestOpenMetricsExporter(t, map[string]interface{}{
+		"openmetrics_export.staleness_window": 60,
+	})
+	now := time.Now()
+	e.now = func() time.Time { return now }
+
+	e.updateSeries(metrics.Series{
+		{Name: "old", Points: []metrics.Point{{Ts: 10, Value: 1}}},
+	})
+	now = now.Add(45 * time.Second)
+	e.updateSeries(metrics.Series{
+		{Name: "recent", Points: []metrics.Point{{Ts: 55, Value: 1}}},
+	})
+	output := string(e.render())
+	assert.Contains(t, output, "old 1 10\n")
+	assert.Contains(t, output, "recent 1 55\n")
+
+	now = now.Add(30 * time.Second)
+	output = string(e.render())
+	assert.NotContains(t, output, "old")
+	assert.Contains(t, output, "recent 1 55\n")
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, "# EOF\n", string(e.render()))
+	assert.Empty(t, e.families)
+}
+
+func TestOpenMetricsExporterHandler(t *testing.T) {
+	e := newTestOpenMetricsExporter(t, nil)
+	e.updateSeries(metrics.Series{
+		{Name: "my.metric", Points: []metrics.Point{{Ts: 10, Value: 1}}},
+	})
+
+	recorder := httptest.NewRecorder()
+	e.handle(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	resp := recorder.Result()
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, openMetricsContentType, resp.Header.Get("Content-Type"))
+	assert.Equal(t, "# TYPE my_metric gauge\nmy_metric 1 10\n# EOF\n", string(body))
+}
+
+func TestOpenMetricsExporterDoesNotChangeFlushedSeries(t *testing.T) {
+	e := newTestOpenMetricsExporter(t, nil)
+	serie := &metrics.Serie{
+		Name:   "my.metric",
+		Points: []metrics.Point{{Ts: 10, Value: 1}},
+		Tags:   []string{"b:1", "a:2"},
+	}
+	e.updateSeries(metrics.Series{serie})
+
+	assert.Equal(t, "my.metric", serie.Name)
+	assert.Equal(t, []string{"b:1", "a:2"}, serie.Tags)
+}
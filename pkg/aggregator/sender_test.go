@@ -393,6 +393,7 @@ func TestCheckSenderInterface(t *testing.T) {
 	s.sender.MonotonicCountWithFlushFirstValue("my.monotonic_count_metric", 12.0, "my-hostname", []string{"foo", "bar"}, true)
 	s.sender.Counter("my.counter_metric", 1.0, "my-hostname", []string{"foo", "bar"})
 	s.sender.Histogram("my.histo_metric", 3.0, "my-hostname", []string{"foo", "bar"})
+	s.sender.Distribution("my.distribution_metric", 4.0, "my-hostname", []string{"foo", "bar"})
 	s.sender.HistogramBucket("my.histogram_bucket", 42, 1.0, 2.0, true, "my-hostname", []string{"foo", "bar"}, true)
 	s.sender.Commit()
 	s.sender.ServiceCheck("my_service.can_connect", metrics.ServiceCheckOK, "my-hostname", []string{"foo", "bar"}, "message")
@@ -447,6 +448,11 @@ func TestCheckSenderInterface(t *testing.T) {
 	assert.Equal(t, metrics.HistogramType, histoSenderSample.metricSample.Mtype)
 	assert.Equal(t, false, histoSenderSample.commit)
 
+	distributionSenderSample := <-s.senderMetricSampleChan
+	assert.EqualValues(t, checkID1, distributionSenderSample.id)
+	assert.Equal(t, metrics.DistributionType, distributionSenderSample.metricSample.Mtype)
+	assert.Equal(t, false, distributionSenderSample.commit)
+
 	commitSenderSample := <-s.senderMetricSampleChan
 	assert.EqualValues(t, checkID1, commitSenderSample.id)
 	assert.Equal(t, true, commitSenderSample.commit)
@@ -28,6 +28,7 @@ type TimeSampler struct {
 	counterLastSampledByContext map[ckey.ContextKey]float64
 	lastCutOffTime              int64
 	sketchMap                   sketchMap
+	histogramOverrides          *histogramOverrides
 }
 
 // NewTimeSampler returns a newly initialized TimeSampler
@@ -44,6 +45,21 @@ func NewTimeSampler(interval int64) *TimeSampler {
 	}
 }
 
+// setContextLimiter sets the limiter applied to the new contexts of the sampler
+func (s *TimeSampler) setContextLimiter(limiter *contextLimiter) {
+	s.contextResolver.resolver.limiter = limiter
+}
+
+// setMetricFilter sets the filter applying the tag rules to the samples of the sampler
+func (s *TimeSampler) setMetricFilter(filter *metricFilter) {
+	s.contextResolver.resolver.metricFilter = filter
+}
+
+// setHistogramOverrides sets the per-metric settings of the histograms of the sampler
+func (s *TimeSampler) setHistogramOverrides(overrides *histogramOverrides) {
+	s.histogramOverrides = overrides
+}
+
 func (s *TimeSampler) calculateBucketStart(timestamp float64) int64 {
 	return int64(timestamp) - int64(timestamp)%s.interval
 }
@@ -55,10 +71,15 @@ func (s *TimeSampler) isBucketStillOpen(bucketStartTimestamp, timestamp int64) b
 // Add the metricSample to the correct bucket
 func (s *TimeSampler) addSample(metricSample *metrics.MetricSample, timestamp float64) {
 	// Keep track of the context
-	contextKey := s.contextResolver.trackContext(metricSample, timestamp)
+	contextKey, ok := s.contextResolver.trackContext(metricSample, timestamp)
+	if !ok {
+		log.Tracef("Dropping sample '%s': over the contexts limits", metricSample.Name)
+		return
+	}
 	bucketStart := s.calculateBucketStart(timestamp)
+	mtype, histogramSettings := s.histogramOverrides.resolve(metricSample)
 
-	switch metricSample.Mtype {
+	switch mtype {
 	case metrics.DistributionType:
 		s.sketchMap.insert(bucketStart, contextKey, metricSample.Value, metricSample.SampleRate)
 	default:
@@ -74,7 +95,7 @@ func (s *TimeSampler) addSample(metricSample *metrics.MetricSample, timestamp fl
 		}
 
 		// Add sample to bucket
-		if err := bucketMetrics.AddSample(contextKey, metricSample, timestamp, s.interval, nil); err != nil {
+		if err := bucketMetrics.AddSample(contextKey, metricSample, timestamp, s.interval, nil, histogramSettings); err != nil {
 			log.Debugf("Ignoring sample '%s' on host '%s' and tags '%s': %s", metricSample.Name, metricSample.Host, metricSample.Tags, err)
 		}
 	}
@@ -221,7 +242,7 @@ func (s *TimeSampler) countersSampleZeroValue(timestamp int64, contextMetrics me
 			}
 			// Add a zero value sample to the counter
 			// It is ok to add a 0 sample to a counter that was already sampled in the bucket, it won't change its value
-			contextMetrics.AddSample(counterContext, sample, float64(timestamp), s.interval, nil) //nolint:errcheck
+			contextMetrics.AddSample(counterContext, sample, float64(timestamp), s.interval, nil, nil) //nolint:errcheck
 
 			// Update the tracked context so that the contextResolver doesn't expire counter contexts too early
 			// i.e. while we are still sending zeros for them
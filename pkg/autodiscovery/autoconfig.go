@@ -306,7 +306,7 @@ func (ac *AutoConfig) processNewConfig(config integration.Config) []integration.
 	}
 
 	// decrypt and store non-template config in AC as well
-	config, err := decryptConfig(config)
+	config, err := ac.decryptAndTrackConfig(config)
 	if err != nil {
 		log.Errorf("Dropping conf for '%s': %s", config.Name, err.Error())
 		return configs
@@ -462,10 +462,11 @@ func decryptConfig(conf integration.Config) (integration.Config, error) {
 }
 
 func (ac *AutoConfig) processRemovedConfigs(configs []integration.Config) {
-	ac.unschedule(configs)
+	removed := make([]integration.Config, 0, len(configs))
 	for _, c := range configs {
-		ac.store.removeLoadedConfig(c)
+		removed = append(removed, ac.store.removeLoadedConfig(c))
 	}
+	ac.unschedule(removed)
 }
 
 func (ac *AutoConfig) removeConfigTemplates(configs []integration.Config) {
@@ -543,7 +544,7 @@ func (ac *AutoConfig) resolveTemplateForService(tpl integration.Config, svc list
 		errorStats.setResolveWarning(tpl.Name, newErr.Error())
 		return tpl, log.Warn(newErr)
 	}
-	resolvedConfig, err := decryptConfig(config)
+	resolvedConfig, err := ac.decryptAndTrackConfig(config)
 	if err != nil {
 		newErr := fmt.Errorf("error decrypting secrets in config %s for service %s: %v", config.Name, svc.GetEntity(), err)
 		return config, log.Warn(newErr)
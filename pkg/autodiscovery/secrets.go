@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package autodiscovery
+
+import (
+	"bytes"
+
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// encryptedConfig is a loaded config along with the config with secrets it was decrypted from
+type encryptedConfig struct {
+	loaded    integration.Config
+	encrypted integration.Config
+}
+
+// decryptAndTrackConfig decrypts the secrets of a config and keeps the config with secrets
+// so that the config can be decrypted again when its secrets are rotated
+func (ac *AutoConfig) decryptAndTrackConfig(conf integration.Config) (integration.Config, error) {
+	encrypted := copyConfigData(conf)
+	decrypted, err := decryptConfig(conf)
+	if err != nil {
+		return decrypted, err
+	}
+	if decrypted.Digest() != encrypted.Digest() {
+		ac.store.setEncryptedConfig(decrypted, encrypted)
+	}
+	return decrypted, nil
+}
+
+// ProcessRotatedSecrets decrypts again the loaded configs using the rotated secret handles
+// and reschedules them
+func (ac *AutoConfig) ProcessRotatedSecrets(handles []string) {
+	for digest, c := range ac.store.getEncryptedConfigs() {
+		if !configUsesSecrets(c.encrypted, handles) {
+			continue
+		}
+
+		newConf, err := decryptConfig(copyConfigData(c.encrypted))
+		if err != nil {
+			log.Errorf("Unable to decrypt the rotated secrets of the configuration %s, keeping the previous configuration: %s", c.loaded.Name, err)
+			continue
+		}
+		if newConf.Digest() == digest {
+			continue
+		}
+
+		ac.unschedule([]integration.Config{c.loaded})
+		ac.store.replaceConfig(digest, newConf)
+		ac.schedule([]integration.Config{newConf})
+		log.Infof("Configuration %s rescheduled after the rotation of its secrets", newConf.Name)
+	}
+}
+
+// copyConfigData returns a copy of the config whose data can be replaced without
+// changing the data of the original config
+func copyConfigData(conf integration.Config) integration.Config {
+	conf.Instances = append([]integration.Data(nil), conf.Instances...)
+	return conf
+}
+
+func configUsesSecrets(conf integration.Config, handles []string) bool {
+	data := append([]integration.Data{conf.InitConfig, conf.MetricConfig, conf.LogsConfig}, conf.Instances...)
+	for _, d := range data {
+		for _, handle := range handles {
+			if bytes.Contains(d, []byte(handle)) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package autodiscovery
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
+	"github.com/DataDog/datadog-agent/pkg/autodiscovery/scheduler"
+)
+
+type recordingScheduler struct {
+	scheduled   []integration.Config
+	unscheduled []integration.Config
+}
+
+func (s *recordingScheduler) Schedule(configs []integration.Config) {
+	s.scheduled = append(s.scheduled, configs...)
+}
+
+func (s *recordingScheduler) Unschedule(configs []integration.Config) {
+	s.unscheduled = append(s.unscheduled, configs...)
+}
+
+func (s *recordingScheduler) Stop() {}
+
+func TestProcessRotatedSecrets(t *testing.T) {
+	secretValues := map[string]string{"db_password": "password1", "api_token": "token1"}
+	originalSecretsDecrypt := secretsDecrypt
+	secretsDecrypt = func(data []byte, origin string) ([]byte, error) {
+		decrypted := string(data)
+		for handle, value := range secretValues {
+			decrypted = strings.ReplaceAll(decrypted, "ENC["+handle+"]", value)
+		}
+		return []byte(decrypted), nil
+	}
+	defer func() { secretsDecrypt = originalSecretsDecrypt }()
+
+	ac := NewAutoConfig(scheduler.NewMetaScheduler())
+	s := &recordingScheduler{}
+	ac.AddScheduler("test", s, false)
+
+	// configs collected by a provider
+	collected := []integration.Config{
+		{
+			Name:      "postgres",
+			Instances: []integration.Data{integration.Data("password: ENC[db_password]")},
+		},
+		{
+			Name:      "http_check",
+			Instances: []integration.Data{integration.Data("token: ENC[api_token]")},
+		},
+		{
+			Name:      "cpu",
+			Instances: []integration.Data{integration.Data("{}")},
+		},
+	}
+	for _, c := range collected {
+		ac.schedule(ac.processNewConfig(c))
+	}
+	require.Len(t, s.scheduled, 3)
+	assert.Equal(t, integration.Data("password: password1"), s.scheduled[0].Instances[0])
+	postgresDigest := s.scheduled[0].Digest()
+	s.scheduled = nil
+
+	secretValues["db_password"] = "password2"
+	ac.ProcessRotatedSecrets([]string{"db_password"})
+
+	require.Len(t, s.unscheduled, 1)
+	assert.Equal(t, "postgres", s.unscheduled[0].Name)
+	require.Len(t, s.scheduled, 1)
+	assert.Equal(t, integration.Data("password: password2"), s.scheduled[0].Instances[0])
+
+	loaded := ac.LoadedConfigs()
+	assert.Len(t, loaded, 3)
+	var digests []string
+	for _, c := range loaded {
+		digests = append(digests, c.Digest())
+	}
+	assert.Contains(t, digests, s.scheduled[0].Digest())
+	assert.NotContains(t, digests, postgresDigest)
+
+	// the config of the provider is left untouched, and still removes the rotated config
+	assert.Equal(t, postgresDigest, collected[0].Digest())
+	s.scheduled = nil
+	secretValues["db_password"] = "password3"
+	ac.ProcessRotatedSecrets([]string{"db_password"})
+	require.Len(t, s.scheduled, 1)
+	rotated := s.scheduled[0]
+	s.unscheduled = s.unscheduled[:1]
+	ac.processRemovedConfigs([]integration.Config{collected[0]})
+	assert.Len(t, ac.LoadedConfigs(), 2)
+	require.Len(t, s.unscheduled, 2)
+	assert.Equal(t, rotated.Digest(), s.unscheduled[1].Digest())
+
+	// the configs are not rescheduled when the value of the secrets didn't change
+	s.scheduled = nil
+	ac.ProcessRotatedSecrets([]string{"api_token"})
+	assert.Empty(t, s.scheduled)
+}
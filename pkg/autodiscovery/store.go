@@ -18,6 +18,8 @@ type store struct {
 	serviceToTagsHash map[string]string
 	templateToConfigs map[string][]integration.Config
 	loadedConfigs     map[string]integration.Config
+	encryptedConfigs  map[string]integration.Config
+	rotatedDigests    map[string]string
 	nameToJMXMetrics  map[string]integration.Data
 	adIDToServices    map[string]map[string]bool
 	entityToService   map[string]listeners.Service
@@ -32,6 +34,8 @@ func newStore() *store {
 		serviceToTagsHash: make(map[string]string),
 		templateToConfigs: make(map[string][]integration.Config),
 		loadedConfigs:     make(map[string]integration.Config),
+		encryptedConfigs:  make(map[string]integration.Config),
+		rotatedDigests:    make(map[string]string),
 		nameToJMXMetrics:  make(map[string]integration.Data),
 		adIDToServices:    make(map[string]map[string]bool),
 		entityToService:   make(map[string]listeners.Service),
@@ -108,11 +112,83 @@ func (s *store) setLoadedConfig(config integration.Config) {
 	s.loadedConfigs[config.Digest()] = config
 }
 
-// removeLoadedConfig removes a loaded config by its digest
-func (s *store) removeLoadedConfig(config integration.Config) {
+// removeLoadedConfig removes a loaded config by its digest, or by the digest it had before
+// its secrets were rotated, and returns the removed config
+func (s *store) removeLoadedConfig(config integration.Config) integration.Config {
 	s.m.Lock()
 	defer s.m.Unlock()
-	delete(s.loadedConfigs, config.Digest())
+	digest := config.Digest()
+	if rotated, found := s.rotatedDigests[digest]; found {
+		delete(s.rotatedDigests, digest)
+		digest = rotated
+	}
+	if loaded, found := s.loadedConfigs[digest]; found {
+		config = loaded
+	}
+	delete(s.loadedConfigs, digest)
+	delete(s.encryptedConfigs, digest)
+	return config
+}
+
+// setEncryptedConfig stores the config with secrets a loaded config was decrypted from, by
+// the digest of the loaded config
+func (s *store) setEncryptedConfig(config integration.Config, encrypted integration.Config) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	s.encryptedConfigs[config.Digest()] = encrypted
+}
+
+// getEncryptedConfigs returns the loaded configs with secrets along with the configs they
+// were decrypted from, by digest of the loaded config
+func (s *store) getEncryptedConfigs() map[string]encryptedConfig {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	configs := make(map[string]encryptedConfig, len(s.encryptedConfigs))
+	for digest, encrypted := range s.encryptedConfigs {
+		if loaded, found := s.loadedConfigs[digest]; found {
+			configs[digest] = encryptedConfig{loaded: loaded, encrypted: encrypted}
+		}
+	}
+	return configs
+}
+
+// replaceConfig replaces a loaded config, and the references of the services and templates
+// to this config, by a new config. The digest of the config before its first replacement is
+// kept so that the config can still be removed by its provider.
+func (s *store) replaceConfig(oldDigest string, new integration.Config) {
+	s.m.Lock()
+	defer s.m.Unlock()
+	newDigest := new.Digest()
+	replaced := false
+	for original, rotated := range s.rotatedDigests {
+		if rotated == oldDigest {
+			s.rotatedDigests[original] = newDigest
+			replaced = true
+		}
+	}
+	if !replaced {
+		s.rotatedDigests[oldDigest] = newDigest
+	}
+	if encrypted, found := s.encryptedConfigs[oldDigest]; found {
+		delete(s.encryptedConfigs, oldDigest)
+		s.encryptedConfigs[newDigest] = encrypted
+	}
+	delete(s.loadedConfigs, oldDigest)
+	s.loadedConfigs[newDigest] = new
+	for _, configs := range s.serviceToConfigs {
+		replaceConfigInSlice(configs, oldDigest, new)
+	}
+	for _, configs := range s.templateToConfigs {
+		replaceConfigInSlice(configs, oldDigest, new)
+	}
+}
+
+func replaceConfigInSlice(configs []integration.Config, digest string, new integration.Config) {
+	for i := range configs {
+		if configs[i].Digest() == digest {
+			configs[i] = new
+		}
+	}
 }
 
 // mapOverLoadedConfigs calls the given function with the map of all
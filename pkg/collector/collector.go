@@ -15,6 +15,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/collector/runner"
 	"github.com/DataDog/datadog-agent/pkg/collector/runner/expvars"
 	"github.com/DataDog/datadog-agent/pkg/collector/scheduler"
+	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
@@ -44,6 +45,7 @@ func NewCollector(paths ...string) *Collector {
 
 	// let the runner some visibility into the scheduler
 	run.SetScheduler(sched)
+	sched.SetMinCollectionInterval(time.Duration(config.Datadog.GetInt("checks_min_collection_interval")) * time.Second)
 	sched.Run()
 
 	c := &Collector{
@@ -163,6 +165,32 @@ func (c *Collector) StopCheck(id check.ID) error {
 	return nil
 }
 
+// SetMinCollectionInterval changes the minimum interval between two runs of the checks and
+// reschedules the running checks accordingly, 0 runs the checks at their own interval
+func (c *Collector) SetMinCollectionInterval(interval time.Duration) error {
+	if !c.started() {
+		return fmt.Errorf("the collector is not running")
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+
+	c.scheduler.SetMinCollectionInterval(interval)
+	for id, ch := range c.checks {
+		// the long running checks are not scheduled
+		if ch.Interval() == 0 {
+			continue
+		}
+		if err := c.scheduler.Cancel(id); err != nil {
+			return fmt.Errorf("unable to unschedule the check %s: %s", id, err)
+		}
+		if err := c.scheduler.Enter(ch); err != nil {
+			return fmt.Errorf("unable to reschedule the check %s: %s", id, err)
+		}
+	}
+	return nil
+}
+
 // cancelCheck calls Cancel on the passed check, with a timeout
 func (c *Collector) cancelCheck(ch check.Check, timeout time.Duration) error {
 	done := make(chan struct{})
@@ -13,6 +13,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/collector/check"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -115,6 +116,17 @@ func (suite *CollectorTestSuite) TestRunCheck() {
 	assert.Equal(suite.T(), "a check with ID TestCheck is already running", err.Error())
 }
 
+func (suite *CollectorTestSuite) TestSetMinCollectionInterval() {
+	ch := NewCheck()
+	_, err := suite.c.RunCheck(ch)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), suite.c.scheduler.IsCheckScheduled(ch.ID()))
+
+	require.NoError(suite.T(), suite.c.SetMinCollectionInterval(5*time.Minute))
+	assert.True(suite.T(), suite.c.scheduler.IsCheckScheduled(ch.ID()))
+	assert.Equal(suite.T(), 1, len(suite.c.checks))
+}
+
 func (suite *CollectorTestSuite) TestStopCheck() {
 	ch := NewCheck()
 
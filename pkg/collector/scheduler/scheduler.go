@@ -48,6 +48,8 @@ type Scheduler struct {
 	tlmTrackedChecks map[check.ID]string         // Keep track of the checks that are tracked with telemetry
 	mu               sync.Mutex                  // To protect critical sections in struct's fields
 
+	minCollectionInterval time.Duration // The checks don't run more often than this interval, when set
+
 	checkToQueue map[check.ID]*jobQueue // Keep track of what is the queue for any Check
 	// To protect checkToQueue. Using mu would create a deadlock when stopping the Scheduler. 'jobQueue' is calling
 	// 'IsCheckScheduled' right when then 'Stop' function is called and mu is already lock. for this reason we have
@@ -77,6 +79,7 @@ func NewScheduler(checksPipe chan<- check.Check) *Scheduler {
 
 // Enter schedules a `Check`s for execution accordingly to the `Check.Interval()` value.
 // If the interval is 0, the check is supposed to run only once.
+// The checks run at least every minimum collection interval when it is set.
 func (s *Scheduler) Enter(check check.Check) error {
 	// enqueue immediately if this is a one-time schedule
 	if check.Interval() == 0 {
@@ -88,25 +91,29 @@ func (s *Scheduler) Enter(check check.Check) error {
 		return fmt.Errorf("Schedule interval must be greater than %v or 0", minAllowedInterval)
 	}
 
-	log.Infof("Scheduling check %v with an interval of %v", check, check.Interval())
-
 	// sync when accessing `jobQueues` and `check2queue`
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, ok := s.jobQueues[check.Interval()]; !ok {
-		s.jobQueues[check.Interval()] = newJobQueue(check.Interval())
-		s.startQueue(s.jobQueues[check.Interval()])
+	interval := check.Interval()
+	if interval < s.minCollectionInterval {
+		interval = s.minCollectionInterval
+	}
+	log.Infof("Scheduling check %v with an interval of %v", check, interval)
+
+	if _, ok := s.jobQueues[interval]; !ok {
+		s.jobQueues[interval] = newJobQueue(interval)
+		s.startQueue(s.jobQueues[interval])
 		if check.IsTelemetryEnabled() {
 			tlmQueuesCount.Inc()
 		}
 		schedulerQueuesCount.Add(1)
 	}
-	s.jobQueues[check.Interval()].addJob(check)
+	s.jobQueues[interval].addJob(check)
 
 	// map each check to the Job Queue it was assigned to
 	s.checkToQueueMutex.Lock()
-	s.checkToQueue[check.ID()] = s.jobQueues[check.Interval()]
+	s.checkToQueue[check.ID()] = s.jobQueues[interval]
 	s.checkToQueueMutex.Unlock()
 
 	schedulerChecksEntered.Add(1)
@@ -119,6 +126,14 @@ func (s *Scheduler) Enter(check check.Check) error {
 	return nil
 }
 
+// SetMinCollectionInterval sets the minimum interval between two runs of the checks entered
+// afterwards, 0 to run them at their own interval
+func (s *Scheduler) SetMinCollectionInterval(interval time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.minCollectionInterval = interval
+}
+
 // Cancel remove a Check from the scheduled queue. If the check is not
 // in the scheduler, this is a noop.
 func (s *Scheduler) Cancel(id check.ID) error {
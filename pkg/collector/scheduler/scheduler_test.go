@@ -117,6 +117,21 @@ func TestCancel(t *testing.T) {
 	assert.Len(t, s.jobQueues[chk.intl].buckets[0].jobs, 0)
 }
 
+func TestMinCollectionInterval(t *testing.T) {
+	s := getScheduler()
+	defer s.Stop()
+
+	s.SetMinCollectionInterval(30 * time.Second)
+	fast := &TestCheck{intl: 10 * time.Second}
+	assert.Nil(t, s.Enter(fast))
+	assert.Contains(t, s.jobQueues, 30*time.Second)
+	assert.NotContains(t, s.jobQueues, 10*time.Second)
+
+	slow := &TestCheck{intl: 60 * time.Second}
+	assert.Nil(t, s.Enter(slow))
+	assert.Contains(t, s.jobQueues, 60*time.Second)
+}
+
 func TestRun(t *testing.T) {
 	s := getScheduler()
 	defer s.Stop()
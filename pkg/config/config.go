@@ -16,6 +16,7 @@ import (
 	"path/filepath"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	yaml "gopkg.in/yaml.v2"
@@ -150,10 +151,59 @@ type MappingProfile struct {
 
 // MetricMapping represent one mapping rule
 type MetricMapping struct {
-	Match     string            `mapstructure:"match" json:"match"`
-	MatchType string            `mapstructure:"match_type" json:"match_type"`
-	Name      string            `mapstructure:"name" json:"name"`
-	Tags      map[string]string `mapstructure:"tags" json:"tags"`
+	Match      string            `mapstructure:"match" json:"match"`
+	MatchType  string            `mapstructure:"match_type" json:"match_type"`
+	Name       string            `mapstructure:"name" json:"name"`
+	Tags       map[string]string `mapstructure:"tags" json:"tags"`
+	MatchTags  map[string]string `mapstructure:"match_tags" json:"match_tags"`
+	DropTags   []string          `mapstructure:"drop_tags" json:"drop_tags"`
+	RenameTags map[string]string `mapstructure:"rename_tags" json:"rename_tags"`
+	Drop       bool              `mapstructure:"drop" json:"drop"`
+}
+
+// MetricFilters represent the rules used by the aggregator to filter and rewrite metrics
+type MetricFilters struct {
+	Include  []string        `mapstructure:"include" json:"include"`
+	Exclude  []string        `mapstructure:"exclude" json:"exclude"`
+	TagRules []MetricTagRule `mapstructure:"tag_rules" json:"tag_rules"`
+}
+
+// MetricTagRule represent the tag removals and rewrites applied to some metrics
+type MetricTagRule struct {
+	Metrics     []string           `mapstructure:"metrics" json:"metrics"`
+	RemoveTags  []string           `mapstructure:"remove_tags" json:"remove_tags"`
+	RewriteTags []MetricTagRewrite `mapstructure:"rewrite_tags" json:"rewrite_tags"`
+}
+
+// MetricTagRewrite represent the rewrite of the values of a tag key
+type MetricTagRewrite struct {
+	Key     string `mapstructure:"key" json:"key"`
+	Match   string `mapstructure:"match" json:"match"`
+	Replace string `mapstructure:"replace" json:"replace"`
+}
+
+// HistogramOverride represent the settings of the histograms of some metrics, overriding
+// the global histogram_aggregates and histogram_percentiles settings
+type HistogramOverride struct {
+	Metrics      []string `mapstructure:"metrics" json:"metrics"`
+	Aggregates   []string `mapstructure:"aggregates" json:"aggregates"`
+	Percentiles  []string `mapstructure:"percentiles" json:"percentiles"`
+	Distribution bool     `mapstructure:"distribution" json:"distribution"`
+}
+
+// ForwarderSink represents a destination receiving a copy of the payloads submitted to the
+// forwarder, in addition to the Datadog domains
+type ForwarderSink struct {
+	// Type is either "file" or "http"
+	Type string `mapstructure:"type" json:"type"`
+	// Endpoints holds the names of the forwarder endpoints to copy, all of them when empty
+	Endpoints []string `mapstructure:"endpoints" json:"endpoints"`
+	// Path, MaxSize and MaxFiles are the settings of the "file" sinks
+	Path     string `mapstructure:"path" json:"path"`
+	MaxSize  int64  `mapstructure:"max_size" json:"max_size"`
+	MaxFiles int    `mapstructure:"max_files" json:"max_files"`
+	// URL is the base URL the "http" sinks post the payloads to
+	URL string `mapstructure:"url" json:"url"`
 }
 
 // Warnings represent the warnings in the config
@@ -220,6 +270,7 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("enable_metadata_collection", true)
 	config.BindEnvAndSetDefault("enable_gohai", true)
 	config.BindEnvAndSetDefault("check_runners", int64(4))
+	config.BindEnvAndSetDefault("checks_min_collection_interval", 0)
 	config.BindEnvAndSetDefault("auth_token_file_path", "")
 	config.BindEnv("bind_host")
 	config.BindEnvAndSetDefault("ipc_address", "localhost")
@@ -304,6 +355,9 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("secret_backend_timeout", 30)
 	config.BindEnvAndSetDefault("secret_backend_command_allow_group_exec_perm", false)
 	config.BindEnvAndSetDefault("secret_backend_skip_checks", false)
+	config.BindEnvAndSetDefault("secret_backend_builtin_resolvers", []string{})
+	config.BindEnvAndSetDefault("secret_backend_builtin_resolvers_for_checks", false)
+	config.BindEnvAndSetDefault("secret_backend_refresh_interval", 0)
 
 	// Use to output logs in JSON format
 	config.BindEnvAndSetDefault("log_format_json", false)
@@ -363,8 +417,39 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("proc_root", "/proc")
 	config.BindEnvAndSetDefault("histogram_aggregates", []string{"max", "median", "avg", "count"})
 	config.BindEnvAndSetDefault("histogram_percentiles", []string{"0.95"})
+	// Per-metric overrides of the histogram settings, see HistogramOverride
+	config.BindEnv("histogram_overrides")
+	config.SetEnvKeyTransformer("histogram_overrides", func(in string) interface{} {
+		var overrides []HistogramOverride
+		if err := json.Unmarshal([]byte(in), &overrides); err != nil {
+			log.Errorf(`"histogram_overrides" can not be parsed: %v`, err)
+		}
+		return overrides
+	})
 	config.BindEnvAndSetDefault("aggregator_stop_timeout", 2)
 	config.BindEnvAndSetDefault("aggregator_buffer_size", 100)
+	// Limits on the number of contexts tracked by the aggregator. 0 means no limit.
+	config.BindEnvAndSetDefault("aggregator_context_limiter.max_contexts_per_metric", 0)
+	config.BindEnvAndSetDefault("aggregator_context_limiter.max_contexts_per_origin", 0) // DogStatsD only
+	config.BindEnvAndSetDefault("aggregator_context_limiter.max_contexts_per_check", 0)
+	config.BindEnvAndSetDefault("aggregator_context_limiter.overflow_action", "drop") // "drop" or "strip_tags"
+	// Filtering and rewriting of the metrics from checks and DogStatsD, see MetricFilters
+	config.BindEnv("metric_filters")
+	config.SetEnvKeyTransformer("metric_filters", func(in string) interface{} {
+		var filters MetricFilters
+		if err := json.Unmarshal([]byte(in), &filters); err != nil {
+			log.Errorf(`"metric_filters" can not be parsed: %v`, err)
+		}
+		return filters
+	})
+	// Local export of the flushed series and sketches in the OpenMetrics format
+	config.BindEnvAndSetDefault("openmetrics_export.enabled", false)
+	config.BindEnvAndSetDefault("openmetrics_export.bind_host", "localhost")
+	config.BindEnvAndSetDefault("openmetrics_export.port", 5010)
+	config.BindEnvAndSetDefault("openmetrics_export.staleness_window", 300) // in seconds, 0 means the contexts are never removed
+	config.BindEnvAndSetDefault("openmetrics_export.include_metrics", []string{})
+	config.BindEnvAndSetDefault("openmetrics_export.exclude_metrics", []string{})
+	config.BindEnvAndSetDefault("openmetrics_export.exclude_tags", []string{})
 	config.BindEnvAndSetDefault("basic_telemetry_add_container_tags", false) // configure adding the agent container tags to the basic agent telemetry metrics (e.g. `datadog.agent.running`)
 	// Serializer
 	config.BindEnvAndSetDefault("enable_stream_payload_serialization", true)
@@ -376,6 +461,10 @@ func InitConfig(config Config) {
 	// Warning: do not change the two following values. Your payloads will get dropped by Datadog's intake.
 	config.BindEnvAndSetDefault("serializer_max_payload_size", 2*megaByte+megaByte/2)
 	config.BindEnvAndSetDefault("serializer_max_uncompressed_payload_size", 4*megaByte)
+	// Compression of the payloads, the compression selected at build time is used when the kind is empty
+	config.BindEnvAndSetDefault("serializer_compressor_kind", "")
+	config.BindEnvAndSetDefault("serializer_compressor_level", 0)
+	config.BindEnvAndSetDefault("serializer_compressor_kind_by_endpoint", map[string]string{})
 
 	config.BindEnvAndSetDefault("use_v2_api.events", false)
 	config.BindEnvAndSetDefault("use_v2_api.series", false)
@@ -396,12 +485,19 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("forwarder_apikey_validation_interval", DefaultAPIKeyValidationInterval) // in minutes
 	config.BindEnvAndSetDefault("forwarder_num_workers", 1)
 	config.BindEnvAndSetDefault("forwarder_stop_timeout", 2)
+	config.BindEnvAndSetDefault("forwarder_send_idempotency_key", false)
+	config.BindEnvAndSetDefault("forwarder_sent_transactions_cache_size", 10000) // 0 means disabled
 	// Forwarder retry settings
 	config.BindEnvAndSetDefault("forwarder_backoff_factor", 2)
 	config.BindEnvAndSetDefault("forwarder_backoff_base", 2)
 	config.BindEnvAndSetDefault("forwarder_backoff_max", 64)
 	config.BindEnvAndSetDefault("forwarder_recovery_interval", DefaultForwarderRecoveryInterval)
 	config.BindEnvAndSetDefault("forwarder_recovery_reset", false)
+	// Forwarder adaptive concurrency
+	config.BindEnvAndSetDefault("forwarder_adaptive_concurrency.enabled", false)
+	config.BindEnvAndSetDefault("forwarder_adaptive_concurrency.min_workers", 1)
+	config.BindEnvAndSetDefault("forwarder_adaptive_concurrency.max_workers", 10)
+	config.BindEnvAndSetDefault("forwarder_adaptive_concurrency.latency_threshold", 2) // in seconds
 
 	// Forwarder storage on disk
 	config.BindEnvAndSetDefault("forwarder_storage_path", "")
@@ -409,6 +505,18 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("forwarder_flush_to_disk_mem_ratio", 0.5)
 	config.BindEnvAndSetDefault("forwarder_storage_max_size_in_bytes", 0) // 0 means disabled. This is a BETA feature.
 	config.BindEnvAndSetDefault("forwarder_storage_max_disk_ratio", 0.80) // Do not store transactions on disk when the disk usage exceeds 80% of the disk capacity. Use 80% as some applications do not behave well when the disk space is very small.
+	config.BindEnvAndSetDefault("forwarder_storage_encryption_enabled", false)
+	config.BindEnvAndSetDefault("forwarder_storage_encryption_key_file", "") // Defaults to the auth token of the Agent.
+
+	// Forwarder sinks, see ForwarderSink
+	config.BindEnv("forwarder_sinks")
+	config.SetEnvKeyTransformer("forwarder_sinks", func(in string) interface{} {
+		var sinks []ForwarderSink
+		if err := json.Unmarshal([]byte(in), &sinks); err != nil {
+			log.Errorf(`"forwarder_sinks" can not be parsed: %v`, err)
+		}
+		return sinks
+	})
 
 	// Forwarder channels buffer size
 	config.BindEnvAndSetDefault("forwarder_high_prio_buffer_size", 100)
@@ -420,7 +528,7 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("dogstatsd_port", 8125)    // Notice: 0 means UDP port closed
 	config.BindEnvAndSetDefault("dogstatsd_pipe_name", "") // experimental and not officially supported for now.
 	// Experimental and not officially supported for now.
-	// Options are: udp, uds, named_pipe
+	// Options are: udp, uds, named_pipe, tcp
 	config.BindEnvAndSetDefault("dogstatsd_eol_required", []string{})
 
 	// The following options allow to configure how the dogstatsd intake buffers and queues incoming datagrams.
@@ -434,7 +542,14 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("dogstatsd_queue_size", 1024)
 
 	config.BindEnvAndSetDefault("dogstatsd_non_local_traffic", false)
-	config.BindEnvAndSetDefault("dogstatsd_socket", "") // Notice: empty means feature disabled
+	config.BindEnvAndSetDefault("dogstatsd_socket", "")        // Notice: empty means feature disabled
+	config.BindEnvAndSetDefault("dogstatsd_stream_socket", "") // Notice: empty means feature disabled
+	config.BindEnvAndSetDefault("dogstatsd_tcp_port", 0)       // Notice: 0 means TCP listener disabled
+	config.BindEnvAndSetDefault("dogstatsd_tcp_framing", "newline")
+	config.BindEnvAndSetDefault("dogstatsd_tcp_tls_cert_file", "")
+	config.BindEnvAndSetDefault("dogstatsd_tcp_tls_key_file", "")
+	config.BindEnvAndSetDefault("dogstatsd_tcp_tls_client_ca_file", "")
+	config.BindEnvAndSetDefault("dogstatsd_tcp_client_tag", false)
 	config.BindEnvAndSetDefault("dogstatsd_stats_port", 5000)
 	config.BindEnvAndSetDefault("dogstatsd_stats_enable", false)
 	config.BindEnvAndSetDefault("dogstatsd_stats_buffer", 10)
@@ -448,6 +563,12 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("dogstatsd_origin_detection", false) // Only supported for socket traffic
 	config.BindEnvAndSetDefault("dogstatsd_so_rcvbuf", 0)
 	config.BindEnvAndSetDefault("dogstatsd_metrics_stats_enable", false)
+	// Per-origin quotas, the traffic over quota is dropped. 0 means no limit.
+	config.BindEnvAndSetDefault("dogstatsd_origin_quotas.packets_per_second", 0)
+	config.BindEnvAndSetDefault("dogstatsd_origin_quotas.contexts_per_second", 0)
+	config.BindEnvAndSetDefault("dogstatsd_profiler.enabled", false)
+	config.BindEnvAndSetDefault("dogstatsd_profiler.window", 60) // in seconds
+	config.BindEnvAndSetDefault("dogstatsd_profiler.max_contexts", 100000)
 	config.BindEnvAndSetDefault("dogstatsd_tags", []string{})
 	config.BindEnvAndSetDefault("dogstatsd_mapper_cache_size", 1000)
 	config.BindEnvAndSetDefault("dogstatsd_string_interner_size", 4096)
@@ -460,6 +581,11 @@ func InitConfig(config Config) {
 	// Depth of the channel the capture writer reads before persisting to disk.
 	// Default is 0 - blocking channel
 	config.BindEnvAndSetDefault("dogstatsd_capture_depth", 0)
+	// Enable the no-aggregation pipeline: samples sent with a timestamp are not aggregated
+	// and are directly sent to the serializer as series with their original timestamp.
+	config.BindEnvAndSetDefault("dogstatsd_no_aggregation_pipeline", true)
+	// How many series the no-aggregation pipeline buffers before sending them to the serializer.
+	config.BindEnvAndSetDefault("dogstatsd_no_aggregation_pipeline_batch_size", 2048)
 
 	config.BindEnv("dogstatsd_mapper_profiles")
 	config.SetEnvKeyTransformer("dogstatsd_mapper_profiles", func(in string) interface{} {
@@ -736,6 +862,12 @@ func InitConfig(config Config) {
 	config.BindEnvAndSetDefault("logs_config.run_path", defaultRunPath)
 	config.BindEnvAndSetDefault("logs_config.use_http", false)
 	config.BindEnvAndSetDefault("logs_config.use_tcp", false)
+	// The payloads which cannot be sent over HTTP are stored on the disk up to this size, 0 means disabled.
+	config.BindEnvAndSetDefault("logs_config.spool_max_size_in_bytes", 0)
+	config.BindEnvAndSetDefault("logs_config.spool_max_disk_ratio", 0.80)
+	config.BindEnvAndSetDefault("logs_config.spool_path", "") // defaults to the `spool` folder of logs_config.run_path
+	// Selects the logs sent to the main endpoint, the additional endpoints have their own `route`.
+	config.BindEnv("logs_config.route")
 
 	bindEnvAndSetLogsConfigKeys(config, "logs_config.")
 	bindEnvAndSetLogsConfigKeys(config, "database_monitoring.samples.")
@@ -1187,9 +1319,12 @@ func ResolveSecrets(config Config, origin string) error {
 		config.GetInt("secret_backend_timeout"),
 		config.GetInt("secret_backend_output_max_size"),
 		config.GetBool("secret_backend_command_allow_group_exec_perm"),
+		config.GetStringSlice("secret_backend_builtin_resolvers"),
+		config.GetBool("secret_backend_builtin_resolvers_for_checks"),
 	)
 
-	if config.GetString("secret_backend_command") != "" {
+	command := config.GetString("secret_backend_command")
+	if command != "" || len(config.GetStringSlice("secret_backend_builtin_resolvers")) != 0 {
 		// Viper doesn't expose the final location of the file it
 		// loads. Since we are searching for 'datadog.yaml' in multiple
 		// locations we let viper determine the one to use before
@@ -1198,8 +1333,13 @@ func ResolveSecrets(config Config, origin string) error {
 		if err != nil {
 			return fmt.Errorf("unable to marshal configuration to YAML to decrypt secrets: %v", err)
 		}
+		// without secret_backend_command, the configuration is only updated
+		// when it contains secrets for the built-in resolvers
+		if command == "" && !bytes.Contains(yamlConf, []byte("ENC[")) {
+			return nil
+		}
 
-		finalYamlConf, err := secrets.Decrypt(yamlConf, origin)
+		finalYamlConf, err := secrets.DecryptAgentConfig(yamlConf, origin)
 		if err != nil {
 			return fmt.Errorf("unable to decrypt secret from datadog.yaml: %v", err)
 		}
@@ -1207,10 +1347,67 @@ func ResolveSecrets(config Config, origin string) error {
 		if err = config.MergeConfigOverride(r); err != nil {
 			return fmt.Errorf("could not update main configuration after decrypting secrets: %v", err)
 		}
+		if bytes.Contains(yamlConf, []byte("ENC[")) {
+			trackEncryptedConfig(config, origin, yamlConf)
+		}
 	}
 	return nil
 }
 
+type encryptedConfig struct {
+	config Config
+	yaml   []byte
+}
+
+var (
+	// encryptedConfigs holds the configurations with secrets, by origin, to decrypt them
+	// again when their secrets are rotated
+	encryptedConfigs           = map[string]encryptedConfig{}
+	encryptedConfigsLock       sync.Mutex
+	registerSecretsRefreshOnce sync.Once
+)
+
+func trackEncryptedConfig(config Config, origin string, yamlConf []byte) {
+	encryptedConfigsLock.Lock()
+	encryptedConfigs[origin] = encryptedConfig{config: config, yaml: yamlConf}
+	encryptedConfigsLock.Unlock()
+
+	registerSecretsRefreshOnce.Do(func() {
+		secrets.RegisterRefreshHandler(refreshEncryptedConfigs)
+	})
+}
+
+// refreshEncryptedConfigs updates the configurations using the rotated secrets
+func refreshEncryptedConfigs(handles []string) {
+	encryptedConfigsLock.Lock()
+	defer encryptedConfigsLock.Unlock()
+
+	for origin, encrypted := range encryptedConfigs {
+		if !usesSecretHandles(encrypted.yaml, handles) {
+			continue
+		}
+		finalYamlConf, err := secrets.DecryptAgentConfig(encrypted.yaml, origin)
+		if err != nil {
+			log.Errorf("Unable to decrypt the rotated secrets of %s: %v", origin, err)
+			continue
+		}
+		if err = encrypted.config.MergeConfigOverride(bytes.NewReader(finalYamlConf)); err != nil {
+			log.Errorf("Could not update the configuration %s after the rotation of its secrets: %v", origin, err)
+			continue
+		}
+		log.Infof("Configuration %s updated after the rotation of its secrets", origin)
+	}
+}
+
+func usesSecretHandles(yamlConf []byte, handles []string) bool {
+	for _, handle := range handles {
+		if bytes.Contains(yamlConf, []byte(handle)) {
+			return true
+		}
+	}
+	return false
+}
+
 // SanitizeAPIKeyConfig strips newlines and other control characters from a given key.
 func SanitizeAPIKeyConfig(config Config, key string) {
 	config.Set(key, SanitizeAPIKey(config.GetString(key)))
@@ -1484,6 +1681,54 @@ func getDogstatsdMappingProfilesConfig(config Config) ([]MappingProfile, error)
 	return mappings, nil
 }
 
+// GetMetricFilters returns the rules used by the aggregator to filter and rewrite metrics
+func GetMetricFilters() (MetricFilters, error) {
+	return getMetricFiltersConfig(Datadog)
+}
+
+func getMetricFiltersConfig(config Config) (MetricFilters, error) {
+	var filters MetricFilters
+	if config.IsSet("metric_filters") {
+		err := config.UnmarshalKey("metric_filters", &filters)
+		if err != nil {
+			return MetricFilters{}, log.Errorf("Could not parse metric_filters: %v", err)
+		}
+	}
+	return filters, nil
+}
+
+// GetHistogramOverrides returns the per-metric overrides of the histogram settings
+func GetHistogramOverrides() ([]HistogramOverride, error) {
+	return getHistogramOverridesConfig(Datadog)
+}
+
+// GetForwarderSinks returns the destinations receiving a copy of the payloads of the forwarder
+func GetForwarderSinks() ([]ForwarderSink, error) {
+	return getForwarderSinksConfig(Datadog)
+}
+
+func getForwarderSinksConfig(config Config) ([]ForwarderSink, error) {
+	var sinks []ForwarderSink
+	if config.IsSet("forwarder_sinks") {
+		err := config.UnmarshalKey("forwarder_sinks", &sinks)
+		if err != nil {
+			return nil, log.Errorf("Could not parse forwarder_sinks: %v", err)
+		}
+	}
+	return sinks, nil
+}
+
+func getHistogramOverridesConfig(config Config) ([]HistogramOverride, error) {
+	var overrides []HistogramOverride
+	if config.IsSet("histogram_overrides") {
+		err := config.UnmarshalKey("histogram_overrides", &overrides)
+		if err != nil {
+			return nil, log.Errorf("Could not parse histogram_overrides: %v", err)
+		}
+	}
+	return overrides, nil
+}
+
 // IsCLCRunner returns whether the Agent is in cluster check runner mode
 func IsCLCRunner() bool {
 	if !Datadog.GetBool("clc_runner_enabled") {
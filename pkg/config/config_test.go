@@ -974,6 +974,136 @@ dogstatsd_mapper_profiles:
 	assert.Empty(t, profiles)
 }
 
+func TestMetricFiltersOk(t *testing.T) {
+	datadogYaml := `
+metric_filters:
+  include:
+    - "my.app.*"
+  exclude:
+    - "/^my\\.app\\.debug\\..*$/"
+  tag_rules:
+    - metrics:
+        - "my.app.requests"
+      remove_tags:
+        - request_id
+      rewrite_tags:
+        - key: env
+          match: "^prod-.*$"
+          replace: "prod"
+`
+	testConfig := setupConfFromYAML(datadogYaml)
+
+	filters, err := getMetricFiltersConfig(testConfig)
+
+	expectedFilters := MetricFilters{
+		Include: []string{"my.app.*"},
+		Exclude: []string{"/^my\\.app\\.debug\\..*$/"},
+		TagRules: []MetricTagRule{
+			{
+				Metrics:     []string{"my.app.requests"},
+				RemoveTags:  []string{"request_id"},
+				RewriteTags: []MetricTagRewrite{{Key: "env", Match: "^prod-.*$", Replace: "prod"}},
+			},
+		},
+	}
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, expectedFilters, filters)
+}
+
+func TestMetricFiltersError(t *testing.T) {
+	datadogYaml := `
+metric_filters:
+  - abc
+`
+	testConfig := setupConfFromYAML(datadogYaml)
+	_, err := getMetricFiltersConfig(testConfig)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Could not parse metric_filters")
+}
+
+func TestForwarderSinks(t *testing.T) {
+	datadogYaml := `
+forwarder_sinks:
+  - type: file
+    path: /tmp/payloads.log
+    max_size: 1000
+    max_files: 2
+    endpoints: ["series_v2", "sketches_v2"]
+  - type: http
+    url: http://localhost:8080
+`
+	testConfig := setupConfFromYAML(datadogYaml)
+
+	sinks, err := getForwarderSinksConfig(testConfig)
+
+	expectedSinks := []ForwarderSink{
+		{
+			Type:      "file",
+			Path:      "/tmp/payloads.log",
+			MaxSize:   1000,
+			MaxFiles:  2,
+			Endpoints: []string{"series_v2", "sketches_v2"},
+		},
+		{
+			Type: "http",
+			URL:  "http://localhost:8080",
+		},
+	}
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, expectedSinks, sinks)
+
+	sinks, err = getForwarderSinksConfig(setupConfFromYAML(""))
+	assert.Nil(t, err)
+	assert.Empty(t, sinks)
+}
+
+func TestHistogramOverridesOk(t *testing.T) {
+	datadogYaml := `
+histogram_overrides:
+  - metrics:
+      - "*.latency"
+    aggregates: ["max", "avg"]
+    percentiles: ["0.5", "0.99"]
+  - metrics:
+      - "/^http\\./"
+    distribution: true
+`
+	testConfig := setupConfFromYAML(datadogYaml)
+
+	overrides, err := getHistogramOverridesConfig(testConfig)
+
+	expectedOverrides := []HistogramOverride{
+		{
+			Metrics:     []string{"*.latency"},
+			Aggregates:  []string{"max", "avg"},
+			Percentiles: []string{"0.5", "0.99"},
+		},
+		{
+			Metrics:      []string{"/^http\\./"},
+			Distribution: true,
+		},
+	}
+
+	assert.Nil(t, err)
+	assert.EqualValues(t, expectedOverrides, overrides)
+}
+
+func TestHistogramOverridesError(t *testing.T) {
+	datadogYaml := `
+histogram_overrides:
+  - metrics: abc
+    distribution: maybe
+`
+	testConfig := setupConfFromYAML(datadogYaml)
+	_, err := getHistogramOverridesConfig(testConfig)
+
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "Could not parse histogram_overrides")
+}
+
 func TestDogstatsdMappingProfilesEnv(t *testing.T) {
 	env := "DD_DOGSTATSD_MAPPER_PROFILES"
 	err := os.Setenv(env, `[{"name":"another_profile","prefix":"abcd","mappings":[{"match":"airflow\\.dag_processing\\.last_runtime\\.(.*)","match_type":"regex","name":"foo","tags":{"a":"$1","b":"$2"}}]},{"name":"some_other_profile","prefix":"some_other_profile.","mappings":[{"match":"some_other_profile.*","name":"some_other_profile.abc","tags":{"a":"$1"}}]}]`)
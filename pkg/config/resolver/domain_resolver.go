@@ -9,6 +9,8 @@
 package resolver
 
 import (
+	"sync"
+
 	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
 	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
 )
@@ -30,6 +32,9 @@ type DomainResolver interface {
 	Resolve(endpoint transaction.Endpoint) (string, DestinationType)
 	// GetAPIKeys returns the list of API Keys associated with this `DomainResolver`
 	GetAPIKeys() []string
+	// SetAPIKeys replaces the list of API Keys associated with this `DomainResolver`, when the
+	// secrets of the API keys are rotated for instance
+	SetAPIKeys(apiKeys []string)
 	// GetBaseDomain returns the base domain for this `DomainResolver`
 	GetBaseDomain() string
 	// GetAlternateDomains returns all the domains that can be returned by `Resolve()` minus the base domain
@@ -42,13 +47,14 @@ type DomainResolver interface {
 type SingleDomainResolver struct {
 	domain  string
 	apiKeys []string
+	keysMu  sync.RWMutex
 }
 
 // NewSingleDomainResolver creates a SingleDomainResolver with its destination domain & API keys
 func NewSingleDomainResolver(domain string, apiKeys []string) *SingleDomainResolver {
 	return &SingleDomainResolver{
-		domain,
-		apiKeys,
+		domain:  domain,
+		apiKeys: apiKeys,
 	}
 }
 
@@ -73,9 +79,18 @@ func (r *SingleDomainResolver) GetBaseDomain() string {
 
 // GetAPIKeys returns the slice of API keys associated with this SingleDomainResolver
 func (r *SingleDomainResolver) GetAPIKeys() []string {
+	r.keysMu.RLock()
+	defer r.keysMu.RUnlock()
 	return r.apiKeys
 }
 
+// SetAPIKeys replaces the slice of API keys associated with this SingleDomainResolver
+func (r *SingleDomainResolver) SetAPIKeys(apiKeys []string) {
+	r.keysMu.Lock()
+	defer r.keysMu.Unlock()
+	r.apiKeys = apiKeys
+}
+
 // SetBaseDomain sets the only destination available for a SingleDomainResolver
 func (r *SingleDomainResolver) SetBaseDomain(domain string) {
 	r.domain = domain
@@ -97,23 +112,33 @@ type MultiDomainResolver struct {
 	apiKeys             []string
 	overrides           map[string]destination
 	alternateDomainList []string
+	keysMu              sync.RWMutex
 }
 
 // NewMultiDomainResolver initializes a MultiDomainResolver with its API keys and base destination
 func NewMultiDomainResolver(baseDomain string, apiKeys []string) *MultiDomainResolver {
 	return &MultiDomainResolver{
-		baseDomain,
-		apiKeys,
-		make(map[string]destination),
-		[]string{},
+		baseDomain:          baseDomain,
+		apiKeys:             apiKeys,
+		overrides:           make(map[string]destination),
+		alternateDomainList: []string{},
 	}
 }
 
-// GetAPIKeys returns the slice of API keys associated with this SingleDomainResolver
+// GetAPIKeys returns the slice of API keys associated with this MultiDomainResolver
 func (r *MultiDomainResolver) GetAPIKeys() []string {
+	r.keysMu.RLock()
+	defer r.keysMu.RUnlock()
 	return r.apiKeys
 }
 
+// SetAPIKeys replaces the slice of API keys associated with this MultiDomainResolver
+func (r *MultiDomainResolver) SetAPIKeys(apiKeys []string) {
+	r.keysMu.Lock()
+	defer r.keysMu.Unlock()
+	r.apiKeys = apiKeys
+}
+
 // Resolve returns the destiation for a given request endpoint
 func (r *MultiDomainResolver) Resolve(endpoint transaction.Endpoint) (string, DestinationType) {
 	if d, ok := r.overrides[endpoint.Name]; ok {
@@ -162,3 +187,31 @@ func NewDomainResolverWithMetricToVector(mainEndpoint string, apiKeys []string,
 	r.RegisterAlternateDestination(vectorEndpoint, endpoints.SketchSeriesEndpoint.Name, Vector)
 	return r
 }
+
+// UpdateAPIKeys sets the API keys of each domain resolver to the API keys of its domain in
+// keysPerDomain, the resolvers of the other domains are left untouched. It returns the domains
+// whose API keys changed.
+func UpdateAPIKeys(resolvers map[string]DomainResolver, keysPerDomain map[string][]string) []string {
+	var updated []string
+	for domain, r := range resolvers {
+		keys, found := keysPerDomain[domain]
+		if !found || equalKeys(r.GetAPIKeys(), keys) {
+			continue
+		}
+		r.SetAPIKeys(keys)
+		updated = append(updated, domain)
+	}
+	return updated
+}
+
+func equalKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
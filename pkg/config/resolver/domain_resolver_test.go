@@ -0,0 +1,31 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package resolver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateAPIKeys(t *testing.T) {
+	resolvers := map[string]DomainResolver{
+		"https://app.datadoghq.com": NewSingleDomainResolver("https://app.datadoghq.com", []string{"key1", "key2"}),
+		"https://app.datadoghq.eu":  NewMultiDomainResolver("https://app.datadoghq.eu", []string{"key3"}),
+		"https://other.example":     NewSingleDomainResolver("https://other.example", []string{"key4"}),
+	}
+
+	updated := UpdateAPIKeys(resolvers, map[string][]string{
+		"https://app.datadoghq.com": {"key1", "rotated"},
+		"https://app.datadoghq.eu":  {"key3"},
+		"https://unknown.example":   {"key5"},
+	})
+
+	assert.Equal(t, []string{"https://app.datadoghq.com"}, updated)
+	assert.Equal(t, []string{"key1", "rotated"}, resolvers["https://app.datadoghq.com"].GetAPIKeys())
+	assert.Equal(t, []string{"key3"}, resolvers["https://app.datadoghq.eu"].GetAPIKeys())
+	assert.Equal(t, []string{"key4"}, resolvers["https://other.example"].GetAPIKeys())
+}
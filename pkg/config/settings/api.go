@@ -4,7 +4,9 @@ package settings
 type Client interface {
 	Get(key string) (interface{}, error)
 	Set(key string, value string) (bool, error)
+	SetPersistent(key string, value string) (bool, error)
 	List() (map[string]RuntimeSettingResponse, error)
+	Overrides() ([]RuntimeSettingOverride, error)
 	FullConfig() (string, error)
 }
 
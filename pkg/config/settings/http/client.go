@@ -81,13 +81,41 @@ func (rc *runtimeSettingsHTTPClient) Get(key string) (interface{}, error) {
 	return nil, fmt.Errorf("unable to get value for this setting: %v", key)
 }
 
+func (rc *runtimeSettingsHTTPClient) Overrides() ([]settings.RuntimeSettingOverride, error) {
+	r, err := util.DoGet(rc.c, fmt.Sprintf("%s/%s", rc.baseURL, "overrides"))
+	if err != nil {
+		var errMap = make(map[string]string)
+		_ = json.Unmarshal(r, &errMap)
+		// If the error has been marshalled into a json object, check it and return it properly
+		if e, found := errMap["error"]; found {
+			return nil, fmt.Errorf(e)
+		}
+		return nil, err
+	}
+	var overrides []settings.RuntimeSettingOverride
+	err = json.Unmarshal(r, &overrides)
+	if err != nil {
+		return nil, err
+	}
+
+	return overrides, nil
+}
+
 func (rc *runtimeSettingsHTTPClient) Set(key string, value string) (bool, error) {
+	return rc.set(key, value, false)
+}
+
+func (rc *runtimeSettingsHTTPClient) SetPersistent(key string, value string) (bool, error) {
+	return rc.set(key, value, true)
+}
+
+func (rc *runtimeSettingsHTTPClient) set(key string, value string, persist bool) (bool, error) {
 	settingsList, err := rc.List()
 	if err != nil {
 		return false, err
 	}
 
-	body := fmt.Sprintf("value=%s", html.EscapeString(value))
+	body := fmt.Sprintf("value=%s&source=%s&persist=%t", html.EscapeString(value), settings.SourceCLI, persist)
 	r, err := util.DoPost(rc.c, fmt.Sprintf("%s/%s", rc.baseURL, key), "application/x-www-form-urlencoded", bytes.NewBuffer([]byte(body)))
 	if err != nil {
 		var errMap = make(map[string]string)
@@ -19,11 +19,13 @@ var Server = struct {
 	GetValue         http.HandlerFunc
 	SetValue         http.HandlerFunc
 	ListConfigurable http.HandlerFunc
+	ListOverrides    http.HandlerFunc
 }{
 	GetFull:          getFullConfig,
 	GetValue:         getConfigValue,
 	SetValue:         setConfigValue,
 	ListConfigurable: listConfigurableSettings,
+	ListOverrides:    listOverrides,
 }
 
 func getFullConfig(namespace string) http.HandlerFunc {
@@ -79,6 +81,17 @@ func listConfigurableSettings(w http.ResponseWriter, _ *http.Request) {
 	_, _ = w.Write(body)
 }
 
+func listOverrides(w http.ResponseWriter, _ *http.Request) {
+	body, err := json.Marshal(settings.RuntimeSettingOverrides())
+	if err != nil {
+		log.Errorf("Unable to marshal runtime setting overrides response: %s", err)
+		body, _ := json.Marshal(map[string]string{"error": err.Error()})
+		http.Error(w, string(body), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(body)
+}
+
 func getConfigValue(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	setting := vars["setting"]
@@ -111,8 +124,13 @@ func setConfigValue(w http.ResponseWriter, r *http.Request) {
 	log.Infof("Got a request to change a setting: %s", setting)
 	_ = r.ParseForm()
 	value := html.UnescapeString(r.Form.Get("value"))
+	persist := r.Form.Get("persist") == "true"
+	source := r.Form.Get("source")
+	if source == "" {
+		source = settings.SourceAPI
+	}
 
-	if err := settings.SetRuntimeSetting(setting, value); err != nil {
+	if err := settings.OverrideRuntimeSetting(setting, value, source, persist); err != nil {
 		body, _ := json.Marshal(map[string]string{"error": err.Error()})
 		switch err.(type) {
 		case *settings.SettingNotFoundError:
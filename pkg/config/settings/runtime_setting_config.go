@@ -0,0 +1,71 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package settings
+
+import (
+	"fmt"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+// ConfigRuntimeSetting is a runtime setting changing the value of a configuration key. The new
+// value can also be applied to the running components, it is only stored in the configuration
+// once applied.
+type ConfigRuntimeSetting struct {
+	key         string
+	description string
+	parse       func(v interface{}) (interface{}, error)
+	apply       func(v interface{}) error
+}
+
+// NewIntConfigRuntimeSetting returns a runtime setting changing an integer configuration key,
+// apply can be nil when the components read the configuration every time they need the value
+func NewIntConfigRuntimeSetting(key string, description string, apply func(int) error) *ConfigRuntimeSetting {
+	s := &ConfigRuntimeSetting{
+		key:         key,
+		description: description,
+		parse:       func(v interface{}) (interface{}, error) { return GetInt(v) },
+	}
+	if apply != nil {
+		s.apply = func(v interface{}) error { return apply(v.(int)) }
+	}
+	return s
+}
+
+// Description returns the runtime setting's description
+func (s *ConfigRuntimeSetting) Description() string {
+	return s.description
+}
+
+// Hidden returns whether or not this setting is hidden from the list of runtime settings
+func (s *ConfigRuntimeSetting) Hidden() bool {
+	return false
+}
+
+// Name returns the name of the runtime setting, which is the configuration key it changes
+func (s *ConfigRuntimeSetting) Name() string {
+	return s.key
+}
+
+// Get returns the current value of the runtime setting
+func (s *ConfigRuntimeSetting) Get() (interface{}, error) {
+	return config.Datadog.Get(s.key), nil
+}
+
+// Set changes the value of the runtime setting
+func (s *ConfigRuntimeSetting) Set(v interface{}) error {
+	value, err := s.parse(v)
+	if err != nil {
+		return fmt.Errorf("%s: %v", s.key, err)
+	}
+	if s.apply != nil {
+		if err := s.apply(value); err != nil {
+			return fmt.Errorf("unable to apply %s: %v", s.key, err)
+		}
+	}
+	config.Datadog.Set(s.key, value)
+	return nil
+}
@@ -0,0 +1,235 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package settings
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Sources of the runtime setting overrides
+const (
+	// SourceAPI is the source of the overrides set through the runtime settings API
+	SourceAPI = "api"
+	// SourceCLI is the source of the overrides set with the `config set` command
+	SourceCLI = "cli"
+	// SourcePersisted is the source of the overrides loaded from the overrides file at startup
+	SourcePersisted = "persisted"
+)
+
+const persistedOverridesHeader = "# Runtime settings persisted with `config set --persist`, they override the configuration files\n"
+
+// RuntimeSettingOverride describes a runtime setting whose value differs from the configuration
+type RuntimeSettingOverride struct {
+	Setting     string      `json:"setting"`
+	ConfigValue interface{} `json:"config_value"`
+	Value       interface{} `json:"value"`
+	Source      string      `json:"source"`
+	Persisted   bool        `json:"persisted"`
+	Time        time.Time   `json:"time"`
+}
+
+var (
+	overrides       = map[string]*RuntimeSettingOverride{}
+	persistedValues = map[string]string{}
+	overridesFile   string
+	overridesLock   sync.Mutex
+)
+
+// SetOverridesFile sets the file where the overrides are persisted. The overrides cannot be
+// persisted when it isn't set.
+func SetOverridesFile(path string) {
+	overridesLock.Lock()
+	defer overridesLock.Unlock()
+	overridesFile = path
+}
+
+// OverrideRuntimeSetting changes the value of a runtime setting on behalf of a user and keeps
+// track of the change, unlike SetRuntimeSetting which is meant for the internal changes. When
+// persist is true, the value is also written to the overrides file to be applied again at the
+// next start. Setting back the value of the configuration removes the override.
+func OverrideRuntimeSetting(setting string, value interface{}, source string, persist bool) error {
+	overridesLock.Lock()
+	defer overridesLock.Unlock()
+
+	if persist && overridesFile == "" {
+		return fmt.Errorf("the runtime settings cannot be persisted by this process")
+	}
+
+	override, err := overrideRuntimeSetting(setting, value, source)
+	if err != nil {
+		return err
+	}
+	if !persist {
+		return nil
+	}
+
+	if override == nil {
+		delete(persistedValues, setting)
+	} else {
+		override.Persisted = true
+		persistedValues[setting] = fmt.Sprint(value)
+	}
+	if err := savePersistedOverrides(); err != nil {
+		return fmt.Errorf("%s was changed but could not be persisted: %v", setting, err)
+	}
+	return nil
+}
+
+// overrideRuntimeSetting changes the value of a runtime setting and returns its override, nil
+// when its value is the one of the configuration. overridesLock must be held.
+func overrideRuntimeSetting(setting string, value interface{}, source string) (*RuntimeSettingOverride, error) {
+	s, found := runtimeSettings[setting]
+	if !found {
+		return nil, &SettingNotFoundError{name: setting}
+	}
+
+	configValue, found := configFileValue(setting)
+	if !found {
+		// the settings which aren't configuration keys are compared to their value before
+		// the first override
+		if override, found := overrides[setting]; found {
+			configValue = override.ConfigValue
+		} else {
+			current, err := s.Get()
+			if err != nil {
+				return nil, err
+			}
+			configValue = current
+		}
+	}
+
+	if err := s.Set(value); err != nil {
+		return nil, err
+	}
+	newValue, err := s.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	// the values are compared as strings since the new values are often sent as strings
+	if fmt.Sprint(newValue) == fmt.Sprint(configValue) {
+		delete(overrides, setting)
+		log.Infof("Runtime setting %s is back to its configured value", setting)
+		return nil, nil
+	}
+
+	override := &RuntimeSettingOverride{
+		Setting:     setting,
+		ConfigValue: configValue,
+		Value:       newValue,
+		Source:      source,
+		Time:        time.Now(),
+	}
+	overrides[setting] = override
+	log.Infof("Runtime setting %s was changed from %v to %v by %s", setting, configValue, newValue, source)
+	return override, nil
+}
+
+// configFileValue returns the value of a configuration key as loaded from the configuration
+// file, with the defaults and the environment variables but without the runtime changes.
+// Returns false if the setting is not a configuration key or the file cannot be read.
+func configFileValue(key string) (interface{}, bool) {
+	cfg := config.NewConfig("datadog", "DD", strings.NewReplacer(".", "_"))
+	config.InitConfig(cfg)
+	if _, known := cfg.GetKnownKeys()[key]; !known {
+		return nil, false
+	}
+	if file := config.Datadog.ConfigFileUsed(); file != "" {
+		cfg.SetConfigFile(file)
+		if err := cfg.ReadInConfig(); err != nil {
+			log.Debugf("Unable to read the configured value of %s: %v", key, err)
+			return nil, false
+		}
+	}
+	return cfg.Get(key), true
+}
+
+// RuntimeSettingOverrides returns the runtime settings whose value differs from the
+// configuration, sorted by name
+func RuntimeSettingOverrides() []RuntimeSettingOverride {
+	overridesLock.Lock()
+	defer overridesLock.Unlock()
+
+	res := make([]RuntimeSettingOverride, 0, len(overrides))
+	for _, override := range overrides {
+		res = append(res, *override)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Setting < res[j].Setting })
+	return res
+}
+
+// LoadPersistedOverrides applies the overrides of the overrides file. It must be called once the
+// runtime settings are registered and the components they change are started. The settings
+// which are unknown or whose value cannot be applied are skipped.
+func LoadPersistedOverrides() error {
+	overridesLock.Lock()
+	defer overridesLock.Unlock()
+
+	if overridesFile == "" {
+		return nil
+	}
+	content, err := ioutil.ReadFile(overridesFile)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to read the persisted runtime settings: %v", err)
+	}
+
+	values := map[string]string{}
+	if err := yaml.Unmarshal(content, &values); err != nil {
+		return fmt.Errorf("unable to parse the persisted runtime settings: %v", err)
+	}
+	persistedValues = values
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		override, err := overrideRuntimeSetting(name, values[name], SourcePersisted)
+		if err != nil {
+			log.Warnf("Unable to apply the persisted runtime setting %s: %v", name, err)
+			// the settings which don't exist anymore are removed at the next save
+			if _, ok := err.(*SettingNotFoundError); ok {
+				delete(persistedValues, name)
+			}
+			continue
+		}
+		if override != nil {
+			override.Persisted = true
+		}
+	}
+	return nil
+}
+
+// savePersistedOverrides writes the persisted values to the overrides file, which is removed
+// when there is none. overridesLock must be held.
+func savePersistedOverrides() error {
+	if len(persistedValues) == 0 {
+		if err := os.Remove(overridesFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	content, err := yaml.Marshal(persistedValues)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(overridesFile, append([]byte(persistedOverridesHeader), content...), 0600)
+}
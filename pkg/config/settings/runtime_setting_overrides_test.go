@@ -0,0 +1,149 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package settings
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetOverrides() {
+	overrides = map[string]*RuntimeSettingOverride{}
+	persistedValues = map[string]string{}
+	overridesFile = ""
+}
+
+func TestConfigRuntimeSetting(t *testing.T) {
+	mockConfig := config.Mock()
+	mockConfig.Set("test_workers", 2)
+
+	var applied []int
+	s := NewIntConfigRuntimeSetting("test_workers", "desc", func(v int) error {
+		if v < 1 {
+			return fmt.Errorf("invalid value")
+		}
+		applied = append(applied, v)
+		return nil
+	})
+	assert.Equal(t, "test_workers", s.Name())
+
+	require.NoError(t, s.Set("4"))
+	assert.Equal(t, []int{4}, applied)
+	v, err := s.Get()
+	require.NoError(t, err)
+	assert.Equal(t, 4, v)
+
+	// the value is not stored when it can't be applied
+	assert.Error(t, s.Set("0"))
+	assert.Error(t, s.Set("four"))
+	assert.Equal(t, 4, mockConfig.GetInt("test_workers"))
+}
+
+func TestOverrideRuntimeSetting(t *testing.T) {
+	cleanRuntimeSetting()
+	resetOverrides()
+	defer resetOverrides()
+	mockConfig := config.Mock()
+	mockConfig.Set("test_workers", 2)
+	require.NoError(t, RegisterRuntimeSetting(NewIntConfigRuntimeSetting("test_workers", "desc", nil)))
+
+	assert.IsType(t, &SettingNotFoundError{}, OverrideRuntimeSetting("unknown", "1", SourceCLI, false))
+	assert.Error(t, OverrideRuntimeSetting("test_workers", "4", SourceCLI, true), "no overrides file")
+
+	require.NoError(t, OverrideRuntimeSetting("test_workers", "4", SourceCLI, false))
+	require.NoError(t, OverrideRuntimeSetting("test_workers", "8", SourceAPI, false))
+	res := RuntimeSettingOverrides()
+	require.Len(t, res, 1)
+	assert.Equal(t, "test_workers", res[0].Setting)
+	assert.Equal(t, 2, res[0].ConfigValue)
+	assert.Equal(t, 8, res[0].Value)
+	assert.Equal(t, SourceAPI, res[0].Source)
+	assert.False(t, res[0].Persisted)
+
+	// setting back the configured value removes the override
+	require.NoError(t, OverrideRuntimeSetting("test_workers", "2", SourceCLI, false))
+	assert.Empty(t, RuntimeSettingOverrides())
+}
+
+func TestOverrideRuntimeSettingConfigFile(t *testing.T) {
+	cleanRuntimeSetting()
+	resetOverrides()
+	defer resetOverrides()
+
+	dir, err := ioutil.TempDir("", "runtime_settings")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "datadog.yaml")
+	require.NoError(t, ioutil.WriteFile(path, []byte("forwarder_num_workers: 3\n"), 0600))
+
+	mockConfig := config.Mock()
+	mockConfig.SetConfigFile(path)
+	// the running value differs from the one of the configuration file
+	mockConfig.Set("forwarder_num_workers", 5)
+	require.NoError(t, RegisterRuntimeSetting(NewIntConfigRuntimeSetting("forwarder_num_workers", "desc", nil)))
+
+	require.NoError(t, OverrideRuntimeSetting("forwarder_num_workers", "8", SourceCLI, false))
+	res := RuntimeSettingOverrides()
+	require.Len(t, res, 1)
+	assert.Equal(t, 3, res[0].ConfigValue)
+	assert.Equal(t, 8, res[0].Value)
+
+	// the value of the configuration file is not an override
+	require.NoError(t, OverrideRuntimeSetting("forwarder_num_workers", "5", SourceCLI, false))
+	assert.Len(t, RuntimeSettingOverrides(), 1)
+	require.NoError(t, OverrideRuntimeSetting("forwarder_num_workers", "3", SourceCLI, false))
+	assert.Empty(t, RuntimeSettingOverrides())
+}
+
+func TestPersistedOverrides(t *testing.T) {
+	cleanRuntimeSetting()
+	resetOverrides()
+	defer resetOverrides()
+	mockConfig := config.Mock()
+	mockConfig.Set("test_workers", 2)
+	require.NoError(t, RegisterRuntimeSetting(NewIntConfigRuntimeSetting("test_workers", "desc", nil)))
+
+	dir, err := ioutil.TempDir("", "runtime_settings")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "runtime_settings.yaml")
+	SetOverridesFile(path)
+
+	require.NoError(t, OverrideRuntimeSetting("test_workers", "4", SourceCLI, true))
+	res := RuntimeSettingOverrides()
+	require.Len(t, res, 1)
+	assert.True(t, res[0].Persisted)
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "test_workers: \"4\"")
+
+	// the persisted value is applied at the next start
+	resetOverrides()
+	mockConfig.Set("test_workers", 2)
+	ioutil.WriteFile(path, append(content, []byte("unknown: foo\n")...), 0600)
+	SetOverridesFile(path)
+	require.NoError(t, LoadPersistedOverrides())
+	assert.Equal(t, 4, mockConfig.GetInt("test_workers"))
+	res = RuntimeSettingOverrides()
+	require.Len(t, res, 1)
+	assert.Equal(t, SourcePersisted, res[0].Source)
+	assert.Equal(t, 2, res[0].ConfigValue)
+	assert.True(t, res[0].Persisted)
+
+	// persisting the configured value removes it from the file
+	require.NoError(t, OverrideRuntimeSetting("test_workers", "2", SourceCLI, true))
+	assert.Empty(t, RuntimeSettingOverrides())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -13,11 +13,16 @@ type batcher struct {
 	samples      []metrics.MetricSample
 	samplesCount int
 
+	// samples carrying their own timestamp, sent to the no-aggregation pipeline
+	samplesWithTs      []metrics.MetricSample
+	samplesWithTsCount int
+
 	events        []*metrics.Event
 	serviceChecks []*metrics.ServiceCheck
 
 	// output channels
 	choutSamples       chan<- []metrics.MetricSample
+	choutSamplesWithTs chan<- []metrics.MetricSample
 	choutEvents        chan<- []*metrics.Event
 	choutServiceChecks chan<- []*metrics.ServiceCheck
 
@@ -28,14 +33,20 @@ func newBatcher(agg *aggregator.BufferedAggregator) *batcher {
 	s, e, sc := agg.GetBufferedChannels()
 	return &batcher{
 		samples:            agg.MetricSamplePool.GetBatch(),
+		samplesWithTs:      agg.MetricSamplePool.GetBatch(),
 		metricSamplePool:   agg.MetricSamplePool,
 		choutSamples:       s,
+		choutSamplesWithTs: agg.GetBufferedNoAggregationChannel(),
 		choutEvents:        e,
 		choutServiceChecks: sc,
 	}
 }
 
 func (b *batcher) appendSample(sample metrics.MetricSample) {
+	if sample.Timestamp > 0 {
+		b.appendSampleWithTs(sample)
+		return
+	}
 	if b.samplesCount == len(b.samples) {
 		b.flushSamples()
 	}
@@ -43,6 +54,14 @@ func (b *batcher) appendSample(sample metrics.MetricSample) {
 	b.samplesCount++
 }
 
+func (b *batcher) appendSampleWithTs(sample metrics.MetricSample) {
+	if b.samplesWithTsCount == len(b.samplesWithTs) {
+		b.flushSamplesWithTs()
+	}
+	b.samplesWithTs[b.samplesWithTsCount] = sample
+	b.samplesWithTsCount++
+}
+
 func (b *batcher) appendEvent(event *metrics.Event) {
 	b.events = append(b.events, event)
 }
@@ -63,9 +82,22 @@ func (b *batcher) flushSamples() {
 	}
 }
 
+func (b *batcher) flushSamplesWithTs() {
+	if b.samplesWithTsCount > 0 {
+		t1 := time.Now()
+		b.choutSamplesWithTs <- b.samplesWithTs[:b.samplesWithTsCount]
+		t2 := time.Now()
+		tlmChannel.Observe(float64(t2.Sub(t1).Nanoseconds()), "metrics_with_ts")
+
+		b.samplesWithTsCount = 0
+		b.samplesWithTs = b.metricSamplePool.GetBatch()
+	}
+}
+
 // flush pushes all batched metrics to the aggregator.
 func (b *batcher) flush() {
 	b.flushSamples()
+	b.flushSamplesWithTs()
 	if len(b.events) > 0 {
 		t1 := time.Now()
 		b.choutEvents <- b.events
@@ -112,6 +112,9 @@ func enrichMetricSample(metricSamples []metrics.MetricSample, ddSample dogstatsd
 
 	mtype := enrichMetricType(ddSample.metricType)
 
+	// samples with a timestamp are sent as-is through the no-aggregation pipeline
+	timestamp := float64(ddSample.timestamp)
+
 	// if 'ddSample.values' contains values we're enriching a multi-value
 	// dogstatsd message and will create a MetricSample per value. If not
 	// we will use 'ddSample.value'and return a single MetricSample
@@ -129,6 +132,7 @@ func enrichMetricSample(metricSamples []metrics.MetricSample, ddSample dogstatsd
 					OriginID:    originID,
 					K8sOriginID: k8sOriginID,
 					Cardinality: cardinality,
+					Timestamp:   timestamp,
 				})
 		}
 		return metricSamples
@@ -146,6 +150,7 @@ func enrichMetricSample(metricSamples []metrics.MetricSample, ddSample dogstatsd
 		OriginID:    originID,
 		K8sOriginID: k8sOriginID,
 		Cardinality: cardinality,
+		Timestamp:   timestamp,
 	})
 }
 
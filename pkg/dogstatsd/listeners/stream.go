@@ -0,0 +1,226 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"expvar"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+)
+
+// streamFraming is the way messages are delimited on stream connections
+type streamFraming int
+
+const (
+	// framingNewline: messages are separated by a '\n'
+	framingNewline streamFraming = iota
+	// framingLengthPrefix: each frame is prefixed by its length as a 4 bytes little-endian
+	// unsigned integer. A frame can contain several messages separated by '\n'.
+	framingLengthPrefix
+)
+
+const lengthPrefixSize = 4
+
+// parseStreamFraming parses the framing name used in the configuration
+func parseStreamFraming(name string) (streamFraming, error) {
+	switch name {
+	case "", "newline":
+		return framingNewline, nil
+	case "length_prefix":
+		return framingLengthPrefix, nil
+	}
+	return framingNewline, fmt.Errorf("invalid framing '%s', must be 'newline' or 'length_prefix'", name)
+}
+
+// streamTelemetry holds the expvars and telemetry counters updated by a streamReader
+type streamTelemetry struct {
+	packets             *expvar.Int
+	bytes               *expvar.Int
+	packetReadingErrors *expvar.Int
+	tlmPackets          telemetry.Counter
+	tlmPacketsBytes     telemetry.Counter
+}
+
+func (t *streamTelemetry) onPacket(n int) {
+	t.packets.Add(1)
+	t.bytes.Add(int64(n))
+	t.tlmPackets.Inc("ok")
+	t.tlmPacketsBytes.Add(float64(n))
+}
+
+func (t *streamTelemetry) onError() {
+	t.packetReadingErrors.Add(1)
+	t.tlmPackets.Inc("error")
+}
+
+// streamReader reads the messages of a stream connection and sends them as
+// packets, taken from the shared packet pool, to the packets buffer.
+type streamReader struct {
+	framing                 streamFraming
+	sharedPacketPoolManager *packets.PoolManager
+	packetsBuffer           *packets.Buffer
+	source                  packets.SourceType
+	origin                  string
+	tags                    []string
+	telemetry               *streamTelemetry
+}
+
+// read reads the connection until it is closed or an invalid frame is received.
+// It returns nil when the connection is closed cleanly by the client.
+func (r *streamReader) read(conn io.Reader) error {
+	if r.framing == framingLengthPrefix {
+		return r.readLengthPrefixed(bufio.NewReader(conn))
+	}
+	return r.readNewlineDelimited(conn)
+}
+
+func (r *streamReader) send(packet *packets.Packet, n int) {
+	r.telemetry.onPacket(n)
+	packet.Contents = packet.Buffer[:n]
+	packet.Origin = r.origin
+	packet.Tags = r.tags
+	packet.Source = r.source
+	// packetsBuffer handles the forwarding of the packets to the dogstatsd server intake channel
+	r.packetsBuffer.Append(packet)
+}
+
+func (r *streamReader) readNewlineDelimited(conn io.Reader) error {
+	// retrieve an available packet from the packet pool,
+	// which will be pushed back by the server when processed.
+	packet := r.sharedPacketPoolManager.Get().(*packets.Packet)
+	start := 0
+	// discarding is true while skipping the end of a message larger than a packet
+	discarding := false
+	for {
+		n, err := conn.Read(packet.Buffer[start:])
+		end := start + n
+
+		if discarding {
+			if i := bytes.IndexByte(packet.Buffer[:end], '\n'); i >= 0 {
+				end = copy(packet.Buffer, packet.Buffer[i+1:end])
+				discarding = false
+			} else {
+				end = 0
+			}
+		}
+
+		// only send the complete messages, the last one is kept for the next read
+		messageSize := bytes.LastIndexByte(packet.Buffer[:end], '\n') + 1
+		switch {
+		case messageSize > 0:
+			next := r.sharedPacketPoolManager.Get().(*packets.Packet)
+			start = copy(next.Buffer, packet.Buffer[messageSize:end])
+			r.send(packet, messageSize)
+			packet = next
+		case end >= len(packet.Buffer):
+			// the message doesn't fit in a packet: drop it
+			r.telemetry.onError()
+			discarding = true
+			start = 0
+		default:
+			start = end
+		}
+
+		if err != nil {
+			// the last message may not be terminated by a '\n'
+			if start > 0 && err == io.EOF {
+				r.send(packet, start)
+			} else {
+				r.sharedPacketPoolManager.Put(packet)
+			}
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (r *streamReader) readLengthPrefixed(conn *bufio.Reader) error {
+	var header [lengthPrefixSize]byte
+	for {
+		if _, err := io.ReadFull(conn, header[:]); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		size := int(binary.LittleEndian.Uint32(header[:]))
+
+		packet := r.sharedPacketPoolManager.Get().(*packets.Packet)
+		if size > len(packet.Buffer) {
+			r.sharedPacketPoolManager.Put(packet)
+			r.telemetry.onError()
+			// the stream can't be resynchronized
+			return fmt.Errorf("frame of %d bytes is larger than the buffer size %d", size, len(packet.Buffer))
+		}
+		if _, err := io.ReadFull(conn, packet.Buffer[:size]); err != nil {
+			r.sharedPacketPoolManager.Put(packet)
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return err
+		}
+		if size == 0 {
+			r.sharedPacketPoolManager.Put(packet)
+			continue
+		}
+		r.send(packet, size)
+	}
+}
+
+// connTracker keeps track of the open connections of a stream listener to close them
+// when the listener is stopped.
+type connTracker struct {
+	mu      sync.Mutex
+	conns   map[net.Conn]struct{}
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func newConnTracker() *connTracker {
+	return &connTracker{conns: make(map[net.Conn]struct{})}
+}
+
+// track registers a new connection, it returns false if the listener is stopped
+func (c *connTracker) track(conn net.Conn) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stopped {
+		return false
+	}
+	c.conns[conn] = struct{}{}
+	c.wg.Add(1)
+	return true
+}
+
+// untrack closes and unregisters a connection
+func (c *connTracker) untrack(conn net.Conn) {
+	c.mu.Lock()
+	delete(c.conns, conn)
+	c.mu.Unlock()
+	conn.Close()
+	c.wg.Done()
+}
+
+// closeAll closes all the connections and waits for them to be untracked
+func (c *connTracker) closeAll() {
+	c.mu.Lock()
+	c.stopped = true
+	for conn := range c.conns {
+		conn.Close()
+	}
+	c.mu.Unlock()
+	c.wg.Wait()
+}
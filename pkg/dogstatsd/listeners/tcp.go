@@ -0,0 +1,235 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/replay"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// tcpHandshakeTimeout is the maximum duration of the TLS handshake of a new connection
+const tcpHandshakeTimeout = 10 * time.Second
+
+// tcpClientTagName is the name of the tag identifying the TCP clients
+const tcpClientTagName = "dogstatsd_client"
+
+var (
+	tcpExpvars             = expvar.NewMap("dogstatsd-tcp")
+	tcpConnections         = expvar.Int{}
+	tcpConnectionErrors    = expvar.Int{}
+	tcpPacketReadingErrors = expvar.Int{}
+	tcpPackets             = expvar.Int{}
+	tcpBytes               = expvar.Int{}
+)
+
+func init() {
+	tcpExpvars.Set("Connections", &tcpConnections)
+	tcpExpvars.Set("ConnectionErrors", &tcpConnectionErrors)
+	tcpExpvars.Set("PacketReadingErrors", &tcpPacketReadingErrors)
+	tcpExpvars.Set("Packets", &tcpPackets)
+	tcpExpvars.Set("Bytes", &tcpBytes)
+}
+
+// TCPListener implements the StatsdListener interface for TCP.
+// It accepts connections on a given TCP address, optionally over TLS, and
+// sends back packets ready to be processed. Messages are delimited either by
+// a newline or by a length prefix.
+// Origin detection is not implemented for TCP. When enabled, the client of the
+// packets is identified by a `dogstatsd_client` tag: the common name of the verified
+// client certificate if any, or the IP of the client otherwise.
+type TCPListener struct {
+	listener                net.Listener
+	framing                 streamFraming
+	packetsBuffer           *packets.Buffer
+	sharedPacketPoolManager *packets.PoolManager
+	trafficCapture          *replay.TrafficCapture // Currently ignored
+	telemetry               *streamTelemetry
+	conns                   *connTracker
+	clientTag               bool
+}
+
+// NewTCPListener returns an idle TCP Statsd listener
+func NewTCPListener(packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager, capture *replay.TrafficCapture) (*TCPListener, error) {
+	var url string
+	if config.Datadog.GetBool("dogstatsd_non_local_traffic") {
+		// Listen to all network interfaces
+		url = fmt.Sprintf(":%d", config.Datadog.GetInt("dogstatsd_tcp_port"))
+	} else {
+		url = net.JoinHostPort(config.GetBindHost(), config.Datadog.GetString("dogstatsd_tcp_port"))
+	}
+
+	framing, err := parseStreamFraming(config.Datadog.GetString("dogstatsd_tcp_framing"))
+	if err != nil {
+		return nil, fmt.Errorf("dogstatsd-tcp: %s", err)
+	}
+
+	tlsConfig, err := buildTCPTLSConfig(
+		config.Datadog.GetString("dogstatsd_tcp_tls_cert_file"),
+		config.Datadog.GetString("dogstatsd_tcp_tls_key_file"),
+		config.Datadog.GetString("dogstatsd_tcp_tls_client_ca_file"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("dogstatsd-tcp: %s", err)
+	}
+
+	var listener net.Listener
+	if tlsConfig != nil {
+		listener, err = tls.Listen("tcp", url, tlsConfig)
+	} else {
+		listener, err = net.Listen("tcp", url)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("can't listen: %s", err)
+	}
+
+	l := &TCPListener{
+		listener: listener,
+		framing:  framing,
+		packetsBuffer: packets.NewBuffer(uint(config.Datadog.GetInt("dogstatsd_packet_buffer_size")),
+			config.Datadog.GetDuration("dogstatsd_packet_buffer_flush_timeout"), packetOut),
+		sharedPacketPoolManager: sharedPacketPoolManager,
+		trafficCapture:          capture,
+		telemetry: &streamTelemetry{
+			packets:             &tcpPackets,
+			bytes:               &tcpBytes,
+			packetReadingErrors: &tcpPacketReadingErrors,
+			tlmPackets:          tlmTCPPackets,
+			tlmPacketsBytes:     tlmTCPPacketsBytes,
+		},
+		conns:     newConnTracker(),
+		clientTag: config.Datadog.GetBool("dogstatsd_tcp_client_tag"),
+	}
+	log.Debugf("dogstatsd-tcp: %s successfully initialized (TLS: %t)", listener.Addr(), tlsConfig != nil)
+	return l, nil
+}
+
+// buildTCPTLSConfig returns the TLS configuration of the listener, or nil if TLS is disabled.
+// Client certificates are required and verified when a client CA file is given.
+func buildTCPTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" && keyFile == "" {
+		if clientCAFile != "" {
+			return nil, fmt.Errorf("a certificate and a key are required to verify client certificates")
+		}
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("could not load the TLS certificate: %s", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if clientCAFile != "" {
+		caPEM, err := ioutil.ReadFile(clientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("could not read the client CA file: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no valid certificate found in the client CA file %s", clientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tlsConfig, nil
+}
+
+// Listen runs the intake loop. Should be called in its own goroutine
+func (l *TCPListener) Listen() {
+	log.Infof("dogstatsd-tcp: starting to listen on %s", l.listener.Addr())
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			// listener has been closed
+			if strings.HasSuffix(err.Error(), " use of closed network connection") {
+				return
+			}
+			log.Errorf("dogstatsd-tcp: error accepting connection: %v", err)
+			tcpConnectionErrors.Add(1)
+			tlmTCPConnections.Inc("error")
+			continue
+		}
+
+		if !l.conns.track(conn) {
+			conn.Close()
+			return
+		}
+		go l.handleConnection(conn)
+	}
+}
+
+func (l *TCPListener) handleConnection(conn net.Conn) {
+	defer l.conns.untrack(conn)
+
+	client, err := tcpConnClient(conn)
+	if err != nil {
+		log.Debugf("dogstatsd-tcp: connection from %s refused: %v", conn.RemoteAddr(), err)
+		tcpConnectionErrors.Add(1)
+		tlmTCPConnections.Inc("error")
+		return
+	}
+	tcpConnections.Add(1)
+	tlmTCPConnections.Inc("ok")
+	log.Debugf("dogstatsd-tcp: new connection from %s", client)
+
+	reader := &streamReader{
+		framing:                 l.framing,
+		sharedPacketPoolManager: l.sharedPacketPoolManager,
+		packetsBuffer:           l.packetsBuffer,
+		source:                  packets.TCP,
+		origin:                  packets.NoOrigin,
+		telemetry:               l.telemetry,
+	}
+	if l.clientTag {
+		reader.tags = []string{tcpClientTagName + ":" + client}
+	}
+	if err := reader.read(conn); err != nil && !strings.HasSuffix(err.Error(), " use of closed network connection") {
+		log.Warnf("dogstatsd-tcp: closing connection from %s: %v", client, err)
+		l.telemetry.onError()
+	}
+}
+
+// tcpConnClient completes the TLS handshake if needed and returns the client of the connection:
+// the common name of its certificate if any, or its IP otherwise
+func tcpConnClient(conn net.Conn) (string, error) {
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		tlsConn.SetDeadline(time.Now().Add(tcpHandshakeTimeout)) //nolint:errcheck
+		if err := tlsConn.Handshake(); err != nil {
+			return "", err
+		}
+		tlsConn.SetDeadline(time.Time{}) //nolint:errcheck
+		if certs := tlsConn.ConnectionState().PeerCertificates; len(certs) > 0 && certs[0].Subject.CommonName != "" {
+			return certs[0].Subject.CommonName, nil
+		}
+	}
+
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return "", err
+	}
+	return host, nil
+}
+
+// Stop closes the listener and the open connections
+func (l *TCPListener) Stop() {
+	l.listener.Close()
+	l.conns.closeAll()
+	l.packetsBuffer.Close()
+}
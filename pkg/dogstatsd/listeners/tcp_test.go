@@ -0,0 +1,222 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+)
+
+func newTestTCPListener(t *testing.T, packetChannel chan packets.Packets, settings map[string]interface{}) *TCPListener {
+	// bind to a random local port
+	settings["dogstatsd_tcp_port"] = 0
+	settings["dogstatsd_non_local_traffic"] = false
+	settings["bind_host"] = "127.0.0.1"
+	for key, value := range settings {
+		previous := config.Datadog.Get(key)
+		config.Datadog.SetDefault(key, value)
+		defer config.Datadog.SetDefault(key, previous)
+	}
+
+	s, err := NewTCPListener(packetChannel, packetPoolManagerTCP, nil)
+	require.NoError(t, err)
+	go s.Listen()
+	t.Cleanup(s.Stop)
+	return s
+}
+
+var (
+	packetPoolTCP        = packets.NewPool(config.Datadog.GetInt("dogstatsd_buffer_size"))
+	packetPoolManagerTCP = packets.NewPoolManager(packetPoolTCP)
+)
+
+func receivePackets(t *testing.T, packetChannel chan packets.Packets) packets.Packets {
+	select {
+	case pkts := <-packetChannel:
+		return pkts
+	case <-time.After(2 * time.Second):
+		require.FailNow(t, "Timeout on receive channel")
+	}
+	return nil
+}
+
+func TestTCPReceiveNewline(t *testing.T) {
+	packetChannel := make(chan packets.Packets)
+	s := newTestTCPListener(t, packetChannel, map[string]interface{}{})
+
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	_, err = conn.Write([]byte("daemon:666|g\ndaemon2:1|c\npartial"))
+	require.NoError(t, err)
+
+	pkts := receivePackets(t, packetChannel)
+	require.Len(t, pkts, 1)
+	assert.Equal(t, "daemon:666|g\ndaemon2:1|c\n", string(pkts[0].Contents))
+	assert.Equal(t, packets.TCP, pkts[0].Source)
+	assert.Equal(t, packets.NoOrigin, pkts[0].Origin)
+	assert.Nil(t, pkts[0].Tags)
+
+	// the last message is sent when the connection is closed
+	conn.Close()
+	pkts = receivePackets(t, packetChannel)
+	require.Len(t, pkts, 1)
+	assert.Equal(t, "partial", string(pkts[0].Contents))
+}
+
+func TestTCPReceiveLengthPrefix(t *testing.T) {
+	packetChannel := make(chan packets.Packets)
+	s := newTestTCPListener(t, packetChannel, map[string]interface{}{"dogstatsd_tcp_framing": "length_prefix"})
+
+	conn, err := net.Dial("tcp", s.listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	payload := []byte("daemon:666|g\ndaemon2:1|c")
+	frame := make([]byte, 4+len(payload))
+	binary.LittleEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err = conn.Write(frame)
+	require.NoError(t, err)
+
+	pkts := receivePackets(t, packetChannel)
+	require.Len(t, pkts, 1)
+	assert.Equal(t, payload, pkts[0].Contents)
+}
+
+func TestTCPInvalidFraming(t *testing.T) {
+	config.Datadog.SetDefault("dogstatsd_tcp_framing", "invalid")
+	defer config.Datadog.SetDefault("dogstatsd_tcp_framing", "newline")
+	_, err := NewTCPListener(nil, packetPoolManagerTCP, nil)
+	assert.Error(t, err)
+}
+
+func TestStreamReaderDiscardsLargeMessages(t *testing.T) {
+	pool := packets.NewPoolManager(packets.NewPool(16))
+	packetChannel := make(chan packets.Packets, 10)
+	buffer := packets.NewBuffer(1, time.Second, packetChannel)
+	defer buffer.Close()
+
+	client, server := net.Pipe()
+	reader := &streamReader{
+		framing:                 framingNewline,
+		sharedPacketPoolManager: pool,
+		packetsBuffer:           buffer,
+		source:                  packets.TCP,
+		telemetry: &streamTelemetry{
+			packets:             &tcpPackets,
+			bytes:               &tcpBytes,
+			packetReadingErrors: &tcpPacketReadingErrors,
+			tlmPackets:          tlmTCPPackets,
+			tlmPacketsBytes:     tlmTCPPacketsBytes,
+		},
+	}
+	done := make(chan error)
+	go func() { done <- reader.read(server) }()
+
+	client.Write([]byte("a:1|c\n"))
+	client.Write([]byte("this.message.is.too.long:1|c\nb:2|c\n"))
+	client.Close()
+	require.NoError(t, <-done)
+
+	var contents []string
+	for len(packetChannel) > 0 {
+		for _, packet := range <-packetChannel {
+			contents = append(contents, string(packet.Contents))
+		}
+	}
+	assert.Equal(t, []string{"a:1|c\n", "b:2|c\n"}, contents)
+}
+
+func TestTCPTLSClientCertificate(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := generateTestCertificate(t, dir, "ca", nil, nil)
+	generateTestCertificate(t, dir, "server", ca, caKey)
+	generateTestCertificate(t, dir, "client", ca, caKey)
+
+	packetChannel := make(chan packets.Packets)
+	s := newTestTCPListener(t, packetChannel, map[string]interface{}{
+		"dogstatsd_tcp_tls_cert_file":      filepath.Join(dir, "server.crt"),
+		"dogstatsd_tcp_tls_key_file":       filepath.Join(dir, "server.key"),
+		"dogstatsd_tcp_tls_client_ca_file": filepath.Join(dir, "ca.crt"),
+		"dogstatsd_tcp_client_tag":         true,
+	})
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+	clientCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	require.NoError(t, err)
+
+	// connections without a client certificate are refused
+	conn, err := tls.Dial("tcp", s.listener.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "server"})
+	if err == nil {
+		conn.Write([]byte("refused:1|c\n"))
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+	}
+	assert.Error(t, err)
+
+	conn, err = tls.Dial("tcp", s.listener.Addr().String(), &tls.Config{RootCAs: roots, ServerName: "server", Certificates: []tls.Certificate{clientCert}})
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("daemon:666|g\n"))
+	require.NoError(t, err)
+
+	pkts := receivePackets(t, packetChannel)
+	require.Len(t, pkts, 1)
+	assert.Equal(t, "daemon:666|g\n", string(pkts[0].Contents))
+	assert.Equal(t, packets.NoOrigin, pkts[0].Origin)
+	assert.Equal(t, []string{"dogstatsd_client:client"}, pkts[0].Tags)
+}
+
+// generateTestCertificate writes <name>.crt and <name>.key in dir, the certificate is
+// self-signed when parent is nil.
+func generateTestCertificate(t *testing.T, dir, name string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		parent, parentKey = template, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".crt"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+	return cert, key
+}
@@ -24,6 +24,20 @@ var (
 	tlmUDSPacketsBytes = telemetry.NewCounter("dogstatsd", "uds_packets_bytes",
 		nil, "Dogstatsd UDS packets bytes")
 
+	// UDS stream
+	tlmUDSStreamPackets = telemetry.NewCounter("dogstatsd", "uds_stream_packets",
+		[]string{"state"}, "Dogstatsd UDS stream packets count")
+	tlmUDSStreamPacketsBytes = telemetry.NewCounter("dogstatsd", "uds_stream_packets_bytes",
+		nil, "Dogstatsd UDS stream packets bytes")
+
+	// TCP
+	tlmTCPPackets = telemetry.NewCounter("dogstatsd", "tcp_packets",
+		[]string{"state"}, "Dogstatsd TCP packets count")
+	tlmTCPPacketsBytes = telemetry.NewCounter("dogstatsd", "tcp_packets_bytes",
+		nil, "Dogstatsd TCP packets bytes count")
+	tlmTCPConnections = telemetry.NewCounter("dogstatsd", "tcp_connections",
+		[]string{"state"}, "Dogstatsd TCP connections count")
+
 	tlmListener            = telemetry.NewHistogramNoOp()
 	defaultListenerBuckets = []float64{300, 500, 1000, 1500, 2000, 2500, 3000, 10000, 20000, 50000}
 )
@@ -67,6 +67,35 @@ func processUDSOrigin(ancillary []byte) (int, string, error) {
 		return 0, packets.NoOrigin, err
 	}
 
+	return originForCredentials(cred)
+}
+
+// processUDSConnOrigin reads the credentials of the peer of a stream connection
+// to determine its origin. They are set by the Linux kernel when the connection
+// is established and read with SO_PEERCRED.
+func processUDSConnOrigin(conn *net.UnixConn) (int, string, error) {
+	rawconn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, packets.NoOrigin, err
+	}
+
+	var cred *unix.Ucred
+	var credErr error
+	err = rawconn.Control(func(fd uintptr) {
+		cred, credErr = unix.GetsockoptUcred(int(fd), unix.SOL_SOCKET, unix.SO_PEERCRED)
+	})
+	if err != nil {
+		return 0, packets.NoOrigin, err
+	}
+	if credErr != nil {
+		return 0, packets.NoOrigin, credErr
+	}
+
+	return originForCredentials(cred)
+}
+
+// originForCredentials returns the PID and the entity of the process with the given credentials
+func originForCredentials(cred *unix.Ucred) (int, string, error) {
 	if cred.Pid == 0 {
 		return 0, packets.NoOrigin, fmt.Errorf("matched PID for the process is 0, it belongs " +
 			"probably to another namespace. Is the agent in host PID mode?")
@@ -13,14 +13,18 @@ package listeners
 
 import (
 	"io/ioutil"
+	"net"
 	"os"
 	"path/filepath"
+	"strconv"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+	"github.com/DataDog/datadog-agent/pkg/util/cache"
 	"golang.org/x/sys/unix"
 )
 
@@ -51,3 +55,27 @@ func TestUDSPassCred(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Equal(t, enabled, 1)
 }
+
+func TestUDSConnOrigin(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "dsd-stream.socket")
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: socketPath, Net: "unix"})
+	require.NoError(t, err)
+	defer listener.Close()
+
+	client, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+	defer client.Close()
+	conn, err := listener.AcceptUnix()
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// the peer credentials are the ones of the test process
+	key := cache.BuildAgentKey(pidToEntityCacheKeyPrefix, strconv.Itoa(os.Getpid()))
+	cache.Cache.Set(key, "container_id://test", pidToEntityCacheDuration)
+	defer cache.Cache.Delete(key)
+
+	pid, origin, err := processUDSConnOrigin(conn)
+	require.NoError(t, err)
+	assert.Equal(t, os.Getpid(), pid)
+	assert.Equal(t, "container_id://test", origin)
+}
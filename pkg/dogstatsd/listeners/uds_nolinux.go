@@ -31,3 +31,7 @@ func enableUDSPassCred(conn *net.UnixConn) error {
 func processUDSOrigin(oob []byte) (int, string, error) {
 	return 0, packets.NoOrigin, ErrLinuxOnly
 }
+
+func processUDSConnOrigin(conn *net.UnixConn) (int, string, error) {
+	return 0, packets.NoOrigin, ErrLinuxOnly
+}
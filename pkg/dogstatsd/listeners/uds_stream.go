@@ -0,0 +1,162 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package listeners
+
+import (
+	"expvar"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/replay"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var (
+	udsStreamExpvars             = expvar.NewMap("dogstatsd-uds-stream")
+	udsStreamConnections         = expvar.Int{}
+	udsStreamPacketReadingErrors = expvar.Int{}
+	udsStreamPackets             = expvar.Int{}
+	udsStreamBytes               = expvar.Int{}
+)
+
+func init() {
+	udsStreamExpvars.Set("Connections", &udsStreamConnections)
+	udsStreamExpvars.Set("PacketReadingErrors", &udsStreamPacketReadingErrors)
+	udsStreamExpvars.Set("Packets", &udsStreamPackets)
+	udsStreamExpvars.Set("Bytes", &udsStreamBytes)
+}
+
+// UDSStreamListener implements the StatsdListener interface for Unix Domain
+// Socket stream protocol. It accepts connections on a given socket path and
+// sends back packets ready to be processed.
+// The messages are sent in frames prefixed by their length, a frame can hold
+// up to `dogstatsd_buffer_size` bytes. When origin detection is enabled, the
+// origin of a connection is read from its peer credentials.
+type UDSStreamListener struct {
+	listener                *net.UnixListener
+	socketPath              string
+	packetsBuffer           *packets.Buffer
+	sharedPacketPoolManager *packets.PoolManager
+	trafficCapture          *replay.TrafficCapture // Currently ignored
+	telemetry               *streamTelemetry
+	conns                   *connTracker
+	OriginDetection         bool
+}
+
+// NewUDSStreamListener returns an idle UDS stream Statsd listener
+func NewUDSStreamListener(packetOut chan packets.Packets, sharedPacketPoolManager *packets.PoolManager, capture *replay.TrafficCapture) (*UDSStreamListener, error) {
+	socketPath := config.Datadog.GetString("dogstatsd_stream_socket")
+	originDetection := config.Datadog.GetBool("dogstatsd_origin_detection")
+
+	address, err := net.ResolveUnixAddr("unix", socketPath)
+	if err != nil {
+		return nil, fmt.Errorf("dogstatsd-uds-stream: can't ResolveUnixAddr: %v", err)
+	}
+	if err := removeStaleSocket(socketPath); err != nil {
+		return nil, err
+	}
+
+	listener, err := net.ListenUnix("unix", address)
+	if err != nil {
+		return nil, fmt.Errorf("can't listen: %s", err)
+	}
+	// the socket file is removed by Stop
+	listener.SetUnlinkOnClose(false)
+	err = os.Chmod(socketPath, 0722)
+	if err != nil {
+		listener.Close()
+		return nil, fmt.Errorf("can't set the socket at write only: %s", err)
+	}
+
+	l := &UDSStreamListener{
+		listener:   listener,
+		socketPath: socketPath,
+		packetsBuffer: packets.NewBuffer(uint(config.Datadog.GetInt("dogstatsd_packet_buffer_size")),
+			config.Datadog.GetDuration("dogstatsd_packet_buffer_flush_timeout"), packetOut),
+		sharedPacketPoolManager: sharedPacketPoolManager,
+		trafficCapture:          capture,
+		telemetry: &streamTelemetry{
+			packets:             &udsStreamPackets,
+			bytes:               &udsStreamBytes,
+			packetReadingErrors: &udsStreamPacketReadingErrors,
+			tlmPackets:          tlmUDSStreamPackets,
+			tlmPacketsBytes:     tlmUDSStreamPacketsBytes,
+		},
+		conns:           newConnTracker(),
+		OriginDetection: originDetection,
+	}
+
+	log.Debugf("dogstatsd-uds-stream: %s successfully initialized", listener.Addr())
+	return l, nil
+}
+
+// Listen runs the intake loop. Should be called in its own goroutine
+func (l *UDSStreamListener) Listen() {
+	log.Infof("dogstatsd-uds-stream: starting to listen on %s", l.listener.Addr())
+	for {
+		conn, err := l.listener.AcceptUnix()
+		if err != nil {
+			// listener has been closed
+			if strings.HasSuffix(err.Error(), " use of closed network connection") {
+				return
+			}
+			log.Errorf("dogstatsd-uds-stream: error accepting connection: %v", err)
+			continue
+		}
+
+		if !l.conns.track(conn) {
+			conn.Close()
+			return
+		}
+		go l.handleConnection(conn)
+	}
+}
+
+func (l *UDSStreamListener) handleConnection(conn *net.UnixConn) {
+	defer l.conns.untrack(conn)
+	udsStreamConnections.Add(1)
+
+	origin := packets.NoOrigin
+	if l.OriginDetection {
+		// the origin of all the packets of the connection is the process which opened it
+		var err error
+		if _, origin, err = processUDSConnOrigin(conn); err != nil {
+			log.Warnf("dogstatsd-uds-stream: error processing origin, data will not be tagged : %v", err)
+			udsOriginDetectionErrors.Add(1)
+			tlmUDSOriginDetectionError.Inc()
+		}
+	}
+
+	reader := &streamReader{
+		framing:                 framingLengthPrefix,
+		sharedPacketPoolManager: l.sharedPacketPoolManager,
+		packetsBuffer:           l.packetsBuffer,
+		source:                  packets.UDSStream,
+		origin:                  origin,
+		telemetry:               l.telemetry,
+	}
+	if err := reader.read(conn); err != nil && !strings.HasSuffix(err.Error(), " use of closed network connection") {
+		log.Warnf("dogstatsd-uds-stream: closing connection: %v", err)
+		l.telemetry.onError()
+	}
+}
+
+// Stop closes the listener and the open connections
+func (l *UDSStreamListener) Stop() {
+	l.listener.Close()
+	l.conns.closeAll()
+	l.packetsBuffer.Close()
+
+	// Socket cleanup on exit
+	err := os.Remove(l.socketPath)
+	if err != nil {
+		log.Infof("dogstatsd-uds-stream: error removing socket file: %s", err)
+	}
+}
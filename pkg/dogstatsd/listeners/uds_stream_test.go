@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build !windows
+
+package listeners
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
+)
+
+func lengthPrefixedFrame(payload []byte) []byte {
+	frame := make([]byte, 4+len(payload))
+	binary.LittleEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	return frame
+}
+
+func TestUDSStreamReceive(t *testing.T) {
+	socketPath := filepath.Join(t.TempDir(), "dsd-stream.socket")
+	mockConfig := config.Mock()
+	mockConfig.Set("dogstatsd_stream_socket", socketPath)
+
+	packetChannel := make(chan packets.Packets)
+	s, err := NewUDSStreamListener(packetChannel, packetPoolManagerUDS, nil)
+	require.NoError(t, err)
+	go s.Listen()
+
+	fi, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	assert.Equal(t, "Srwx-w--w-", fi.Mode().String())
+
+	conn, err := net.Dial("unix", socketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	// a payload larger than the usual datagram sizes is received in one packet
+	var payload bytes.Buffer
+	for payload.Len() < 6000 {
+		payload.WriteString("daemon:666|g|#sometag1:somevalue1\n")
+	}
+	_, err = conn.Write(lengthPrefixedFrame(payload.Bytes()))
+	require.NoError(t, err)
+
+	pkts := receivePackets(t, packetChannel)
+	require.Len(t, pkts, 1)
+	assert.Equal(t, payload.Bytes(), pkts[0].Contents)
+	assert.Equal(t, packets.UDSStream, pkts[0].Source)
+	assert.Equal(t, packets.NoOrigin, pkts[0].Origin)
+
+	s.Stop()
+	_, err = os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -11,7 +11,10 @@ import (
 	"fmt"
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"regexp"
+	"strconv"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var (
@@ -38,16 +41,47 @@ type MappingProfile struct {
 
 // MetricMapping represent one mapping rule
 type MetricMapping struct {
-	name  string
-	tags  map[string]string
-	regex *regexp.Regexp
+	name       string
+	tags       map[string]string
+	regex      *regexp.Regexp
+	matchTags  map[string]*regexp.Regexp
+	dropTags   map[string]struct{}
+	renameTags map[string]string
+	drop       bool
 }
 
 // MapResult represent the outcome of the mapping
 type MapResult struct {
-	Name    string
-	Tags    []string
-	matched bool
+	Name string
+	Tags []string
+	// Drop is true when the metric must be dropped
+	Drop       bool
+	dropTags   map[string]struct{}
+	renameTags map[string]string
+	matched    bool
+}
+
+// MapTags returns the given tags without the tags dropped by the mapping, with the
+// renamed tags and the tags extracted by the mapping. The given slice may be reused.
+func (r *MapResult) MapTags(tags []string) []string {
+	if len(r.dropTags) == 0 && len(r.renameTags) == 0 {
+		return append(tags, r.Tags...)
+	}
+	mapped := make([]string, 0, len(tags)+len(r.Tags))
+	for _, tag := range tags {
+		key, value := splitTag(tag)
+		if _, found := r.dropTags[key]; found {
+			continue
+		}
+		if newKey, found := r.renameTags[key]; found {
+			tag = newKey
+			if value != "" {
+				tag += ":" + value
+			}
+		}
+		mapped = append(mapped, tag)
+	}
+	return append(mapped, r.Tags...)
 }
 
 // NewMetricMapper creates, validates, prepares a new MetricMapper
@@ -69,7 +103,7 @@ func NewMetricMapper(configProfiles []config.MappingProfile, cacheSize int) (*Me
 			if matchType != matchTypeWildcard && matchType != matchTypeRegex {
 				return nil, fmt.Errorf("profile: %s, mapping num %d: invalid match type, must be `wildcard` or `regex`", profile.Name, i)
 			}
-			if currentMapping.Name == "" {
+			if currentMapping.Name == "" && !currentMapping.Drop {
 				return nil, fmt.Errorf("profile: %s, mapping num %d: name is required", profile.Name, i)
 			}
 			if currentMapping.Match == "" {
@@ -79,7 +113,33 @@ func NewMetricMapper(configProfiles []config.MappingProfile, cacheSize int) (*Me
 			if err != nil {
 				return nil, err
 			}
-			profile.Mappings = append(profile.Mappings, &MetricMapping{name: currentMapping.Name, tags: currentMapping.Tags, regex: regex})
+			mapping := &MetricMapping{name: currentMapping.Name, tags: currentMapping.Tags, regex: regex, drop: currentMapping.Drop}
+			for tagKey, valueRe := range currentMapping.MatchTags {
+				tagRegex, err := regexp.Compile("^(?:" + valueRe + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("profile: %s, mapping num %d: invalid match_tags regex `%s` for tag `%s`: %v", profile.Name, i, valueRe, tagKey, err)
+				}
+				if mapping.matchTags == nil {
+					mapping.matchTags = make(map[string]*regexp.Regexp)
+				}
+				mapping.matchTags[tagKey] = tagRegex
+			}
+			for _, tagKey := range currentMapping.DropTags {
+				if mapping.dropTags == nil {
+					mapping.dropTags = make(map[string]struct{})
+				}
+				mapping.dropTags[tagKey] = struct{}{}
+			}
+			for oldKey, newKey := range currentMapping.RenameTags {
+				if newKey == "" {
+					return nil, fmt.Errorf("profile: %s, mapping num %d: invalid rename_tags, empty new name for tag `%s`", profile.Name, i, oldKey)
+				}
+				if mapping.renameTags == nil {
+					mapping.renameTags = make(map[string]string)
+				}
+				mapping.renameTags[oldKey] = newKey
+			}
+			profile.Mappings = append(profile.Mappings, mapping)
 		}
 		profiles = append(profiles, profile)
 	}
@@ -108,8 +168,9 @@ func buildRegex(matchRe string, matchType string) (*regexp.Regexp, error) {
 	return regex, nil
 }
 
-// Map returns a MapResult
-func (m *MetricMapper) Map(metricName string) *MapResult {
+// Map returns a MapResult, or nil if no mapping matches the metric. The tags of the metric
+// are only used by the mappings with `match_tags`.
+func (m *MetricMapper) Map(metricName string, tags []string) *MapResult {
 	for _, profile := range m.Profiles {
 		if !strings.HasPrefix(metricName, profile.Prefix) && profile.Prefix != "*" {
 			continue
@@ -121,32 +182,165 @@ func (m *MetricMapper) Map(metricName string) *MapResult {
 			}
 			return nil
 		}
+		// the results depending on the tags can't be cached by metric name
+		dependsOnTags := false
 		for _, mapping := range profile.Mappings {
 			matches := mapping.regex.FindStringSubmatchIndex(metricName)
 			if len(matches) == 0 {
 				continue
 			}
 
-			name := string(mapping.regex.ExpandString(
-				[]byte{},
-				mapping.name,
-				metricName,
-				matches,
-			))
+			var tagCaptures map[string]string
+			if len(mapping.matchTags) > 0 {
+				dependsOnTags = true
+				var ok bool
+				if tagCaptures, ok = mapping.matchTagValues(tags); !ok {
+					continue
+				}
+			}
 
-			var tags []string
-			for tagKey, tagValueExpr := range mapping.tags {
-				tagValue := string(mapping.regex.ExpandString([]byte{}, tagValueExpr, metricName, matches))
-				tags = append(tags, tagKey+":"+tagValue)
+			mapResult := &MapResult{Drop: mapping.drop, matched: true}
+			if !mapping.drop {
+				lookup := func(name string) string {
+					return mapping.lookupCapture(name, metricName, matches, tagCaptures)
+				}
+				mapResult.Name = expandTemplate(mapping.name, lookup)
+				for tagKey, tagValueExpr := range mapping.tags {
+					mapResult.Tags = append(mapResult.Tags, tagKey+":"+expandTemplate(tagValueExpr, lookup))
+				}
+				mapResult.dropTags = mapping.dropTags
+				mapResult.renameTags = mapping.renameTags
 			}
 
-			mapResult := &MapResult{Name: name, matched: true, Tags: tags}
-			m.cache.add(metricName, mapResult)
+			if !dependsOnTags {
+				m.cache.add(metricName, mapResult)
+			}
 			return mapResult
 		}
-		mapResult := &MapResult{matched: false}
-		m.cache.add(metricName, mapResult)
+		if !dependsOnTags {
+			m.cache.add(metricName, &MapResult{matched: false})
+		}
 		return nil
 	}
 	return nil
 }
+
+// matchTagValues returns the named groups captured from the tag values if all
+// the `match_tags` of the mapping match one of the given tags.
+func (mapping *MetricMapping) matchTagValues(tags []string) (map[string]string, bool) {
+	captures := make(map[string]string)
+	for tagKey, tagRegex := range mapping.matchTags {
+		matched := false
+		for _, tag := range tags {
+			key, value := splitTag(tag)
+			if key != tagKey {
+				continue
+			}
+			submatches := tagRegex.FindStringSubmatch(value)
+			if submatches == nil {
+				continue
+			}
+			for i, name := range tagRegex.SubexpNames() {
+				if name != "" {
+					captures[name] = submatches[i]
+				}
+			}
+			matched = true
+			break
+		}
+		if !matched {
+			return nil, false
+		}
+	}
+	return captures, true
+}
+
+// lookupCapture returns the value of a template variable: the numbered and named groups of
+// the `match` pattern, or the named groups of the `match_tags` regexes.
+func (mapping *MetricMapping) lookupCapture(name string, metricName string, matches []int, tagCaptures map[string]string) string {
+	index := -1
+	if num, err := strconv.Atoi(name); err == nil && num >= 0 {
+		index = num
+	} else {
+		index = mapping.regex.SubexpIndex(name)
+		if index < 0 {
+			return tagCaptures[name]
+		}
+	}
+	if 2*index+1 < len(matches) && matches[2*index] >= 0 {
+		return metricName[matches[2*index]:matches[2*index+1]]
+	}
+	return ""
+}
+
+// expandTemplate replaces the `$name` and `${name}` variables of the template, following
+// the syntax of regexp.Regexp.Expand. `$$` is replaced by a literal `$`.
+func expandTemplate(template string, lookup func(name string) string) string {
+	if !strings.Contains(template, "$") {
+		return template
+	}
+	var b strings.Builder
+	for {
+		i := strings.IndexByte(template, '$')
+		if i < 0 {
+			break
+		}
+		b.WriteString(template[:i])
+		template = template[i:]
+		if len(template) > 1 && template[1] == '$' {
+			b.WriteByte('$')
+			template = template[2:]
+			continue
+		}
+		name, rest, ok := extractTemplateName(template)
+		if !ok {
+			// malformed variable, the `$` is kept as is
+			b.WriteByte('$')
+			template = template[1:]
+			continue
+		}
+		b.WriteString(lookup(name))
+		template = rest
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// extractTemplateName returns the name of the variable at the beginning of the
+// template, which starts with `$`, and the rest of the template.
+func extractTemplateName(template string) (string, string, bool) {
+	if len(template) < 2 {
+		return "", "", false
+	}
+	brace := template[1] == '{'
+	start := 1
+	if brace {
+		start = 2
+	}
+	end := start
+	for end < len(template) {
+		r, size := utf8.DecodeRuneInString(template[end:])
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			break
+		}
+		end += size
+	}
+	if end == start {
+		return "", "", false
+	}
+	name := template[start:end]
+	if brace {
+		if end >= len(template) || template[end] != '}' {
+			return "", "", false
+		}
+		end++
+	}
+	return name, template[end:], true
+}
+
+func splitTag(tag string) (string, string) {
+	if i := strings.IndexByte(tag, ':'); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
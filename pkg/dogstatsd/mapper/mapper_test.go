@@ -333,7 +333,7 @@ dogstatsd_mapper_profiles:
 
 			var actualResults []MapResult
 			for _, packet := range scenario.packets {
-				mapResult := mapper.Map(packet)
+				mapResult := mapper.Map(packet, nil)
 				if mapResult != nil {
 					actualResults = append(actualResults, *mapResult)
 				}
@@ -349,6 +349,74 @@ dogstatsd_mapper_profiles:
 	}
 }
 
+func TestMappingsWithTags(t *testing.T) {
+	mapper, err := getMapper(`
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.ignored.*"
+        drop: true
+      - match: 'test\.request\.(?P<endpoint>\w+)'
+        match_type: regex
+        match_tags:
+          http_method: "(?P<method>GET|POST)"
+          env: "prod|staging"
+        name: "test.request.${method}"
+        tags:
+          endpoint: "${endpoint}"
+        drop_tags:
+          - request_id
+        rename_tags:
+          http_method: method
+      - match: "test.request.*"
+        name: "test.request.other"
+`)
+	require.NoError(t, err)
+
+	result := mapper.Map("test.ignored.foo", nil)
+	require.NotNil(t, result)
+	assert.True(t, result.Drop)
+
+	tags := []string{"http_method:GET", "env:prod", "request_id:42", "other"}
+	result = mapper.Map("test.request.users", tags)
+	require.NotNil(t, result)
+	assert.False(t, result.Drop)
+	assert.Equal(t, "test.request.GET", result.Name)
+	assert.Equal(t, []string{"endpoint:users"}, result.Tags)
+	assert.Equal(t, []string{"method:GET", "env:prod", "other", "endpoint:users"}, result.MapTags(tags))
+
+	// the result depends on the tags, it must not be cached by metric name
+	result = mapper.Map("test.request.users", []string{"http_method:DELETE", "env:prod"})
+	require.NotNil(t, result)
+	assert.Equal(t, "test.request.other", result.Name)
+	result = mapper.Map("test.request.users", []string{"http_method:POST", "env:dev"})
+	require.NotNil(t, result)
+	assert.Equal(t, "test.request.other", result.Name)
+	result = mapper.Map("test.request.users", []string{"http_method:POST", "env:staging"})
+	require.NotNil(t, result)
+	assert.Equal(t, "test.request.POST", result.Name)
+}
+
+func TestExpandTemplate(t *testing.T) {
+	lookup := func(name string) string {
+		return map[string]string{"1": "one", "name": "value"}[name]
+	}
+	for template, expected := range map[string]string{
+		"no.variable":    "no.variable",
+		"a.$1.b":         "a.one.b",
+		"a.${1}x":        "a.onex",
+		"a.$1x":          "a.",
+		"${name}.$name":  "value.value",
+		"$$1":            "$1",
+		"a.${name":       "a.${name",
+		"trailing.$":     "trailing.$",
+		"unknown.${foo}": "unknown.",
+	} {
+		assert.Equal(t, expected, expandTemplate(template, lookup), template)
+	}
+}
+
 func TestMappingErrors(t *testing.T) {
 	scenarios := []struct {
 		name          string
@@ -453,6 +521,23 @@ dogstatsd_mapper_profiles:
 			},
 			expectedError: "it should not contain consecutive `*`",
 		},
+		{
+			name: "Invalid match_tags regex",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.job.duration"
+        name: "test.job.duration"
+        match_tags:
+          env: "(prod"
+`,
+			packets: []string{
+				"test.job.duration",
+			},
+			expectedError: "invalid match_tags regex",
+		},
 		{
 			name: "Invalid match type",
 			config: `
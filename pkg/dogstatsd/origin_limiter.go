@@ -0,0 +1,228 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package dogstatsd
+
+import (
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/murmur3"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+)
+
+const (
+	// originQuotaWindow is the period over which the quotas are enforced
+	originQuotaWindow = time.Second
+	// originQuotaExpiry is the duration after which an origin without traffic is forgotten
+	originQuotaExpiry = 5 * time.Minute
+
+	dropReasonPackets  = "packets"
+	dropReasonContexts = "contexts"
+)
+
+var (
+	dogstatsdOriginLimiterExpvars = expvar.NewMap("dogstatsd-origin-limiter")
+	originDroppedPackets          = expvar.Int{}
+	originDroppedSamples          = expvar.Int{}
+
+	tlmOriginDropped = telemetry.NewCounter("dogstatsd", "origin_dropped",
+		[]string{"origin", "reason"}, "Count of packets and metric samples dropped by dogstatsd because their origin was over quota")
+)
+
+func init() {
+	dogstatsdOriginLimiterExpvars.Set("DroppedPackets", &originDroppedPackets)
+	dogstatsdOriginLimiterExpvars.Set("DroppedSamples", &originDroppedSamples)
+}
+
+// originStat holds the number of packets and metric samples of an origin dropped
+// because they were over the quotas.
+type originStat struct {
+	DroppedPackets uint64    `json:"dropped_packets"`
+	DroppedSamples uint64    `json:"dropped_samples"`
+	LastDrop       time.Time `json:"last_drop"`
+}
+
+// originQuota tracks the traffic of an origin in the current window
+type originQuota struct {
+	window   time.Time
+	lastSeen time.Time
+	packets  int
+	contexts map[ckey.ContextKey]struct{}
+	stat     originStat
+}
+
+// originLimiterShards is the number of shards of the originLimiter state. The origins are
+// spread over the shards by hash, so that the workers of the server rarely wait for each other.
+const originLimiterShards = 32
+
+// originLimiter enforces per-origin quotas on the number of packets and of unique
+// contexts received by the server every second. Traffic without origin is not limited.
+// It is shared by the workers of the server.
+type originLimiter struct {
+	maxPackets  int
+	maxContexts int
+	// lastExpiry is the last time the origins without traffic were forgotten, in nanoseconds
+	lastExpiry int64
+	shards     [originLimiterShards]originLimiterShard
+}
+
+// originLimiterShard holds the quotas of the origins of a shard
+type originLimiterShard struct {
+	sync.Mutex
+	origins map[string]*originQuota
+
+	keyGen          *ckey.KeyGenerator
+	tagsAccumulator *tagset.HashingTagsAccumulator
+}
+
+// newOriginLimiter returns a new originLimiter, or nil if no quota is set.
+// A quota of 0 means no limit.
+func newOriginLimiter(maxPackets, maxContexts int) *originLimiter {
+	if maxPackets <= 0 && maxContexts <= 0 {
+		return nil
+	}
+	l := &originLimiter{
+		maxPackets:  maxPackets,
+		maxContexts: maxContexts,
+	}
+	for i := range l.shards {
+		l.shards[i].origins = make(map[string]*originQuota)
+		l.shards[i].keyGen = ckey.NewKeyGenerator()
+		l.shards[i].tagsAccumulator = tagset.NewHashingTagsAccumulator()
+	}
+	return l
+}
+
+// newOriginLimiterFromConfig returns the originLimiter configured with `dogstatsd_origin_quotas`
+func newOriginLimiterFromConfig() *originLimiter {
+	return newOriginLimiter(
+		config.Datadog.GetInt("dogstatsd_origin_quotas.packets_per_second"),
+		config.Datadog.GetInt("dogstatsd_origin_quotas.contexts_per_second"),
+	)
+}
+
+// shard returns the shard of the origin, forgetting the origins without traffic if it's time to.
+func (l *originLimiter) shard(origin string, now time.Time) *originLimiterShard {
+	if last := atomic.LoadInt64(&l.lastExpiry); now.UnixNano()-last > int64(originQuotaExpiry) &&
+		atomic.CompareAndSwapInt64(&l.lastExpiry, last, now.UnixNano()) {
+		l.expire(now)
+	}
+	return &l.shards[murmur3.StringSum64(origin)%originLimiterShards]
+}
+
+// expire forgets the origins which haven't sent anything for a while.
+func (l *originLimiter) expire(now time.Time) {
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.Lock()
+		for origin, q := range s.origins {
+			if now.Sub(q.lastSeen) > originQuotaExpiry {
+				delete(s.origins, origin)
+				tlmOriginDropped.Delete(origin, dropReasonPackets)
+				tlmOriginDropped.Delete(origin, dropReasonContexts)
+			}
+		}
+		s.Unlock()
+	}
+}
+
+// quota returns the quota of the origin, starting a new window if needed.
+// Must be called with the lock of the shard held.
+func (s *originLimiterShard) quota(origin string, now time.Time) *originQuota {
+	q, found := s.origins[origin]
+	if !found {
+		q = &originQuota{}
+		s.origins[origin] = q
+	}
+	q.lastSeen = now
+	if window := now.Truncate(originQuotaWindow); window.After(q.window) {
+		q.window = window
+		q.packets = 0
+		q.contexts = nil
+	}
+	return q
+}
+
+// allowPacket returns false if the packet must be dropped because its origin
+// sent too many packets in the current window.
+func (l *originLimiter) allowPacket(origin string, now time.Time) bool {
+	if origin == "" || l.maxPackets <= 0 {
+		return true
+	}
+
+	s := l.shard(origin, now)
+	s.Lock()
+	defer s.Unlock()
+
+	q := s.quota(origin, now)
+	if q.packets < l.maxPackets {
+		q.packets++
+		return true
+	}
+
+	q.stat.DroppedPackets++
+	q.stat.LastDrop = now
+	originDroppedPackets.Add(1)
+	tlmOriginDropped.Inc(origin, dropReasonPackets)
+	return false
+}
+
+// allowSample returns false if the sample must be dropped because it would be
+// a new context for an origin which already sent too many unique contexts in
+// the current window.
+func (l *originLimiter) allowSample(origin string, name string, host string, tags []string, now time.Time) bool {
+	if origin == "" || l.maxContexts <= 0 {
+		return true
+	}
+
+	s := l.shard(origin, now)
+	s.Lock()
+	defer s.Unlock()
+
+	s.tagsAccumulator.Append(tags...)
+	key := s.keyGen.Generate(name, host, s.tagsAccumulator)
+	s.tagsAccumulator.Reset()
+
+	q := s.quota(origin, now)
+	if _, found := q.contexts[key]; found {
+		return true
+	}
+	if len(q.contexts) < l.maxContexts {
+		if q.contexts == nil {
+			q.contexts = make(map[ckey.ContextKey]struct{})
+		}
+		q.contexts[key] = struct{}{}
+		return true
+	}
+
+	q.stat.DroppedSamples++
+	q.stat.LastDrop = now
+	originDroppedSamples.Add(1)
+	tlmOriginDropped.Inc(origin, dropReasonContexts)
+	return false
+}
+
+// stats returns the drops of the origins which have been over quota
+func (l *originLimiter) stats() map[string]originStat {
+	stats := make(map[string]originStat)
+	for i := range l.shards {
+		s := &l.shards[i]
+		s.Lock()
+		for origin, q := range s.origins {
+			if q.stat.DroppedPackets > 0 || q.stat.DroppedSamples > 0 {
+				stats[origin] = q.stat
+			}
+		}
+		s.Unlock()
+	}
+	return stats
+}
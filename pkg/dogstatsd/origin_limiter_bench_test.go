@@ -0,0 +1,34 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package dogstatsd
+
+import (
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func BenchmarkOriginLimiterParallel(b *testing.B) {
+	for _, origins := range []int{1, 100} {
+		b.Run(fmt.Sprintf("%d-origins", origins), func(sb *testing.B) {
+			l := newOriginLimiter(1000000, 1000000)
+			tags := buildTags(10)
+			var worker int64
+			now := time.Now()
+
+			sb.ReportAllocs()
+			sb.ResetTimer()
+			sb.RunParallel(func(pb *testing.PB) {
+				origin := fmt.Sprintf("container_id://%d", atomic.AddInt64(&worker, 1)%int64(origins))
+				for pb.Next() {
+					l.allowPacket(origin, now)
+					l.allowSample(origin, "metric", "", tags, now)
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,80 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package dogstatsd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOriginLimiterDisabled(t *testing.T) {
+	assert.Nil(t, newOriginLimiter(0, 0))
+}
+
+func TestOriginLimiterPackets(t *testing.T) {
+	l := newOriginLimiter(2, 0)
+	now := time.Now().Truncate(time.Second)
+
+	assert.True(t, l.allowPacket("container_id://a", now))
+	assert.True(t, l.allowPacket("container_id://a", now))
+	assert.False(t, l.allowPacket("container_id://a", now))
+	// the quotas are per origin
+	assert.True(t, l.allowPacket("container_id://b", now))
+	// the traffic without origin is not limited
+	for i := 0; i < 5; i++ {
+		assert.True(t, l.allowPacket("", now))
+	}
+	// the quota is reset every second
+	assert.True(t, l.allowPacket("container_id://a", now.Add(time.Second)))
+
+	stats := l.stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, uint64(1), stats["container_id://a"].DroppedPackets)
+	assert.Equal(t, uint64(0), stats["container_id://a"].DroppedSamples)
+	assert.Equal(t, now, stats["container_id://a"].LastDrop)
+}
+
+func TestOriginLimiterContexts(t *testing.T) {
+	l := newOriginLimiter(0, 2)
+	now := time.Now().Truncate(time.Second)
+	origin := "container_id://a"
+
+	assert.True(t, l.allowSample(origin, "metric", "", []string{"a", "b"}, now))
+	assert.True(t, l.allowSample(origin, "metric", "", []string{"c"}, now))
+	// the known contexts are still accepted, whatever the order of the tags
+	assert.True(t, l.allowSample(origin, "metric", "", []string{"b", "a"}, now))
+	assert.False(t, l.allowSample(origin, "metric", "", []string{"d"}, now))
+	assert.False(t, l.allowSample(origin, "metric", "host", []string{"c"}, now))
+	assert.True(t, l.allowSample("", "metric", "", []string{"d"}, now))
+	// packets are not limited
+	assert.True(t, l.allowPacket(origin, now))
+
+	assert.True(t, l.allowSample(origin, "metric", "", []string{"d"}, now.Add(time.Second)))
+
+	stats := l.stats()
+	require.Len(t, stats, 1)
+	assert.Equal(t, uint64(2), stats[origin].DroppedSamples)
+}
+
+func TestOriginLimiterExpiry(t *testing.T) {
+	l := newOriginLimiter(1, 0)
+	now := time.Now()
+
+	l.allowPacket("container_id://a", now)
+	l.allowPacket("container_id://a", now)
+	require.Len(t, l.stats(), 1)
+
+	l.allowPacket("container_id://b", now.Add(originQuotaExpiry+time.Second))
+	assert.Len(t, l.stats(), 0)
+	origins := 0
+	for i := range l.shards {
+		origins += len(l.shards[i].origins)
+	}
+	assert.Equal(t, 1, origins)
+}
@@ -54,7 +54,7 @@ func (p *Pool) Get() interface{} {
 	return p.pool.Get()
 }
 
-// Put resets the Packet origin and puts it back in the pool.
+// Put resets the Packet origin and tags and puts it back in the pool.
 func (p *Pool) Put(x interface{}) {
 	if x == nil {
 		return
@@ -65,6 +65,9 @@ func (p *Pool) Put(x interface{}) {
 	if ok && packet.Origin != NoOrigin {
 		packet.Origin = NoOrigin
 	}
+	if ok && packet.Tags != nil {
+		packet.Tags = nil
+	}
 	if p.tlmEnabled {
 		tlmPoolPut.Inc()
 		tlmPool.Dec()
@@ -15,6 +15,10 @@ const (
 	UDS
 	// NamedPipe Windows named pipe listner
 	NamedPipe
+	// TCP listener
+	TCP
+	// UDSStream listener
+	UDSStream
 )
 
 // Packet represents a statsd packet ready to process,
@@ -28,6 +32,7 @@ type Packet struct {
 	Buffer   []byte     // Underlying buffer for data read
 	Origin   string     // Origin container if identified
 	Source   SourceType // Type of listener that produced the packet
+	Tags     []string   // Tags added to the messages of the packet, shared by the packets of a client
 }
 
 // Packets is a slice of packet pointers
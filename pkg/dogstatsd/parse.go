@@ -31,14 +31,19 @@ var (
 type parser struct {
 	interner    *stringInterner
 	float64List *float64ListPool
+
+	// readTimestamps is true if the parser has to read timestamps from messages.
+	readTimestamps bool
 }
 
 func newParser(float64List *float64ListPool) *parser {
 	stringInternerCacheSize := config.Datadog.GetInt("dogstatsd_string_interner_size")
+	readTimestamps := config.Datadog.GetBool("dogstatsd_no_aggregation_pipeline")
 
 	return &parser{
-		interner:    newStringInterner(stringInternerCacheSize),
-		float64List: float64List,
+		interner:       newStringInterner(stringInternerCacheSize),
+		float64List:    float64List,
+		readTimestamps: readTimestamps,
 	}
 }
 
@@ -127,6 +132,7 @@ func (p *parser) parseMetricSample(message []byte) (dogstatsdMetricSample, error
 
 	sampleRate := 1.0
 	var tags []string
+	var timestamp int64
 	var optionalField []byte
 	for message != nil {
 		optionalField, message = nextField(message)
@@ -137,6 +143,11 @@ func (p *parser) parseMetricSample(message []byte) (dogstatsdMetricSample, error
 			if err != nil {
 				return dogstatsdMetricSample{}, fmt.Errorf("could not parse dogstatsd sample rate %q", optionalField)
 			}
+		} else if p.readTimestamps && bytes.HasPrefix(optionalField, timestampFieldPrefix) {
+			timestamp, err = parseMetricSampleTimestamp(optionalField[1:])
+			if err != nil {
+				return dogstatsdMetricSample{}, fmt.Errorf("could not parse dogstatsd timestamp %q: %v", optionalField, err)
+			}
 		}
 	}
 
@@ -148,6 +159,7 @@ func (p *parser) parseMetricSample(message []byte) (dogstatsdMetricSample, error
 		metricType: metricType,
 		sampleRate: sampleRate,
 		tags:       tags,
+		timestamp:  timestamp,
 	}, nil
 }
 
@@ -301,3 +301,48 @@ func TestParseMetricError(t *testing.T) {
 	_, err = parseMetricSample([]byte("daemon:666|g|@abc"))
 	assert.Error(t, err)
 }
+
+func TestParseGaugeWithTimestamp(t *testing.T) {
+	sample, err := parseMetricSample([]byte("metric:1234|g|#onetag|T1657100430"))
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "metric", sample.name)
+	assert.InEpsilon(t, 1234.0, sample.value, epsilon)
+	assert.Equal(t, gaugeType, sample.metricType)
+	require.Equal(t, 1, len(sample.tags))
+	assert.Equal(t, "onetag", sample.tags[0])
+	assert.Equal(t, int64(1657100430), sample.timestamp)
+}
+
+func TestParseCountWithSampleRateAndTimestamp(t *testing.T) {
+	sample, err := parseMetricSample([]byte("metric:1234|c|@0.21|#onetag|T1657100430"))
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, "metric", sample.name)
+	assert.Equal(t, countType, sample.metricType)
+	assert.InEpsilon(t, 0.21, sample.sampleRate, epsilon)
+	assert.Equal(t, int64(1657100430), sample.timestamp)
+}
+
+func TestParseTimestampError(t *testing.T) {
+	_, err := parseMetricSample([]byte("metric:1234|g|#onetag|Tabc"))
+	assert.Error(t, err)
+
+	_, err = parseMetricSample([]byte("metric:1234|g|#onetag|T-1000"))
+	assert.Error(t, err)
+
+	_, err = parseMetricSample([]byte("metric:1234|g|#onetag|T0"))
+	assert.Error(t, err)
+}
+
+func TestParseTimestampIgnoredWhenPipelineDisabled(t *testing.T) {
+	parser := newParser(newFloat64ListPool())
+	parser.readTimestamps = false
+
+	sample, err := parser.parseMetricSample([]byte("metric:1234|g|#onetag|T1657100430"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), sample.timestamp)
+}
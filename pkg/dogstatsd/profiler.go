@@ -0,0 +1,329 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package dogstatsd
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+	"github.com/DataDog/datadog-agent/pkg/tagset"
+)
+
+const (
+	// profilerBuckets is the number of buckets the sliding window of the profiler is split into
+	profilerBuckets = 6
+	// profilerDefaultTopEntries is the number of entries reported when none is requested
+	profilerDefaultTopEntries = 10
+)
+
+var (
+	dogstatsdProfilerExpvars = expvar.NewMap("dogstatsd-profiler")
+	profilerUntrackedSamples = expvar.Int{}
+	profilerTrackedContexts  = expvar.Int{}
+)
+
+func init() {
+	dogstatsdProfilerExpvars.Set("UntrackedSamples", &profilerUntrackedSamples)
+	dogstatsdProfilerExpvars.Set("TrackedContexts", &profilerTrackedContexts)
+}
+
+// profiledContext holds the traffic of a context in a bucket of the profiler
+type profiledContext struct {
+	name    string
+	origin  string
+	tags    []string
+	samples uint64
+}
+
+// profilerBucket holds the contexts received during a slice of the window
+type profilerBucket struct {
+	start    time.Time
+	contexts map[ckey.ContextKey]*profiledContext
+	// untracked counts the samples of the new contexts received once maxContexts was reached
+	untracked uint64
+}
+
+// trafficProfiler keeps track of the contexts received by the server over a sliding
+// window, to report the metrics, the origins and the tag keys responsible for most of
+// the volume and of the cardinality. It is shared by the workers of the server.
+type trafficProfiler struct {
+	sync.Mutex
+	window      time.Duration
+	bucketWidth time.Duration
+	maxContexts int
+	buckets     [profilerBuckets]profilerBucket
+
+	keyGen          *ckey.KeyGenerator
+	tagsAccumulator *tagset.HashingTagsAccumulator
+}
+
+// newTrafficProfiler returns a trafficProfiler reporting on the given window, and tracking
+// at most maxContexts contexts per slice of the window (0 means no limit).
+func newTrafficProfiler(window time.Duration, maxContexts int) *trafficProfiler {
+	if window < profilerBuckets*time.Second {
+		window = profilerBuckets * time.Second
+	}
+	return &trafficProfiler{
+		window:          window,
+		bucketWidth:     window / profilerBuckets,
+		maxContexts:     maxContexts,
+		keyGen:          ckey.NewKeyGenerator(),
+		tagsAccumulator: tagset.NewHashingTagsAccumulator(),
+	}
+}
+
+// newTrafficProfilerFromConfig returns the trafficProfiler configured with `dogstatsd_profiler`,
+// or nil if it is disabled.
+func newTrafficProfilerFromConfig() *trafficProfiler {
+	if !config.Datadog.GetBool("dogstatsd_profiler.enabled") {
+		return nil
+	}
+	return newTrafficProfiler(
+		time.Duration(config.Datadog.GetInt("dogstatsd_profiler.window"))*time.Second,
+		config.Datadog.GetInt("dogstatsd_profiler.max_contexts"),
+	)
+}
+
+// bucket returns the bucket of the given time, resetting it if it holds an older slice.
+// Must be called with the lock held.
+func (p *trafficProfiler) bucket(now time.Time) *profilerBucket {
+	start := now.Truncate(p.bucketWidth)
+	b := &p.buckets[(start.UnixNano()/int64(p.bucketWidth))%profilerBuckets]
+	if !b.start.Equal(start) {
+		profilerTrackedContexts.Add(-int64(len(b.contexts)))
+		b.start = start
+		b.contexts = make(map[ckey.ContextKey]*profiledContext, len(b.contexts))
+		b.untracked = 0
+	}
+	return b
+}
+
+// track records a metric sample received from the given origin
+func (p *trafficProfiler) track(sample *metrics.MetricSample, origin string, now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.tagsAccumulator.Append(sample.Tags...)
+	key := p.keyGen.Generate(sample.Name, sample.Host, p.tagsAccumulator)
+	p.tagsAccumulator.Reset()
+
+	b := p.bucket(now)
+	if ctx, found := b.contexts[key]; found {
+		ctx.samples++
+		return
+	}
+	if p.maxContexts > 0 && len(b.contexts) >= p.maxContexts {
+		b.untracked++
+		profilerUntrackedSamples.Add(1)
+		return
+	}
+
+	b.contexts[key] = &profiledContext{
+		name:    sample.Name,
+		origin:  origin,
+		tags:    append([]string(nil), sample.Tags...), // the parser reuses the tags array
+		samples: 1,
+	}
+	profilerTrackedContexts.Add(1)
+}
+
+// profileEntry is the traffic of a metric name or of an origin in a trafficProfile
+type profileEntry struct {
+	Name     string `json:"name"`
+	Samples  uint64 `json:"samples"`
+	Contexts int    `json:"contexts"`
+}
+
+// tagKeyEntry is the cardinality brought by a tag key in a trafficProfile
+type tagKeyEntry struct {
+	Key      string `json:"key"`
+	Values   int    `json:"values"`
+	Contexts int    `json:"contexts"`
+}
+
+// trafficProfile is the payload of the dogstatsd-top command
+type trafficProfile struct {
+	WindowSeconds        float64        `json:"window_seconds"`
+	Samples              uint64         `json:"samples"`
+	Contexts             int            `json:"contexts"`
+	UntrackedSamples     uint64         `json:"untracked_samples"`
+	TopMetricsBySamples  []profileEntry `json:"top_metrics_by_samples"`
+	TopMetricsByContexts []profileEntry `json:"top_metrics_by_contexts"`
+	TopOrigins           []profileEntry `json:"top_origins"`
+	TopTagKeys           []tagKeyEntry  `json:"top_tag_keys"`
+}
+
+// addContext adds a context with the given number of samples to the entry of the given name
+func addContext(entries map[string]*profileEntry, name string, samples uint64) {
+	e, found := entries[name]
+	if !found {
+		e = &profileEntry{Name: name}
+		entries[name] = e
+	}
+	e.Samples += samples
+	e.Contexts++
+}
+
+// topEntries returns the first n entries of the given map sorted with less
+func topEntries(entries map[string]*profileEntry, n int, less func(a, b *profileEntry) bool) []profileEntry {
+	sorted := make([]*profileEntry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if less(sorted[i], sorted[j]) || less(sorted[j], sorted[i]) {
+			return less(sorted[i], sorted[j])
+		}
+		return sorted[i].Name < sorted[j].Name
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+
+	top := make([]profileEntry, 0, len(sorted))
+	for _, e := range sorted {
+		top = append(top, *e)
+	}
+	return top
+}
+
+// profile returns the top n metric names, origins and tag keys of the window ending at now
+func (p *trafficProfiler) profile(now time.Time, n int) trafficProfile {
+	p.Lock()
+	defer p.Unlock()
+
+	if n <= 0 {
+		n = profilerDefaultTopEntries
+	}
+	profile := trafficProfile{WindowSeconds: p.window.Seconds()}
+
+	// merge the contexts of the buckets of the window
+	contexts := make(map[ckey.ContextKey]*profiledContext)
+	for i := range p.buckets {
+		b := &p.buckets[i]
+		if !b.start.After(now.Add(-p.window)) || b.start.After(now) {
+			continue
+		}
+		profile.UntrackedSamples += b.untracked
+		for key, ctx := range b.contexts {
+			if merged, found := contexts[key]; found {
+				merged.samples += ctx.samples
+				continue
+			}
+			c := *ctx
+			contexts[key] = &c
+		}
+	}
+
+	names := make(map[string]*profileEntry)
+	origins := make(map[string]*profileEntry)
+	tagValues := make(map[string]map[string]struct{})
+	tagContexts := make(map[string]int)
+	for _, ctx := range contexts {
+		profile.Samples += ctx.samples
+
+		addContext(names, ctx.name, ctx.samples)
+		addContext(origins, ctx.origin, ctx.samples)
+
+		for _, tag := range ctx.tags {
+			key, value := tag, ""
+			if i := strings.IndexByte(tag, ':'); i >= 0 {
+				key, value = tag[:i], tag[i+1:]
+			}
+			if tagValues[key] == nil {
+				tagValues[key] = make(map[string]struct{})
+			}
+			tagValues[key][value] = struct{}{}
+			tagContexts[key]++
+		}
+	}
+	profile.Contexts = len(contexts)
+
+	profile.TopMetricsBySamples = topEntries(names, n, func(a, b *profileEntry) bool { return a.Samples > b.Samples })
+	profile.TopMetricsByContexts = topEntries(names, n, func(a, b *profileEntry) bool { return a.Contexts > b.Contexts })
+	profile.TopOrigins = topEntries(origins, n, func(a, b *profileEntry) bool {
+		if a.Contexts != b.Contexts {
+			return a.Contexts > b.Contexts
+		}
+		return a.Samples > b.Samples
+	})
+
+	profile.TopTagKeys = make([]tagKeyEntry, 0, len(tagValues))
+	for key, values := range tagValues {
+		profile.TopTagKeys = append(profile.TopTagKeys, tagKeyEntry{Key: key, Values: len(values), Contexts: tagContexts[key]})
+	}
+	sort.Slice(profile.TopTagKeys, func(i, j int) bool {
+		ki, kj := profile.TopTagKeys[i], profile.TopTagKeys[j]
+		if ki.Values != kj.Values {
+			return ki.Values > kj.Values
+		}
+		return ki.Key < kj.Key
+	})
+	if len(profile.TopTagKeys) > n {
+		profile.TopTagKeys = profile.TopTagKeys[:n]
+	}
+
+	return profile
+}
+
+// GetJSONTrafficProfile returns the jsonified top n metric names, origins and tag keys
+// received by the server over the window of the profiler. It returns an error if the
+// profiler is disabled.
+func (s *Server) GetJSONTrafficProfile(n int) ([]byte, error) {
+	if s.profiler == nil {
+		return nil, fmt.Errorf("the dogstatsd profiler is not enabled")
+	}
+	return json.Marshal(s.profiler.profile(time.Now(), n))
+}
+
+// FormatTrafficProfile returns a printable version of a traffic profile.
+func FormatTrafficProfile(data []byte) (string, error) {
+	var profile trafficProfile
+	if err := json.Unmarshal(data, &profile); err != nil {
+		return "", err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	fmt.Fprintf(buf, "Over the last %v: %d samples, %d contexts", time.Duration(profile.WindowSeconds*float64(time.Second)), profile.Samples, profile.Contexts)
+	if profile.UntrackedSamples > 0 {
+		fmt.Fprintf(buf, " (%d samples of untracked contexts)", profile.UntrackedSamples)
+	}
+	buf.WriteString("\n")
+
+	writeEntries := func(title string, entries []profileEntry) {
+		header := fmt.Sprintf("%-60s | %-12s | %-12s\n", title, "Samples", "Contexts")
+		buf.WriteString("\n" + header)
+		buf.WriteString(strings.Repeat("-", len(header)) + "\n")
+		for _, e := range entries {
+			name := e.Name
+			if name == "" {
+				name = "<unknown>"
+			}
+			fmt.Fprintf(buf, "%-60s | %-12d | %-12d\n", name, e.Samples, e.Contexts)
+		}
+	}
+	writeEntries("Top metrics by samples", profile.TopMetricsBySamples)
+	writeEntries("Top metrics by contexts", profile.TopMetricsByContexts)
+	writeEntries("Top origins", profile.TopOrigins)
+
+	header := fmt.Sprintf("%-60s | %-12s | %-12s\n", "Top tag keys by values", "Values", "Contexts")
+	buf.WriteString("\n" + header)
+	buf.WriteString(strings.Repeat("-", len(header)) + "\n")
+	for _, k := range profile.TopTagKeys {
+		fmt.Fprintf(buf, "%-60s | %-12d | %-12d\n", k.Key, k.Values, k.Contexts)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,109 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package dogstatsd
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/metrics"
+)
+
+func TestTrafficProfilerTop(t *testing.T) {
+	p := newTrafficProfiler(60*time.Second, 0)
+	now := time.Now()
+
+	for i := 0; i < 10; i++ {
+		p.track(&metrics.MetricSample{Name: "noisy", Tags: []string{"env:prod"}}, "container_id://a", now)
+	}
+	for _, user := range []string{"1", "2", "3"} {
+		p.track(&metrics.MetricSample{Name: "cardinal", Tags: []string{"env:prod", "user:" + user}}, "container_id://b", now)
+	}
+	p.track(&metrics.MetricSample{Name: "quiet"}, "", now)
+
+	profile := p.profile(now, 2)
+	assert.Equal(t, 60.0, profile.WindowSeconds)
+	assert.Equal(t, uint64(14), profile.Samples)
+	assert.Equal(t, 5, profile.Contexts)
+
+	assert.Equal(t, []profileEntry{
+		{Name: "noisy", Samples: 10, Contexts: 1},
+		{Name: "cardinal", Samples: 3, Contexts: 3},
+	}, profile.TopMetricsBySamples)
+	assert.Equal(t, []profileEntry{
+		{Name: "cardinal", Samples: 3, Contexts: 3},
+		{Name: "noisy", Samples: 10, Contexts: 1},
+	}, profile.TopMetricsByContexts)
+	assert.Equal(t, []profileEntry{
+		{Name: "container_id://b", Samples: 3, Contexts: 3},
+		{Name: "container_id://a", Samples: 10, Contexts: 1},
+	}, profile.TopOrigins)
+	assert.Equal(t, []tagKeyEntry{
+		{Key: "user", Values: 3, Contexts: 3},
+		{Key: "env", Values: 1, Contexts: 4},
+	}, profile.TopTagKeys)
+}
+
+func TestTrafficProfilerWindow(t *testing.T) {
+	p := newTrafficProfiler(60*time.Second, 0)
+	start := time.Now().Truncate(10 * time.Second)
+
+	p.track(&metrics.MetricSample{Name: "old"}, "", start)
+	p.track(&metrics.MetricSample{Name: "recent"}, "", start.Add(30*time.Second))
+	p.track(&metrics.MetricSample{Name: "recent"}, "", start.Add(55*time.Second))
+
+	profile := p.profile(start.Add(59*time.Second), 0)
+	assert.Equal(t, uint64(3), profile.Samples)
+	assert.Equal(t, 2, profile.Contexts)
+
+	// the first bucket slid out of the window
+	profile = p.profile(start.Add(61*time.Second), 0)
+	assert.Equal(t, uint64(2), profile.Samples)
+	assert.Equal(t, []profileEntry{{Name: "recent", Samples: 2, Contexts: 1}}, profile.TopMetricsBySamples)
+
+	// the bucket is reused once the ring wrapped around
+	p.track(&metrics.MetricSample{Name: "new"}, "", start.Add(62*time.Second))
+	profile = p.profile(start.Add(62*time.Second), 0)
+	assert.Equal(t, uint64(3), profile.Samples)
+	assert.Equal(t, 2, profile.Contexts)
+}
+
+func TestTrafficProfilerMaxContexts(t *testing.T) {
+	p := newTrafficProfiler(60*time.Second, 2)
+	now := time.Now()
+
+	for _, name := range []string{"a", "b", "c", "a", "d"} {
+		p.track(&metrics.MetricSample{Name: name}, "", now)
+	}
+
+	profile := p.profile(now, 0)
+	assert.Equal(t, uint64(3), profile.Samples)
+	assert.Equal(t, 2, profile.Contexts)
+	assert.Equal(t, uint64(2), profile.UntrackedSamples)
+}
+
+func TestFormatTrafficProfile(t *testing.T) {
+	p := newTrafficProfiler(60*time.Second, 0)
+	now := time.Now()
+	p.track(&metrics.MetricSample{Name: "custom.metric", Tags: []string{"user:1"}}, "container_id://a", now)
+
+	data, err := json.Marshal(p.profile(now, 0))
+	require.NoError(t, err)
+
+	out, err := FormatTrafficProfile(data)
+	require.NoError(t, err)
+	assert.Contains(t, out, "Over the last 1m0s: 1 samples, 1 contexts\n")
+	assert.Contains(t, out, "custom.metric")
+	assert.Contains(t, out, "container_id://a")
+	assert.Contains(t, out, "Top tag keys by values")
+
+	_, err = FormatTrafficProfile([]byte("{"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,57 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo"
+)
+
+// ExportText writes the messages of the capture selected by the filter as plain-text
+// statsd lines, one message per line, so that they can be sent again by any statsd client.
+// It returns the number of messages written. The filter can be nil.
+func (tc *TrafficCaptureReader) ExportText(w io.Writer, filter *Filter) (int, error) {
+	tc.Seek(0)
+
+	bw := bufio.NewWriter(w)
+	count := 0
+	for {
+		msg, err := tc.ReadNext()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return count, err
+		}
+
+		for _, message := range bytes.Split(filter.Apply(msg), []byte("\n")) {
+			if len(message) == 0 {
+				continue
+			}
+			bw.Write(message) //nolint:errcheck
+			if err := bw.WriteByte('\n'); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, bw.Flush()
+}
+
+// ExportState writes the tagger state and the pid map stored in the capture as JSON.
+func (tc *TrafficCaptureReader) ExportState(w io.Writer) error {
+	pidMap, state, err := tc.ReadState()
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(&pb.TaggerState{State: state, PidMap: pidMap})
+}
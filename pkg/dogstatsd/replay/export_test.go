@@ -0,0 +1,55 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo"
+)
+
+func TestExportText(t *testing.T) {
+	tc, err := NewTrafficCaptureReader("resources/test/datadog-capture.dog", 1)
+	require.NoError(t, err)
+	defer tc.Close()
+
+	var buf bytes.Buffer
+	count, err := tc.ExportText(&buf, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 21, count)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	require.Len(t, lines, 21)
+	assert.Equal(t, "jaime.uds.test:8|g|#shell:test", lines[0])
+
+	// the export can be repeated, with a filter
+	filter, err := NewFilter([]string{"other.*"}, nil, nil)
+	require.NoError(t, err)
+	buf.Reset()
+	count, err = tc.ExportText(&buf, filter)
+	require.NoError(t, err)
+	assert.Equal(t, 0, count)
+	assert.Empty(t, buf.String())
+}
+
+func TestExportState(t *testing.T) {
+	tc, err := NewTrafficCaptureReader("resources/test/datadog-capture.dog.zstd", 1)
+	require.NoError(t, err)
+	defer tc.Close()
+
+	var buf bytes.Buffer
+	require.NoError(t, tc.ExportState(&buf))
+
+	var state pb.TaggerState
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &state))
+	assert.NotEmpty(t, state.PidMap)
+	assert.Contains(t, state.State, "container_id://c1371eaf97a11f43ac700fd8524b4ea316d83a7259282a9e9eeac8d071406b22")
+}
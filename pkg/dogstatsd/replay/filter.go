@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replay
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo"
+)
+
+const containerIDPrefix = "container_id://"
+
+// Filter selects the messages of a capture to replay or to export.
+type Filter struct {
+	// metrics are glob patterns matching the names of the metrics to keep, the events
+	// and service checks are dropped when set
+	metrics []string
+	// origins holds the container IDs and the PIDs of the packets to keep
+	origins map[string]struct{}
+	pidMap  map[int32]string
+}
+
+// NewFilter returns a Filter keeping the metrics matching one of the given glob patterns,
+// sent by one of the given origins. An origin is a container ID, optionally prefixed by
+// `container_id://`, or the PID of the client. The container of a PID is found in the
+// pidMap stored in the capture. It returns nil if there is nothing to filter.
+func NewFilter(metrics []string, origins []string, pidMap map[int32]string) (*Filter, error) {
+	if len(metrics) == 0 && len(origins) == 0 {
+		return nil, nil
+	}
+
+	for _, pattern := range metrics {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid metric name pattern '%s': %v", pattern, err)
+		}
+	}
+
+	f := &Filter{metrics: metrics, pidMap: pidMap}
+	if len(origins) > 0 {
+		f.origins = make(map[string]struct{}, len(origins))
+		for _, origin := range origins {
+			f.origins[strings.TrimPrefix(origin, containerIDPrefix)] = struct{}{}
+		}
+	}
+	return f, nil
+}
+
+func (f *Filter) matchOrigin(pid int32) bool {
+	if f.origins == nil {
+		return true
+	}
+	if _, found := f.origins[strconv.Itoa(int(pid))]; found {
+		return true
+	}
+	if containerID, found := f.pidMap[pid]; found {
+		_, found = f.origins[strings.TrimPrefix(containerID, containerIDPrefix)]
+		return found
+	}
+	return false
+}
+
+func (f *Filter) matchMessage(message []byte) bool {
+	if len(f.metrics) == 0 {
+		return true
+	}
+	if bytes.HasPrefix(message, []byte("_e{")) || bytes.HasPrefix(message, []byte("_sc|")) {
+		return false
+	}
+
+	name := message
+	if i := bytes.IndexByte(message, ':'); i >= 0 {
+		name = message[:i]
+	}
+	for _, pattern := range f.metrics {
+		if matched, _ := path.Match(pattern, string(name)); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply returns the messages of the packet selected by the filter, or nil if there is none.
+// The payload of the packet is returned as is when no metric name pattern is set.
+func (f *Filter) Apply(msg *pb.UnixDogstatsdMsg) []byte {
+	payload := msg.Payload[:msg.PayloadSize]
+	if f == nil {
+		return payload
+	}
+	if !f.matchOrigin(msg.Pid) {
+		return nil
+	}
+	if len(f.metrics) == 0 {
+		return payload
+	}
+
+	var filtered []byte
+	kept := 0
+	for _, message := range bytes.Split(payload, []byte("\n")) {
+		if len(message) == 0 || !f.matchMessage(message) {
+			continue
+		}
+		if kept > 0 {
+			filtered = append(filtered, '\n')
+		}
+		filtered = append(filtered, message...)
+		kept++
+	}
+
+	if kept == 0 {
+		return nil
+	}
+	if bytes.HasSuffix(payload, []byte("\n")) {
+		filtered = append(filtered, '\n')
+	}
+	return filtered
+}
@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package replay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	pb "github.com/DataDog/datadog-agent/pkg/proto/pbgo"
+)
+
+func newTestMsg(payload string, pid int32) *pb.UnixDogstatsdMsg {
+	return &pb.UnixDogstatsdMsg{Payload: []byte(payload), PayloadSize: int32(len(payload)), Pid: pid}
+}
+
+func TestFilterDisabled(t *testing.T) {
+	f, err := NewFilter(nil, nil, nil)
+	require.NoError(t, err)
+	assert.Nil(t, f)
+	assert.Equal(t, []byte("a:1|c"), f.Apply(newTestMsg("a:1|c", 1)))
+
+	_, err = NewFilter([]string{"[a-"}, nil, nil)
+	assert.Error(t, err)
+}
+
+func TestFilterMetrics(t *testing.T) {
+	f, err := NewFilter([]string{"app.*", "db.?"}, nil, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte("app.requests:1|c\n"), f.Apply(newTestMsg("app.requests:1|c\n", 1)))
+	assert.Equal(t, []byte("app.requests:1|c\ndb.1:2|g"), f.Apply(newTestMsg("app.requests:1|c\nother:1|c\ndb.1:2|g\ndb.10:2|g", 1)))
+	assert.Nil(t, f.Apply(newTestMsg("other:1|c\n_e{5,4}:title|text\n_sc|app.check|0\n", 1)))
+}
+
+func TestFilterOrigins(t *testing.T) {
+	pidMap := map[int32]string{
+		10: "container_id://abc",
+		11: "container_id://def",
+	}
+	f, err := NewFilter(nil, []string{"container_id://abc", "12"}, pidMap)
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte("a:1|c"), f.Apply(newTestMsg("a:1|c", 10)))
+	assert.Nil(t, f.Apply(newTestMsg("a:1|c", 11)))
+	assert.Equal(t, []byte("a:1|c"), f.Apply(newTestMsg("a:1|c", 12)))
+	assert.Nil(t, f.Apply(newTestMsg("a:1|c", 13)))
+
+	// the container ID can be given without prefix
+	f, err = NewFilter([]string{"a"}, []string{"def"}, pidMap)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a:1|c"), f.Apply(newTestMsg("a:1|c\nb:1|c", 11)))
+	assert.Nil(t, f.Apply(newTestMsg("a:1|c", 10)))
+}
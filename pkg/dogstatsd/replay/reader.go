@@ -30,6 +30,7 @@ type TrafficCaptureReader struct {
 	Done         chan struct{}
 	fuse         chan struct{}
 	offset       uint32
+	speed        float64
 
 	sync.Mutex
 }
@@ -75,9 +76,20 @@ func NewTrafficCaptureReader(path string, depth int) (*TrafficCaptureReader, err
 		Contents:     contents,
 		Version:      ver,
 		Traffic:      make(chan *pb.UnixDogstatsdMsg, depth),
+		speed:        1,
 	}, nil
 }
 
+// SetSpeed sets the pace of the replay relative to the capture: a speed of 2 replays
+// the traffic twice as fast as it was captured, a speed of 0 replays it as fast as possible.
+// It applies to the next calls to Read.
+func (tc *TrafficCaptureReader) SetSpeed(speed float64) {
+	tc.Lock()
+	defer tc.Unlock()
+
+	tc.speed = speed
+}
+
 // Read reads the contents of the traffic capture and writes each packet to a channel
 func (tc *TrafficCaptureReader) Read(ready chan struct{}) {
 	tc.Lock()
@@ -96,6 +108,7 @@ func (tc *TrafficCaptureReader) Read(ready chan struct{}) {
 	} else {
 		tsResolution = time.Nanosecond
 	}
+	speed := tc.speed
 	tc.Unlock()
 
 	last := int64(0)
@@ -116,9 +129,9 @@ func (tc *TrafficCaptureReader) Read(ready chan struct{}) {
 			break
 		}
 
-		if last != 0 {
+		if last != 0 && speed > 0 {
 			if msg.Timestamp > last {
-				util.Wait(tsResolution * time.Duration(msg.Timestamp-last))
+				util.Wait(time.Duration(float64(tsResolution*time.Duration(msg.Timestamp-last)) / speed))
 			}
 		}
 
@@ -223,7 +236,6 @@ func (tc *TrafficCaptureReader) ReadState() (map[int32]string, map[string]*pb.En
 	pbState := &pb.TaggerState{}
 	err := proto.Unmarshal(tc.Contents[length-int(sz)-4:length-4], pbState)
 	if err != nil {
-		tc.Unlock()
 		return nil, nil, err
 	}
 
@@ -8,6 +8,7 @@ package replay
 import (
 	"io"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -83,3 +84,34 @@ func TestSeek(t *testing.T) {
 	assert.Equal(t, cnt*i, total)
 
 }
+
+func TestReadAsFastAsPossible(t *testing.T) {
+	tc, err := NewTrafficCaptureReader("resources/test/datadog-capture.dog", 1)
+	assert.Nil(t, err)
+	defer tc.Close()
+	tc.SetSpeed(0)
+
+	ready := make(chan struct{})
+	go tc.Read(ready)
+	<-ready
+
+	cnt := 0
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case <-tc.Traffic:
+			cnt++
+			continue
+		case <-tc.Done:
+		case <-timeout:
+			assert.FailNow(t, "the capture should be replayed without waiting")
+		}
+		break
+	}
+	// drain the messages sent before Done was closed
+	for len(tc.Traffic) > 0 {
+		<-tc.Traffic
+		cnt++
+	}
+	assert.Equal(t, 21, cnt)
+}
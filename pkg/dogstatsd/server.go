@@ -45,12 +45,16 @@ var (
 	dogstatsdMetricPackets            = expvar.Int{}
 	dogstatsdPacketsLastSec           = expvar.Int{}
 	dogstatsdUnterminatedMetricErrors = expvar.Int{}
+	dogstatsdMetricDroppedByMapper    = expvar.Int{}
 
 	tlmProcessed = telemetry.NewCounter("dogstatsd", "processed",
 		[]string{"message_type", "state", "origin"}, "Count of service checks/events/metrics processed by dogstatsd")
 	tlmProcessedOk    = tlmProcessed.WithValues("metrics", "ok", "")
 	tlmProcessedError = tlmProcessed.WithValues("metrics", "error", "")
 
+	tlmMapperDropped = telemetry.NewSimpleCounter("dogstatsd", "mapper_dropped",
+		"Count of metrics dropped by a dogstatsd mapper profile")
+
 	// while we try to add the origin tag in the tlmProcessed metric, we want to
 	// avoid having it growing indefinitely, hence this safeguard to limit the
 	// size of this cache for long-running agent or environment with a lot of
@@ -70,6 +74,7 @@ func init() {
 	dogstatsdExpvars.Set("MetricParseErrors", &dogstatsdMetricParseErrors)
 	dogstatsdExpvars.Set("MetricPackets", &dogstatsdMetricPackets)
 	dogstatsdExpvars.Set("UnterminatedMetricErrors", &dogstatsdUnterminatedMetricErrors)
+	dogstatsdExpvars.Set("MetricDroppedByMapper", &dogstatsdMetricDroppedByMapper)
 }
 
 // used in debug mode to add the origin on the processed metric as a tag
@@ -145,9 +150,12 @@ type Server struct {
 	debugTagsAccumulator      *tagset.HashingTagsAccumulator
 	TCapture                  *replay.TrafficCapture
 	mapper                    *mapper.MetricMapper
+	originLimiter             *originLimiter
+	profiler                  *trafficProfiler
 	eolTerminationUDP         bool
 	eolTerminationUDS         bool
 	eolTerminationNamedPipe   bool
+	eolTerminationTCP         bool
 	telemetryEnabled          bool
 	entityIDPrecedenceEnabled bool
 	// disableVerboseLogs is a feature flag to disable the logs capable
@@ -243,6 +251,15 @@ func NewServer(aggregator *aggregator.BufferedAggregator, extraTags []string) (*
 			udsListenerRunning = true
 		}
 	}
+	streamSocketPath := config.Datadog.GetString("dogstatsd_stream_socket")
+	if len(streamSocketPath) > 0 {
+		unixStreamListener, err := listeners.NewUDSStreamListener(packetsChannel, sharedPacketPoolManager, capture)
+		if err != nil {
+			log.Errorf(err.Error())
+		} else {
+			tmpListeners = append(tmpListeners, unixStreamListener)
+		}
+	}
 	if config.Datadog.GetInt("dogstatsd_port") > 0 {
 		udpListener, err := listeners.NewUDPListener(packetsChannel, sharedPacketPoolManager, capture)
 		if err != nil {
@@ -252,6 +269,15 @@ func NewServer(aggregator *aggregator.BufferedAggregator, extraTags []string) (*
 		}
 	}
 
+	if config.Datadog.GetInt("dogstatsd_tcp_port") > 0 {
+		tcpListener, err := listeners.NewTCPListener(packetsChannel, sharedPacketPoolManager, capture)
+		if err != nil {
+			log.Errorf(err.Error())
+		} else {
+			tmpListeners = append(tmpListeners, tcpListener)
+		}
+	}
+
 	pipeName := config.Datadog.GetString("dogstatsd_pipe_name")
 	if len(pipeName) > 0 {
 		namedPipeListener, err := listeners.NewNamedPipeListener(pipeName, packetsChannel, sharedPacketPoolManager, capture)
@@ -292,6 +318,7 @@ func NewServer(aggregator *aggregator.BufferedAggregator, extraTags []string) (*
 	eolTerminationUDP := false
 	eolTerminationUDS := false
 	eolTerminationNamedPipe := false
+	eolTerminationTCP := false
 
 	for _, v := range config.Datadog.GetStringSlice("dogstatsd_eol_required") {
 		switch v {
@@ -301,6 +328,8 @@ func NewServer(aggregator *aggregator.BufferedAggregator, extraTags []string) (*
 			eolTerminationUDS = true
 		case "named_pipe":
 			eolTerminationNamedPipe = true
+		case "tcp":
+			eolTerminationTCP = true
 		default:
 			log.Errorf("Invalid dogstatsd_eol_required value: %s", v)
 		}
@@ -327,9 +356,12 @@ func NewServer(aggregator *aggregator.BufferedAggregator, extraTags []string) (*
 		eolTerminationUDP:         eolTerminationUDP,
 		eolTerminationUDS:         eolTerminationUDS,
 		eolTerminationNamedPipe:   eolTerminationNamedPipe,
+		eolTerminationTCP:         eolTerminationTCP,
 		telemetryEnabled:          telemetry_utils.IsEnabled(),
 		entityIDPrecedenceEnabled: entityIDPrecedenceEnabled,
 		disableVerboseLogs:        config.Datadog.GetBool("dogstatsd_disable_verbose_logs"),
+		originLimiter:             newOriginLimiterFromConfig(),
+		profiler:                  newTrafficProfilerFromConfig(),
 		Debug: &dsdServerDebug{
 			Stats: make(map[ckey.ContextKey]metricStat),
 			metricsCounts: metricsCountBuckets{
@@ -503,6 +535,8 @@ func (s *Server) eolEnabled(sourceType packets.SourceType) bool {
 		return s.eolTerminationUDP
 	case packets.NamedPipe:
 		return s.eolTerminationNamedPipe
+	case packets.TCP:
+		return s.eolTerminationTCP
 	}
 	return false
 }
@@ -510,6 +544,11 @@ func (s *Server) eolEnabled(sourceType packets.SourceType) bool {
 func (s *Server) parsePackets(batcher *batcher, parser *parser, packets []*packets.Packet, samples []metrics.MetricSample) []metrics.MetricSample {
 	for _, packet := range packets {
 		log.Tracef("Dogstatsd receive: %q", packet.Contents)
+		if s.originLimiter != nil && !s.originLimiter.allowPacket(packet.Origin, time.Now()) {
+			log.Tracef("Dogstatsd: packet from %q dropped, the origin is over its packets quota", packet.Origin)
+			s.sharedPacketPoolManager.Put(packet)
+			continue
+		}
 		for {
 			message := nextMessage(&packet.Contents, s.eolEnabled(packet.Source))
 			if message == nil {
@@ -530,6 +569,7 @@ func (s *Server) parsePackets(batcher *batcher, parser *parser, packets []*packe
 					s.errLog("Dogstatsd: error parsing service check '%q': %s", message, err)
 					continue
 				}
+				serviceCheck.Tags = append(serviceCheck.Tags, packet.Tags...)
 				batcher.appendServiceCheck(serviceCheck)
 			case eventType:
 				event, err := s.parseEventMessage(parser, message, packet.Origin)
@@ -537,6 +577,7 @@ func (s *Server) parsePackets(batcher *batcher, parser *parser, packets []*packe
 					s.errLog("Dogstatsd: error parsing event '%q': %s", message, err)
 					continue
 				}
+				event.Tags = append(event.Tags, packet.Tags...)
 				batcher.appendEvent(event)
 			case metricSampleType:
 				var err error
@@ -551,9 +592,18 @@ func (s *Server) parsePackets(batcher *batcher, parser *parser, packets []*packe
 				}
 
 				for idx := range samples {
+					if len(packet.Tags) > 0 {
+						samples[idx].Tags = append(samples[idx].Tags, packet.Tags...)
+					}
+					if s.originLimiter != nil && !s.originLimiter.allowSample(packet.Origin, samples[idx].Name, samples[idx].Host, samples[idx].Tags, time.Now()) {
+						continue
+					}
 					if debugEnabled {
 						s.storeMetricStats(samples[idx])
 					}
+					if s.profiler != nil {
+						s.profiler.track(&samples[idx], packet.Origin, time.Now())
+					}
 					batcher.appendSample(samples[idx])
 					if s.histToDist && samples[idx].Mtype == metrics.HistogramType {
 						distSample := samples[idx].Copy()
@@ -630,11 +680,20 @@ func (s *Server) parseMetricMessage(metricSamples []metrics.MetricSample, parser
 	}
 
 	if s.mapper != nil {
-		mapResult := s.mapper.Map(sample.name)
+		mapResult := s.mapper.Map(sample.name, sample.tags)
+		if mapResult != nil && mapResult.Drop {
+			log.Tracef("Dogstatsd mapper: metric %q dropped", sample.name)
+			dogstatsdMetricDroppedByMapper.Add(1)
+			tlmMapperDropped.Inc()
+			if len(sample.values) > 0 {
+				s.sharedFloat64List.put(sample.values)
+			}
+			return metricSamples, nil
+		}
 		if mapResult != nil {
 			log.Tracef("Dogstatsd mapper: metric mapped from %q to %q with tags %v", sample.name, mapResult.Name, mapResult.Tags)
 			sample.name = mapResult.Name
-			sample.tags = append(sample.tags, mapResult.Tags...)
+			sample.tags = mapResult.MapTags(sample.tags)
 		}
 	}
 	metricSamples = enrichMetricSample(metricSamples, sample, s.metricPrefix, s.metricPrefixBlacklist, s.metricBlocklist, s.defaultHostname, origin, s.entityIDPrecedenceEnabled, s.ServerlessMode)
@@ -808,19 +867,41 @@ func (s *Server) DisableMetricsStats() {
 	log.Info("Disabling DogStatsD debug metrics stats.")
 }
 
+// debugStats is the payload of the dogstatsd-stats command
+type debugStats struct {
+	Metrics map[ckey.ContextKey]metricStat `json:"metrics"`
+	// Origins holds the drops of the origins over quota, see originLimiter
+	Origins map[string]originStat `json:"origins,omitempty"`
+}
+
 // GetJSONDebugStats returns jsonified debug statistics.
 func (s *Server) GetJSONDebugStats() ([]byte, error) {
+	var origins map[string]originStat
+	if s.originLimiter != nil {
+		origins = s.originLimiter.stats()
+	}
+
 	s.Debug.Lock()
 	defer s.Debug.Unlock()
-	return json.Marshal(s.Debug.Stats)
+	return json.Marshal(debugStats{Metrics: s.Debug.Stats, Origins: origins})
 }
 
 // FormatDebugStats returns a printable version of debug stats.
 func FormatDebugStats(stats []byte) (string, error) {
-	var dogStats map[uint64]metricStat
-	if err := json.Unmarshal(stats, &dogStats); err != nil {
+	var payload struct {
+		Metrics map[uint64]metricStat `json:"metrics"`
+		Origins map[string]originStat `json:"origins"`
+	}
+	if err := json.Unmarshal(stats, &payload); err != nil {
 		return "", err
 	}
+	dogStats := payload.Metrics
+	if dogStats == nil {
+		// agents without origin quotas only send the metrics
+		if err := json.Unmarshal(stats, &dogStats); err != nil {
+			return "", err
+		}
+	}
 
 	// put metrics in order: first is the more frequent
 	order := make([]uint64, len(dogStats))
@@ -850,6 +931,27 @@ func FormatDebugStats(stats []byte) (string, error) {
 		buf.Write([]byte("No metrics processed yet."))
 	}
 
+	if len(payload.Origins) > 0 {
+		// put origins in order: first is the one with the most drops
+		origins := make([]string, 0, len(payload.Origins))
+		for origin := range payload.Origins {
+			origins = append(origins, origin)
+		}
+		sort.Slice(origins, func(i, j int) bool {
+			oi, oj := payload.Origins[origins[i]], payload.Origins[origins[j]]
+			return oi.DroppedPackets+oi.DroppedSamples > oj.DroppedPackets+oj.DroppedSamples
+		})
+
+		header = fmt.Sprintf("%-40s | %-15s | %-15s | %-20s\n", "Origin over quota", "Dropped Packets", "Dropped Samples", "Last Drop")
+		buf.Write([]byte("\n" + header))
+		buf.Write([]byte(strings.Repeat("-", len(header)) + "\n"))
+
+		for _, origin := range origins {
+			stats := payload.Origins[origin]
+			buf.Write([]byte(fmt.Sprintf("%-40s | %-15d | %-15d | %-20v\n", origin, stats.DroppedPackets, stats.DroppedSamples, stats.LastDrop)))
+		}
+	}
+
 	return buf.String(), nil
 }
 
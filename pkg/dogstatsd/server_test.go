@@ -21,6 +21,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/aggregator/ckey"
 	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/dogstatsd/packets"
 	"github.com/DataDog/datadog-agent/pkg/metrics"
 	"github.com/DataDog/datadog-agent/pkg/tagset"
 )
@@ -415,6 +416,55 @@ func TestHistToDist(t *testing.T) {
 	}
 }
 
+func TestUDPReceiveWithTimestamp(t *testing.T) {
+	port, err := getAvailableUDPPort()
+	require.NoError(t, err)
+	defaultPort := config.Datadog.GetInt("dogstatsd_port")
+	config.Datadog.SetDefault("dogstatsd_port", port)
+	defer config.Datadog.SetDefault("dogstatsd_port", defaultPort)
+
+	agg := mockAggregator()
+	metricOut, _, _ := agg.GetBufferedChannels()
+	noAggOut := agg.GetBufferedNoAggregationChannel()
+	s, err := NewServer(agg, nil)
+	require.NoError(t, err, "cannot start DSD")
+	defer s.Stop()
+
+	url := fmt.Sprintf("127.0.0.1:%d", config.Datadog.GetInt("dogstatsd_port"))
+	conn, err := net.Dial("udp", url)
+	require.NoError(t, err, "cannot connect to DSD socket")
+	defer conn.Close()
+
+	// a sample with a timestamp goes to the no-aggregation pipeline
+	conn.Write([]byte("daemon:666|g|#sometag1:somevalue1|T1658328888"))
+	select {
+	case res := <-noAggOut:
+		require.Equal(t, 1, len(res))
+		sample := res[0]
+		assert.Equal(t, "daemon", sample.Name)
+		assert.EqualValues(t, 666.0, sample.Value)
+		assert.Equal(t, metrics.GaugeType, sample.Mtype)
+		assert.EqualValues(t, 1658328888, sample.Timestamp)
+		assert.ElementsMatch(t, []string{"sometag1:somevalue1"}, sample.Tags)
+	case <-metricOut:
+		assert.FailNow(t, "Timestamped sample received on the aggregated channel")
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+
+	// a sample without a timestamp is still aggregated
+	conn.Write([]byte("daemon:666|g|#sometag1:somevalue1"))
+	select {
+	case res := <-metricOut:
+		require.Equal(t, 1, len(res))
+		assert.EqualValues(t, 0, res[0].Timestamp)
+	case <-noAggOut:
+		assert.FailNow(t, "Sample without timestamp received on the no-aggregation channel")
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+}
+
 func TestScanLines(t *testing.T) {
 
 	messages := []string{"foo", "bar", "baz", "quz", "hax", ""}
@@ -626,9 +676,10 @@ func TestDebugStats(t *testing.T) {
 	require.NotNil(t, data)
 	require.NotEmpty(t, data)
 
-	var stats map[ckey.ContextKey]metricStat
-	err = json.Unmarshal(data, &stats)
+	var payload debugStats
+	err = json.Unmarshal(data, &payload)
 	require.NoError(t, err, "data is not valid")
+	stats := payload.Metrics
 	require.Len(t, stats, 2, "two metrics should have been captured")
 
 	require.True(t, stats[hash1].LastSeen.After(stats[hash2].LastSeen), "some.metric1 should have appeared again after some.metric2")
@@ -640,8 +691,9 @@ func TestDebugStats(t *testing.T) {
 	s.storeMetricStats(sample4)
 	s.storeMetricStats(sample5)
 	data, _ = s.GetJSONDebugStats()
-	err = json.Unmarshal(data, &stats)
+	err = json.Unmarshal(data, &payload)
 	require.NoError(t, err, "data is not valid")
+	stats = payload.Metrics
 	require.Len(t, stats, 4, "4 metrics should have been captured")
 
 	// test stats array
@@ -758,6 +810,37 @@ dogstatsd_mapper_profiles:
 			},
 			expectedCacheSize: 1000,
 		},
+		{
+			name: "Match tags, drop and rename tags",
+			config: `
+dogstatsd_mapper_profiles:
+  - name: test
+    prefix: 'test.'
+    mappings:
+      - match: "test.debug.*"
+        drop: true
+      - match: "test.request.*"
+        match_tags:
+          http_method: "(?P<method>GET|POST)"
+        name: "test.request.${method}"
+        tags:
+          endpoint: "$1"
+        drop_tags:
+          - request_id
+        rename_tags:
+          env_name: env
+`,
+			packets: []string{
+				"test.debug.foo:666|g",
+				"test.request.users:666|g|#http_method:GET,request_id:42,env_name:prod",
+				"test.request.users:666|g|#http_method:DELETE",
+			},
+			expectedSamples: []MetricSample{
+				{Name: "test.request.GET", Tags: []string{"http_method:GET", "env:prod", "endpoint:users"}, Mtype: metrics.GaugeType, Value: 666.0},
+				{Name: "test.request.users", Tags: []string{"http_method:DELETE"}, Mtype: metrics.GaugeType, Value: 666.0},
+			},
+			expectedCacheSize: 1000,
+		},
 		{
 			name: "Cache size",
 			config: `
@@ -928,3 +1011,124 @@ func TestProcessedMetricsOrigin(t *testing.T) {
 	assert.Equal(s.cachedOrder[1].ok, map[string]string{"message_type": "metrics", "state": "ok", "origin": "fourth_origin"})
 	assert.Equal(s.cachedOrder[1].err, map[string]string{"message_type": "metrics", "state": "error", "origin": "fourth_origin"})
 }
+
+func TestOriginQuotas(t *testing.T) {
+	agg := mockAggregator()
+	metricOut, _, _ := agg.GetBufferedChannels()
+	s, err := NewServer(agg, nil)
+	require.NoError(t, err, "cannot start DSD")
+	s.Stop()
+	s.originLimiter = newOriginLimiter(1, 1)
+
+	newPacket := func(contents string, origin string) *packets.Packet {
+		packet := s.sharedPacketPoolManager.Get().(*packets.Packet)
+		packet.Contents = append(packet.Buffer[:0], contents...)
+		packet.Origin = origin
+		return packet
+	}
+	batcher := newBatcher(agg)
+	parser := newParser(newFloat64ListPool())
+	s.parsePackets(batcher, parser, []*packets.Packet{
+		// the second context of the packet is over quota
+		newPacket("first:1|c\nsecond:1|c\nfirst:2|c", "container_id://noisy"),
+		// the packet is over quota
+		newPacket("first:3|c", "container_id://noisy"),
+		newPacket("first:4|c", "container_id://quiet"),
+	}, nil)
+
+	select {
+	case samples := <-metricOut:
+		require.Len(t, samples, 3)
+		assert.Equal(t, "first", samples[0].Name)
+		assert.EqualValues(t, 1, samples[0].Value)
+		assert.Equal(t, "first", samples[1].Name)
+		assert.EqualValues(t, 2, samples[1].Value)
+		assert.EqualValues(t, 4, samples[2].Value)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+
+	data, err := s.GetJSONDebugStats()
+	require.NoError(t, err)
+	var payload debugStats
+	require.NoError(t, json.Unmarshal(data, &payload))
+	require.Len(t, payload.Origins, 1)
+	assert.Equal(t, uint64(1), payload.Origins["container_id://noisy"].DroppedPackets)
+	assert.Equal(t, uint64(1), payload.Origins["container_id://noisy"].DroppedSamples)
+
+	formatted, err := FormatDebugStats(data)
+	require.NoError(t, err)
+	assert.Contains(t, formatted, "container_id://noisy")
+}
+
+func TestTrafficProfiler(t *testing.T) {
+	agg := mockAggregator()
+	s, err := NewServer(agg, nil)
+	require.NoError(t, err, "cannot start DSD")
+	s.Stop()
+
+	_, err = s.GetJSONTrafficProfile(0)
+	assert.Error(t, err, "the profiler is disabled by default")
+
+	s.profiler = newTrafficProfiler(time.Minute, 0)
+	packet := s.sharedPacketPoolManager.Get().(*packets.Packet)
+	packet.Contents = append(packet.Buffer[:0], "first:1|c|#user:1\nfirst:1|c|#user:2\nsecond:1|g"...)
+	packet.Origin = "container_id://noisy"
+	s.parsePackets(newBatcher(agg), newParser(newFloat64ListPool()), []*packets.Packet{packet}, nil)
+
+	data, err := s.GetJSONTrafficProfile(1)
+	require.NoError(t, err)
+	var profile trafficProfile
+	require.NoError(t, json.Unmarshal(data, &profile))
+	assert.Equal(t, uint64(3), profile.Samples)
+	assert.Equal(t, []profileEntry{{Name: "first", Samples: 2, Contexts: 2}}, profile.TopMetricsByContexts)
+	assert.Equal(t, []profileEntry{{Name: "container_id://noisy", Samples: 3, Contexts: 3}}, profile.TopOrigins)
+	assert.Equal(t, []tagKeyEntry{{Key: "user", Values: 2, Contexts: 2}}, profile.TopTagKeys)
+}
+
+func TestPacketTags(t *testing.T) {
+	agg := mockAggregator()
+	metricOut, eventOut, serviceCheckOut := agg.GetBufferedChannels()
+	s, err := NewServer(agg, []string{})
+	require.NoError(t, err, "cannot start DSD")
+	s.Stop()
+
+	packet := s.sharedPacketPoolManager.Get().(*packets.Packet)
+	packet.Contents = append(packet.Buffer[:0], "daemon:666|g|#sometag:somevalue\n_e{5,4}:title|text\n_sc|agent.up|0"...)
+	packet.Tags = []string{"dogstatsd_client:client"}
+	s.parsePackets(newBatcher(agg), newParser(newFloat64ListPool()), []*packets.Packet{packet}, nil)
+
+	select {
+	case samples := <-metricOut:
+		require.Len(t, samples, 1)
+		assert.ElementsMatch(t, []string{"sometag:somevalue", "dogstatsd_client:client"}, samples[0].Tags)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+	select {
+	case events := <-eventOut:
+		require.Len(t, events, 1)
+		assert.Equal(t, []string{"dogstatsd_client:client"}, events[0].Tags)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+	select {
+	case serviceChecks := <-serviceCheckOut:
+		require.Len(t, serviceChecks, 1)
+		assert.Equal(t, []string{"dogstatsd_client:client"}, serviceChecks[0].Tags)
+	case <-time.After(2 * time.Second):
+		assert.FailNow(t, "Timeout on receive channel")
+	}
+}
+
+func TestFormatDebugStatsWithoutOrigins(t *testing.T) {
+	// agents without origin quotas only send the metrics
+	formatted, err := FormatDebugStats([]byte(`{"42":{"name":"some.metric","count":3,"tags":"a:b"}}`))
+	require.NoError(t, err)
+	assert.Contains(t, formatted, "some.metric")
+	assert.NotContains(t, formatted, "Origin over quota")
+
+	formatted, err = FormatDebugStats([]byte(`{}`))
+	require.NoError(t, err)
+	assert.Contains(t, formatted, "No metrics processed yet.")
+}
@@ -213,6 +213,13 @@ func createArchive(confSearchPaths SearchPaths, local bool, zipFilePath string,
 		if err != nil {
 			log.Errorf("Could not zip workload list: %s", err)
 		}
+
+		if config.Datadog.GetBool("use_dogstatsd") && config.Datadog.GetBool("dogstatsd_profiler.enabled") {
+			err = zipDogstatsdProfile(tempDir, hostname)
+			if err != nil {
+				log.Errorf("Could not zip dogstatsd traffic profile: %s", err)
+			}
+		}
 	}
 
 	// auth token permissions info (only if existing)
@@ -242,6 +249,11 @@ func createArchive(confSearchPaths SearchPaths, local bool, zipFilePath string,
 		log.Errorf("Could not zip diagnose: %s", err)
 	}
 
+	err = zipHostnameTrace(tempDir, hostname)
+	if err != nil {
+		log.Errorf("Could not zip hostname trace: %s", err)
+	}
+
 	err = zipRegistryJSON(tempDir, hostname)
 	if err != nil {
 		log.Warnf("Could not zip registry.json: %s", err)
@@ -801,6 +813,78 @@ func zipWorkloadList(tempDir, hostname string) error {
 	return err
 }
 
+// dogstatsdProfileURL allows mocking the agent HTTP server
+var dogstatsdProfileURL string
+
+func zipDogstatsdProfile(tempDir, hostname string) error {
+	f := filepath.Join(tempDir, hostname, "dogstatsd-profile.json")
+	err := ensureParentDirsExist(f)
+	if err != nil {
+		return err
+	}
+
+	w, err := newRedactingWriter(f, os.ModePerm, true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	ipcAddress, err := config.GetIPCAddress()
+	if err != nil {
+		return err
+	}
+
+	if dogstatsdProfileURL == "" {
+		dogstatsdProfileURL = fmt.Sprintf("https://%v:%v/agent/dogstatsd-profile?top=50", ipcAddress, config.Datadog.GetInt("cmd_port"))
+	}
+
+	c := apiutil.GetClient(false) // FIX: get certificates right then make this true
+
+	r, err := apiutil.DoGet(c, dogstatsdProfileURL)
+	if err != nil {
+		return err
+	}
+
+	// Pretty print JSON output
+	var b bytes.Buffer
+	err = json.Indent(&b, r, "", "\t")
+	if err != nil {
+		_, err = w.Write(r)
+		return err
+	}
+
+	_, err = w.Write(b.Bytes())
+	return err
+}
+
+// zipHostnameTrace adds how the hostname of the flare was resolved
+func zipHostnameTrace(tempDir, hostname string) error {
+	trace := util.GetHostnameTrace()
+	if trace == nil {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(trace, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	f := filepath.Join(tempDir, hostname, "hostname-trace.json")
+	err = ensureParentDirsExist(f)
+	if err != nil {
+		return err
+	}
+
+	w, err := newRedactingWriter(f, os.ModePerm, true)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	_, err = w.Write(data)
+	return err
+}
+
 func zipHealth(tempDir, hostname string) error {
 	s := health.GetReady()
 	sort.Strings(s.Healthy)
@@ -7,6 +7,7 @@ package flare
 
 import (
 	"archive/zip"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -26,6 +27,7 @@ import (
 	"github.com/DataDog/datadog-agent/cmd/agent/common"
 	"github.com/DataDog/datadog-agent/pkg/autodiscovery/integration"
 	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util"
 	"github.com/DataDog/datadog-agent/pkg/workloadmeta"
 )
 
@@ -317,6 +319,50 @@ func TestZipTaggerList(t *testing.T) {
 	assert.Contains(t, string(content), "image_name:custom-agent")
 }
 
+func TestZipDogstatsdProfile(t *testing.T) {
+	s := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"window_seconds":60,"samples":12,"contexts":3,"top_metrics_by_samples":[{"name":"custom.metric","samples":12,"contexts":3}]}`))
+	}))
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "TestZipDogstatsdProfile")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	dogstatsdProfileURL = s.URL
+	zipDogstatsdProfile(dir, "")
+	content, err := ioutil.ReadFile(filepath.Join(dir, "dogstatsd-profile.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	assert.Contains(t, string(content), "\"top_metrics_by_samples\": [")
+	assert.Contains(t, string(content), "custom.metric")
+}
+
+func TestZipHostnameTrace(t *testing.T) {
+	config.Datadog.Set("hostname", "tracedhostname")
+	defer config.Datadog.Set("hostname", "")
+	util.ExplainHostname(context.TODO())
+
+	dir, err := ioutil.TempDir("", "TestZipHostnameTrace")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	zipHostnameTrace(dir, "")
+	content, err := ioutil.ReadFile(filepath.Join(dir, "hostname-trace.json"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	assert.Contains(t, string(content), "\"hostname\": \"tracedhostname\"")
+	assert.Contains(t, string(content), "\"provider\": \"configuration\"")
+}
+
 func TestZipWorkloadList(t *testing.T) {
 	workloadMap := make(map[string]workloadmeta.WorkloadEntity)
 	workloadMap["kind_id"] = workloadmeta.WorkloadEntity{
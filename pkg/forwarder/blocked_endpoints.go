@@ -14,14 +14,48 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
+const (
+	circuitClosed   = "closed"
+	circuitOpen     = "open"
+	circuitHalfOpen = "half-open"
+)
+
+// block is the circuit breaker of an endpoint:
+//   - closed: the transactions are sent.
+//   - open: the transactions are sent back to the retry queue until `until`.
+//   - half-open: once `until` is reached, a single transaction is sent as a probe. The
+//     circuit is closed if it succeeds and opened again, for longer, if it fails.
 type block struct {
 	nbError int
 	until   time.Time
+	// probeUntil is set while a probe is in flight. Another probe is allowed after it, in case
+	// the outcome of the previous one is never reported.
+	probeUntil time.Time
+}
+
+func (b *block) state(now time.Time) string {
+	switch {
+	case now.Before(b.until):
+		return circuitOpen
+	case !b.until.IsZero() && b.nbError > 0:
+		return circuitHalfOpen
+	default:
+		return circuitClosed
+	}
+}
+
+// circuitBreakerStatus is the state of the circuit breaker of an endpoint, reported in the
+// status of the forwarder
+type circuitBreakerStatus struct {
+	State  string `json:"state"`
+	Errors int    `json:"errors"`
+	Until  string `json:"until,omitempty"`
 }
 
 type blockedEndpoints struct {
 	errorPerEndpoint map[string]*block
 	backoffPolicy    backoff.Policy
+	probeTimeout     time.Duration
 	m                sync.RWMutex
 }
 
@@ -55,6 +89,7 @@ func newBlockedEndpoints() *blockedEndpoints {
 	return &blockedEndpoints{
 		errorPerEndpoint: make(map[string]*block),
 		backoffPolicy:    backoff.NewPolicy(backoffFactor, backoffBase, backoffMax, recInterval, recoveryReset),
+		probeTimeout:     2 * config.Datadog.GetDuration("forwarder_timeout") * time.Second,
 	}
 }
 
@@ -71,6 +106,7 @@ func (e *blockedEndpoints) close(endpoint string) {
 
 	b.nbError = e.backoffPolicy.IncError(b.nbError)
 	b.until = time.Now().Add(e.getBackoffDuration(b.nbError))
+	b.probeUntil = time.Time{}
 
 	e.errorPerEndpoint[endpoint] = b
 }
@@ -86,22 +122,82 @@ func (e *blockedEndpoints) recover(endpoint string) {
 		b = &block{}
 	}
 
+	// A success closes the circuit: the remaining errors only make the next opening longer.
 	b.nbError = e.backoffPolicy.DecError(b.nbError)
-	b.until = time.Now().Add(e.getBackoffDuration(b.nbError))
+	b.until = time.Time{}
+	b.probeUntil = time.Time{}
 
 	e.errorPerEndpoint[endpoint] = b
 }
 
+// isBlock returns true if the transactions of the endpoint must not be sent, because
+// the circuit is open or because a probe is in flight
 func (e *blockedEndpoints) isBlock(endpoint string) bool {
 	e.m.RLock()
 	defer e.m.RUnlock()
 
-	if b, ok := e.errorPerEndpoint[endpoint]; ok && time.Now().Before(b.until) {
-		return true
+	if b, ok := e.errorPerEndpoint[endpoint]; ok {
+		now := time.Now()
+		return now.Before(b.until) || now.Before(b.probeUntil)
 	}
 	return false
 }
 
+// isHalfOpen returns true if the next transaction sent to the endpoint is a probe
+func (e *blockedEndpoints) isHalfOpen(endpoint string) bool {
+	e.m.RLock()
+	defer e.m.RUnlock()
+
+	b, ok := e.errorPerEndpoint[endpoint]
+	return ok && b.state(time.Now()) == circuitHalfOpen
+}
+
+// allow returns true if a transaction can be sent to the endpoint. When the circuit is
+// half-open, only the first caller is allowed, until the outcome of its probe is reported
+// with close or recover.
+func (e *blockedEndpoints) allow(endpoint string) bool {
+	e.m.Lock()
+	defer e.m.Unlock()
+
+	b, ok := e.errorPerEndpoint[endpoint]
+	if !ok {
+		return true
+	}
+	now := time.Now()
+	switch b.state(now) {
+	case circuitOpen:
+		return false
+	case circuitHalfOpen:
+		if now.Before(b.probeUntil) {
+			return false
+		}
+		b.probeUntil = now.Add(e.probeTimeout)
+		log.Debugf("Sending a probe to the endpoint '%s' after %d error(s)", endpoint, b.nbError)
+	}
+	return true
+}
+
+// statuses returns the state of the circuit breakers which are not closed
+func (e *blockedEndpoints) statuses() map[string]circuitBreakerStatus {
+	e.m.RLock()
+	defer e.m.RUnlock()
+
+	now := time.Now()
+	statuses := make(map[string]circuitBreakerStatus)
+	for endpoint, b := range e.errorPerEndpoint {
+		state := b.state(now)
+		if state == circuitClosed {
+			continue
+		}
+		status := circuitBreakerStatus{State: state, Errors: b.nbError}
+		if state == circuitOpen {
+			status.Until = b.until.Format(time.RFC3339)
+		}
+		statuses[endpoint] = status
+	}
+	return statuses
+}
+
 func (e *blockedEndpoints) getBackoffDuration(numErrors int) time.Duration {
 	return e.backoffPolicy.GetBackoffDuration(numErrors)
 }
@@ -262,3 +262,48 @@ func TestIsblockUnknown(t *testing.T) {
 
 	assert.False(t, e.isBlock("test"))
 }
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	e := newBlockedEndpoints()
+
+	assert.True(t, e.allow("test"))
+	e.close("test")
+	assert.False(t, e.allow("test"))
+	assert.False(t, e.isHalfOpen("test"))
+	assert.Equal(t, circuitOpen, e.statuses()["test"].State)
+	assert.NotEmpty(t, e.statuses()["test"].Until)
+
+	// once the backoff is over, a single probe is allowed
+	e.errorPerEndpoint["test"].until = time.Now().Add(-time.Second)
+	assert.True(t, e.isHalfOpen("test"))
+	assert.False(t, e.isBlock("test"))
+	assert.Equal(t, circuitHalfOpen, e.statuses()["test"].State)
+	assert.True(t, e.allow("test"))
+	assert.False(t, e.allow("test"))
+	assert.True(t, e.isBlock("test"))
+
+	// the probe fails: the circuit is opened again, for longer
+	e.close("test")
+	assert.Equal(t, 2, e.errorPerEndpoint["test"].nbError)
+	assert.False(t, e.allow("test"))
+
+	// the probe succeeds: the circuit is closed
+	e.errorPerEndpoint["test"].until = time.Now().Add(-time.Second)
+	assert.True(t, e.allow("test"))
+	e.recover("test")
+	assert.True(t, e.allow("test"))
+	assert.True(t, e.allow("test"))
+	assert.False(t, e.isHalfOpen("test"))
+	assert.NotContains(t, e.statuses(), "test")
+}
+
+func TestCircuitBreakerProbeTimeout(t *testing.T) {
+	e := newBlockedEndpoints()
+	e.probeTimeout = 0
+
+	e.close("test")
+	e.errorPerEndpoint["test"].until = time.Now().Add(-time.Second)
+	assert.True(t, e.allow("test"))
+	// the outcome of the first probe is never reported
+	assert.True(t, e.allow("test"))
+}
@@ -0,0 +1,160 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+var (
+	tlmConcurrencyLimit = telemetry.NewGauge("forwarder", "concurrency_limit",
+		[]string{"domain"}, "Number of transactions the workers of a domain can send concurrently")
+)
+
+// concurrencyStatus is the state of a concurrencyLimiter, reported in the status of the forwarder
+type concurrencyStatus struct {
+	Limit    int `json:"limit"`
+	InFlight int `json:"in_flight"`
+	Max      int `json:"max"`
+}
+
+// concurrencyLimiter limits the number of transactions sent concurrently to a domain, with an
+// AIMD (additive increase, multiplicative decrease) policy: the limit grows by one after a
+// full window of transactions sent without error under latencyThreshold, and is halved when
+// a transaction fails or is slower.
+type concurrencyLimiter struct {
+	domain           string
+	min              float64
+	max              float64
+	latencyThreshold time.Duration
+
+	m        sync.Mutex
+	limit    float64
+	inFlight int
+	// released is closed, and replaced, when a slot may have been freed
+	released chan struct{}
+	// lastDecrease prevents the transactions sent before a decrease from decreasing the
+	// limit again, when several of them fail at once
+	lastDecrease time.Time
+}
+
+func newConcurrencyLimiter(domain string, initial, min, max int, latencyThreshold time.Duration) *concurrencyLimiter {
+	if min < 1 {
+		min = 1
+	}
+	if max < min {
+		max = min
+	}
+	if initial < min {
+		initial = min
+	} else if initial > max {
+		initial = max
+	}
+	l := &concurrencyLimiter{
+		domain:           domain,
+		min:              float64(min),
+		max:              float64(max),
+		latencyThreshold: latencyThreshold,
+		limit:            float64(initial),
+		released:         make(chan struct{}),
+	}
+	tlmConcurrencyLimit.Set(float64(initial), domain)
+	return l
+}
+
+// newConcurrencyLimiterFromConfig returns the concurrencyLimiter configured with
+// `forwarder_adaptive_concurrency`, or nil if the adaptive concurrency is disabled.
+func newConcurrencyLimiterFromConfig(domain string, numberOfWorkers int) *concurrencyLimiter {
+	if !config.Datadog.GetBool("forwarder_adaptive_concurrency.enabled") {
+		return nil
+	}
+
+	min := config.Datadog.GetInt("forwarder_adaptive_concurrency.min_workers")
+	max := config.Datadog.GetInt("forwarder_adaptive_concurrency.max_workers")
+	if max < numberOfWorkers {
+		log.Warnf("forwarder_adaptive_concurrency.max_workers (%d) is lower than forwarder_num_workers; %d will be used", max, numberOfWorkers)
+		max = numberOfWorkers
+	}
+	threshold := config.Datadog.GetFloat64("forwarder_adaptive_concurrency.latency_threshold")
+	if threshold <= 0 {
+		log.Warnf("Configured forwarder_adaptive_concurrency.latency_threshold (%v) is not positive; 2 seconds will be used", threshold)
+		threshold = 2
+	}
+	return newConcurrencyLimiter(domain, numberOfWorkers, min, max, time.Duration(threshold*float64(time.Second)))
+}
+
+// maxConcurrency returns the maximum number of transactions sent concurrently, which is the
+// number of workers to start
+func (l *concurrencyLimiter) maxConcurrency() int {
+	return int(l.max)
+}
+
+// acquire waits until a transaction can be sent. It returns the time at which the
+// transaction starts, to pass to release, or an error if ctx is done first.
+func (l *concurrencyLimiter) acquire(ctx context.Context) (time.Time, error) {
+	for {
+		l.m.Lock()
+		if l.inFlight < int(l.limit) {
+			l.inFlight++
+			l.m.Unlock()
+			return time.Now(), nil
+		}
+		released := l.released
+		l.m.Unlock()
+
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return time.Time{}, ctx.Err()
+		}
+	}
+}
+
+// release frees the slot of a transaction started at start. The limit is updated from its
+// outcome unless sample is false, when the transaction was not sent for instance.
+func (l *concurrencyLimiter) release(start time.Time, sample bool, failed bool) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	l.inFlight--
+	if sample {
+		latency := time.Since(start)
+		previous := l.limit
+		if failed || latency > l.latencyThreshold {
+			if start.After(l.lastDecrease) {
+				l.limit = math.Max(l.min, math.Floor(l.limit/2))
+				l.lastDecrease = time.Now()
+			}
+		} else {
+			l.limit = math.Min(l.max, l.limit+1/math.Floor(l.limit))
+		}
+		if int(l.limit) != int(previous) {
+			log.Debugf("Concurrency limit for %s changed from %d to %d (latency: %s, failed: %t)", l.domain, int(previous), int(l.limit), latency, failed)
+			tlmConcurrencyLimit.Set(math.Floor(l.limit), l.domain)
+		}
+	}
+
+	close(l.released)
+	l.released = make(chan struct{})
+}
+
+func (l *concurrencyLimiter) status() concurrencyStatus {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return concurrencyStatus{
+		Limit:    int(l.limit),
+		InFlight: l.inFlight,
+		Max:      int(l.max),
+	}
+}
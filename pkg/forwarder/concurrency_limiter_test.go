@@ -0,0 +1,123 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+func TestConcurrencyLimiterAIMD(t *testing.T) {
+	l := newConcurrencyLimiter("domain", 2, 1, 4, time.Minute)
+	assert.Equal(t, concurrencyStatus{Limit: 2, Max: 4}, l.status())
+
+	// additive increase: the limit grows by one every `limit` successes
+	for _, expected := range []int{2, 3, 3, 3, 4, 4, 4, 4, 4} {
+		start, err := l.acquire(context.Background())
+		require.NoError(t, err)
+		l.release(start, true, false)
+		assert.Equal(t, expected, l.status().Limit)
+	}
+
+	// multiplicative decrease
+	start, err := l.acquire(context.Background())
+	require.NoError(t, err)
+	l.release(start, true, true)
+	assert.Equal(t, 2, l.status().Limit)
+
+	// the transactions which are not sent don't change the limit
+	start, err = l.acquire(context.Background())
+	require.NoError(t, err)
+	l.release(start, false, true)
+	assert.Equal(t, 2, l.status().Limit)
+
+	// the limit doesn't go under the minimum
+	for i := 0; i < 3; i++ {
+		start, err = l.acquire(context.Background())
+		require.NoError(t, err)
+		l.release(start, true, true)
+	}
+	assert.Equal(t, 1, l.status().Limit)
+	assert.Equal(t, 0, l.status().InFlight)
+}
+
+func TestConcurrencyLimiterLatency(t *testing.T) {
+	l := newConcurrencyLimiter("domain", 4, 1, 4, 0)
+
+	start, err := l.acquire(context.Background())
+	require.NoError(t, err)
+	time.Sleep(time.Millisecond)
+	l.release(start, true, false)
+	assert.Equal(t, 2, l.status().Limit)
+}
+
+func TestConcurrencyLimiterSingleDecrease(t *testing.T) {
+	l := newConcurrencyLimiter("domain", 4, 1, 4, time.Minute)
+
+	// the transactions sent before a decrease don't decrease the limit again
+	var starts []time.Time
+	for i := 0; i < 4; i++ {
+		start, err := l.acquire(context.Background())
+		require.NoError(t, err)
+		starts = append(starts, start)
+	}
+	for _, start := range starts {
+		l.release(start, true, true)
+	}
+	assert.Equal(t, 2, l.status().Limit)
+}
+
+func TestConcurrencyLimiterAcquire(t *testing.T) {
+	l := newConcurrencyLimiter("domain", 1, 1, 1, time.Minute)
+
+	start, err := l.acquire(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, l.status().InFlight)
+
+	// no slot is available until the first one is released
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = l.acquire(ctx)
+	assert.Error(t, err)
+
+	acquired := make(chan struct{})
+	go func() {
+		start, err := l.acquire(context.Background())
+		assert.NoError(t, err)
+		l.release(start, false, false)
+		close(acquired)
+	}()
+	l.release(start, true, false)
+
+	select {
+	case <-acquired:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the slot was not acquired after its release")
+	}
+	assert.Equal(t, 0, l.status().InFlight)
+}
+
+func TestConcurrencyLimiterFromConfig(t *testing.T) {
+	mockConfig := config.Mock()
+	assert.Nil(t, newConcurrencyLimiterFromConfig("domain", 1))
+
+	mockConfig.Set("forwarder_adaptive_concurrency.enabled", true)
+	defer mockConfig.Set("forwarder_adaptive_concurrency.enabled", false)
+	l := newConcurrencyLimiterFromConfig("domain", 2)
+	require.NotNil(t, l)
+	assert.Equal(t, concurrencyStatus{Limit: 2, Max: 10}, l.status())
+	assert.Equal(t, 2*time.Second, l.latencyThreshold)
+
+	// max_workers is raised to forwarder_num_workers
+	l = newConcurrencyLimiterFromConfig("domain", 20)
+	assert.Equal(t, concurrencyStatus{Limit: 20, Max: 20}, l.status())
+}
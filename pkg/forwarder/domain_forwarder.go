@@ -34,12 +34,14 @@ type domainForwarder struct {
 	stopRetry                 chan bool
 	stopConnectionReset       chan bool
 	workers                   []*Worker
+	workersLock               sync.Mutex // To protect workers, which can be resized at runtime
 	retryQueue                *retry.TransactionRetryQueue
 	connectionResetInterval   time.Duration
 	internalState             uint32
 	m                         sync.Mutex // To control Start/Stop races
 	transactionPrioritySorter retry.TransactionPrioritySorter
 	blockedList               *blockedEndpoints
+	limiter                   *concurrencyLimiter
 }
 
 func newDomainForwarder(
@@ -55,6 +57,7 @@ func newDomainForwarder(
 		connectionResetInterval:   connectionResetInterval,
 		internalState:             Stopped,
 		blockedList:               newBlockedEndpoints(),
+		limiter:                   newConcurrencyLimiterFromConfig(domain, numberOfWorkers),
 		transactionPrioritySorter: transactionPrioritySorter,
 	}
 }
@@ -70,6 +73,9 @@ func (f *domainForwarder) retryTransactions(retryBefore time.Time) {
 
 	droppedRetryQueueFull := 0
 	droppedWorkerBusy := 0
+	// a single transaction is sent to the endpoints whose circuit breaker is half-open,
+	// the others wait for the outcome of this probe in the retry queue
+	probes := make(map[string]struct{})
 
 	var transactions []transaction.Transaction
 	var err error
@@ -83,7 +89,14 @@ func (f *domainForwarder) retryTransactions(retryBefore time.Time) {
 
 	for _, t := range transactions {
 		transactionEndpointName := t.GetEndpointName()
-		if !f.blockedList.isBlock(t.GetTarget()) {
+		target := t.GetTarget()
+		send := !f.blockedList.isBlock(target)
+		if send && f.blockedList.isHalfOpen(target) {
+			_, probing := probes[target]
+			send = !probing
+			probes[target] = struct{}{}
+		}
+		if send {
 			select {
 			case f.lowPrio <- t:
 				transactionsRetriedByEndpoint.Add(transactionEndpointName, 1)
@@ -159,9 +172,11 @@ func (f *domainForwarder) scheduleConnectionResets() {
 		select {
 		case <-ticker.C:
 			log.Debugf("Scheduling reset of connections used for domain: %q", f.domain)
+			f.workersLock.Lock()
 			for _, worker := range f.workers {
 				worker.ScheduleConnectionReset()
 			}
+			f.workersLock.Unlock()
 		case <-f.stopConnectionReset:
 			ticker.Stop()
 			return
@@ -179,7 +194,9 @@ func (f *domainForwarder) init() {
 	f.requeuedTransaction = make(chan transaction.Transaction, requeuedTransactionBuffSize)
 	f.stopRetry = make(chan bool)
 	f.stopConnectionReset = make(chan bool)
+	f.workersLock.Lock()
 	f.workers = []*Worker{}
+	f.workersLock.Unlock()
 }
 
 // Start starts a domainForwarder.
@@ -195,15 +212,21 @@ func (f *domainForwarder) Start() error {
 	// reset internal state to purge transactions from past starts
 	f.init()
 
-	for i := 0; i < f.numberOfWorkers; i++ {
-		w := NewWorker(f.highPrio, f.lowPrio, f.requeuedTransaction, f.blockedList)
-		w.Start()
-		f.workers = append(f.workers, w)
+	numberOfWorkers := f.numberOfWorkers
+	if f.limiter != nil {
+		// the limiter decides how many of them send transactions at the same time
+		numberOfWorkers = f.limiter.maxConcurrency()
+	}
+	f.workersLock.Lock()
+	for i := 0; i < numberOfWorkers; i++ {
+		f.startWorker()
 	}
+	f.workersLock.Unlock()
 	go f.handleFailedTransactions()
 	if f.connectionResetInterval != 0 {
 		go f.scheduleConnectionResets()
 	}
+	registerDomainForwarderHealth(f)
 
 	f.internalState = Started
 	return nil
@@ -220,14 +243,17 @@ func (f *domainForwarder) Stop(purgeHighPrio bool) {
 		return
 	}
 
+	unregisterDomainForwarderHealth(f)
 	if f.connectionResetInterval != 0 {
 		f.stopConnectionReset <- true
 	}
 	f.stopRetry <- true
+	f.workersLock.Lock()
 	for _, w := range f.workers {
 		w.Stop(purgeHighPrio)
 	}
 	f.workers = []*Worker{}
+	f.workersLock.Unlock()
 	close(f.highPrio)
 	close(f.lowPrio)
 	close(f.requeuedTransaction)
@@ -235,6 +261,43 @@ func (f *domainForwarder) Stop(purgeHighPrio bool) {
 	f.internalState = Stopped
 }
 
+// startWorker starts a new worker, workersLock must be held
+func (f *domainForwarder) startWorker() {
+	w := NewWorker(f.highPrio, f.lowPrio, f.requeuedTransaction, f.blockedList)
+	w.limiter = f.limiter
+	w.Start()
+	f.workers = append(f.workers, w)
+}
+
+// setNumberOfWorkers changes the number of workers sending the transactions of the domain. When
+// the domainForwarder is started, the workers are started or stopped right away; the stopped
+// workers finish the transaction they are processing.
+func (f *domainForwarder) setNumberOfWorkers(numberOfWorkers int) error {
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	if f.limiter != nil {
+		return fmt.Errorf("the number of workers for %s is managed by the adaptive concurrency", f.domain)
+	}
+	f.numberOfWorkers = numberOfWorkers
+	if f.internalState != Started {
+		return nil
+	}
+
+	f.workersLock.Lock()
+	defer f.workersLock.Unlock()
+	for len(f.workers) < numberOfWorkers {
+		f.startWorker()
+	}
+	for len(f.workers) > numberOfWorkers {
+		last := len(f.workers) - 1
+		f.workers[last].Stop(false)
+		f.workers = f.workers[:last]
+	}
+	log.Infof("The forwarder for %s now uses %d worker(s)", f.domain, numberOfWorkers)
+	return nil
+}
+
 func (f *domainForwarder) State() uint32 {
 	// Lock so we can't start/stop a Forwarder while getting its state
 	f.m.Lock()
@@ -271,6 +271,76 @@ func TestDomainForwarderRetryQueueAllPayloadsMaxSize(t *testing.T) {
 	require.Equal(t, 2, trs[1].GetPayloadSize())
 }
 
+func TestForwarderRetryHalfOpen(t *testing.T) {
+	forwarder := newDomainForwarderForTest(0)
+	forwarder.init()
+	forwarder.blockedList.close("probe")
+	forwarder.blockedList.errorPerEndpoint["probe"].until = time.Now().Add(-1 * time.Second)
+
+	var transactions []*testTransaction
+	for i := 0; i < 2; i++ {
+		tr := newTestTransactionDomainForwarder()
+		tr.On("GetCreatedAt").Return(time.Now())
+		tr.On("GetTarget").Return("probe")
+		forwarder.requeueTransaction(tr)
+		transactions = append(transactions, tr)
+	}
+
+	// only one of them is sent as a probe
+	forwarder.retryTransactions(time.Now())
+	require.Len(t, forwarder.lowPrio, 1)
+	requireLenForwarderRetryQueue(t, forwarder, 1)
+	for _, tr := range transactions {
+		tr.AssertNumberOfCalls(t, "GetTarget", 1)
+	}
+}
+
+func TestDomainForwarderAdaptiveConcurrency(t *testing.T) {
+	mockConfig := config.Mock()
+	mockConfig.Set("forwarder_adaptive_concurrency.enabled", true)
+	mockConfig.Set("forwarder_adaptive_concurrency.max_workers", 3)
+	defer mockConfig.Set("forwarder_adaptive_concurrency.enabled", false)
+
+	forwarder := newDomainForwarderForTest(0)
+	require.NotNil(t, forwarder.limiter)
+	require.NoError(t, forwarder.Start())
+
+	// the limiter starts at forwarder_num_workers and the workers are started for the maximum
+	require.Len(t, forwarder.workers, 3)
+	for _, w := range forwarder.workers {
+		assert.Equal(t, forwarder.limiter, w.limiter)
+	}
+	assert.Equal(t, concurrencyStatus{Limit: 1, Max: 3}, getConcurrencyStatuses()["test"])
+
+	forwarder.Stop(false)
+	assert.NotContains(t, getConcurrencyStatuses(), "test")
+}
+
+func TestDomainForwarderSetNumberOfWorkers(t *testing.T) {
+	forwarder := newDomainForwarderForTest(0)
+
+	// the number of workers is used at the next start when stopped
+	require.NoError(t, forwarder.setNumberOfWorkers(2))
+	assert.Len(t, forwarder.workers, 0)
+	require.NoError(t, forwarder.Start())
+	assert.Len(t, forwarder.workers, 2)
+
+	require.NoError(t, forwarder.setNumberOfWorkers(4))
+	assert.Len(t, forwarder.workers, 4)
+	require.NoError(t, forwarder.setNumberOfWorkers(1))
+	assert.Len(t, forwarder.workers, 1)
+	assert.Equal(t, 1, forwarder.numberOfWorkers)
+
+	forwarder.Stop(false)
+	assert.Len(t, forwarder.workers, 0)
+
+	mockConfig := config.Mock()
+	mockConfig.Set("forwarder_adaptive_concurrency.enabled", true)
+	defer mockConfig.Set("forwarder_adaptive_concurrency.enabled", false)
+	forwarder = newDomainForwarderForTest(0)
+	assert.Error(t, forwarder.setNumberOfWorkers(2))
+}
+
 func TestDomainForwarderInitConfigs(t *testing.T) {
 	// Test default values
 	forwarder := newDomainForwarderForTest(0)
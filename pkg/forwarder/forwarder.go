@@ -104,6 +104,9 @@ type Options struct {
 	DomainResolvers                map[string]resolver.DomainResolver
 	ConnectionResetInterval        time.Duration
 	CompletionHandler              transaction.HTTPCompletionHandler
+	// Sinks receive a copy of the payloads submitted to the forwarder, see NewSinksFromConfig.
+	// A Sink must only be given to one forwarder.
+	Sinks []Sink
 }
 
 // SetFeature sets forwarder features in a feature set
@@ -202,6 +205,7 @@ type DefaultForwarder struct {
 	m                sync.Mutex // To control Start/Stop races
 
 	completionHandler transaction.HTTPCompletionHandler
+	sinkWorkers       []*sinkWorker
 }
 
 // NewDefaultForwarder returns a new DefaultForwarder.
@@ -217,6 +221,7 @@ func NewDefaultForwarder(options *Options) *DefaultForwarder {
 			validationInterval:    options.APIKeyValidationInterval,
 		},
 		completionHandler: options.CompletionHandler,
+		sinkWorkers:       newSinkWorkers(options.Sinks),
 	}
 	var optionalRemovalPolicy *retry.FileRemovalPolicy
 	storageMaxSize := config.Datadog.GetInt64("forwarder_storage_max_size_in_bytes")
@@ -319,6 +324,9 @@ func (f *DefaultForwarder) Start() error {
 	for _, df := range f.domainForwarders {
 		_ = df.Start()
 	}
+	for _, w := range f.sinkWorkers {
+		w.start()
+	}
 
 	// log endpoints configuration
 	endpointLogs := make([]string, 0, len(f.domainResolvers))
@@ -377,6 +385,10 @@ func (f *DefaultForwarder) Stop() {
 		}
 	}
 
+	for _, w := range f.sinkWorkers {
+		w.stop(sinkStopTimeout)
+	}
+
 	f.healthChecker.Stop()
 
 	f.healthChecker = nil
@@ -392,6 +404,59 @@ func (f *DefaultForwarder) State() uint32 {
 
 	return f.internalState
 }
+
+// SetNumberOfWorkers changes the number of workers of every domain at runtime
+func (f *DefaultForwarder) SetNumberOfWorkers(numberOfWorkers int) error {
+	if numberOfWorkers < 1 {
+		return fmt.Errorf("the number of workers must be at least 1")
+	}
+
+	f.m.Lock()
+	defer f.m.Unlock()
+
+	// the alternate domains share the domainForwarder of their main domain
+	resized := map[*domainForwarder]struct{}{}
+	for _, df := range f.domainForwarders {
+		if _, found := resized[df]; found {
+			continue
+		}
+		resized[df] = struct{}{}
+		if err := df.setNumberOfWorkers(numberOfWorkers); err != nil {
+			return err
+		}
+	}
+	f.NumberOfWorkers = numberOfWorkers
+	return nil
+}
+
+// submitToSinks queues a copy of the payload for the sinks, with the headers of the transactions
+// but the API key
+func (f *DefaultForwarder) submitToSinks(endpoint transaction.Endpoint, payload []byte, extra http.Header) {
+	if len(f.sinkWorkers) == 0 {
+		return
+	}
+
+	headers := make(http.Header, len(extra)+3)
+	headers.Set(versionHTTPHeaderKey, version.AgentVersion)
+	headers.Set(useragentHTTPHeaderKey, fmt.Sprintf("datadog-agent/%s", version.AgentVersion))
+	if config.Datadog.GetBool("allow_arbitrary_tags") {
+		headers.Set(arbitraryTagHTTPHeaderKey, "true")
+	}
+	for key := range extra {
+		headers.Set(key, extra.Get(key))
+	}
+
+	sinkPayload := &SinkPayload{
+		Endpoint:  endpoint,
+		Payload:   payload,
+		Headers:   headers,
+		CreatedAt: time.Now(),
+	}
+	for _, w := range f.sinkWorkers {
+		w.submit(sinkPayload)
+	}
+}
+
 func (f *DefaultForwarder) createHTTPTransactions(endpoint transaction.Endpoint, payloads Payloads, apiKeyInQueryString bool, extra http.Header) []*transaction.HTTPTransaction {
 	return f.createAdvancedHTTPTransactions(endpoint, payloads, apiKeyInQueryString, extra, transaction.TransactionPriorityNormal, true)
 }
@@ -401,6 +466,8 @@ func (f *DefaultForwarder) createAdvancedHTTPTransactions(endpoint transaction.E
 	allowArbitraryTags := config.Datadog.GetBool("allow_arbitrary_tags")
 
 	for _, payload := range payloads {
+		f.submitToSinks(endpoint, *payload, extra)
+
 		for domain, dr := range f.domainResolvers {
 			for _, apiKey := range dr.GetAPIKeys() {
 				t := transaction.NewHTTPTransaction()
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"net/http"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config/resolver"
@@ -34,6 +35,11 @@ var (
 	validateAPIKeyTimeout = 10 * time.Second
 
 	apiKeyStatus = expvar.Map{}
+
+	// domainForwarders holds the running domain forwarders, whose circuit breakers and
+	// concurrency are reported in the status
+	domainForwarders   = map[*domainForwarder]struct{}{}
+	domainForwardersMu sync.Mutex
 )
 
 func init() {
@@ -46,6 +52,57 @@ func init() {
 func initForwarderHealthExpvars() {
 	apiKeyStatus.Init()
 	transaction.ForwarderExpvars.Set("APIKeyStatus", &apiKeyStatus)
+	transaction.ForwarderExpvars.Set("CircuitBreakers", expvar.Func(func() interface{} {
+		return getCircuitBreakerStatuses()
+	}))
+	transaction.ForwarderExpvars.Set("Concurrency", expvar.Func(func() interface{} {
+		return getConcurrencyStatuses()
+	}))
+}
+
+func registerDomainForwarderHealth(f *domainForwarder) {
+	domainForwardersMu.Lock()
+	defer domainForwardersMu.Unlock()
+	domainForwarders[f] = struct{}{}
+}
+
+func unregisterDomainForwarderHealth(f *domainForwarder) {
+	domainForwardersMu.Lock()
+	defer domainForwardersMu.Unlock()
+	delete(domainForwarders, f)
+}
+
+// getCircuitBreakerStatuses returns the circuit breakers which are not closed, by domain.
+// They don't make the forwarder unhealthy: an outage of the intake must not restart the Agents.
+func getCircuitBreakerStatuses() map[string]map[string]circuitBreakerStatus {
+	domainForwardersMu.Lock()
+	defer domainForwardersMu.Unlock()
+
+	statuses := make(map[string]map[string]circuitBreakerStatus)
+	for f := range domainForwarders {
+		for endpoint, status := range f.blockedList.statuses() {
+			if statuses[f.domain] == nil {
+				statuses[f.domain] = make(map[string]circuitBreakerStatus)
+			}
+			statuses[f.domain][endpoint] = status
+		}
+	}
+	return statuses
+}
+
+// getConcurrencyStatuses returns the concurrency of the domains when the adaptive concurrency
+// is enabled
+func getConcurrencyStatuses() map[string]concurrencyStatus {
+	domainForwardersMu.Lock()
+	defer domainForwardersMu.Unlock()
+
+	statuses := make(map[string]concurrencyStatus)
+	for f := range domainForwarders {
+		if f.limiter != nil {
+			statuses[f.domain] = f.limiter.status()
+		}
+	}
+	return statuses
 }
 
 // forwarderHealth report the health status of the Forwarder. A Forwarder is
@@ -120,6 +177,9 @@ func (fh *forwarderHealth) healthCheckLoop() {
 		case <-fh.stop:
 			return
 		case <-validateTicker.C:
+			// the API keys of the domain resolvers are updated when their secrets are rotated
+			fh.keysPerAPIEndpoint = make(map[string][]string)
+			fh.computeDomainsURL()
 			valid := fh.hasValidAPIKey()
 			if !valid {
 				log.Errorf("No valid api key found, reporting the forwarder as unhealthy.")
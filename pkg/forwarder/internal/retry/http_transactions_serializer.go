@@ -85,6 +85,9 @@ func (s *HTTPTransactionsSerializer) Add(transaction *transaction.HTTPTransactio
 		CreatedAt:  transaction.CreatedAt.Unix(),
 		Retryable:  transaction.Retryable,
 		Priority:   priority,
+		// The idempotency key is kept so that a transaction read back from the disk is not
+		// sent again when it already received a 2xx response.
+		IdempotencyKey: transaction.IdempotencyKey,
 	}
 	s.collection.Values = append(s.collection.Values, &transactionProto)
 	return nil
@@ -112,6 +115,11 @@ func (s *HTTPTransactionsSerializer) Deserialize(bytes []byte) ([]transaction.Tr
 		var route string
 		var proto http.Header
 		e := tr.Endpoint
+		if e == nil {
+			log.Errorf("Error when deserializing a transaction: no endpoint")
+			errorCount++
+			continue
+		}
 
 		priority, err := fromTransactionPriorityProto(tr.Priority)
 		if err == nil {
@@ -139,6 +147,7 @@ func (s *HTTPTransactionsSerializer) Deserialize(bytes []byte) ([]transaction.Tr
 			Retryable:      tr.Retryable,
 			StorableOnDisk: true,
 			Priority:       priority,
+			IdempotencyKey: tr.IdempotencyKey,
 		}
 		tr.SetDefaultHandlers()
 		httpTransactions = append(httpTransactions, &tr)
@@ -22,8 +22,14 @@ import (
 const retryTransactionsExtension = ".retry"
 const retryFileFormat = "2006_01_02__15_04_05_"
 
+// quarantineFolder is the folder, relative to the storage path, where the files which
+// cannot be read back are moved to. It holds at most maxQuarantinedFiles files.
+const quarantineFolder = "quarantine"
+const maxQuarantinedFiles = 10
+
 type onDiskRetryQueue struct {
 	serializer         *HTTPTransactionsSerializer
+	codec              *retryFileCodec
 	storagePath        string
 	diskUsageLimit     *diskUsageLimit
 	filenames          []string
@@ -33,6 +39,7 @@ type onDiskRetryQueue struct {
 
 func newOnDiskRetryQueue(
 	serializer *HTTPTransactionsSerializer,
+	codec *retryFileCodec,
 	storagePath string,
 	diskUsageLimit *diskUsageLimit,
 	telemetry onDiskRetryQueueTelemetry) (*onDiskRetryQueue, error) {
@@ -43,6 +50,7 @@ func newOnDiskRetryQueue(
 
 	storage := &onDiskRetryQueue{
 		serializer:     serializer,
+		codec:          codec,
 		storagePath:    storagePath,
 		diskUsageLimit: diskUsageLimit,
 		telemetry:      telemetry,
@@ -73,7 +81,11 @@ func (s *onDiskRetryQueue) Serialize(transactions []transaction.Transaction) err
 		}
 	}
 
-	bytes, err := s.serializer.GetBytesAndReset()
+	content, err := s.serializer.GetBytesAndReset()
+	if err != nil {
+		return err
+	}
+	bytes, err := s.codec.encode(content)
 	if err != nil {
 		return err
 	}
@@ -104,33 +116,56 @@ func (s *onDiskRetryQueue) Serialize(transactions []transaction.Transaction) err
 }
 
 // Deserialize deserializes a transactions from the file system.
+// The files which cannot be read back are moved to the quarantine folder and skipped.
 func (s *onDiskRetryQueue) Deserialize() ([]transaction.Transaction, error) {
-	if len(s.filenames) == 0 {
-		return nil, nil
-	}
-	s.telemetry.addDeserializeCount()
-	index := len(s.filenames) - 1
-	path := s.filenames[index]
-	bytes, err := ioutil.ReadFile(path)
+	for len(s.filenames) > 0 {
+		s.telemetry.addDeserializeCount()
+		index := len(s.filenames) - 1
+		path := s.filenames[index]
+		bytes, err := ioutil.ReadFile(path)
+		if err != nil {
+			// Remove the file even in case of a read failure.
+			if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
+				return nil, errRemoveFile
+			}
+			return nil, err
+		}
 
-	// Remove the file even in case of a read failure.
-	if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
-		return nil, errRemoveFile
+		transactions, errorsCount, err := s.decode(bytes)
+		if err != nil {
+			log.Errorf("Cannot read the retry file %s, moving it to the quarantine folder: %v", path, err)
+			if errQuarantine := s.quarantineFileAt(index); errQuarantine != nil {
+				return nil, errQuarantine
+			}
+			s.telemetry.addFilesQuarantinedCount()
+			continue
+		}
+
+		if errRemoveFile := s.removeFileAt(index); errRemoveFile != nil {
+			return nil, errRemoveFile
+		}
+		s.telemetry.addDeserializeErrorsCount(errorsCount)
+		s.telemetry.addDeserializeTransactionsCount(len(transactions))
+		s.telemetry.setCurrentSizeInBytes(s.getCurrentSizeInBytes())
+		s.telemetry.setFilesCount(s.getFilesCount())
+		return transactions, nil
 	}
+	s.telemetry.setCurrentSizeInBytes(s.getCurrentSizeInBytes())
+	s.telemetry.setFilesCount(s.getFilesCount())
+	return nil, nil
+}
 
+// decode returns the transactions of the content of a `.retry` file
+func (s *onDiskRetryQueue) decode(bytes []byte) ([]transaction.Transaction, int, error) {
+	content, err := s.codec.decode(bytes)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
-
-	transactions, errorsCount, err := s.serializer.Deserialize(bytes)
+	transactions, errorsCount, err := s.serializer.Deserialize(content)
 	if err != nil {
-		return nil, err
+		return nil, 0, fmt.Errorf("%w: %v", errCorruptedRetryFile, err)
 	}
-	s.telemetry.addDeserializeErrorsCount(errorsCount)
-	s.telemetry.addDeserializeTransactionsCount(len(transactions))
-	s.telemetry.setCurrentSizeInBytes(s.getCurrentSizeInBytes())
-	s.telemetry.setFilesCount(s.getFilesCount())
-	return transactions, err
+	return transactions, errorsCount, nil
 }
 
 // GetFileCount returns the current files count.
@@ -186,6 +221,42 @@ func (s *onDiskRetryQueue) removeFileAt(index int) error {
 	return nil
 }
 
+// quarantineFileAt moves the file to the quarantine folder, removing the oldest
+// quarantined files to keep at most maxQuarantinedFiles.
+func (s *onDiskRetryQueue) quarantineFileAt(index int) error {
+	filename := s.filenames[index]
+	s.filenames = append(s.filenames[:index], s.filenames[index+1:]...)
+
+	size, err := util.GetFileSize(filename)
+	if err != nil {
+		return err
+	}
+
+	folder := path.Join(s.storagePath, quarantineFolder)
+	if err := os.MkdirAll(folder, 0700); err != nil {
+		return err
+	}
+	if err := os.Rename(filename, path.Join(folder, filepath.Base(filename))); err != nil {
+		return err
+	}
+	s.currentSizeInBytes -= size
+
+	entries, err := ioutil.ReadDir(folder)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for len(entries) > maxQuarantinedFiles {
+		if err := os.Remove(path.Join(folder, entries[0].Name())); err != nil {
+			return err
+		}
+		entries = entries[1:]
+	}
+	return nil
+}
+
 func (s *onDiskRetryQueue) reloadExistingRetryFiles() error {
 	files, sizeInBytes, err := s.getExistingRetryFiles()
 	if err != nil {
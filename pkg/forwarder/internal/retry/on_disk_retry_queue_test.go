@@ -8,6 +8,7 @@ package retry
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strconv"
 	"testing"
 
@@ -48,7 +49,7 @@ func TestOnDiskRetryQueueMaxSize(t *testing.T) {
 	path, clean := createTmpFolder(a)
 	defer clean()
 
-	maxSizeInBytes := int64(100)
+	maxSizeInBytes := int64(300)
 	q := newTestOnDiskRetryQueue(a, path, maxSizeInBytes)
 
 	i := 0
@@ -91,6 +92,99 @@ func TestOnDiskRetryQueueReloadExistingRetryFiles(t *testing.T) {
 	a.Equal([]string{"endpoint1", "endpoint2"}, getEndpointsFromTransactions(transactions))
 }
 
+func TestOnDiskRetryQueueEncryption(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	codec, err := newRetryFileCodec([]byte("secret"))
+	a.NoError(err)
+	q := newTestOnDiskRetryQueueWithCodec(a, path, 1000, codec)
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("endpoint1", "endpoint2")))
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("endpoint3")))
+
+	content, err := ioutil.ReadFile(q.filenames[0])
+	a.NoError(err)
+	a.NotContains(string(content), "endpoint1")
+
+	transactions, err := q.Deserialize()
+	a.NoError(err)
+	a.Equal([]string{"endpoint3"}, getEndpointsFromTransactions(transactions))
+
+	// the file cannot be decrypted with another key
+	otherCodec, err := newRetryFileCodec([]byte("other secret"))
+	a.NoError(err)
+	q = newTestOnDiskRetryQueueWithCodec(a, path, 1000, otherCodec)
+	a.Equal(1, q.getFilesCount())
+	transactions, err = q.Deserialize()
+	a.NoError(err)
+	a.Empty(transactions)
+	a.Equal(0, q.getFilesCount())
+	a.Equal(int64(0), q.getCurrentSizeInBytes())
+
+	quarantined, err := ioutil.ReadDir(filepath.Join(path, quarantineFolder))
+	a.NoError(err)
+	a.Len(quarantined, 1)
+}
+
+func TestOnDiskRetryQueueQuarantine(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	q := newTestOnDiskRetryQueue(a, path, 100000)
+	for i := 0; i < maxQuarantinedFiles+2; i++ {
+		a.NoError(q.Serialize(createHTTPTransactionCollectionTests(strconv.Itoa(i))))
+	}
+	a.NoError(q.Serialize(createHTTPTransactionCollectionTests("valid")))
+	for _, filename := range q.filenames[1 : len(q.filenames)-1] {
+		content, err := ioutil.ReadFile(filename)
+		a.NoError(err)
+		content[len(content)-1]++
+		a.NoError(ioutil.WriteFile(filename, content, 0600))
+	}
+
+	transactions, err := q.Deserialize()
+	a.NoError(err)
+	a.Equal([]string{"valid"}, getEndpointsFromTransactions(transactions))
+
+	// the corrupted files are skipped
+	transactions, err = q.Deserialize()
+	a.NoError(err)
+	a.Equal([]string{"0"}, getEndpointsFromTransactions(transactions))
+	a.Equal(0, q.getFilesCount())
+
+	quarantined, err := ioutil.ReadDir(filepath.Join(path, quarantineFolder))
+	a.NoError(err)
+	a.Len(quarantined, maxQuarantinedFiles)
+
+	// the quarantined files are not reloaded
+	q = newTestOnDiskRetryQueue(a, path, 100000)
+	a.Equal(0, q.getFilesCount())
+}
+
+func TestOnDiskRetryQueueFileWithoutHeader(t *testing.T) {
+	a := assert.New(t)
+	path, clean := createTmpFolder(a)
+	defer clean()
+
+	// files written by previous versions of the Agent only hold the serialized transactions
+	serializer := NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil))
+	for _, tr := range createHTTPTransactionCollectionTests("endpoint1") {
+		a.NoError(tr.SerializeTo(serializer))
+	}
+	content, err := serializer.GetBytesAndReset()
+	a.NoError(err)
+	a.NoError(ioutil.WriteFile(filepath.Join(path, "old"+retryTransactionsExtension), content, 0600))
+
+	codec, err := newRetryFileCodec([]byte("secret"))
+	a.NoError(err)
+	q := newTestOnDiskRetryQueueWithCodec(a, path, 1000, codec)
+	transactions, err := q.Deserialize()
+	a.NoError(err)
+	a.Equal([]string{"endpoint1"}, getEndpointsFromTransactions(transactions))
+}
+
 func createHTTPTransactionCollectionTests(endpoints ...string) []transaction.Transaction {
 	var transactions []transaction.Transaction
 
@@ -106,7 +200,7 @@ func createHTTPTransactionCollectionTests(endpoints ...string) []transaction.Tra
 func createTmpFolder(a *assert.Assertions) (string, func()) {
 	path, err := ioutil.TempDir("", "tests")
 	a.NoError(err)
-	return path, func() { _ = os.Remove(path) }
+	return path, func() { _ = os.RemoveAll(path) }
 }
 
 func getEndpointsFromTransactions(transactions []transaction.Transaction) []string {
@@ -119,6 +213,10 @@ func getEndpointsFromTransactions(transactions []transaction.Transaction) []stri
 }
 
 func newTestOnDiskRetryQueue(a *assert.Assertions, path string, maxSizeInBytes int64) *onDiskRetryQueue {
+	return newTestOnDiskRetryQueueWithCodec(a, path, maxSizeInBytes, &retryFileCodec{})
+}
+
+func newTestOnDiskRetryQueueWithCodec(a *assert.Assertions, path string, maxSizeInBytes int64, codec *retryFileCodec) *onDiskRetryQueue {
 	telemetry := newOnDiskRetryQueueTelemetry("domain")
 	disk := diskUsageRetrieverMock{
 		diskUsage: &filesystem.DiskUsage{
@@ -126,7 +224,7 @@ func newTestOnDiskRetryQueue(a *assert.Assertions, path string, maxSizeInBytes i
 			Total:     10000,
 		}}
 	diskUsageLimit := newDiskUsageLimit("", disk, maxSizeInBytes, 1)
-	storage, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil)), path, diskUsageLimit, telemetry)
+	storage, err := newOnDiskRetryQueue(NewHTTPTransactionsSerializer(resolver.NewSingleDomainResolver(domainName, nil)), codec, path, diskUsageLimit, telemetry)
 	a.NoError(err)
 	return storage
 }
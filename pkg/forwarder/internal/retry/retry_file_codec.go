@@ -0,0 +1,168 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/api/security"
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+// The content of a `.retry` file is prefixed by a header:
+//   - retryFileMagic
+//   - the version of the format (1 byte)
+//   - flags (1 byte), see retryFileFlagEncrypted
+//   - the SHA-256 checksum of the rest of the file (32 bytes)
+//
+// Files written by previous versions of the Agent have no header and hold the
+// serialized transactions as is. They are still read.
+const (
+	retryFileVersion       = 1
+	retryFileFlagEncrypted = 1 << 0
+	retryFileHeaderSize    = len(retryFileMagic) + 2 + sha256.Size
+
+	// retryFileMagic starts with a byte which cannot start a protobuf message with
+	// a version field, to tell the files without header apart.
+	retryFileMagic = "\xfeDDR"
+
+	retryFileKeySalt = "datadog-agent forwarder retry files"
+	retryFileKeyInfo = "aes-256-gcm v1"
+)
+
+// errCorruptedRetryFile is returned when a `.retry` file cannot be read back
+var errCorruptedRetryFile = errors.New("corrupted retry file")
+
+// retryFileCodec adds a checksum to the content of the `.retry` files and optionally
+// encrypts it with AES-256-GCM.
+type retryFileCodec struct {
+	// aead is nil when the encryption is disabled
+	aead cipher.AEAD
+}
+
+// newRetryFileCodec returns a retryFileCodec encrypting the files with a key derived from
+// the given secret, or only adding a checksum if secret is nil.
+func newRetryFileCodec(secret []byte) (*retryFileCodec, error) {
+	if secret == nil {
+		return &retryFileCodec{}, nil
+	}
+	if len(secret) == 0 {
+		return nil, errors.New("the secret used to encrypt the retry files is empty")
+	}
+
+	block, err := aes.NewCipher(deriveRetryFileKey(secret))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &retryFileCodec{aead: aead}, nil
+}
+
+// newRetryFileCodecFromConfig returns the retryFileCodec configured with
+// `forwarder_storage_encryption_enabled`. The key is derived from the content of
+// `forwarder_storage_encryption_key_file`, or from the auth token of the Agent.
+func newRetryFileCodecFromConfig() (*retryFileCodec, error) {
+	if !config.Datadog.GetBool("forwarder_storage_encryption_enabled") {
+		return newRetryFileCodec(nil)
+	}
+
+	var secret []byte
+	if keyFile := config.Datadog.GetString("forwarder_storage_encryption_key_file"); keyFile != "" {
+		content, err := ioutil.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the retry files encryption key: %v", err)
+		}
+		secret = []byte(strings.TrimSpace(string(content)))
+	} else {
+		token, err := security.FetchAuthToken()
+		if err != nil {
+			return nil, fmt.Errorf("unable to read the auth token to encrypt the retry files, set forwarder_storage_encryption_key_file: %v", err)
+		}
+		secret = []byte(token)
+	}
+	return newRetryFileCodec(secret)
+}
+
+// deriveRetryFileKey derives a 256-bit key from the secret with HKDF-SHA256 (RFC 5869)
+func deriveRetryFileKey(secret []byte) []byte {
+	extract := hmac.New(sha256.New, []byte(retryFileKeySalt))
+	extract.Write(secret)
+	expand := hmac.New(sha256.New, extract.Sum(nil))
+	expand.Write([]byte(retryFileKeyInfo))
+	expand.Write([]byte{1})
+	return expand.Sum(nil)
+}
+
+// encode returns the content of a `.retry` file holding the given serialized transactions
+func (c *retryFileCodec) encode(content []byte) ([]byte, error) {
+	var flags byte
+	body := content
+	if c.aead != nil {
+		flags |= retryFileFlagEncrypted
+		nonce := make([]byte, c.aead.NonceSize(), c.aead.NonceSize()+len(content)+c.aead.Overhead())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		body = c.aead.Seal(nonce, nonce, content, []byte(retryFileMagic))
+	}
+
+	checksum := sha256.Sum256(body)
+	data := make([]byte, 0, retryFileHeaderSize+len(body))
+	data = append(data, retryFileMagic...)
+	data = append(data, retryFileVersion, flags)
+	data = append(data, checksum[:]...)
+	return append(data, body...), nil
+}
+
+// decode returns the serialized transactions of a `.retry` file. The error wraps
+// errCorruptedRetryFile when the file cannot be read back.
+func (c *retryFileCodec) decode(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, []byte(retryFileMagic)) {
+		// written by a previous version of the Agent
+		return data, nil
+	}
+	if len(data) < retryFileHeaderSize {
+		return nil, fmt.Errorf("%w: truncated header", errCorruptedRetryFile)
+	}
+
+	version, flags := data[len(retryFileMagic)], data[len(retryFileMagic)+1]
+	if version != retryFileVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", errCorruptedRetryFile, version)
+	}
+	body := data[retryFileHeaderSize:]
+	checksum := sha256.Sum256(body)
+	if !bytes.Equal(checksum[:], data[len(retryFileMagic)+2:retryFileHeaderSize]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", errCorruptedRetryFile)
+	}
+
+	if flags&retryFileFlagEncrypted == 0 {
+		return body, nil
+	}
+	if c.aead == nil {
+		return nil, fmt.Errorf("%w: the file is encrypted but the encryption is disabled", errCorruptedRetryFile)
+	}
+	if len(body) < c.aead.NonceSize() {
+		return nil, fmt.Errorf("%w: truncated nonce", errCorruptedRetryFile)
+	}
+	nonce, ciphertext := body[:c.aead.NonceSize()], body[c.aead.NonceSize():]
+	content, err := c.aead.Open(nil, nonce, ciphertext, []byte(retryFileMagic))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unable to decrypt, the encryption key may have changed: %v", errCorruptedRetryFile, err)
+	}
+	return content, nil
+}
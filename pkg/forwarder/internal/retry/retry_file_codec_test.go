@@ -0,0 +1,122 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package retry
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+)
+
+func TestRetryFileCodecChecksum(t *testing.T) {
+	codec, err := newRetryFileCodec(nil)
+	require.NoError(t, err)
+
+	data, err := codec.encode([]byte("content"))
+	require.NoError(t, err)
+	assert.Len(t, data, retryFileHeaderSize+len("content"))
+	assert.Contains(t, string(data), "content")
+
+	content, err := codec.decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("content"), content)
+
+	for _, corrupted := range [][]byte{
+		data[:retryFileHeaderSize-1],
+		append(data[:len(data)-1:len(data)-1], 'X'),
+		append([]byte(retryFileMagic), append([]byte{retryFileVersion + 1}, data[len(retryFileMagic)+1:]...)...),
+	} {
+		_, err = codec.decode(corrupted)
+		assert.True(t, errors.Is(err, errCorruptedRetryFile), "unexpected error: %v", err)
+	}
+}
+
+func TestRetryFileCodecEncryption(t *testing.T) {
+	codec, err := newRetryFileCodec([]byte("secret"))
+	require.NoError(t, err)
+
+	data, err := codec.encode([]byte("content"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "content")
+
+	content, err := codec.decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("content"), content)
+
+	// the nonce is random
+	other, err := codec.encode([]byte("content"))
+	require.NoError(t, err)
+	assert.NotEqual(t, data, other)
+
+	otherCodec, err := newRetryFileCodec([]byte("other secret"))
+	require.NoError(t, err)
+	_, err = otherCodec.decode(data)
+	assert.True(t, errors.Is(err, errCorruptedRetryFile))
+
+	plainCodec, err := newRetryFileCodec(nil)
+	require.NoError(t, err)
+	_, err = plainCodec.decode(data)
+	assert.True(t, errors.Is(err, errCorruptedRetryFile))
+
+	// the files written without encryption can still be read
+	data, err = plainCodec.encode([]byte("content"))
+	require.NoError(t, err)
+	content, err = codec.decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("content"), content)
+
+	_, err = newRetryFileCodec([]byte{})
+	assert.Error(t, err)
+}
+
+func TestRetryFileCodecWithoutHeader(t *testing.T) {
+	codec, err := newRetryFileCodec([]byte("secret"))
+	require.NoError(t, err)
+
+	content, err := codec.decode([]byte("\x08\x01"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("\x08\x01"), content)
+}
+
+func TestRetryFileCodecFromConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "retry_file_codec")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	keyFile := filepath.Join(dir, "key")
+	require.NoError(t, ioutil.WriteFile(keyFile, []byte("secret\n"), 0600))
+
+	mockConfig := config.Mock()
+	mockConfig.Set("forwarder_storage_encryption_enabled", false)
+	codec, err := newRetryFileCodecFromConfig()
+	require.NoError(t, err)
+	assert.Nil(t, codec.aead)
+
+	mockConfig.Set("forwarder_storage_encryption_enabled", true)
+	mockConfig.Set("forwarder_storage_encryption_key_file", keyFile)
+	codec, err = newRetryFileCodecFromConfig()
+	require.NoError(t, err)
+	assert.NotNil(t, codec.aead)
+
+	// the trailing new line of the key file is ignored
+	expected, err := newRetryFileCodec([]byte("secret"))
+	require.NoError(t, err)
+	data, err := codec.encode([]byte("content"))
+	require.NoError(t, err)
+	content, err := expected.decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("content"), content)
+
+	mockConfig.Set("forwarder_storage_encryption_key_file", filepath.Join(dir, "missing"))
+	_, err = newRetryFileCodecFromConfig()
+	assert.Error(t, err)
+}
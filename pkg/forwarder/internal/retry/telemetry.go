@@ -74,6 +74,7 @@ var (
 	filesRemovedCountTelemetry              *counterExpvar
 	deserializeErrorsCountTelemetry         *counterExpvar
 	deserializeTransactionsCountTelemetry   *counterExpvar
+	filesQuarantinedCountTelemetry          *counterExpvar
 )
 
 func init() {
@@ -185,6 +186,12 @@ func init() {
 		domainTag,
 		"The number of transactions read from the disk",
 		&fileStorageExpvar)
+	filesQuarantinedCountTelemetry = newCounterExpvar(
+		"file_storage",
+		"files_quarantined_count",
+		domainTag,
+		"The number of files moved to the quarantine folder because they could not be read back",
+		&fileStorageExpvar)
 }
 
 // FileRemovalPolicyTelemetry handles the telemetry for FileRemovalPolicy.
@@ -279,6 +286,10 @@ func (t onDiskRetryQueueTelemetry) addDeserializeTransactionsCount(count int) {
 	deserializeTransactionsCountTelemetry.add(float64(count), t.domainName)
 }
 
+func (t onDiskRetryQueueTelemetry) addFilesQuarantinedCount() {
+	filesQuarantinedCountTelemetry.add(1, t.domainName)
+}
+
 func toCamelCase(s string) string {
 	parts := strings.Split(s, "_")
 	var camelCase string
@@ -56,8 +56,12 @@ func BuildTransactionRetryQueue(
 		serializer := NewHTTPTransactionsSerializer(resolver)
 		diskRatio := config.Datadog.GetFloat64("forwarder_storage_max_disk_ratio")
 
-		diskUsageLimit := newDiskUsageLimit(optionalDomainFolderPath, filesystem.NewDisk(), storageMaxSize, diskRatio)
-		storage, err = newOnDiskRetryQueue(serializer, optionalDomainFolderPath, diskUsageLimit, newOnDiskRetryQueueTelemetry(resolver.GetBaseDomain()))
+		// Do not store the transactions unencrypted when the encryption cannot be set up
+		var codec *retryFileCodec
+		if codec, err = newRetryFileCodecFromConfig(); err == nil {
+			diskUsageLimit := newDiskUsageLimit(optionalDomainFolderPath, filesystem.NewDisk(), storageMaxSize, diskRatio)
+			storage, err = newOnDiskRetryQueue(serializer, codec, optionalDomainFolderPath, diskUsageLimit, newOnDiskRetryQueueTelemetry(resolver.GetBaseDomain()))
+		}
 
 		// If the storage on disk cannot be used, log the error and continue.
 		// Returning `nil, err` would mean not using `TransactionRetryQueue` and so not using `forwarder_retry_queue_payloads_max_size` config.
@@ -0,0 +1,202 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/transaction"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// sinkQueueSize is the number of payloads waiting to be sent to a sink, the
+	// payloads submitted when the queue is full are dropped
+	sinkQueueSize = 100
+	// sinkStopTimeout is the time given to the sinks to send the queued payloads when stopping
+	sinkStopTimeout = 2 * time.Second
+
+	sinkStatusSent    = "sent"
+	sinkStatusDropped = "dropped"
+	sinkStatusError   = "error"
+)
+
+var (
+	tlmSinkPayloads = telemetry.NewCounter("forwarder", "sink_payloads",
+		[]string{"sink", "endpoint", "status"}, "Count of payloads copied to the forwarder sinks")
+)
+
+// SinkPayload is a payload submitted to the forwarder. It is shared by the sinks and must
+// not be modified.
+type SinkPayload struct {
+	Endpoint  transaction.Endpoint
+	Payload   []byte
+	Headers   http.Header
+	CreatedAt time.Time
+}
+
+// Sink receives a copy of the payloads submitted to the forwarder, in addition to
+// the Datadog domains. A Sink is only used by one goroutine.
+type Sink interface {
+	// Name identifies the sink in the logs and in the telemetry
+	Name() string
+	Send(payload *SinkPayload) error
+	Close() error
+}
+
+// sinkWorker sends the payloads of the given endpoints to a Sink asynchronously
+type sinkWorker struct {
+	sink Sink
+	// endpoints holds the names of the endpoints to send, all of them if nil
+	endpoints map[string]struct{}
+	input     chan *SinkPayload
+	stopped   chan struct{}
+
+	// mu protects input from being closed while a payload is submitted
+	mu      sync.RWMutex
+	running bool
+}
+
+func newSinkWorker(sink Sink, endpoints []string) *sinkWorker {
+	w := &sinkWorker{sink: sink}
+	if len(endpoints) > 0 {
+		w.endpoints = make(map[string]struct{}, len(endpoints))
+		for _, e := range endpoints {
+			w.endpoints[e] = struct{}{}
+		}
+	}
+	return w
+}
+
+func (w *sinkWorker) start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.input = make(chan *SinkPayload, sinkQueueSize)
+	w.stopped = make(chan struct{})
+	w.running = true
+	go func() {
+		defer close(w.stopped)
+		for payload := range w.input {
+			if err := w.sink.Send(payload); err != nil {
+				log.Debugf("Cannot send a %s payload to the forwarder sink %s: %v", payload.Endpoint.Name, w.sink.Name(), err)
+				tlmSinkPayloads.Inc(w.sink.Name(), payload.Endpoint.Name, sinkStatusError)
+				continue
+			}
+			tlmSinkPayloads.Inc(w.sink.Name(), payload.Endpoint.Name, sinkStatusSent)
+		}
+		if err := w.sink.Close(); err != nil {
+			log.Warnf("Error when closing the forwarder sink %s: %v", w.sink.Name(), err)
+		}
+	}()
+}
+
+// stop sends the queued payloads and closes the sink, waiting at most timeout
+func (w *sinkWorker) stop(timeout time.Duration) {
+	w.mu.Lock()
+	if !w.running {
+		w.mu.Unlock()
+		return
+	}
+	w.running = false
+	close(w.input)
+	w.mu.Unlock()
+
+	select {
+	case <-w.stopped:
+	case <-time.After(timeout):
+		log.Warnf("Timeout sending the queued payloads to the forwarder sink %s", w.sink.Name())
+	}
+}
+
+// submit queues the payload if its endpoint is sent to the sink. It never blocks.
+func (w *sinkWorker) submit(payload *SinkPayload) {
+	if w.endpoints != nil {
+		if _, found := w.endpoints[payload.Endpoint.Name]; !found {
+			return
+		}
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if !w.running {
+		return
+	}
+	select {
+	case w.input <- payload:
+	default:
+		tlmSinkPayloads.Inc(w.sink.Name(), payload.Endpoint.Name, sinkStatusDropped)
+	}
+}
+
+// endpointsSink is a Sink only receiving the payloads of some endpoints
+type endpointsSink struct {
+	Sink
+	endpoints []string
+}
+
+// NewSinksFromConfig returns the sinks set in `forwarder_sinks`, to pass in the Options of
+// the forwarder. They must be built once per process and given to a single forwarder, so
+// that the payloads are not copied several times. The invalid sinks are logged and ignored.
+func NewSinksFromConfig() []Sink {
+	cfg, err := config.GetForwarderSinks()
+	if err != nil {
+		log.Errorf("Forwarder sinks are disabled: %v", err)
+		return nil
+	}
+
+	var sinks []Sink
+	for i, c := range cfg {
+		sink, err := newSink(c)
+		if err != nil {
+			log.Errorf("Invalid forwarder sink #%d: %v", i+1, err)
+			continue
+		}
+		log.Infof("Forwarder payloads are copied to the sink %s", sink.Name())
+		if len(c.Endpoints) > 0 {
+			sink = &endpointsSink{Sink: sink, endpoints: c.Endpoints}
+		}
+		sinks = append(sinks, sink)
+	}
+	return sinks
+}
+
+// newSinkWorkers returns the workers of the given sinks
+func newSinkWorkers(sinks []Sink) []*sinkWorker {
+	var workers []*sinkWorker
+	for _, sink := range sinks {
+		var endpoints []string
+		if s, ok := sink.(*endpointsSink); ok {
+			sink, endpoints = s.Sink, s.endpoints
+		}
+		workers = append(workers, newSinkWorker(sink, endpoints))
+	}
+	return workers
+}
+
+func newSink(c config.ForwarderSink) (Sink, error) {
+	switch c.Type {
+	case "file":
+		sink, err := newFileSink(c.Path, c.MaxSize, c.MaxFiles)
+		if err != nil {
+			return nil, err
+		}
+		return sink, nil
+	case "http":
+		sink, err := newHTTPSink(c.URL)
+		if err != nil {
+			return nil, err
+		}
+		return sink, nil
+	default:
+		return nil, fmt.Errorf("unknown type %q, expected \"file\" or \"http\"", c.Type)
+	}
+}
@@ -0,0 +1,163 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+)
+
+const (
+	defaultFileSinkMaxSize  = 10 * 1024 * 1024
+	defaultFileSinkMaxFiles = 5
+)
+
+// fileSinkRecord is a line of the file written by a fileSink
+type fileSinkRecord struct {
+	Time     time.Time         `json:"time"`
+	Endpoint string            `json:"endpoint"`
+	Route    string            `json:"route"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	// Payload holds the decompressed payload when it is a valid JSON document,
+	// PayloadBase64 holds it otherwise (protobuf payloads for instance).
+	Payload       json.RawMessage `json:"payload,omitempty"`
+	PayloadBase64 []byte          `json:"payload_base64,omitempty"`
+}
+
+// fileSink writes the payloads, decompressed, as JSON lines to a local file. The file
+// is rotated when it reaches maxSize, keeping maxFiles rotated files: path.1 is the
+// most recent one.
+type fileSink struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	file *os.File
+	size int64
+}
+
+func newFileSink(path string, maxSize int64, maxFiles int) (*fileSink, error) {
+	if path == "" {
+		return nil, errors.New("no path set")
+	}
+	if maxSize <= 0 {
+		maxSize = defaultFileSinkMaxSize
+	}
+	if maxFiles <= 0 {
+		maxFiles = defaultFileSinkMaxFiles
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	return &fileSink{path: path, maxSize: maxSize, maxFiles: maxFiles}, nil
+}
+
+// Name returns the name of the sink
+func (s *fileSink) Name() string {
+	return "file:" + s.path
+}
+
+// Send writes the payload to the file
+func (s *fileSink) Send(payload *SinkPayload) error {
+	line, err := json.Marshal(newFileSinkRecord(payload))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	if s.file != nil && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+// Close closes the file, it is opened again by the next call to Send
+func (s *fileSink) Close() error {
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
+
+func (s *fileSink) open() error {
+	f, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	s.file = f
+	s.size = info.Size()
+	return nil
+}
+
+// rotate renames path.N-1 to path.N, ..., path to path.1 and removes path.maxFiles
+func (s *fileSink) rotate() error {
+	if err := s.Close(); err != nil {
+		return err
+	}
+	_ = os.Remove(fmt.Sprintf("%s.%d", s.path, s.maxFiles))
+	for i := s.maxFiles - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", s.path, i), fmt.Sprintf("%s.%d", s.path, i+1))
+	}
+	return os.Rename(s.path, s.path+".1")
+}
+
+func newFileSinkRecord(payload *SinkPayload) *fileSinkRecord {
+	record := &fileSinkRecord{
+		Time:     payload.CreatedAt,
+		Endpoint: payload.Endpoint.Name,
+		Route:    payload.Endpoint.Route,
+	}
+	for key := range payload.Headers {
+		if http.CanonicalHeaderKey(key) == http.CanonicalHeaderKey(apiHTTPHeaderKey) {
+			continue
+		}
+		if record.Headers == nil {
+			record.Headers = make(map[string]string)
+		}
+		record.Headers[key] = payload.Headers.Get(key)
+	}
+
+	content := payload.Payload
+	// the payloads aren't all compressed with the build time compression, the logs
+	// and the event platform payloads for instance select theirs at runtime
+	if contentEncoding := payload.Headers.Get("Content-Encoding"); contentEncoding != "" {
+		if compressor, err := compression.NewCompressorForContentEncoding(contentEncoding); err == nil {
+			if decompressed, err := compressor.Decompress(content); err == nil {
+				content = decompressed
+			}
+		}
+	}
+	if json.Valid(content) {
+		record.Payload = content
+	} else {
+		record.PayloadBase64 = content
+	}
+	return record
+}
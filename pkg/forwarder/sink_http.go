@@ -0,0 +1,84 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const httpSinkTimeout = 10 * time.Second
+
+// httpSink posts the payloads as is, with their headers, to the route of their endpoint on
+// another server, a local stand-in of the intake for instance. The API key is not sent.
+type httpSink struct {
+	url    string
+	client *http.Client
+}
+
+func newHTTPSink(rawURL string) (*httpSink, error) {
+	if rawURL == "" {
+		return nil, errors.New("no url set")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q in url %q", u.Scheme, rawURL)
+	}
+	return &httpSink{
+		url:    strings.TrimSuffix(rawURL, "/"),
+		client: &http.Client{Timeout: httpSinkTimeout},
+	}, nil
+}
+
+// Name returns the name of the sink
+func (s *httpSink) Name() string {
+	return "http:" + s.url
+}
+
+// Send posts the payload
+func (s *httpSink) Send(payload *SinkPayload) error {
+	ctx, cancel := context.WithTimeout(context.Background(), httpSinkTimeout)
+	defer cancel()
+
+	req, err := http.NewRequest("POST", s.url+payload.Endpoint.Route, bytes.NewReader(payload.Payload))
+	if err != nil {
+		return err
+	}
+	for key := range payload.Headers {
+		if http.CanonicalHeaderKey(key) == http.CanonicalHeaderKey(apiHTTPHeaderKey) {
+			continue
+		}
+		req.Header.Set(key, payload.Headers.Get(key))
+	}
+
+	resp, err := s.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Close does nothing
+func (s *httpSink) Close() error {
+	return nil
+}
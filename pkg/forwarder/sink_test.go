@@ -0,0 +1,293 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package forwarder
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/config/resolver"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+)
+
+type mockSink struct {
+	sync.Mutex
+	payloads []*SinkPayload
+	closed   bool
+	block    chan struct{}
+}
+
+func (s *mockSink) Name() string { return "mock" }
+
+func (s *mockSink) Send(payload *SinkPayload) error {
+	if s.block != nil {
+		<-s.block
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.payloads = append(s.payloads, payload)
+	return nil
+}
+
+func (s *mockSink) Close() error {
+	s.Lock()
+	defer s.Unlock()
+	s.closed = true
+	return nil
+}
+
+func (s *mockSink) received() []*SinkPayload {
+	s.Lock()
+	defer s.Unlock()
+	return append([]*SinkPayload(nil), s.payloads...)
+}
+
+func readFileSinkRecords(t *testing.T, path string) []fileSinkRecord {
+	f, err := os.Open(path)
+	require.NoError(t, err)
+	defer f.Close()
+
+	var records []fileSinkRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var record fileSinkRecord
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	require.NoError(t, scanner.Err())
+	return records
+}
+
+func TestFileSink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forwarder_sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "sink", "payloads.json")
+
+	sink, err := newFileSink(path, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "file:"+path, sink.Name())
+
+	compressed, err := compression.Compress(nil, []byte(`{"series":[]}`))
+	require.NoError(t, err)
+	headers := http.Header{}
+	headers.Set(apiHTTPHeaderKey, "api_key")
+	headers.Set("Content-Type", "application/json")
+	if compression.ContentEncoding != "" {
+		headers.Set("Content-Encoding", compression.ContentEncoding)
+	}
+	require.NoError(t, sink.Send(&SinkPayload{Endpoint: endpoints.SeriesEndpoint, Payload: compressed, Headers: headers}))
+	require.NoError(t, sink.Send(&SinkPayload{Endpoint: endpoints.SketchSeriesEndpoint, Payload: []byte{0x08, 0xff}}))
+	// the payloads compressed with another compression than the build time one are decoded too
+	gzipCompressor, err := compression.NewCompressor(compression.GzipKind, 0)
+	require.NoError(t, err)
+	gzipped, err := gzipCompressor.Compress([]byte(`{"events":[]}`))
+	require.NoError(t, err)
+	gzipHeaders := http.Header{}
+	gzipHeaders.Set("Content-Encoding", "gzip")
+	require.NoError(t, sink.Send(&SinkPayload{Endpoint: endpoints.V1IntakeEndpoint, Payload: gzipped, Headers: gzipHeaders}))
+	require.NoError(t, sink.Close())
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	records := readFileSinkRecords(t, path)
+	require.Len(t, records, 3)
+	assert.Equal(t, "series_v2", records[0].Endpoint)
+	assert.Equal(t, "/api/v2/series", records[0].Route)
+	assert.JSONEq(t, `{"series":[]}`, string(records[0].Payload))
+	assert.Nil(t, records[0].PayloadBase64)
+	assert.Equal(t, "application/json", records[0].Headers["Content-Type"])
+	assert.NotContains(t, records[0].Headers, apiHTTPHeaderKey)
+	assert.Equal(t, "sketches_v2", records[1].Endpoint)
+	assert.Nil(t, records[1].Payload)
+	assert.Equal(t, []byte{0x08, 0xff}, records[1].PayloadBase64)
+	assert.JSONEq(t, `{"events":[]}`, string(records[2].Payload))
+
+	// the file is opened again after Close
+	require.NoError(t, sink.Send(&SinkPayload{Endpoint: endpoints.SeriesEndpoint, Payload: []byte(`{}`)}))
+	require.NoError(t, sink.Close())
+	assert.Len(t, readFileSinkRecords(t, path), 4)
+}
+
+func TestFileSinkRotation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forwarder_sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "payloads.json")
+
+	sink, err := newFileSink(path, 200, 2)
+	require.NoError(t, err)
+	defer sink.Close()
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, sink.Send(&SinkPayload{Endpoint: endpoints.SeriesEndpoint, Payload: []byte(`{"payload":"payload"}`)}))
+	}
+
+	files, err := filepath.Glob(path + "*")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{path, path + ".1", path + ".2"}, files)
+	for _, file := range files {
+		info, err := os.Stat(file)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, info.Size(), int64(200))
+		assert.NotEmpty(t, readFileSinkRecords(t, file))
+	}
+}
+
+func TestHTTPSink(t *testing.T) {
+	var mu sync.Mutex
+	var requests []*http.Request
+	var bodies []string
+	status := http.StatusOK
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r)
+		bodies = append(bodies, string(body))
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	sink, err := newHTTPSink(ts.URL + "/")
+	require.NoError(t, err)
+	assert.Equal(t, "http:"+ts.URL, sink.Name())
+
+	headers := http.Header{}
+	headers.Set(apiHTTPHeaderKey, "api_key")
+	headers.Set("Content-Type", "application/json")
+	require.NoError(t, sink.Send(&SinkPayload{Endpoint: endpoints.SeriesEndpoint, Payload: []byte("payload"), Headers: headers}))
+
+	mu.Lock()
+	require.Len(t, requests, 1)
+	assert.Equal(t, "POST", requests[0].Method)
+	assert.Equal(t, "/api/v2/series", requests[0].URL.Path)
+	assert.Equal(t, "application/json", requests[0].Header.Get("Content-Type"))
+	assert.Empty(t, requests[0].Header.Get(apiHTTPHeaderKey))
+	assert.Equal(t, "payload", bodies[0])
+	status = http.StatusInternalServerError
+	mu.Unlock()
+
+	assert.Error(t, sink.Send(&SinkPayload{Endpoint: endpoints.SeriesEndpoint, Payload: []byte("payload")}))
+
+	for _, invalid := range []string{"", "ftp://localhost", ":invalid"} {
+		_, err := newHTTPSink(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSinkWorker(t *testing.T) {
+	sink := &mockSink{}
+	w := newSinkWorker(sink, []string{"series_v2"})
+
+	// payloads submitted before start are ignored
+	w.submit(&SinkPayload{Endpoint: endpoints.SeriesEndpoint})
+
+	w.start()
+	w.submit(&SinkPayload{Endpoint: endpoints.SeriesEndpoint})
+	w.submit(&SinkPayload{Endpoint: endpoints.EventsEndpoint})
+	w.stop(time.Second)
+
+	require.Len(t, sink.received(), 1)
+	assert.Equal(t, endpoints.SeriesEndpoint, sink.received()[0].Endpoint)
+	assert.True(t, sink.closed)
+
+	// payloads submitted after stop are ignored
+	w.submit(&SinkPayload{Endpoint: endpoints.SeriesEndpoint})
+	w.stop(time.Second)
+	assert.Len(t, sink.received(), 1)
+}
+
+func TestSinkWorkerDropsWhenFull(t *testing.T) {
+	sink := &mockSink{block: make(chan struct{})}
+	w := newSinkWorker(sink, nil)
+	w.start()
+
+	// one payload is held by Send, sinkQueueSize are queued and the others are dropped
+	for i := 0; i < sinkQueueSize+10; i++ {
+		w.submit(&SinkPayload{Endpoint: endpoints.SeriesEndpoint})
+	}
+	close(sink.block)
+	w.stop(time.Second)
+
+	received := len(sink.received())
+	assert.True(t, received >= sinkQueueSize && received <= sinkQueueSize+1, "received %d payloads", received)
+}
+
+func TestNewSinksFromConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forwarder_sink")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	mockConfig := config.Mock()
+	defer mockConfig.Set("forwarder_sinks", nil)
+	mockConfig.Set("forwarder_sinks", []map[string]interface{}{
+		{"type": "file", "path": filepath.Join(dir, "payloads.json"), "endpoints": []string{"series_v2"}},
+		{"type": "http", "url": "http://localhost:8080"},
+		{"type": "http"},
+		{"type": "unknown"},
+	})
+
+	sinks := NewSinksFromConfig()
+	require.Len(t, sinks, 2)
+	workers := newSinkWorkers(sinks)
+	require.Len(t, workers, 2)
+	assert.Equal(t, "file:"+filepath.Join(dir, "payloads.json"), workers[0].sink.Name())
+	assert.Contains(t, workers[0].endpoints, "series_v2")
+	assert.Equal(t, "http:http://localhost:8080", workers[1].sink.Name())
+	assert.Nil(t, workers[1].endpoints)
+
+	// the forwarders only use the sinks given in their options
+	f := NewDefaultForwarder(NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(map[string][]string{})))
+	assert.Empty(t, f.sinkWorkers)
+}
+
+func TestForwarderSinks(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	sink := &mockSink{}
+	options := NewOptionsWithResolvers(resolver.NewSingleDomainResolvers(map[string][]string{
+		ts.URL: {"api_key1", "api_key2"},
+	}))
+	options.Sinks = []Sink{sink}
+	f := NewDefaultForwarder(options)
+	require.NoError(t, f.Start())
+
+	data1 := []byte("data payload 1")
+	data2 := []byte("data payload 2")
+	headers := http.Header{}
+	headers.Set("key", "value")
+	assert.NoError(t, f.SubmitSeries(Payloads{&data1, &data2}, headers))
+	f.Stop()
+
+	// one copy per payload, whatever the number of API keys
+	received := sink.received()
+	require.Len(t, received, 2)
+	assert.Equal(t, endpoints.SeriesEndpoint, received[0].Endpoint)
+	assert.Equal(t, data1, received[0].Payload)
+	assert.Equal(t, data2, received[1].Payload)
+	assert.Equal(t, "value", received[0].Headers.Get("key"))
+	assert.Empty(t, received[0].Headers.Get(apiHTTPHeaderKey))
+	assert.True(t, sink.closed)
+}
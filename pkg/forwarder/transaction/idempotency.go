@@ -0,0 +1,98 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package transaction
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// IdempotencyKeyHTTPHeaderKey is the HTTP header carrying the idempotency key of a transaction
+const IdempotencyKeyHTTPHeaderKey = "DD-Idempotency-Key"
+
+// sentKeys holds the idempotency keys of the transactions which already received a 2xx response
+var sentKeys = newSentKeysCache()
+
+// newIdempotencyKey returns a random key identifying a transaction across its retries
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		log.Debugf("Could not generate an idempotency key for a transaction: %s", err)
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// sentKeysCache is a bounded set of idempotency keys, the oldest keys are evicted first
+type sentKeysCache struct {
+	mu    sync.Mutex
+	keys  map[string]struct{}
+	order []string
+	next  int
+	size  int
+}
+
+func newSentKeysCache() *sentKeysCache {
+	return &sentKeysCache{keys: make(map[string]struct{})}
+}
+
+// maxSize returns the number of keys the cache holds, 0 when it is disabled
+func (c *sentKeysCache) maxSize() int {
+	size := config.Datadog.GetInt("forwarder_sent_transactions_cache_size")
+	if size < 0 {
+		return 0
+	}
+	return size
+}
+
+func (c *sentKeysCache) add(key string) {
+	size := c.maxSize()
+	if key == "" || size == 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.size != size {
+		// the size changed, start over
+		c.keys = make(map[string]struct{}, size)
+		c.order = make([]string, size)
+		c.next = 0
+		c.size = size
+	}
+	if _, found := c.keys[key]; found {
+		return
+	}
+	if evicted := c.order[c.next]; evicted != "" {
+		delete(c.keys, evicted)
+	}
+	c.order[c.next] = key
+	c.keys[key] = struct{}{}
+	c.next = (c.next + 1) % size
+}
+
+func (c *sentKeysCache) contains(key string) bool {
+	if key == "" || c.maxSize() == 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, found := c.keys[key]
+	return found
+}
+
+func (c *sentKeysCache) reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.keys = make(map[string]struct{})
+	c.order = nil
+	c.next = 0
+	c.size = 0
+}
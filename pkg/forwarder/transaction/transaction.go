@@ -19,6 +19,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 	"github.com/DataDog/datadog-agent/pkg/util/scrubber"
 )
@@ -43,6 +44,9 @@ var (
 	// TransactionsSuccessByEndpoint is the number of transaction succeeded by endpoint.
 	TransactionsSuccessByEndpoint = expvar.Map{}
 
+	// TransactionsAlreadySent is the number of transactions not sent again because they already received a 2xx response.
+	TransactionsAlreadySent = expvar.Int{}
+
 	transactionsSuccessBytesByEndpoint = expvar.Map{}
 	transactionsSuccess                = expvar.Int{}
 	transactionsErrors                 = expvar.Int{}
@@ -69,6 +73,10 @@ var (
 		[]string{"domain", "endpoint", "error_type"}, "Count of transactions errored grouped by type of error")
 	tlmTxHTTPErrors = telemetry.NewCounter("transactions", "http_errors",
 		[]string{"domain", "endpoint", "code"}, "Count of transactions http errors per http code")
+	tlmTxAlreadySent = telemetry.NewCounter("transactions", "already_sent",
+		[]string{"domain", "endpoint"}, "Count of transactions not sent again because they already received a 2xx response")
+	tlmTxCompressionFallbacks = telemetry.NewCounter("transactions", "compression_fallbacks",
+		[]string{"domain", "endpoint", "content_encoding"}, "Count of transactions compressed again with zlib because their content encoding was rejected")
 )
 
 // Trace is an httptrace.ClientTrace instance that traces the events within HTTP client requests.
@@ -138,6 +146,7 @@ func init() {
 	TransactionsExpvars.Set("Dropped", &TransactionsDropped)
 	TransactionsExpvars.Set("DroppedByEndpoint", &TransactionsDroppedByEndpoint)
 	TransactionsExpvars.Set("SuccessByEndpoint", &TransactionsSuccessByEndpoint)
+	TransactionsExpvars.Set("AlreadySent", &TransactionsAlreadySent)
 	TransactionsExpvars.Set("SuccessBytesByEndpoint", &transactionsSuccessBytesByEndpoint)
 	TransactionsExpvars.Set("Success", &transactionsSuccess)
 	TransactionsExpvars.Set("Errors", &transactionsErrors)
@@ -192,6 +201,11 @@ type HTTPTransaction struct {
 	CompletionHandler HTTPCompletionHandler
 
 	Priority Priority
+
+	// IdempotencyKey identifies the transaction, it stays the same across retries and when the
+	// transaction is stored on disk. The transaction is not sent again once a request with this
+	// key received a 2xx response.
+	IdempotencyKey string
 }
 
 // TransactionsSerializer serializes Transaction instances.
@@ -223,6 +237,7 @@ func NewHTTPTransaction() *HTTPTransaction {
 		Retryable:      true,
 		StorableOnDisk: true,
 		Headers:        make(http.Header),
+		IdempotencyKey: newIdempotencyKey(),
 	}
 	tr.SetDefaultHandlers()
 	return tr
@@ -266,6 +281,13 @@ func (t *HTTPTransaction) GetPayloadSize() int {
 
 // Process sends the Payload of the transaction to the right Endpoint and Domain.
 func (t *HTTPTransaction) Process(ctx context.Context, client *http.Client) error {
+	if sentKeys.contains(t.IdempotencyKey) {
+		log.Debugf("The transaction to %q already received a 2xx response, not sending it again", t.GetTarget())
+		TransactionsAlreadySent.Add(1)
+		tlmTxAlreadySent.Inc(t.Domain, t.GetEndpointName())
+		return nil
+	}
+
 	t.AttemptHandler(t)
 
 	statusCode, body, err := t.internalProcess(ctx, client)
@@ -301,6 +323,11 @@ func (t *HTTPTransaction) internalProcess(ctx context.Context, client *http.Clie
 	}
 	req = req.WithContext(ctx)
 	req.Header = t.Headers
+	if t.IdempotencyKey != "" && config.Datadog.GetBool("forwarder_send_idempotency_key") {
+		// the headers may be shared with other transactions
+		req.Header = t.Headers.Clone()
+		req.Header.Set(IdempotencyKeyHTTPHeaderKey, t.IdempotencyKey)
+	}
 	resp, err := client.Do(req)
 
 	if err != nil {
@@ -315,6 +342,11 @@ func (t *HTTPTransaction) internalProcess(ctx context.Context, client *http.Clie
 	}
 	defer func() { _ = resp.Body.Close() }()
 
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		// the payload was accepted, even if the response cannot be read
+		sentKeys.add(t.IdempotencyKey)
+	}
+
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		log.Errorf("Fail to read the response Body: %s", err)
@@ -335,7 +367,12 @@ func (t *HTTPTransaction) internalProcess(ctx context.Context, client *http.Clie
 		tlmTxHTTPErrors.Inc(t.Domain, transactionEndpointName, statusCode)
 	}
 
-	if resp.StatusCode == 400 || resp.StatusCode == 404 || resp.StatusCode == 413 {
+	if resp.StatusCode == http.StatusUnsupportedMediaType && t.recompressWithZlib() {
+		// the endpoint doesn't support the content encoding of the payload, send it again
+		// right away as it was compressed with zlib
+		log.Warnf("Error code %q received while sending transaction to %q, sending it again compressed with zlib", resp.Status, logURL)
+		return t.internalProcess(ctx, client)
+	} else if resp.StatusCode == 400 || resp.StatusCode == 404 || resp.StatusCode == 413 {
 		log.Errorf("Error code %q received while sending transaction to %q: %s, dropping it", resp.Status, logURL, string(body))
 		TransactionsDroppedByEndpoint.Add(transactionEndpointName, 1)
 		TransactionsDropped.Add(1)
@@ -376,6 +413,46 @@ func (t *HTTPTransaction) internalProcess(ctx context.Context, client *http.Clie
 	return resp.StatusCode, body, nil
 }
 
+// recompressWithZlib compresses the payload with zlib when its content encoding is another one,
+// and records that this content encoding is not supported by the endpoint so that the
+// serializer stops using it. It returns false if the payload cannot be compressed again.
+func (t *HTTPTransaction) recompressWithZlib() bool {
+	contentEncoding := t.Headers.Get("Content-Encoding")
+	if contentEncoding == "" || contentEncoding == "deflate" {
+		return false
+	}
+	decompressor, err := compression.NewCompressorForContentEncoding(contentEncoding)
+	if err != nil {
+		return false
+	}
+	payload, err := decompressor.Decompress(*t.Payload)
+	if err != nil {
+		log.Errorf("Could not decompress the %s payload of a transaction to %q: %s", contentEncoding, t.Endpoint.Name, err)
+		return false
+	}
+	zlib, err := compression.NewCompressor(compression.ZlibKind, 0)
+	if err != nil {
+		return false
+	}
+	compressed, err := zlib.Compress(payload)
+	if err != nil {
+		log.Errorf("Could not compress the payload of a transaction to %q with zlib: %s", t.Endpoint.Name, err)
+		return false
+	}
+
+	if compression.MarkUnsupported(t.Endpoint.Name, contentEncoding) {
+		log.Warnf("The endpoint %q doesn't support the %q content encoding, its payloads will be compressed with zlib", t.Endpoint.Name, contentEncoding)
+	}
+	tlmTxCompressionFallbacks.Inc(t.Domain, t.Endpoint.Name, contentEncoding)
+
+	// the headers may be shared with other transactions
+	headers := t.Headers.Clone()
+	headers.Set("Content-Encoding", zlib.ContentEncoding())
+	t.Headers = headers
+	t.Payload = &compressed
+	return true
+}
+
 // SerializeTo serializes the transaction using TransactionsSerializer
 func (t *HTTPTransaction) SerializeTo(serializer TransactionsSerializer) error {
 	if t.StorableOnDisk {
@@ -7,12 +7,17 @@ package transaction
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 func TestNewHTTPTransaction(t *testing.T) {
@@ -24,6 +29,8 @@ func TestNewHTTPTransaction(t *testing.T) {
 
 	assert.True(t, transaction.CreatedAt.After(before) || transaction.CreatedAt.Equal(before))
 	assert.True(t, transaction.CreatedAt.Before(after) || transaction.CreatedAt.Equal(after))
+	assert.Len(t, transaction.IdempotencyKey, 32)
+	assert.NotEqual(t, transaction.IdempotencyKey, NewHTTPTransaction().IdempotencyKey)
 }
 
 func TestGetCreatedAt(t *testing.T) {
@@ -112,6 +119,64 @@ func TestProcessHTTPError(t *testing.T) {
 	assert.Equal(t, transaction.ErrorCount, 1)
 }
 
+func TestProcessUnsupportedContentEncoding(t *testing.T) {
+	defer compression.ResetUnsupported()
+
+	zstd, err := compression.NewCompressor(compression.ZstdKind, 0)
+	require.NoError(t, err)
+	zlib, err := compression.NewCompressor(compression.ZlibKind, 0)
+	require.NoError(t, err)
+
+	var received []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Content-Encoding"))
+		if r.Header.Get("Content-Encoding") != "deflate" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		payload, err := zlib.Decompress(body)
+		require.NoError(t, err)
+		assert.Equal(t, "test payload", string(payload))
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	headers := make(http.Header)
+	headers.Set("Content-Encoding", "zstd")
+
+	transaction := NewHTTPTransaction()
+	transaction.Domain = ts.URL
+	transaction.Endpoint = Endpoint{Route: "/endpoint/test", Name: "test"}
+	transaction.Headers = headers
+	payload, err := zstd.Compress([]byte("test payload"))
+	require.NoError(t, err)
+	transaction.Payload = &payload
+
+	client := &http.Client{}
+
+	err = transaction.Process(context.Background(), client)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"zstd", "deflate"}, received)
+	assert.True(t, compression.IsUnsupported("test", "zstd"))
+	// the headers shared with other transactions are left untouched
+	assert.Equal(t, "zstd", headers.Get("Content-Encoding"))
+	assert.Equal(t, "deflate", transaction.Headers.Get("Content-Encoding"))
+
+	// a rejected zlib payload is rescheduled like the other errors
+	sentKeys.reset() // forget that the transaction already received a 2xx response
+	received = nil
+	transaction.Endpoint.Route = "/endpoint/other"
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("Content-Encoding"))
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+	})
+	err = transaction.Process(context.Background(), client)
+	assert.NotNil(t, err)
+	assert.Equal(t, []string{"deflate"}, received)
+}
+
 func TestProcessCancel(t *testing.T) {
 	transaction := NewHTTPTransaction()
 	transaction.Domain = "example.com"
@@ -126,3 +191,95 @@ func TestProcessCancel(t *testing.T) {
 	err := transaction.Process(ctx, client)
 	assert.Nil(t, err)
 }
+
+func TestProcessIdempotencyKeyHeader(t *testing.T) {
+	mockConfig := config.Mock()
+	defer mockConfig.Set("forwarder_send_idempotency_key", false)
+
+	var received []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get(IdempotencyKeyHTTPHeaderKey))
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer ts.Close()
+
+	transaction := NewHTTPTransaction()
+	transaction.Domain = ts.URL
+	transaction.Endpoint.Route = "/endpoint/test"
+	payload := []byte("test payload")
+	transaction.Payload = &payload
+
+	client := &http.Client{}
+
+	err := transaction.Process(context.Background(), client)
+	assert.NotNil(t, err)
+
+	mockConfig.Set("forwarder_send_idempotency_key", true)
+	err = transaction.Process(context.Background(), client)
+	assert.NotNil(t, err)
+	err = transaction.Process(context.Background(), client)
+	assert.NotNil(t, err)
+
+	// the key is the same across retries and the shared headers are not modified
+	assert.Equal(t, []string{"", transaction.IdempotencyKey, transaction.IdempotencyKey}, received)
+	assert.Empty(t, transaction.Headers.Get(IdempotencyKeyHTTPHeaderKey))
+}
+
+func TestProcessAlreadySent(t *testing.T) {
+	mockConfig := config.Mock()
+	defer sentKeys.reset()
+
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	transaction := NewHTTPTransaction()
+	transaction.Domain = ts.URL
+	transaction.Endpoint.Route = "/endpoint/test"
+	payload := []byte("test payload")
+	transaction.Payload = &payload
+
+	client := &http.Client{}
+	alreadySent := TransactionsAlreadySent.Value()
+
+	assert.Nil(t, transaction.Process(context.Background(), client))
+	assert.Nil(t, transaction.Process(context.Background(), client))
+	assert.Equal(t, 1, requests)
+	assert.Equal(t, alreadySent+1, TransactionsAlreadySent.Value())
+
+	// another transaction with the same payload is sent
+	other := NewHTTPTransaction()
+	other.Domain = ts.URL
+	other.Endpoint.Route = "/endpoint/test"
+	other.Payload = &payload
+	assert.Nil(t, other.Process(context.Background(), client))
+	assert.Equal(t, 2, requests)
+
+	// the tracking is disabled with a size of 0
+	mockConfig.Set("forwarder_sent_transactions_cache_size", 0)
+	defer mockConfig.Set("forwarder_sent_transactions_cache_size", 10000)
+	assert.Nil(t, transaction.Process(context.Background(), client))
+	assert.Equal(t, 3, requests)
+}
+
+func TestSentKeysCacheEviction(t *testing.T) {
+	mockConfig := config.Mock()
+	mockConfig.Set("forwarder_sent_transactions_cache_size", 2)
+	defer mockConfig.Set("forwarder_sent_transactions_cache_size", 10000)
+
+	cache := newSentKeysCache()
+	cache.add("a")
+	cache.add("b")
+	cache.add("b")
+	assert.True(t, cache.contains("a"))
+	assert.True(t, cache.contains("b"))
+
+	cache.add("c")
+	assert.False(t, cache.contains("a"))
+	assert.True(t, cache.contains("b"))
+	assert.True(t, cache.contains("c"))
+	assert.False(t, cache.contains(""))
+}
@@ -36,6 +36,9 @@ type Worker struct {
 	stopChan            chan struct{}
 	stopped             chan struct{}
 	blockedList         *blockedEndpoints
+	// limiter limits the number of transactions sent concurrently by the workers of a
+	// domain, it is nil when the adaptive concurrency is disabled
+	limiter *concurrencyLimiter
 }
 
 // NewWorker returns a new worker to consume Transaction from inputChan
@@ -172,17 +175,36 @@ func (w *Worker) process(ctx context.Context, t transaction.Transaction) {
 		}
 	}
 
+	var start time.Time
+	if w.limiter != nil {
+		var err error
+		if start, err = w.limiter.acquire(ctx); err != nil {
+			requeue()
+			return
+		}
+	}
+
 	// Run the endpoint through our blockedEndpoints circuit breaker
 	target := t.GetTarget()
-	if w.blockedList.isBlock(target) {
+	if !w.blockedList.allow(target) {
+		w.release(start, false, false)
 		requeue()
 		log.Errorf("Too many errors for endpoint '%s': retrying later", target)
 	} else if err := t.Process(ctx, w.Client); err != nil {
 		w.blockedList.close(target)
+		// a transaction canceled because the worker is stopping says nothing about the endpoint
+		w.release(start, ctx.Err() == nil, true)
 		requeue()
 		log.Errorf("Error while processing transaction: %v", err)
 	} else {
 		w.blockedList.recover(target)
+		w.release(start, true, false)
+	}
+}
+
+func (w *Worker) release(start time.Time, sample bool, failed bool) {
+	if w.limiter != nil {
+		w.limiter.release(start, sample, failed)
 	}
 }
 
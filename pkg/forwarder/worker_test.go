@@ -188,3 +188,23 @@ func TestWorkerPurgeOnStop(t *testing.T) {
 	mockTransaction.AssertNumberOfCalls(t, "Process", 1)
 	mockRetryTransaction.AssertNumberOfCalls(t, "Process", 0)
 }
+
+func TestWorkerConcurrencyLimiter(t *testing.T) {
+	highPrio := make(chan transaction.Transaction)
+	lowPrio := make(chan transaction.Transaction)
+	requeue := make(chan transaction.Transaction, 1)
+	w := NewWorker(highPrio, lowPrio, requeue, newBlockedEndpoints())
+	w.limiter = newConcurrencyLimiter("domain", 2, 1, 2, time.Minute)
+
+	mock := newTestTransaction()
+	mock.On("Process", w.Client).Return(fmt.Errorf("some kind of error")).Times(1)
+	mock.On("GetTarget").Return("error_url").Times(1)
+
+	w.Start()
+	highPrio <- mock
+	<-requeue
+	w.Stop(false)
+
+	// the failure halved the limit and released the slot
+	assert.Equal(t, concurrencyStatus{Limit: 1, Max: 2}, w.limiter.status())
+}
@@ -10,3 +10,28 @@ type Destination interface {
 	Send(payload []byte) error
 	SendAsync(payload []byte)
 }
+
+// StatusReporter is notified of the delivery status of the payloads sent in the background,
+// a Route for instance.
+type StatusReporter interface {
+	ReportSent()
+	ReportError()
+}
+
+// ReportingDestination is a Destination reporting the delivery status of the payloads sent by SendAsync.
+type ReportingDestination interface {
+	Destination
+	SetStatusReporter(reporter StatusReporter)
+}
+
+// ReportStatus reports the delivery status of a payload to reporter, when it is not nil.
+func ReportStatus(reporter StatusReporter, err error) {
+	if reporter == nil {
+		return
+	}
+	if err != nil {
+		reporter.ReportError()
+	} else {
+		reporter.ReportSent()
+	}
+}
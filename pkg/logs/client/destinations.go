@@ -5,10 +5,18 @@
 
 package client
 
+import (
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
 // Destinations holds the main destination and additional ones to send logs to.
 type Destinations struct {
 	Main        Destination
 	Additionals []Destination
+	// MainRoute and AdditionalRoutes select the logs sent to each destination,
+	// they are nil when all the logs are sent to all the destinations.
+	MainRoute        *Route
+	AdditionalRoutes []*Route
 }
 
 // NewDestinations returns a new destinations composite.
@@ -18,3 +26,55 @@ func NewDestinations(main Destination, additionals []Destination) *Destinations
 		Additionals: additionals,
 	}
 }
+
+// NewRoutedDestinations returns a new destinations composite sending to each destination only
+// the logs matching its route, `additionalRoutes` holds the route of each additional destination.
+// The additional destinations don't depend on the main one nor on each other, they report the
+// delivery status of their payloads on their route.
+func NewRoutedDestinations(main Destination, mainRoute *Route, additionals []Destination, additionalRoutes []*Route) *Destinations {
+	for i, destination := range additionals {
+		if d, ok := destination.(ReportingDestination); ok {
+			d.SetStatusReporter(additionalRoutes[i])
+		}
+	}
+	return &Destinations{
+		Main:             main,
+		Additionals:      additionals,
+		MainRoute:        mainRoute,
+		AdditionalRoutes: additionalRoutes,
+	}
+}
+
+// IsRouted returns true when the logs are sent to the destinations matching their routes.
+func (d *Destinations) IsRouted() bool {
+	return d.MainRoute != nil
+}
+
+// Route returns the messages to send to the main destination, and the ones to send to each
+// additional destination. The messages not matching a route are reported as filtered out.
+func (d *Destinations) Route(messages []*message.Message) ([]*message.Message, [][]*message.Message) {
+	main := route(d.MainRoute, messages)
+	additionals := make([][]*message.Message, len(d.AdditionalRoutes))
+	for i, r := range d.AdditionalRoutes {
+		additionals[i] = route(r, messages)
+	}
+	return main, additionals
+}
+
+func route(r *Route, messages []*message.Message) []*message.Message {
+	var routed []*message.Message
+	for _, msg := range messages {
+		if r.Match(msg) {
+			routed = append(routed, msg)
+		}
+	}
+	if filtered := len(messages) - len(routed); filtered > 0 {
+		r.reportFiltered(filtered)
+	}
+	return routed
+}
+
+// SendToAdditional sends a payload in the background to the additional destination at index `i`.
+func (d *Destinations) SendToAdditional(i int, payload []byte) {
+	d.Additionals[i].SendAsync(payload)
+}
@@ -0,0 +1,145 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package client
+
+import (
+	"errors"
+	"expvar"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+type fakeDestination struct {
+	sync.Mutex
+	err      error
+	payloads []string
+	reporter StatusReporter
+}
+
+func (d *fakeDestination) Send(payload []byte) error {
+	d.Lock()
+	defer d.Unlock()
+	if d.err != nil {
+		return d.err
+	}
+	d.payloads = append(d.payloads, string(payload))
+	return nil
+}
+
+func (d *fakeDestination) SendAsync(payload []byte) {
+	go func() {
+		ReportStatus(d.reporter, d.Send(payload))
+	}()
+}
+
+func (d *fakeDestination) SetStatusReporter(reporter StatusReporter) {
+	d.reporter = reporter
+}
+
+func (d *fakeDestination) sent() []string {
+	d.Lock()
+	defer d.Unlock()
+	return append([]string{}, d.payloads...)
+}
+
+func newRoutedMessage(content string, source string, service string, status string, tags ...string) *message.Message {
+	msg := message.NewMessageWithSource([]byte(content), status, config.NewLogSource("", &config.LogsConfig{Source: source, Service: service}), 0)
+	msg.Origin.SetTags(tags)
+	return msg
+}
+
+func contents(messages []*message.Message) []string {
+	var contents []string
+	for _, msg := range messages {
+		contents = append(contents, string(msg.Content))
+	}
+	return contents
+}
+
+func routeStat(expVars *expvar.Map, name string) int64 {
+	if v, ok := expVars.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func TestRouteMatch(t *testing.T) {
+	route := NewRoute("test_match", &config.Route{
+		Include: config.RouteFilter{Sources: []string{"auditd"}, Tags: []string{"team:security", "env:prod"}},
+		Exclude: config.RouteFilter{Statuses: []string{"DEBUG"}},
+	})
+	assert.True(t, route.Match(newRoutedMessage("", "auditd", "", message.StatusInfo, "env:prod")))
+	assert.False(t, route.Match(newRoutedMessage("", "auditd", "", message.StatusDebug, "env:prod")))
+	assert.False(t, route.Match(newRoutedMessage("", "auditd", "", message.StatusInfo, "env:dev")))
+	assert.False(t, route.Match(newRoutedMessage("", "nginx", "", message.StatusInfo, "env:prod")))
+
+	route = NewRoute("test_match", &config.Route{Include: config.RouteFilter{Services: []string{"api"}}})
+	assert.True(t, route.Match(newRoutedMessage("", "", "api", "")))
+	assert.False(t, route.Match(newRoutedMessage("", "", "web", "")))
+	assert.False(t, route.Match(&message.Message{}))
+
+	// a route without filters matches all the logs
+	route = NewRoute("test_match", nil)
+	assert.True(t, route.Match(newRoutedMessage("", "nginx", "web", message.StatusDebug)))
+	assert.True(t, route.Match(&message.Message{}))
+}
+
+func TestDestinationsRoute(t *testing.T) {
+	destinations := NewRoutedDestinations(
+		&fakeDestination{},
+		NewRoute("test_route_main", &config.Route{Exclude: config.RouteFilter{Statuses: []string{"debug"}}}),
+		[]Destination{&fakeDestination{}, &fakeDestination{}},
+		[]*Route{
+			NewRoute("test_route_security", &config.Route{Include: config.RouteFilter{Sources: []string{"auditd"}}}),
+			NewRoute("test_route_debug", &config.Route{Include: config.RouteFilter{Statuses: []string{"debug"}}}),
+		},
+	)
+	assert.True(t, destinations.IsRouted())
+	assert.False(t, NewDestinations(&fakeDestination{}, nil).IsRouted())
+
+	main, additionals := destinations.Route([]*message.Message{
+		newRoutedMessage("1", "auditd", "", message.StatusInfo),
+		newRoutedMessage("2", "nginx", "", message.StatusDebug),
+		newRoutedMessage("3", "nginx", "", message.StatusError),
+	})
+	assert.Equal(t, []string{"1", "3"}, contents(main))
+	assert.Equal(t, []string{"1"}, contents(additionals[0]))
+	assert.Equal(t, []string{"2"}, contents(additionals[1]))
+
+	assert.EqualValues(t, 1, routeStat(&metrics.RouteLogsFiltered, "test_route_main"))
+	assert.EqualValues(t, 2, routeStat(&metrics.RouteLogsFiltered, "test_route_security"))
+	assert.EqualValues(t, 2, routeStat(&metrics.RouteLogsFiltered, "test_route_debug"))
+}
+
+func TestDestinationsSendToAdditional(t *testing.T) {
+	available := &fakeDestination{}
+	unavailable := &fakeDestination{err: errors.New("unavailable")}
+	destinations := NewRoutedDestinations(
+		&fakeDestination{},
+		NewRoute("test_send_main", nil),
+		[]Destination{available, unavailable},
+		[]*Route{NewRoute("test_send_available", nil), NewRoute("test_send_unavailable", nil)},
+	)
+
+	destinations.SendToAdditional(0, []byte("payload"))
+	destinations.SendToAdditional(1, []byte("payload"))
+
+	// the delivery status of each route is reported separately
+	assert.Eventually(t, func() bool {
+		return routeStat(&metrics.RoutePayloadsSent, "test_send_available") == 1 &&
+			routeStat(&metrics.RouteErrors, "test_send_unavailable") == 1
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"payload"}, available.sent())
+	assert.EqualValues(t, 0, routeStat(&metrics.RouteErrors, "test_send_available"))
+	assert.EqualValues(t, 0, routeStat(&metrics.RoutePayloadsSent, "test_send_unavailable"))
+}
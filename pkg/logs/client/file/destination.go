@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package file
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// Destination writes the payloads to a local file, one payload per line.
+type Destination struct {
+	path                string
+	destinationsContext *client.DestinationsContext
+	statusReporter      client.StatusReporter
+
+	mu   sync.Mutex
+	file *os.File
+	// closeCtx is the context of the destinations whose end closes the file
+	closeCtx context.Context
+}
+
+// NewDestination returns a new destination writing to the path of the endpoint,
+// the file is created when the first payload is written and closed when the
+// destinations context stops.
+func NewDestination(endpoint config.Endpoint, destinationsContext *client.DestinationsContext) *Destination {
+	return &Destination{
+		path:                endpoint.Path,
+		destinationsContext: destinationsContext,
+	}
+}
+
+// Send appends a payload to the file.
+func (d *Destination) Send(payload []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		file, err := os.OpenFile(d.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0640)
+		if err != nil {
+			return err
+		}
+		d.file = file
+		d.closeOnStop()
+	}
+
+	// the payload and its delimiter are written at once, as the file can be shared by the
+	// destinations of the different pipelines
+	line := make([]byte, 0, len(payload)+1)
+	line = append(append(line, payload...), '\n')
+	if _, err := d.file.Write(line); err != nil {
+		d.file.Close()
+		d.file = nil
+		return err
+	}
+	return nil
+}
+
+// SendAsync appends a payload to the file, writing to a local file does not block.
+func (d *Destination) SendAsync(payload []byte) {
+	err := d.Send(payload)
+	if err != nil {
+		log.Warnf("Could not write logs to %s: %v", d.path, err)
+	}
+	client.ReportStatus(d.statusReporter, err)
+}
+
+// SetStatusReporter sets the reporter of the delivery status of the payloads sent by SendAsync,
+// it must be called before SendAsync.
+func (d *Destination) SetStatusReporter(reporter client.StatusReporter) {
+	d.statusReporter = reporter
+}
+
+// Close closes the file, it is opened again by the next payload.
+func (d *Destination) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.file == nil {
+		return nil
+	}
+	err := d.file.Close()
+	d.file = nil
+	return err
+}
+
+// closeOnStop closes the file when the current destinations context stops, the pipelines
+// create new destinations when they are restarted.
+func (d *Destination) closeOnStop() {
+	if d.destinationsContext == nil {
+		return
+	}
+	ctx := d.destinationsContext.Context()
+	if ctx == nil || ctx == d.closeCtx {
+		return
+	}
+	d.closeCtx = ctx
+	go func() {
+		<-ctx.Done()
+		if err := d.Close(); err != nil {
+			log.Warnf("Could not close %s: %v", d.path, err)
+		}
+	}()
+}
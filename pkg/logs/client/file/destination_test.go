@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+func TestDestinationAppendsPayloads(t *testing.T) {
+	dir, err := ioutil.TempDir("", "destination")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "debug.log")
+
+	destination := NewDestination(config.Endpoint{Path: path}, nil)
+	require.NoError(t, destination.Send([]byte(`[{"message":"first"}]`)))
+	destination.SendAsync([]byte(`[{"message":"second"}]`))
+
+	// the file is appended to, not truncated
+	require.NoError(t, NewDestination(config.Endpoint{Path: path}, nil).Send([]byte(`[{"message":"third"}]`)))
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "[{\"message\":\"first\"}]\n[{\"message\":\"second\"}]\n[{\"message\":\"third\"}]\n", string(content))
+}
+
+func TestDestinationInvalidPath(t *testing.T) {
+	destination := NewDestination(config.Endpoint{Path: "/a/path/that/does/not/exist/debug.log"}, nil)
+	assert.Error(t, destination.Send([]byte("payload")))
+}
+
+func TestDestinationClosedWhenContextStops(t *testing.T) {
+	dir, err := ioutil.TempDir("", "destination")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "debug.log")
+
+	destinationsContext := client.NewDestinationsContext()
+	destinationsContext.Start()
+	destination := NewDestination(config.Endpoint{Path: path}, destinationsContext)
+	require.NoError(t, destination.Send([]byte("first")))
+
+	destinationsContext.Stop()
+	assert.Eventually(t, func() bool {
+		destination.mu.Lock()
+		defer destination.mu.Unlock()
+		return destination.file == nil
+	}, 5*time.Second, time.Millisecond)
+
+	// the file is opened again after a restart
+	destinationsContext.Start()
+	defer destinationsContext.Stop()
+	require.NoError(t, destination.Send([]byte("second")))
+	require.NoError(t, destination.Close())
+
+	content, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "first\nsecond\n", string(content))
+}
@@ -6,8 +6,9 @@
 package http
 
 import (
-	"bytes"
 	"compress/gzip"
+
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 // ContentEncoding encodes the payload
@@ -29,12 +30,32 @@ func (c *identityContentType) encode(payload []byte) ([]byte, error) {
 	return payload, nil
 }
 
+// CompressorContentEncoding encodes the payload with a compressor
+type CompressorContentEncoding struct {
+	compressor compression.Compressor
+}
+
+// NewCompressorContentEncoding creates a new content type encoding the payload with compressor
+func NewCompressorContentEncoding(compressor compression.Compressor) *CompressorContentEncoding {
+	return &CompressorContentEncoding{
+		compressor,
+	}
+}
+
+func (c *CompressorContentEncoding) name() string {
+	return c.compressor.ContentEncoding()
+}
+
+func (c *CompressorContentEncoding) encode(payload []byte) ([]byte, error) {
+	return c.compressor.Compress(payload)
+}
+
 // GzipContentEncoding encodes the payload using gzip algorithm
 type GzipContentEncoding struct {
-	level int
+	CompressorContentEncoding
 }
 
-// NewGzipContentEncoding creates a new Gzip content type
+// NewGzipContentEncoding creates a new Gzip content type, 0 disables the compression
 func NewGzipContentEncoding(level int) *GzipContentEncoding {
 	if level < gzip.NoCompression {
 		level = gzip.NoCompression
@@ -42,32 +63,9 @@ func NewGzipContentEncoding(level int) *GzipContentEncoding {
 		level = gzip.BestCompression
 	}
 
+	// the level is always valid
+	compressor, _ := compression.NewGzipCompressor(level)
 	return &GzipContentEncoding{
-		level,
-	}
-}
-
-func (c *GzipContentEncoding) name() string {
-	return "gzip"
-}
-
-func (c *GzipContentEncoding) encode(payload []byte) ([]byte, error) {
-	var compressedPayload bytes.Buffer
-	gzipWriter, err := gzip.NewWriterLevel(&compressedPayload, c.level)
-	if err != nil {
-		return nil, err
-	}
-	_, err = gzipWriter.Write(payload)
-	if err != nil {
-		return nil, err
-	}
-	err = gzipWriter.Flush()
-	if err != nil {
-		return nil, err
-	}
-	err = gzipWriter.Close()
-	if err != nil {
-		return nil, err
+		CompressorContentEncoding{compressor},
 	}
-	return compressedPayload.Bytes(), nil
 }
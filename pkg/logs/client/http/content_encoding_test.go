@@ -11,6 +11,8 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 func TestIdentityContentType(t *testing.T) {
@@ -38,10 +40,42 @@ func TestGzipContentEncoding(t *testing.T) {
 	assert.Equal(t, payload, decompressedPayload)
 }
 
+func TestGzipContentEncodingNoCompression(t *testing.T) {
+	payload := bytes.Repeat([]byte("my payload"), 100)
+
+	for _, level := range []int{gzip.NoCompression, -1} {
+		encodedPayload, err := NewGzipContentEncoding(level).encode(payload)
+		assert.Nil(t, err)
+		// the payload is stored without compression
+		assert.True(t, len(encodedPayload) > len(payload))
+
+		decompressedPayload, err := decompress(encodedPayload)
+		assert.Nil(t, err)
+		assert.Equal(t, payload, decompressedPayload)
+	}
+}
+
 func TestGzipContentEncodingName(t *testing.T) {
 	assert.Equal(t, NewGzipContentEncoding(gzip.BestCompression).name(), "gzip")
 }
 
+func TestCompressorContentEncoding(t *testing.T) {
+	payload := []byte("my payload")
+
+	compressor, err := compression.NewCompressor(compression.ZstdKind, 0)
+	assert.Nil(t, err)
+	contentEncoding := NewCompressorContentEncoding(compressor)
+	assert.Equal(t, "zstd", contentEncoding.name())
+
+	encodedPayload, err := contentEncoding.encode(payload)
+	assert.Nil(t, err)
+
+	decompressedPayload, err := compressor.Decompress(encodedPayload)
+	assert.Nil(t, err)
+
+	assert.Equal(t, payload, decompressedPayload)
+}
+
 func decompress(payload []byte) ([]byte, error) {
 	reader, err := gzip.NewReader(bytes.NewReader(payload))
 	if err != nil {
@@ -49,6 +49,7 @@ type Destination struct {
 	once                sync.Once
 	payloadChan         chan []byte
 	climit              chan struct{} // semaphore for limiting concurrent background sends
+	statusReporter      client.StatusReporter
 	backoff             backoff.Policy
 	nbErrors            int
 	blockedUntil        time.Time
@@ -203,6 +204,12 @@ func (d *Destination) SendAsync(payload []byte) {
 	d.payloadChan <- payload
 }
 
+// SetStatusReporter sets the reporter of the delivery status of the payloads sent in background,
+// it must be called before SendAsync.
+func (d *Destination) SetStatusReporter(reporter client.StatusReporter) {
+	d.statusReporter = reporter
+}
+
 // sendInBackground sends all payloads from payloadChan in background.
 func (d *Destination) sendInBackground(payloadChan chan []byte) {
 	ctx := d.destinationsContext.Context()
@@ -212,12 +219,12 @@ func (d *Destination) sendInBackground(payloadChan chan []byte) {
 			case payload := <-payloadChan:
 				// if the channel is non-buffered then there is no concurrency and we block on sending each payload
 				if cap(d.climit) == 0 {
-					d.unconditionalSend(payload) //nolint:errcheck
+					client.ReportStatus(d.statusReporter, d.unconditionalSend(payload))
 					break
 				}
 				d.climit <- struct{}{}
 				go func() {
-					d.unconditionalSend(payload) //nolint:errcheck
+					client.ReportStatus(d.statusReporter, d.unconditionalSend(payload))
 					<-d.climit
 				}()
 			case <-ctx.Done():
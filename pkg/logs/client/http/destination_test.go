@@ -11,7 +11,9 @@ import (
 	"net/http/httptest"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
 	"github.com/stretchr/testify/assert"
@@ -104,6 +106,26 @@ func TestDestinationSend200(t *testing.T) {
 	server.stop()
 }
 
+type countingReporter struct {
+	sent, errors int32
+}
+
+func (r *countingReporter) ReportSent()  { atomic.AddInt32(&r.sent, 1) }
+func (r *countingReporter) ReportError() { atomic.AddInt32(&r.errors, 1) }
+
+func TestDestinationSendAsyncReportsStatus(t *testing.T) {
+	for statusCode, expected := range map[int]countingReporter{200: {sent: 1}, 400: {errors: 1}} {
+		server := NewHTTPServerTest(statusCode)
+		reporter := &countingReporter{}
+		server.destination.SetStatusReporter(reporter)
+		server.destination.SendAsync([]byte("yo"))
+		assert.Eventually(t, func() bool {
+			return atomic.LoadInt32(&reporter.sent) == expected.sent && atomic.LoadInt32(&reporter.errors) == expected.errors
+		}, 5*time.Second, time.Millisecond)
+		server.stop()
+	}
+}
+
 func TestDestinationSend500(t *testing.T) {
 	server := NewHTTPServerTest(500)
 	err := server.destination.Send([]byte("yo"))
@@ -0,0 +1,132 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package client
+
+import (
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+// Route selects the logs sent to a destination and reports their delivery status under its name,
+// the status of the routes sharing the same name, such as the ones of the different pipelines,
+// are merged.
+type Route struct {
+	name    string
+	include *routeFilter
+	exclude *routeFilter
+}
+
+// routeFilter holds the values of each criteria of a config.RouteFilter, a nil set matches all
+// the logs.
+type routeFilter struct {
+	sources  map[string]bool
+	services map[string]bool
+	tags     map[string]bool
+	statuses map[string]bool
+}
+
+// NewRoute returns a new route, all the logs match a nil route.
+func NewRoute(name string, route *config.Route) *Route {
+	r := &Route{
+		name: name,
+	}
+	if route != nil {
+		r.include = newRouteFilter(route.Include)
+		r.exclude = newRouteFilter(route.Exclude)
+	}
+	return r
+}
+
+// Name returns the name of the route.
+func (r *Route) Name() string {
+	return r.name
+}
+
+// Match returns true when a message matches the include filter of the route and doesn't match
+// its exclude filter.
+func (r *Route) Match(msg *message.Message) bool {
+	if r.include != nil && !r.include.match(msg) {
+		return false
+	}
+	return r.exclude == nil || !r.exclude.match(msg)
+}
+
+// ReportSent reports a payload sent to the destination of the route.
+func (r *Route) ReportSent() {
+	metrics.RoutePayloadsSent.Add(r.name, 1)
+	metrics.TlmRoutePayloadsSent.Inc(r.name)
+}
+
+// ReportError reports a payload which could not be sent to the destination of the route.
+func (r *Route) ReportError() {
+	metrics.RouteErrors.Add(r.name, 1)
+	metrics.TlmRouteErrors.Inc(r.name)
+}
+
+// reportFiltered reports the number of logs not sent to the destination of the route.
+func (r *Route) reportFiltered(count int) {
+	metrics.RouteLogsFiltered.Add(r.name, int64(count))
+	metrics.TlmRouteLogsFiltered.Add(float64(count), r.name)
+}
+
+// newRouteFilter returns nil when the filter has no criteria.
+func newRouteFilter(filter config.RouteFilter) *routeFilter {
+	if filter.IsEmpty() {
+		return nil
+	}
+	return &routeFilter{
+		sources:  toSet(filter.Sources, false),
+		services: toSet(filter.Services, false),
+		tags:     toSet(filter.Tags, false),
+		statuses: toSet(filter.Statuses, true),
+	}
+}
+
+// match returns true when a message matches one of the values of each criteria of the filter.
+func (f *routeFilter) match(msg *message.Message) bool {
+	if f.statuses != nil && !f.statuses[msg.GetStatus()] {
+		return false
+	}
+	var source, service string
+	var tags []string
+	if msg.Origin != nil {
+		source = msg.Origin.Source()
+		service = msg.Origin.Service()
+		tags = msg.Origin.Tags()
+	}
+	if f.sources != nil && !f.sources[source] {
+		return false
+	}
+	if f.services != nil && !f.services[service] {
+		return false
+	}
+	if f.tags != nil {
+		for _, tag := range tags {
+			if f.tags[tag] {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+func toSet(values []string, lowerCase bool) map[string]bool {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		if lowerCase {
+			value = strings.ToLower(value)
+		}
+		set[value] = true
+	}
+	return set
+}
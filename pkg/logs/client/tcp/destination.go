@@ -31,6 +31,7 @@ type Destination struct {
 	connCreationTime    time.Time
 	inputChan           chan []byte
 	once                sync.Once
+	statusReporter      client.StatusReporter
 }
 
 // NewDestination returns a new destination.
@@ -108,16 +109,25 @@ func (d *Destination) SendAsync(payload []byte) {
 		}
 		metrics.DestinationLogsDropped.Add(host, 1)
 		metrics.TlmLogsDropped.Inc(host)
+		if d.statusReporter != nil {
+			d.statusReporter.ReportError()
+		}
 	}
 }
 
+// SetStatusReporter sets the reporter of the delivery status of the payloads sent by SendAsync,
+// it must be called before SendAsync.
+func (d *Destination) SetStatusReporter(reporter client.StatusReporter) {
+	d.statusReporter = reporter
+}
+
 // runAsync read the messages from the channel and send them
 func (d *Destination) runAsync() {
 	ctx := d.destinationsContext.Context()
 	for {
 		select {
 		case payload := <-d.inputChan:
-			d.Send(payload) //nolint:errcheck
+			client.ReportStatus(d.statusReporter, d.Send(payload))
 		case <-ctx.Done():
 			return
 		}
@@ -190,6 +190,9 @@ func buildTCPEndpoints(logsConfig *LogsConfigKeys) (*Endpoints, error) {
 		additionals[i].ProxyAddress = proxyAddress
 		additionals[i].APIKey = coreConfig.SanitizeAPIKey(additionals[i].APIKey)
 	}
+	if err := setupRoutes(logsConfig, &main, additionals); err != nil {
+		return nil, err
+	}
 	return NewEndpoints(main, additionals, useProto, false), nil
 }
 
@@ -250,6 +253,9 @@ func BuildHTTPEndpointsWithConfig(logsConfig *LogsConfigKeys, endpointPrefix str
 			additionals[i].Origin = intakeOrigin
 		}
 	}
+	if err := setupRoutes(logsConfig, &main, additionals); err != nil {
+		return nil, err
+	}
 
 	batchWait := logsConfig.batchWait()
 	batchMaxConcurrentSend := logsConfig.batchMaxConcurrentSend()
@@ -259,6 +265,24 @@ func BuildHTTPEndpointsWithConfig(logsConfig *LogsConfigKeys, endpointPrefix str
 	return NewEndpointsWithBatchSettings(main, additionals, false, true, batchWait, batchMaxConcurrentSend, batchMaxSize, batchMaxContentSize), nil
 }
 
+// setupRoutes sets the route of the main endpoint and validates the routes of all the endpoints.
+func setupRoutes(logsConfig *LogsConfigKeys, main *Endpoint, additionals []Endpoint) error {
+	route, err := logsConfig.getMainRoute()
+	if err != nil {
+		return fmt.Errorf("could not parse %s: %v", logsConfig.getConfigKey("route"), err)
+	}
+	main.Route = route
+	for _, endpoint := range append([]Endpoint{*main}, additionals...) {
+		if endpoint.Route == nil {
+			continue
+		}
+		if err := endpoint.Route.Validate(); err != nil {
+			return fmt.Errorf("invalid route for the endpoint %s: %v", endpoint.Host+endpoint.Path, err)
+		}
+	}
+	return nil
+}
+
 // parseAddress returns the host and the port of the address.
 func parseAddress(address string) (string, int, error) {
 	host, portString, err := net.SplitHostPort(address)
@@ -137,6 +137,29 @@ func (l *LogsConfigKeys) getAdditionalEndpoints() []Endpoint {
 	return endpoints
 }
 
+// getMainRoute returns the route of the main endpoint, nil when it receives all the logs.
+func (l *LogsConfigKeys) getMainRoute() (*Route, error) {
+	var route Route
+	var err error
+	configKey := l.getConfigKey("route")
+	raw := l.getConfig().Get(configKey)
+	if raw == nil {
+		return nil, nil
+	}
+	if s, ok := raw.(string); ok {
+		if s == "" {
+			return nil, nil
+		}
+		err = json.Unmarshal([]byte(s), &route)
+	} else {
+		err = l.getConfig().UnmarshalKey(configKey, &route)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &route, nil
+}
+
 func (l *LogsConfigKeys) expectedTagsDuration() time.Duration {
 	return l.getConfig().GetDuration(l.getConfigKey("expected_tags_duration"))
 }
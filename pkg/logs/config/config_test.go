@@ -412,6 +412,48 @@ func (suite *ConfigTestSuite) TestMultipleTCPEndpointsInConf() {
 	suite.Equal(expectedEndpoints, endpoints)
 }
 
+func (suite *ConfigTestSuite) TestEndpointsWithRoutes() {
+	suite.config.Set("api_key", "123")
+	suite.config.Set("logs_config.route", map[string]interface{}{
+		"exclude": map[string]interface{}{"statuses": []string{"debug"}},
+	})
+	endpointsInConfig := []map[string]interface{}{
+		{
+			"api_key": "456",
+			"host":    "additional.endpoint",
+			"name":    "security",
+			"route": map[string]interface{}{
+				"include": map[string]interface{}{"sources": []string{"auditd", "cloudtrail"}},
+			},
+		},
+		{
+			"path": "/var/log/datadog/debug.log",
+			"route": map[string]interface{}{
+				"include": map[string]interface{}{"statuses": []string{"debug"}},
+			},
+		},
+	}
+	suite.config.Set("logs_config.additional_endpoints", endpointsInConfig)
+
+	endpoints, err := BuildHTTPEndpoints("test-track", "test-proto", "test-source")
+	suite.Nil(err)
+	suite.True(endpoints.IsRouted())
+	suite.Equal(&Route{Exclude: RouteFilter{Statuses: []string{"debug"}}}, endpoints.Main.Route)
+	suite.Equal("main", endpoints.MainName())
+
+	suite.Len(endpoints.Additionals, 2)
+	suite.Equal(&Route{Include: RouteFilter{Sources: []string{"auditd", "cloudtrail"}}}, endpoints.Additionals[0].Route)
+	suite.Equal("security", endpoints.AdditionalName(0))
+	suite.False(endpoints.Additionals[0].IsFile())
+	suite.Equal(&Route{Include: RouteFilter{Statuses: []string{"debug"}}}, endpoints.Additionals[1].Route)
+	suite.Equal("additional_2", endpoints.AdditionalName(1))
+	suite.True(endpoints.Additionals[1].IsFile())
+
+	suite.config.Set("logs_config.route", `{"include": {"statuses": ["verbose"]}}`)
+	_, err = BuildHTTPEndpoints("test-track", "test-proto", "test-source")
+	suite.NotNil(err)
+}
+
 func (suite *ConfigTestSuite) TestEndpointsSetLogsDDUrl() {
 	suite.config.Set("api_key", "123")
 	suite.config.Set("compliance_config.endpoints.logs_dd_url", "my-proxy:443")
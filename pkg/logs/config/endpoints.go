@@ -6,6 +6,7 @@
 package config
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/config"
@@ -52,6 +53,18 @@ type Endpoint struct {
 	TrackType IntakeTrackType
 	Protocol  IntakeProtocol
 	Origin    IntakeOrigin
+
+	// Name identifies the endpoint in the delivery status of the routes.
+	Name string `mapstructure:"name" json:"name"`
+	// Path is the file the logs are written to when the endpoint is a local file.
+	Path string `mapstructure:"path" json:"path"`
+	// Route selects the logs sent to the endpoint, all the logs are sent when it is nil.
+	Route *Route `mapstructure:"route" json:"route"`
+}
+
+// IsFile returns true when the logs are written to a local file instead of being sent.
+func (e *Endpoint) IsFile() bool {
+	return e.Path != ""
 }
 
 // Endpoints holds the main endpoint and additional ones to dualship logs.
@@ -66,6 +79,36 @@ type Endpoints struct {
 	BatchMaxContentSize    int
 }
 
+// IsRouted returns true when at least one of the endpoints only receives the logs matching its route.
+func (e *Endpoints) IsRouted() bool {
+	if e.Main.Route != nil {
+		return true
+	}
+	for _, additional := range e.Additionals {
+		if additional.Route != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// MainName returns the name identifying the main endpoint in the delivery status of the routes.
+func (e *Endpoints) MainName() string {
+	if e.Main.Name != "" {
+		return e.Main.Name
+	}
+	return "main"
+}
+
+// AdditionalName returns the name identifying an additional endpoint in the delivery status of
+// the routes, the endpoints without a name are named after their position.
+func (e *Endpoints) AdditionalName(i int) string {
+	if e.Additionals[i].Name != "" {
+		return e.Additionals[i].Name
+	}
+	return fmt.Sprintf("additional_%d", i+1)
+}
+
 // NewEndpoints returns a new endpoints composite with default batching settings
 func NewEndpoints(main Endpoint, additionals []Endpoint, useProto bool, useHTTP bool) *Endpoints {
 	return &Endpoints{
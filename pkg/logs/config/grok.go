@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// maxGrokDepth limits the nesting of the grok patterns
+const maxGrokDepth = 10
+
+// grokReference matches `%{PATTERN}`, `%{PATTERN:field}` and `%{PATTERN:field:type}`, the type
+// is ignored since all the fields are parsed as strings
+var grokReference = regexp.MustCompile(`%\{(\w+)(?::(\w+))?(?::\w+)?\}`)
+
+// grokPatterns are the patterns which can be referenced by the grok rules, a subset of the
+// common grok patterns
+var grokPatterns = map[string]string{
+	"WORD":              `\b\w+\b`,
+	"NOTSPACE":          `\S+`,
+	"SPACE":             `\s*`,
+	"DATA":              `.*?`,
+	"GREEDYDATA":        `.*`,
+	"INT":               `[+-]?\d+`,
+	"POSINT":            `\b[1-9]\d*\b`,
+	"NUMBER":            `[+-]?(?:\d+(?:\.\d*)?|\.\d+)`,
+	"USERNAME":          `[a-zA-Z0-9._-]+`,
+	"UUID":              `[A-Fa-f0-9]{8}-(?:[A-Fa-f0-9]{4}-){3}[A-Fa-f0-9]{12}`,
+	"IPV4":              `(?:\d{1,3}\.){3}\d{1,3}`,
+	"IPV6":              `(?:[0-9A-Fa-f]{0,4}:){2,7}[0-9A-Fa-f]{0,4}`,
+	"IP":                `(?:%{IPV6}|%{IPV4})`,
+	"HOSTNAME":          `\b[0-9A-Za-z][0-9A-Za-z-]{0,62}(?:\.[0-9A-Za-z][0-9A-Za-z-]{0,62})*\.?\b`,
+	"QUOTEDSTRING":      `"(?:[^"\\]|\\.)*"|'(?:[^'\\]|\\.)*'`,
+	"LOGLEVEL":          `(?i:trace|debug|info(?:rmation)?|notice|warn(?:ing)?|err(?:or)?|crit(?:ical)?|fatal|severe|emerg(?:ency)?|alert)`,
+	"TIMESTAMP_ISO8601": `\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(?::\d{2}(?:[.,]\d+)?)?(?:Z|[+-]\d{2}:?\d{2})?`,
+	"HTTPDATE":          `\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}`,
+	"SYSLOGTIMESTAMP":   `\w{3} +\d{1,2} \d{2}:\d{2}:\d{2}`,
+}
+
+// compileGrokPattern expands the references of a grok pattern and compiles it into a regular
+// expression matching the whole message, whose named groups are the fields of the pattern
+func compileGrokPattern(pattern string) (*regexp.Regexp, error) {
+	expanded, err := expandGrokPattern(pattern, 0)
+	if err != nil {
+		return nil, err
+	}
+	return regexp.Compile("^" + expanded + "$")
+}
+
+func expandGrokPattern(pattern string, depth int) (string, error) {
+	if depth > maxGrokDepth {
+		return "", fmt.Errorf("the grok patterns are nested more than %d times", maxGrokDepth)
+	}
+
+	var err error
+	expanded := grokReference.ReplaceAllStringFunc(pattern, func(reference string) string {
+		submatches := grokReference.FindStringSubmatch(reference)
+		name, field := submatches[1], submatches[2]
+		definition, found := grokPatterns[name]
+		if !found {
+			err = fmt.Errorf("unknown grok pattern %s", name)
+			return reference
+		}
+		definition, expandErr := expandGrokPattern(definition, depth+1)
+		if expandErr != nil {
+			err = expandErr
+			return reference
+		}
+		if field == "" {
+			return "(?:" + definition + ")"
+		}
+		return "(?P<" + field + ">" + definition + ")"
+	})
+	return expanded, err
+}
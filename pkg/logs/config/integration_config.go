@@ -7,6 +7,7 @@ package config
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
@@ -67,6 +68,16 @@ type LogsConfig struct {
 	AutoMultiLine               bool    `mapstructure:"auto_multi_line_detection" json:"auto_multi_line_detection"`
 	AutoMultiLineSampleSize     int     `mapstructure:"auto_multi_line_sample_size" json:"auto_multi_line_sample_size"`
 	AutoMultiLineMatchThreshold float64 `mapstructure:"auto_multi_line_match_threshold" json:"auto_multi_line_match_threshold"`
+
+	// The throughput limits of the source, in lines and bytes per second
+	MaxLinesPerSecond float64 `mapstructure:"max_lines_per_second" json:"max_lines_per_second"`
+	MaxBytesPerSecond float64 `mapstructure:"max_bytes_per_second" json:"max_bytes_per_second"`
+	// SampleRate is the ratio of the logs kept by the sampling, the logs are sampled on the hash of
+	// the first group of SampleKey, or of their content when it doesn't match
+	SampleRate float64 `mapstructure:"sample_rate" json:"sample_rate"`
+	SampleKey  string  `mapstructure:"sample_key" json:"sample_key"`
+	// The statuses of the logs which are never sampled nor rate limited
+	KeepStatuses []string `mapstructure:"keep_statuses" json:"keep_statuses"`
 }
 
 // TailingMode type
@@ -131,13 +142,40 @@ func (c *LogsConfig) Validate() error {
 	case c.Type == UDPType && c.Port == 0:
 		return fmt.Errorf("udp source must have a port")
 	}
-	err := ValidateProcessingRules(c.ProcessingRules)
+	err := c.validateThrottling()
+	if err != nil {
+		return err
+	}
+	err = ValidateProcessingRules(c.ProcessingRules)
 	if err != nil {
 		return err
 	}
 	return CompileProcessingRules(c.ProcessingRules)
 }
 
+// IsThrottled returns true if the logs of the source are rate limited or sampled
+func (c *LogsConfig) IsThrottled() bool {
+	return c.MaxLinesPerSecond > 0 || c.MaxBytesPerSecond > 0 || (c.SampleRate > 0 && c.SampleRate < 1)
+}
+
+func (c *LogsConfig) validateThrottling() error {
+	if c.MaxLinesPerSecond < 0 {
+		return fmt.Errorf("invalid max_lines_per_second %v, must be positive", c.MaxLinesPerSecond)
+	}
+	if c.MaxBytesPerSecond < 0 {
+		return fmt.Errorf("invalid max_bytes_per_second %v, must be positive", c.MaxBytesPerSecond)
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("invalid sample_rate %v, must be between 0 and 1", c.SampleRate)
+	}
+	if c.SampleKey != "" {
+		if _, err := regexp.Compile(c.SampleKey); err != nil {
+			return fmt.Errorf("invalid sample_key %s: %v", c.SampleKey, err)
+		}
+	}
+	return nil
+}
+
 func (c *LogsConfig) validateTailingMode() error {
 	mode, found := TailingModeFromString(c.TailingMode)
 	if !found && c.TailingMode != "" {
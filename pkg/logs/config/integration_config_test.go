@@ -21,6 +21,7 @@ func TestValidateShouldSucceedWithValidConfigs(t *testing.T) {
 		{Type: DockerType},
 		{Type: JournaldType, ProcessingRules: []*ProcessingRule{{Name: "foo", Type: ExcludeAtMatch, Pattern: ".*"}}},
 		{Type: SnmpTrapsType},
+		{Type: DockerType, MaxLinesPerSecond: 100, MaxBytesPerSecond: 1000, SampleRate: 0.1, SampleKey: `trace_id=(\w+)`, KeepStatuses: []string{"error"}},
 	}
 
 	for _, config := range validConfigs {
@@ -42,6 +43,10 @@ func TestValidateShouldFailWithInvalidConfigs(t *testing.T) {
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Type: ExcludeAtMatch, Pattern: ".*"}}},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Type: ExcludeAtMatch}}},
 		{Type: DockerType, ProcessingRules: []*ProcessingRule{{Pattern: ".*"}}},
+		{Type: DockerType, MaxLinesPerSecond: -1},
+		{Type: DockerType, MaxBytesPerSecond: -1},
+		{Type: DockerType, SampleRate: 1.5},
+		{Type: DockerType, SampleRate: 0.5, SampleKey: "(unclosed"},
 	}
 
 	for _, config := range invalidConfigs {
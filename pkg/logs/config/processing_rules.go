@@ -16,15 +16,44 @@ const (
 	IncludeAtMatch = "include_at_match"
 	MaskSequences  = "mask_sequences"
 	MultiLine      = "multi_line"
+	JSONParser     = "parse_json"
+	LogfmtParser   = "parse_logfmt"
+	KeyValueParser = "parse_key_value"
+	GrokParser     = "parse_grok"
+	GenerateMetric = "generate_metric"
 )
 
-// ProcessingRule defines an exclusion or a masking rule to
+// Types of the metrics generated from the logs
+const (
+	CountMetric        = "count"
+	DistributionMetric = "distribution"
+)
+
+// ProcessingRule defines an exclusion, a masking, a parsing or a metric generation rule to
 // be applied on log lines
 type ProcessingRule struct {
 	Type               string
 	Name               string
 	ReplacePlaceholder string `mapstructure:"replace_placeholder" json:"replace_placeholder"`
 	Pattern            string
+	// The fields of the parsed logs promoted to the attributes of the messages, the
+	// other fields are dropped.
+	StatusField     string   `mapstructure:"status_field" json:"status_field"`
+	ServiceField    string   `mapstructure:"service_field" json:"service_field"`
+	TimestampField  string   `mapstructure:"timestamp_field" json:"timestamp_field"`
+	TimestampFormat string   `mapstructure:"timestamp_format" json:"timestamp_format"`
+	MessageField    string   `mapstructure:"message_field" json:"message_field"`
+	TagFields       []string `mapstructure:"tag_fields" json:"tag_fields"`
+	// The separators of the parse_key_value rules
+	PairSeparator     string `mapstructure:"pair_separator" json:"pair_separator"`
+	KeyValueSeparator string `mapstructure:"key_value_separator" json:"key_value_separator"`
+	// The metric generated by the generate_metric rules from the matching logs, a count of
+	// the logs or a distribution of the value captured by ValueGroup. The other named groups
+	// of the pattern are added to the tags of the metric.
+	MetricName       string `mapstructure:"metric_name" json:"metric_name"`
+	MetricType       string `mapstructure:"metric_type" json:"metric_type"`
+	ValueGroup       string `mapstructure:"value_group" json:"value_group"`
+	DropMatchingLogs bool   `mapstructure:"drop_matching_logs" json:"drop_matching_logs"`
 	// TODO: should be moved out
 	Regex       *regexp.Regexp
 	Placeholder []byte
@@ -34,7 +63,7 @@ type ProcessingRule struct {
 // Each processing rule must have:
 // - a valid name
 // - a valid type
-// - a valid pattern that compiles
+// - a valid pattern that compiles, except for the parsing rules of JSON, logfmt and key/value pairs
 func ValidateProcessingRules(rules []*ProcessingRule) error {
 	for _, rule := range rules {
 		if rule.Name == "" {
@@ -42,8 +71,15 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 		}
 
 		switch rule.Type {
-		case ExcludeAtMatch, IncludeAtMatch, MaskSequences, MultiLine:
+		case ExcludeAtMatch, IncludeAtMatch, MaskSequences, MultiLine, GrokParser:
 			break
+		case GenerateMetric:
+			if err := validateGenerateMetricRule(rule); err != nil {
+				return err
+			}
+		case JSONParser, LogfmtParser, KeyValueParser:
+			// these rules parse all the messages
+			continue
 		case "":
 			return fmt.Errorf("type must be set for processing rule `%s`", rule.Name)
 		default:
@@ -53,6 +89,12 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 		if rule.Pattern == "" {
 			return fmt.Errorf("no pattern provided for processing rule: %s", rule.Name)
 		}
+		if rule.Type == GrokParser {
+			if _, err := compileGrokPattern(rule.Pattern); err != nil {
+				return fmt.Errorf("invalid grok pattern %s for processing rule: %s: %v", rule.Pattern, rule.Name, err)
+			}
+			continue
+		}
 		_, err := regexp.Compile(rule.Pattern)
 		if err != nil {
 			return fmt.Errorf("invalid pattern %s for processing rule: %s", rule.Pattern, rule.Name)
@@ -64,12 +106,20 @@ func ValidateProcessingRules(rules []*ProcessingRule) error {
 // CompileProcessingRules compiles all processing rule regular expressions.
 func CompileProcessingRules(rules []*ProcessingRule) error {
 	for _, rule := range rules {
+		if rule.Type == GrokParser {
+			re, err := compileGrokPattern(rule.Pattern)
+			if err != nil {
+				return err
+			}
+			rule.Regex = re
+			continue
+		}
 		re, err := regexp.Compile(rule.Pattern)
 		if err != nil {
 			return err
 		}
 		switch rule.Type {
-		case ExcludeAtMatch, IncludeAtMatch:
+		case ExcludeAtMatch, IncludeAtMatch, GenerateMetric:
 			rule.Regex = re
 		case MaskSequences:
 			rule.Regex = re
@@ -83,3 +133,34 @@ func CompileProcessingRules(rules []*ProcessingRule) error {
 	}
 	return nil
 }
+
+// validateGenerateMetricRule checks the metric of a generate_metric rule, the value of the
+// distributions must be captured by a named group of the pattern
+func validateGenerateMetricRule(rule *ProcessingRule) error {
+	if rule.MetricName == "" {
+		return fmt.Errorf("no metric name provided for processing rule: %s", rule.Name)
+	}
+	switch rule.MetricType {
+	case "", CountMetric:
+		return nil
+	case DistributionMetric:
+		break
+	default:
+		return fmt.Errorf("metric type %s is not supported for processing rule: %s", rule.MetricType, rule.Name)
+	}
+
+	if rule.ValueGroup == "" {
+		return fmt.Errorf("no value group provided for the distribution of processing rule: %s", rule.Name)
+	}
+	re, err := regexp.Compile(rule.Pattern)
+	if err != nil {
+		// the pattern is validated afterwards
+		return nil
+	}
+	for _, name := range re.SubexpNames() {
+		if name == rule.ValueGroup {
+			return nil
+		}
+	}
+	return fmt.Errorf("the pattern of processing rule %s has no group named %s", rule.Name, rule.ValueGroup)
+}
@@ -33,3 +33,48 @@ func TestCompileShouldFailWithInvalidRules(t *testing.T) {
 		assert.Nil(t, rule.Regex)
 	}
 }
+
+func TestValidateParsingRules(t *testing.T) {
+	// the JSON, logfmt and key/value parsing rules don't need a pattern
+	for _, ruleType := range []string{JSONParser, LogfmtParser, KeyValueParser} {
+		assert.Nil(t, ValidateProcessingRules([]*ProcessingRule{{Type: ruleType, Name: "parse"}}))
+	}
+
+	assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{{Type: GrokParser, Name: "grok"}}))
+	assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{{Type: GrokParser, Name: "grok", Pattern: "%{UNKNOWN:field}"}}))
+	assert.Nil(t, ValidateProcessingRules([]*ProcessingRule{{Type: GrokParser, Name: "grok", Pattern: "%{WORD:level} %{GREEDYDATA:message}"}}))
+}
+
+func TestCompileGrokRules(t *testing.T) {
+	rules := []*ProcessingRule{{Type: GrokParser, Pattern: `%{TIMESTAMP_ISO8601:time} \[%{LOGLEVEL:level}\] %{IP:client} %{GREEDYDATA:message}`}}
+	assert.Nil(t, CompileProcessingRules(rules))
+
+	re := rules[0].Regex
+	match := re.FindStringSubmatch("2021-06-01T10:00:00.123Z [WARN] 10.0.0.1 disk almost full")
+	assert.NotNil(t, match)
+	fields := map[string]string{}
+	for i, name := range re.SubexpNames() {
+		if name != "" {
+			fields[name] = match[i]
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"time":    "2021-06-01T10:00:00.123Z",
+		"level":   "WARN",
+		"client":  "10.0.0.1",
+		"message": "disk almost full",
+	}, fields)
+
+	// the pattern must match the whole message
+	assert.False(t, re.MatchString("prefix 2021-06-01T10:00:00Z [WARN] 10.0.0.1 disk almost full"))
+}
+
+func TestValidateGenerateMetricRules(t *testing.T) {
+	assert.Nil(t, ValidateProcessingRules([]*ProcessingRule{{Type: GenerateMetric, Name: "count", Pattern: "ERROR", MetricName: "app.errors"}}))
+	assert.Nil(t, ValidateProcessingRules([]*ProcessingRule{{Type: GenerateMetric, Name: "distribution", Pattern: `took (?P<duration>\d+)ms`, MetricName: "app.duration", MetricType: DistributionMetric, ValueGroup: "duration"}}))
+
+	assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{{Type: GenerateMetric, Name: "no_pattern", MetricName: "app.errors"}}))
+	assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{{Type: GenerateMetric, Name: "no_name", Pattern: "ERROR"}}))
+	assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{{Type: GenerateMetric, Name: "gauge", Pattern: "ERROR", MetricName: "app.errors", MetricType: "gauge"}}))
+	assert.NotNil(t, ValidateProcessingRules([]*ProcessingRule{{Type: GenerateMetric, Name: "no_group", Pattern: `took (\d+)ms`, MetricName: "app.duration", MetricType: DistributionMetric, ValueGroup: "duration"}}))
+}
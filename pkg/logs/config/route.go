@@ -0,0 +1,53 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validRouteStatuses are the statuses a route can filter on.
+var validRouteStatuses = map[string]bool{
+	"emergency": true,
+	"alert":     true,
+	"critical":  true,
+	"error":     true,
+	"warn":      true,
+	"notice":    true,
+	"info":      true,
+	"debug":     true,
+}
+
+// Route selects the logs sent to an endpoint: the logs matching `include` and not matching
+// `exclude`. An empty `include` matches all the logs and an empty `exclude` matches none.
+type Route struct {
+	Include RouteFilter `mapstructure:"include" json:"include"`
+	Exclude RouteFilter `mapstructure:"exclude" json:"exclude"`
+}
+
+// RouteFilter matches the logs matching one of the values of each of its non-empty fields.
+type RouteFilter struct {
+	Sources  []string `mapstructure:"sources" json:"sources"`
+	Services []string `mapstructure:"services" json:"services"`
+	Tags     []string `mapstructure:"tags" json:"tags"`
+	Statuses []string `mapstructure:"statuses" json:"statuses"`
+}
+
+// IsEmpty returns true when the filter has no criteria.
+func (f *RouteFilter) IsEmpty() bool {
+	return len(f.Sources) == 0 && len(f.Services) == 0 && len(f.Tags) == 0 && len(f.Statuses) == 0
+}
+
+// Validate returns an error if the route filters on an unknown status.
+func (r *Route) Validate() error {
+	for _, status := range append(append([]string{}, r.Include.Statuses...), r.Exclude.Statuses...) {
+		if !validRouteStatuses[strings.ToLower(status)] {
+			return fmt.Errorf("invalid status %q for the route, the valid statuses are emergency, alert, critical, error, warn, notice, info and debug", status)
+		}
+	}
+	return nil
+}
@@ -13,12 +13,13 @@ import (
 
 // Origin represents the Origin of a message
 type Origin struct {
-	Identifier string
-	LogSource  *config.LogSource
-	Offset     string
-	service    string
-	source     string
-	tags       []string
+	Identifier    string
+	LogSource     *config.LogSource
+	Offset        string
+	service       string
+	parsedService string
+	source        string
+	tags          []string
 }
 
 // NewOrigin returns a new Origin
@@ -88,6 +89,12 @@ func (o *Origin) SetTags(tags []string) {
 	o.tags = tags
 }
 
+// AddTags adds tags to the tags of the origin.
+func (o *Origin) AddTags(tags ...string) {
+	// the tags can be shared with the other origins of a tailer
+	o.tags = append(append([]string{}, o.tags...), tags...)
+}
+
 // SetSource sets the source of the origin.
 func (o *Origin) SetSource(source string) {
 	o.source = source
@@ -107,9 +114,18 @@ func (o *Origin) SetService(service string) {
 	o.service = service
 }
 
-// Service returns the service of the configuration if set or the service of the message,
-// if none are defined, returns an empty string by default.
+// SetParsedService sets the service parsed from the content of the message, which takes
+// precedence over the service of the configuration.
+func (o *Origin) SetParsedService(service string) {
+	o.parsedService = service
+}
+
+// Service returns the parsed service if set, then the service of the configuration if set or
+// the service of the message, if none are defined, returns an empty string by default.
 func (o *Origin) Service() string {
+	if o.parsedService != "" {
+		return o.parsedService
+	}
 	if o.LogSource.Config.Service != "" {
 		return o.LogSource.Config.Service
 	}
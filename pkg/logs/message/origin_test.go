@@ -105,3 +105,11 @@ func TestDefaultServiceValueIsServiceFromConfig(t *testing.T) {
 	origin.SetService("bar")
 	assert.Equal(t, "bar", origin.Service())
 }
+
+func TestParsedServiceOverridesServiceFromConfig(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{Service: "foo"})
+	origin := NewOrigin(source)
+	origin.SetService("bar")
+	origin.SetParsedService("baz")
+	assert.Equal(t, "baz", origin.Service())
+}
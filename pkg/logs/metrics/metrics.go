@@ -24,6 +24,16 @@ var (
 	// TlmLogsProcessed is the total number of processed logs.
 	TlmLogsProcessed = telemetry.NewCounter("logs", "processed",
 		nil, "Total number of processed logs")
+	// LogsParsingErrors is the total number of logs which could not be parsed by a parsing rule
+	LogsParsingErrors = expvar.Int{}
+	// TlmLogsParsingErrors is the total number of logs which could not be parsed per parsing rule
+	TlmLogsParsingErrors = telemetry.NewCounter("logs", "parsing_errors",
+		[]string{"rule"}, "Total number of logs which could not be parsed per parsing rule")
+	// LogsThrottled is the total number of logs dropped by the rate limits and the sampling of the sources
+	LogsThrottled = expvar.Int{}
+	// TlmLogsThrottled is the total number of logs dropped by the rate limits and the sampling per source
+	TlmLogsThrottled = telemetry.NewCounter("logs", "throttled",
+		[]string{"source", "reason"}, "Total number of logs dropped by the rate limits and the sampling per source")
 
 	// LogsSent is the total number of sent logs.
 	LogsSent = expvar.Int{}
@@ -40,6 +50,21 @@ var (
 	// TlmLogsDropped is the total number of logs dropped per Destination
 	TlmLogsDropped = telemetry.NewCounter("logs", "dropped",
 		[]string{"destination"}, "Total number of logs dropped per Destination")
+	// RoutePayloadsSent is the total number of payloads sent per route
+	RoutePayloadsSent = expvar.Map{}
+	// TlmRoutePayloadsSent is the total number of payloads sent per route
+	TlmRoutePayloadsSent = telemetry.NewCounter("logs", "route_payloads_sent",
+		[]string{"route"}, "Total number of payloads sent per route")
+	// RouteErrors is the total number of payloads which could not be sent per route
+	RouteErrors = expvar.Map{}
+	// TlmRouteErrors is the total number of payloads which could not be sent per route
+	TlmRouteErrors = telemetry.NewCounter("logs", "route_errors",
+		[]string{"route"}, "Total number of payloads which could not be sent per route")
+	// RouteLogsFiltered is the total number of logs not matching the route per route
+	RouteLogsFiltered = expvar.Map{}
+	// TlmRouteLogsFiltered is the total number of logs not matching the route per route
+	TlmRouteLogsFiltered = telemetry.NewCounter("logs", "route_logs_filtered",
+		[]string{"route"}, "Total number of logs not matching the route per route")
 	// BytesSent is the total number of sent bytes before encoding if any
 	BytesSent = expvar.Int{}
 	// TlmBytesSent is the total number of sent bytes before encoding if any
@@ -64,9 +89,14 @@ func init() {
 	LogsExpvars = expvar.NewMap("logs-agent")
 	LogsExpvars.Set("LogsDecoded", &LogsDecoded)
 	LogsExpvars.Set("LogsProcessed", &LogsProcessed)
+	LogsExpvars.Set("LogsParsingErrors", &LogsParsingErrors)
+	LogsExpvars.Set("LogsThrottled", &LogsThrottled)
 	LogsExpvars.Set("LogsSent", &LogsSent)
 	LogsExpvars.Set("DestinationErrors", &DestinationErrors)
 	LogsExpvars.Set("DestinationLogsDropped", &DestinationLogsDropped)
+	LogsExpvars.Set("RoutePayloadsSent", &RoutePayloadsSent)
+	LogsExpvars.Set("RouteErrors", &RouteErrors)
+	LogsExpvars.Set("RouteLogsFiltered", &RouteLogsFiltered)
 	LogsExpvars.Set("BytesSent", &BytesSent)
 	LogsExpvars.Set("EncodedBytesSent", &EncodedBytesSent)
 	LogsExpvars.Set("SenderLatency", &SenderLatency)
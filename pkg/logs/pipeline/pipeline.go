@@ -7,8 +7,14 @@ package pipeline
 
 import (
 	"context"
+	"path/filepath"
+	"strconv"
+
+	coreConfig "github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/client/file"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/http"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/tcp"
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
@@ -27,22 +33,7 @@ type Pipeline struct {
 
 // NewPipeline returns a new Pipeline
 func NewPipeline(outputChan chan *message.Message, processingRules []*config.ProcessingRule, endpoints *config.Endpoints, destinationsContext *client.DestinationsContext, diagnosticMessageReceiver diagnostic.MessageReceiver, serverless bool, pipelineID int) *Pipeline {
-	var destinations *client.Destinations
-	if endpoints.UseHTTP {
-		main := http.NewDestination(endpoints.Main, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend)
-		additionals := []client.Destination{}
-		for _, endpoint := range endpoints.Additionals {
-			additionals = append(additionals, http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend))
-		}
-		destinations = client.NewDestinations(main, additionals)
-	} else {
-		main := tcp.NewDestination(endpoints.Main, endpoints.UseProto, destinationsContext)
-		additionals := []client.Destination{}
-		for _, endpoint := range endpoints.Additionals {
-			additionals = append(additionals, tcp.NewDestination(endpoint, endpoints.UseProto, destinationsContext))
-		}
-		destinations = client.NewDestinations(main, additionals)
-	}
+	destinations := buildDestinations(endpoints, destinationsContext)
 
 	senderChan := make(chan *message.Message, config.ChanSize)
 
@@ -52,7 +43,11 @@ func NewPipeline(outputChan chan *message.Message, processingRules []*config.Pro
 	} else {
 		strategy = sender.StreamStrategy
 	}
-	sender := sender.NewSender(senderChan, outputChan, destinations, strategy)
+	var spool *sender.Spool
+	if endpoints.UseHTTP && !serverless {
+		spool = buildSpool(pipelineID)
+	}
+	sender := sender.NewSender(senderChan, outputChan, destinations, strategy, spool)
 
 	var encoder processor.Encoder
 	if serverless {
@@ -92,3 +87,53 @@ func (p *Pipeline) Flush(ctx context.Context) {
 	p.processor.Flush(ctx) // flush messages in the processor into the sender
 	p.sender.Flush(ctx)    // flush the sender
 }
+
+// buildDestinations returns the destinations of the endpoints, each destination only receives
+// the logs matching its route when one of the endpoints has a route.
+func buildDestinations(endpoints *config.Endpoints, destinationsContext *client.DestinationsContext) *client.Destinations {
+	main := buildDestination(endpoints.Main, endpoints, destinationsContext)
+	additionals := []client.Destination{}
+	for _, endpoint := range endpoints.Additionals {
+		additionals = append(additionals, buildDestination(endpoint, endpoints, destinationsContext))
+	}
+	if !endpoints.IsRouted() {
+		return client.NewDestinations(main, additionals)
+	}
+	additionalRoutes := []*client.Route{}
+	for i, endpoint := range endpoints.Additionals {
+		additionalRoutes = append(additionalRoutes, client.NewRoute(endpoints.AdditionalName(i), endpoint.Route))
+	}
+	return client.NewRoutedDestinations(main, client.NewRoute(endpoints.MainName(), endpoints.Main.Route), additionals, additionalRoutes)
+}
+
+func buildDestination(endpoint config.Endpoint, endpoints *config.Endpoints, destinationsContext *client.DestinationsContext) client.Destination {
+	if endpoint.IsFile() {
+		return file.NewDestination(endpoint, destinationsContext)
+	}
+	if endpoints.UseHTTP {
+		return http.NewDestination(endpoint, http.JSONContentType, destinationsContext, endpoints.BatchMaxConcurrentSend)
+	}
+	return tcp.NewDestination(endpoint, endpoints.UseProto, destinationsContext)
+}
+
+// buildSpool returns the spool storing on the disk the payloads of a pipeline which cannot be
+// sent, nil when it is disabled. The disk space of the spool is shared by the pipelines.
+func buildSpool(pipelineID int) *sender.Spool {
+	maxSizeInBytes := coreConfig.Datadog.GetInt64("logs_config.spool_max_size_in_bytes")
+	if maxSizeInBytes <= 0 {
+		return nil
+	}
+	storagePath := coreConfig.Datadog.GetString("logs_config.spool_path")
+	if storagePath == "" {
+		storagePath = filepath.Join(coreConfig.Datadog.GetString("logs_config.run_path"), "spool")
+	}
+	storagePath = filepath.Join(storagePath, strconv.Itoa(pipelineID))
+	maxDiskRatio := coreConfig.Datadog.GetFloat64("logs_config.spool_max_disk_ratio")
+
+	spool, err := sender.NewSpool(storagePath, maxSizeInBytes/int64(config.NumberOfPipelines), maxDiskRatio)
+	if err != nil {
+		log.Errorf("Could not create the logs spool in %s, the logs won't be stored on the disk during the outages: %v", storagePath, err)
+		return nil
+	}
+	return spool
+}
@@ -6,6 +6,8 @@
 package pipeline
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -20,12 +22,17 @@ import (
 
 type ProviderTestSuite struct {
 	suite.Suite
-	p *provider
-	a *auditor.RegistryAuditor
+	p       *provider
+	a       *auditor.RegistryAuditor
+	testDir string
 }
 
 func (suite *ProviderTestSuite) SetupTest() {
-	suite.a = auditor.New("", auditor.DefaultRegistryFilename, time.Hour, health.RegisterLiveness("fake"))
+	var err error
+	suite.testDir, err = ioutil.TempDir("", "tests")
+	suite.Nil(err)
+
+	suite.a = auditor.New(suite.testDir, auditor.DefaultRegistryFilename, time.Hour, health.RegisterLiveness("fake"))
 	suite.p = &provider{
 		numberOfPipelines: 3,
 		auditor:           suite.a,
@@ -34,6 +41,10 @@ func (suite *ProviderTestSuite) SetupTest() {
 	}
 }
 
+func (suite *ProviderTestSuite) TearDownTest() {
+	os.RemoveAll(suite.testDir)
+}
+
 func (suite *ProviderTestSuite) TestProvider() {
 	suite.a.Start()
 	suite.p.Start()
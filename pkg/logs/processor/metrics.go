@@ -0,0 +1,90 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// metricsCommitInterval is the interval at which the metrics generated from the logs are
+// committed to the aggregator
+var metricsCommitInterval = 15 * time.Second
+
+// getMetricsSender returns the sender of the metrics generated from the logs
+var getMetricsSender = aggregator.GetDefaultSender
+
+// generateMetric submits the metric of a generate_metric rule when the content matches its
+// pattern, and returns whether it matched
+func (p *Processor) generateMetric(rule *config.ProcessingRule, msg *message.Message, content []byte) bool {
+	match := rule.Regex.FindSubmatch(content)
+	if match == nil {
+		return false
+	}
+
+	sender := p.getSender()
+	if sender == nil {
+		return true
+	}
+
+	value := 1.0
+	tags := append([]string{}, msg.Origin.Tags()...)
+	if source := msg.Origin.Source(); source != "" {
+		tags = append(tags, "source:"+source)
+	}
+	if service := msg.Origin.Service(); service != "" {
+		tags = append(tags, "service:"+service)
+	}
+	for i, name := range rule.Regex.SubexpNames() {
+		if name == "" || match[i] == nil {
+			continue
+		}
+		if rule.MetricType == config.DistributionMetric && name == rule.ValueGroup {
+			v, err := strconv.ParseFloat(string(match[i]), 64)
+			if err != nil {
+				log.Debugf("Unable to parse the value of %s from the logs: %v", rule.MetricName, err)
+				return true
+			}
+			value = v
+			continue
+		}
+		tags = append(tags, name+":"+string(match[i]))
+	}
+
+	if rule.MetricType == config.DistributionMetric {
+		sender.Distribution(rule.MetricName, value, "", tags)
+	} else {
+		sender.Count(rule.MetricName, value, "", tags)
+	}
+	atomic.StoreUint32(&p.pendingMetrics, 1)
+	return true
+}
+
+// getSender returns the sender of the metrics, nil when the aggregator is not available
+func (p *Processor) getSender() aggregator.Sender {
+	p.senderOnce.Do(func() {
+		sender, err := getMetricsSender()
+		if err != nil {
+			log.Errorf("Unable to generate the metrics of the logs processing rules: %v", err)
+			return
+		}
+		p.metricsSender = sender
+	})
+	return p.metricsSender
+}
+
+// commitMetrics commits the metrics generated since the last commit
+func (p *Processor) commitMetrics() {
+	if atomic.SwapUint32(&p.pendingMetrics, 0) == 1 {
+		p.metricsSender.Commit()
+	}
+}
@@ -0,0 +1,99 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
+	"github.com/DataDog/datadog-agent/pkg/aggregator/mocksender"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+)
+
+func newMetricsProcessor(rules ...*config.ProcessingRule) (*Processor, *mocksender.MockSender) {
+	if err := config.CompileProcessingRules(rules); err != nil {
+		panic(err)
+	}
+	sender := new(mocksender.MockSender)
+	sender.SetupAcceptAll()
+	p := &Processor{processingRules: rules}
+	p.senderOnce.Do(func() { p.metricsSender = sender })
+	return p, sender
+}
+
+func TestGenerateCountMetric(t *testing.T) {
+	p, sender := newMetricsProcessor(&config.ProcessingRule{
+		Type:       config.GenerateMetric,
+		Name:       "http_requests",
+		Pattern:    `(?P<method>GET|POST) \S+ (?P<status_code>\d{3})`,
+		MetricName: "app.http.requests",
+		MetricType: config.CountMetric,
+	})
+	source := config.NewLogSource("", &config.LogsConfig{Source: "nginx", Service: "web"})
+
+	shouldProcess, content := p.applyRedactingRules(newMessage([]byte("GET /index.html 404"), source, ""))
+	assert.True(t, shouldProcess)
+	assert.Equal(t, []byte("GET /index.html 404"), content)
+	sender.AssertMetric(t, "Count", "app.http.requests", 1, "", []string{"source:nginx", "service:web", "method:GET", "status_code:404"})
+
+	shouldProcess, _ = p.applyRedactingRules(newMessage([]byte("healthcheck"), source, ""))
+	assert.True(t, shouldProcess)
+	sender.AssertNumberOfCalls(t, "Count", 1)
+
+	sender.AssertNotCalled(t, "Commit")
+	p.commitMetrics()
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+	// nothing to commit since the last commit
+	p.commitMetrics()
+	sender.AssertNumberOfCalls(t, "Commit", 1)
+}
+
+func TestGenerateDistributionMetric(t *testing.T) {
+	p, sender := newMetricsProcessor(&config.ProcessingRule{
+		Type:             config.GenerateMetric,
+		Name:             "query_duration",
+		Pattern:          `query on (?P<table>\w+) took (?P<duration>[\d.]+)ms`,
+		MetricName:       "app.db.query.duration",
+		MetricType:       config.DistributionMetric,
+		ValueGroup:       "duration",
+		DropMatchingLogs: true,
+	})
+	source := config.NewLogSource("", &config.LogsConfig{})
+
+	shouldProcess, _ := p.applyRedactingRules(newMessage([]byte("query on users took 12.5ms"), source, ""))
+	assert.False(t, shouldProcess)
+	sender.AssertMetric(t, "Distribution", "app.db.query.duration", 12.5, "", []string{"table:users"})
+
+	// the logs which don't match are kept
+	shouldProcess, _ = p.applyRedactingRules(newMessage([]byte("connection opened"), source, ""))
+	assert.True(t, shouldProcess)
+	sender.AssertNumberOfCalls(t, "Distribution", 1)
+}
+
+func TestGenerateMetricWithoutSender(t *testing.T) {
+	defer func(f func() (aggregator.Sender, error)) { getMetricsSender = f }(getMetricsSender)
+	getMetricsSender = func() (aggregator.Sender, error) {
+		return nil, assert.AnError
+	}
+
+	rules := []*config.ProcessingRule{{
+		Type:             config.GenerateMetric,
+		Name:             "errors",
+		Pattern:          "ERROR",
+		MetricName:       "app.errors",
+		DropMatchingLogs: true,
+	}}
+	assert.NoError(t, config.CompileProcessingRules(rules))
+	p := &Processor{processingRules: rules}
+	source := config.NewLogSource("", &config.LogsConfig{})
+
+	// the rule still applies when the metrics cannot be submitted
+	shouldProcess, _ := p.applyRedactingRules(newMessage([]byte("ERROR boom"), source, ""))
+	assert.False(t, shouldProcess)
+	p.commitMetrics()
+}
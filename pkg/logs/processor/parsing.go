@@ -0,0 +1,243 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+const (
+	defaultMessageField      = "message"
+	defaultPairSeparator     = " "
+	defaultKeyValueSeparator = "="
+)
+
+// statuses maps the common severities of the logs to the statuses of the messages
+var statuses = map[string]string{
+	"emerg":         message.StatusEmergency,
+	"emergency":     message.StatusEmergency,
+	"panic":         message.StatusEmergency,
+	"alert":         message.StatusAlert,
+	"crit":          message.StatusCritical,
+	"critical":      message.StatusCritical,
+	"fatal":         message.StatusCritical,
+	"err":           message.StatusError,
+	"error":         message.StatusError,
+	"severe":        message.StatusError,
+	"warn":          message.StatusWarning,
+	"warning":       message.StatusWarning,
+	"notice":        message.StatusNotice,
+	"info":          message.StatusInfo,
+	"information":   message.StatusInfo,
+	"informational": message.StatusInfo,
+	"debug":         message.StatusDebug,
+	"trace":         message.StatusDebug,
+	"verbose":       message.StatusDebug,
+	// syslog severities
+	"0": message.StatusEmergency,
+	"1": message.StatusAlert,
+	"2": message.StatusCritical,
+	"3": message.StatusError,
+	"4": message.StatusWarning,
+	"5": message.StatusNotice,
+	"6": message.StatusInfo,
+	"7": message.StatusDebug,
+}
+
+// applyParsingRule parses the content of a message, promotes the fields of the rule to the
+// attributes of the message and returns the value of its message field. The content is
+// returned unchanged when it cannot be parsed.
+func applyParsingRule(rule *config.ProcessingRule, msg *message.Message, content []byte) []byte {
+	var fields map[string]string
+	var err error
+	switch rule.Type {
+	case config.JSONParser:
+		fields, err = parseJSON(content)
+	case config.LogfmtParser:
+		fields, err = parseKeyValues(content, defaultPairSeparator, defaultKeyValueSeparator)
+	case config.KeyValueParser:
+		fields, err = parseKeyValues(content, rule.PairSeparator, rule.KeyValueSeparator)
+	case config.GrokParser:
+		fields, err = parseGrok(rule, content)
+	}
+	if err != nil {
+		metrics.LogsParsingErrors.Add(1)
+		metrics.TlmLogsParsingErrors.Inc(rule.Name)
+		return content
+	}
+
+	if value, found := fields[rule.StatusField]; found && rule.StatusField != "" {
+		if status, known := statuses[strings.ToLower(value)]; known {
+			msg.SetStatus(status)
+		}
+	}
+	if value, found := fields[rule.ServiceField]; found && rule.ServiceField != "" && value != "" {
+		msg.Origin.SetParsedService(value)
+	}
+	if value, found := fields[rule.TimestampField]; found && rule.TimestampField != "" {
+		if ts, err := parseTimestamp(value, rule.TimestampFormat); err == nil {
+			msg.Timestamp = ts
+		}
+	}
+	var tags []string
+	for _, field := range rule.TagFields {
+		if value, found := fields[field]; found && value != "" {
+			tags = append(tags, field+":"+value)
+		}
+	}
+	if len(tags) != 0 {
+		msg.Origin.AddTags(tags...)
+	}
+
+	messageField := rule.MessageField
+	if messageField == "" {
+		messageField = defaultMessageField
+	}
+	if value, found := fields[messageField]; found {
+		return []byte(value)
+	}
+	return content
+}
+
+// parseJSON returns the fields of a JSON object, the fields of the nested objects are named
+// after their path joined with dots
+func parseJSON(content []byte) (map[string]string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var object map[string]interface{}
+	if err := decoder.Decode(&object); err != nil {
+		return nil, err
+	}
+	fields := make(map[string]string, len(object))
+	flattenJSON("", object, fields)
+	return fields, nil
+}
+
+func flattenJSON(prefix string, object map[string]interface{}, fields map[string]string) {
+	for key, value := range object {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenJSON(prefix+key+".", v, fields)
+		case string:
+			fields[prefix+key] = v
+		case nil:
+			fields[prefix+key] = ""
+		case []interface{}:
+			encoded, _ := json.Marshal(v)
+			fields[prefix+key] = string(encoded)
+		default:
+			fields[prefix+key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// parseKeyValues returns the key/value pairs of the content, the values can be quoted with
+// double or single quotes. The pairs without separator are ignored.
+func parseKeyValues(content []byte, pairSeparator string, keyValueSeparator string) (map[string]string, error) {
+	if pairSeparator == "" {
+		pairSeparator = defaultPairSeparator
+	}
+	if keyValueSeparator == "" {
+		keyValueSeparator = defaultKeyValueSeparator
+	}
+
+	fields := map[string]string{}
+	for _, pair := range splitOutsideQuotes(string(content), pairSeparator) {
+		i := strings.Index(pair, keyValueSeparator)
+		if i <= 0 {
+			continue
+		}
+		fields[strings.TrimSpace(pair[:i])] = unquote(strings.TrimSpace(pair[i+len(keyValueSeparator):]))
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no key/value pair found")
+	}
+	return fields, nil
+}
+
+// splitOutsideQuotes splits s around the separators which are not quoted
+func splitOutsideQuotes(s string, separator string) []string {
+	var parts []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0 && s[i] == '\\':
+			i++
+		case quote != 0 && s[i] == quote:
+			quote = 0
+		case quote == 0 && (s[i] == '"' || s[i] == '\''):
+			quote = s[i]
+		case quote == 0 && strings.HasPrefix(s[i:], separator):
+			parts = append(parts, s[start:i])
+			i += len(separator) - 1
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquote removes the quotes around a value and unescapes its quotes
+func unquote(value string) string {
+	if len(value) < 2 {
+		return value
+	}
+	quote := value[0]
+	if (quote != '"' && quote != '\'') || value[len(value)-1] != quote {
+		return value
+	}
+	value = value[1 : len(value)-1]
+	return strings.NewReplacer(`\\`, `\`, `\`+string(quote), string(quote)).Replace(value)
+}
+
+// parseGrok returns the fields captured by the grok pattern of the rule
+func parseGrok(rule *config.ProcessingRule, content []byte) (map[string]string, error) {
+	match := rule.Regex.FindSubmatch(content)
+	if match == nil {
+		return nil, fmt.Errorf("the message doesn't match the pattern")
+	}
+	fields := map[string]string{}
+	for i, name := range rule.Regex.SubexpNames() {
+		if name != "" && match[i] != nil {
+			fields[name] = string(match[i])
+		}
+	}
+	return fields, nil
+}
+
+// parseTimestamp parses a timestamp with a Go layout, or as seconds or milliseconds since the
+// epoch with the `unix` and `unix_ms` formats. The timestamps are parsed as RFC3339 by default.
+func parseTimestamp(value string, format string) (time.Time, error) {
+	switch format {
+	case "unix", "unix_ms":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if format == "unix_ms" {
+			f /= 1000
+		}
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	case "":
+		format = time.RFC3339Nano
+	}
+	ts, err := time.Parse(format, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ts.UTC(), nil
+}
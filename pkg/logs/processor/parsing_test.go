@@ -0,0 +1,157 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newParsingSource(rule *config.ProcessingRule) *config.LogSource {
+	rules := []*config.ProcessingRule{rule}
+	if err := config.CompileProcessingRules(rules); err != nil {
+		panic(err)
+	}
+	return config.NewLogSource("", &config.LogsConfig{ProcessingRules: rules})
+}
+
+func TestParseJSON(t *testing.T) {
+	p := &Processor{}
+	source := newParsingSource(&config.ProcessingRule{
+		Type:           config.JSONParser,
+		Name:           "json",
+		StatusField:    "log.level",
+		ServiceField:   "app",
+		TimestampField: "ts",
+		TagFields:      []string{"env", "missing"},
+	})
+
+	msg := newMessage([]byte(`{"message":"disk full","log":{"level":"ERROR"},"app":"billing","ts":"2021-06-01T10:00:00.5+02:00","env":"prod","user":"bob"}`), source, "")
+	shouldProcess, content := p.applyRedactingRules(msg)
+	assert.True(t, shouldProcess)
+	assert.Equal(t, []byte("disk full"), content)
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+	assert.Equal(t, "billing", msg.Origin.Service())
+	assert.Equal(t, time.Date(2021, 6, 1, 8, 0, 0, 500000000, time.UTC), msg.Timestamp)
+	assert.Equal(t, []string{"env:prod"}, msg.Origin.Tags())
+
+	// the messages which are not JSON are left unchanged
+	msg = newMessage([]byte("disk full"), source, "")
+	_, content = p.applyRedactingRules(msg)
+	assert.Equal(t, []byte("disk full"), content)
+	assert.Equal(t, message.StatusInfo, msg.GetStatus())
+	assert.True(t, msg.Timestamp.IsZero())
+}
+
+func TestParseLogfmt(t *testing.T) {
+	p := &Processor{}
+	source := newParsingSource(&config.ProcessingRule{
+		Type:            config.LogfmtParser,
+		Name:            "logfmt",
+		StatusField:     "level",
+		TimestampField:  "time",
+		TimestampFormat: "unix_ms",
+		MessageField:    "msg",
+	})
+
+	msg := newMessage([]byte(`time=1622541600500 level=warn msg="retrying \"payment\" call" attempt=2`), source, "")
+	_, content := p.applyRedactingRules(msg)
+	assert.Equal(t, []byte(`retrying "payment" call`), content)
+	assert.Equal(t, message.StatusWarning, msg.GetStatus())
+	assert.Equal(t, time.Date(2021, 6, 1, 10, 0, 0, 500000000, time.UTC), msg.Timestamp)
+}
+
+func TestParseKeyValue(t *testing.T) {
+	fields, err := parseKeyValues([]byte("severity: 3; text: 'a; b'; ignored"), ";", ":")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"severity": "3", "text": "a; b"}, fields)
+
+	_, err = parseKeyValues([]byte("no pairs here"), "", "")
+	assert.Error(t, err)
+
+	p := &Processor{}
+	source := newParsingSource(&config.ProcessingRule{
+		Type:              config.KeyValueParser,
+		Name:              "kv",
+		PairSeparator:     ";",
+		KeyValueSeparator: ":",
+		StatusField:       "severity",
+		MessageField:      "text",
+	})
+	msg := newMessage([]byte("severity: 3; text: 'a; b'"), source, "")
+	_, content := p.applyRedactingRules(msg)
+	assert.Equal(t, []byte("a; b"), content)
+	assert.Equal(t, message.StatusError, msg.GetStatus())
+}
+
+func TestParsedServiceOverridesServiceFromConfig(t *testing.T) {
+	p := &Processor{}
+	rules := []*config.ProcessingRule{{
+		Type:         config.JSONParser,
+		Name:         "json",
+		ServiceField: "app",
+	}}
+	require.NoError(t, config.CompileProcessingRules(rules))
+	source := config.NewLogSource("", &config.LogsConfig{Service: "web", ProcessingRules: rules})
+
+	msg := newMessage([]byte(`{"message":"disk full","app":"billing"}`), source, "")
+	_, _ = p.applyRedactingRules(msg)
+	assert.Equal(t, "billing", msg.Origin.Service())
+
+	// the service of the configuration is kept when the field is missing
+	msg = newMessage([]byte(`{"message":"disk full"}`), source, "")
+	_, _ = p.applyRedactingRules(msg)
+	assert.Equal(t, "web", msg.Origin.Service())
+}
+
+func TestParseGrok(t *testing.T) {
+	p := &Processor{}
+	source := newParsingSource(&config.ProcessingRule{
+		Type:           config.GrokParser,
+		Name:           "grok",
+		Pattern:        `%{TIMESTAMP_ISO8601:time} %{LOGLEVEL:level} \[%{NOTSPACE:service}\] %{GREEDYDATA:message}`,
+		StatusField:    "level",
+		ServiceField:   "service",
+		TimestampField: "time",
+	})
+
+	msg := newMessage([]byte("2021-06-01T10:00:00Z CRITICAL [checkout] out of memory"), source, "")
+	_, content := p.applyRedactingRules(msg)
+	assert.Equal(t, []byte("out of memory"), content)
+	assert.Equal(t, message.StatusCritical, msg.GetStatus())
+	assert.Equal(t, "checkout", msg.Origin.Service())
+	assert.Equal(t, time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC), msg.Timestamp)
+
+	// the parsed status overrides the status of the message, which is kept when the parsing fails
+	msg = newMessage([]byte("2021-06-01T10:00:00Z info [checkout] started"), source, message.StatusWarning)
+	_, _ = p.applyRedactingRules(msg)
+	assert.Equal(t, message.StatusInfo, msg.GetStatus())
+	msg = newMessage([]byte("not matching"), source, message.StatusWarning)
+	_, content = p.applyRedactingRules(msg)
+	assert.Equal(t, []byte("not matching"), content)
+	assert.Equal(t, message.StatusWarning, msg.GetStatus())
+}
+
+func TestParseThenExclude(t *testing.T) {
+	rules := []*config.ProcessingRule{
+		{Type: config.JSONParser, Name: "json"},
+		{Type: config.ExcludeAtMatch, Name: "exclude", Pattern: "^healthcheck"},
+	}
+	require.NoError(t, config.CompileProcessingRules(rules))
+	p := &Processor{processingRules: rules}
+	source := config.NewLogSource("", &config.LogsConfig{})
+
+	shouldProcess, _ := p.applyRedactingRules(newMessage([]byte(`{"message":"healthcheck ok","path":"/health"}`), source, ""))
+	assert.False(t, shouldProcess)
+	shouldProcess, content := p.applyRedactingRules(newMessage([]byte(`{"message":"order created"}`), source, ""))
+	assert.True(t, shouldProcess)
+	assert.Equal(t, []byte("order created"), content)
+}
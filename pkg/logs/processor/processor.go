@@ -8,7 +8,9 @@ package processor
 import (
 	"context"
 	"sync"
+	"time"
 
+	"github.com/DataDog/datadog-agent/pkg/aggregator"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/config"
@@ -27,6 +29,11 @@ type Processor struct {
 	done                      chan struct{}
 	diagnosticMessageReceiver diagnostic.MessageReceiver
 	mu                        sync.Mutex
+
+	// the metrics generated from the logs
+	metricsSender  aggregator.Sender
+	senderOnce     sync.Once
+	pendingMetrics uint32
 }
 
 // New returns an initialized Processor.
@@ -57,6 +64,7 @@ func (p *Processor) Stop() {
 func (p *Processor) Flush(ctx context.Context) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	defer p.commitMetrics()
 	for {
 		select {
 		case <-ctx.Done():
@@ -76,10 +84,21 @@ func (p *Processor) run() {
 	defer func() {
 		p.done <- struct{}{}
 	}()
-	for msg := range p.inputChan {
-		p.processMessage(msg)
-		p.mu.Lock() // block here if we're trying to flush synchronously
-		p.mu.Unlock()
+	ticker := time.NewTicker(metricsCommitInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case msg, ok := <-p.inputChan:
+			if !ok {
+				p.commitMetrics()
+				return
+			}
+			p.processMessage(msg)
+			p.mu.Lock() // block here if we're trying to flush synchronously
+			p.mu.Unlock()
+		case <-ticker.C:
+			p.commitMetrics()
+		}
 	}
 }
 
@@ -87,6 +106,9 @@ func (p *Processor) processMessage(msg *message.Message) {
 	metrics.LogsDecoded.Add(1)
 	metrics.TlmLogsDecoded.Inc()
 	if shouldProcess, redactedMsg := p.applyRedactingRules(msg); shouldProcess {
+		if t := getThrottler(msg.Origin.LogSource); t != nil && !t.allow(msg, redactedMsg) {
+			return
+		}
 		metrics.LogsProcessed.Add(1)
 		metrics.TlmLogsProcessed.Inc()
 
@@ -104,7 +126,7 @@ func (p *Processor) processMessage(msg *message.Message) {
 }
 
 // applyRedactingRules returns given a message if we should process it or not,
-// and a copy of the message with some fields redacted, depending on config
+// and a copy of the message with some fields redacted or parsed, depending on config
 func (p *Processor) applyRedactingRules(msg *message.Message) (bool, []byte) {
 	content := msg.Content
 	rules := append(p.processingRules, msg.Origin.LogSource.Config.ProcessingRules...)
@@ -120,6 +142,12 @@ func (p *Processor) applyRedactingRules(msg *message.Message) (bool, []byte) {
 			}
 		case config.MaskSequences:
 			content = rule.Regex.ReplaceAll(content, rule.Placeholder)
+		case config.JSONParser, config.LogfmtParser, config.KeyValueParser, config.GrokParser:
+			content = applyParsingRule(rule, msg, content)
+		case config.GenerateMetric:
+			if p.generateMetric(rule, msg, content) && rule.DropMatchingLogs {
+				return false, nil
+			}
 		}
 	}
 	return true, content
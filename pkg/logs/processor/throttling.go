@@ -0,0 +1,150 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/twmb/murmur3"
+	"golang.org/x/time/rate"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+)
+
+const (
+	throttlingInfoKey = "Throttling"
+	// maxLogSize is the size of the largest logs, above which the logs always exceed the bytes
+	// limit of the sources
+	maxLogSize = 256 * 1000
+)
+
+// the throttlers are shared by all the pipelines processing the logs of a source, the lock
+// only guards their creation
+var throttlersLock sync.Mutex
+
+// throttler rate limits and samples the logs of a source. It is registered as an info of the
+// source to report the number of dropped logs on the status page.
+type throttler struct {
+	rateLimited int64
+	sampled     int64
+
+	source       string
+	lines        *rate.Limiter
+	bytes        *rate.Limiter
+	bytesBurst   int
+	sampleRate   float64
+	sampleKey    *regexp.Regexp
+	keepStatuses map[string]bool
+}
+
+// getThrottler returns the throttler of a source, nil when its logs are not throttled
+func getThrottler(source *config.LogSource) *throttler {
+	if !source.Config.IsThrottled() {
+		return nil
+	}
+	if t, ok := source.GetInfo(throttlingInfoKey).(*throttler); ok {
+		return t
+	}
+	throttlersLock.Lock()
+	defer throttlersLock.Unlock()
+	if t, ok := source.GetInfo(throttlingInfoKey).(*throttler); ok {
+		return t
+	}
+	t := newThrottler(source.Name, source.Config)
+	source.RegisterInfo(t)
+	return t
+}
+
+func newThrottler(source string, c *config.LogsConfig) *throttler {
+	t := &throttler{
+		source:       source,
+		sampleRate:   c.SampleRate,
+		keepStatuses: make(map[string]bool, len(c.KeepStatuses)),
+	}
+	if c.MaxLinesPerSecond > 0 {
+		t.lines = rate.NewLimiter(rate.Limit(c.MaxLinesPerSecond), int(math.Max(1, math.Ceil(c.MaxLinesPerSecond))))
+	}
+	if c.MaxBytesPerSecond > 0 {
+		t.bytesBurst = int(math.Min(maxLogSize, math.Max(1, math.Ceil(c.MaxBytesPerSecond))))
+		t.bytes = rate.NewLimiter(rate.Limit(c.MaxBytesPerSecond), t.bytesBurst)
+	}
+	if c.SampleKey != "" {
+		// the key was validated with the config
+		t.sampleKey = regexp.MustCompile(c.SampleKey)
+	}
+	for _, status := range c.KeepStatuses {
+		t.keepStatuses[strings.ToLower(status)] = true
+	}
+	return t
+}
+
+// allow returns whether a log should be kept, the logs of the kept statuses are never dropped
+func (t *throttler) allow(msg *message.Message, content []byte) bool {
+	if t.keepStatuses[msg.GetStatus()] {
+		return true
+	}
+	if t.sampleRate > 0 && t.sampleRate < 1 && !t.sample(content) {
+		t.drop(&t.sampled, "sampling")
+		return false
+	}
+	if t.lines != nil && !t.lines.Allow() {
+		t.drop(&t.rateLimited, "rate_limit")
+		return false
+	}
+	if t.bytes != nil {
+		n := len(content)
+		if n > t.bytesBurst {
+			n = t.bytesBurst
+		}
+		if !t.bytes.AllowN(time.Now(), n) {
+			t.drop(&t.rateLimited, "rate_limit")
+			return false
+		}
+	}
+	return true
+}
+
+// sample keeps the logs with a hash of their key below the sample rate, so that the logs
+// sharing the same key are all kept or all dropped
+func (t *throttler) sample(content []byte) bool {
+	key := content
+	if t.sampleKey != nil {
+		if match := t.sampleKey.FindSubmatch(content); match != nil {
+			key = match[0]
+			if len(match) > 1 {
+				key = match[1]
+			}
+		}
+	}
+	return float64(murmur3.Sum64(key)) < t.sampleRate*math.MaxUint64
+}
+
+func (t *throttler) drop(count *int64, reason string) {
+	atomic.AddInt64(count, 1)
+	metrics.LogsThrottled.Add(1)
+	metrics.TlmLogsThrottled.Inc(t.source, reason)
+}
+
+// InfoKey returns the key of the throttling info
+func (t *throttler) InfoKey() string {
+	return throttlingInfoKey
+}
+
+// Info returns the number of logs dropped by the rate limits and the sampling
+func (t *throttler) Info() []string {
+	return []string{
+		fmt.Sprintf("%d logs dropped by the rate limits", atomic.LoadInt64(&t.rateLimited)),
+		fmt.Sprintf("%d logs dropped by the sampling", atomic.LoadInt64(&t.sampled)),
+	}
+}
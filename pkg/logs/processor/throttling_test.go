@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package processor
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/diagnostic"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+)
+
+func TestThrottlerNotConfigured(t *testing.T) {
+	source := config.NewLogSource("", &config.LogsConfig{SampleRate: 1})
+	assert.Nil(t, getThrottler(source))
+	assert.Empty(t, source.GetInfoStatus())
+}
+
+func TestThrottlerRateLimits(t *testing.T) {
+	source := config.NewLogSource("app", &config.LogsConfig{MaxLinesPerSecond: 2, KeepStatuses: []string{"ERROR"}})
+	throttler := getThrottler(source)
+	// the throttler is shared by all the pipelines
+	assert.Same(t, throttler, getThrottler(source))
+
+	assert.True(t, throttler.allow(newMessage([]byte("1"), source, ""), []byte("1")))
+	assert.True(t, throttler.allow(newMessage([]byte("2"), source, ""), []byte("2")))
+	assert.False(t, throttler.allow(newMessage([]byte("3"), source, ""), []byte("3")))
+	// the errors are always kept
+	assert.True(t, throttler.allow(newMessage([]byte("4"), source, message.StatusError), []byte("4")))
+
+	assert.Equal(t, map[string][]string{
+		"Throttling": {"1 logs dropped by the rate limits", "0 logs dropped by the sampling"},
+	}, source.GetInfoStatus())
+
+	source = config.NewLogSource("app", &config.LogsConfig{MaxBytesPerSecond: 10})
+	throttler = getThrottler(source)
+	assert.True(t, throttler.allow(newMessage(nil, source, ""), []byte("123456")))
+	assert.False(t, throttler.allow(newMessage(nil, source, ""), []byte("123456")))
+}
+
+func TestThrottlerSampling(t *testing.T) {
+	source := config.NewLogSource("app", &config.LogsConfig{SampleRate: 0.5, SampleKey: `trace_id=(\w+)`})
+	throttler := getThrottler(source)
+
+	kept := 0
+	for i := 0; i < 1000; i++ {
+		content := []byte(fmt.Sprintf("request trace_id=%d", i))
+		allowed := throttler.allow(newMessage(content, source, ""), content)
+		if allowed {
+			kept++
+		}
+		// the logs sharing the same key are all kept or all dropped
+		content = []byte(fmt.Sprintf("response trace_id=%d", i))
+		assert.Equal(t, allowed, throttler.allow(newMessage(content, source, ""), content))
+	}
+	assert.InDelta(t, 500, kept, 100)
+	assert.EqualValues(t, 2*(1000-kept), throttler.sampled)
+}
+
+func TestProcessorDropsThrottledLogs(t *testing.T) {
+	p := New(make(chan *message.Message, 3), make(chan *message.Message, 3), nil, RawEncoder, diagnostic.NewBufferedMessageReceiver())
+	source := config.NewLogSource("app", &config.LogsConfig{MaxLinesPerSecond: 1})
+
+	p.processMessage(newMessage([]byte("first"), source, ""))
+	p.processMessage(newMessage([]byte("second"), source, ""))
+	assert.Len(t, p.outputChan, 1)
+}
+
+func TestThrottlerSharedByConcurrentPipelines(t *testing.T) {
+	source := config.NewLogSource("app", &config.LogsConfig{MaxLinesPerSecond: 10})
+	throttlers := make([]*throttler, 8)
+	var wg sync.WaitGroup
+	for i := range throttlers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			throttlers[i] = getThrottler(source)
+		}(i)
+	}
+	wg.Wait()
+	for _, throttler := range throttlers {
+		assert.Same(t, throttlers[0], throttler)
+	}
+}
+
+func BenchmarkGetThrottlerParallel(b *testing.B) {
+	sources := make([]*config.LogSource, 8)
+	for i := range sources {
+		sources[i] = config.NewLogSource(fmt.Sprintf("app%d", i), &config.LogsConfig{MaxLinesPerSecond: 1000})
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			getThrottler(sources[i%len(sources)])
+			i++
+		}
+	})
+}
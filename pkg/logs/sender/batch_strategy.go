@@ -73,6 +73,12 @@ func newBatchStrategyWithClock(serializer Serializer, batchWait time.Duration, m
 
 }
 
+// disableConcurrentSends makes the strategy block while sending each payload, it must be
+// called before the strategy is started.
+func (s *batchStrategy) disableConcurrentSends() {
+	s.climit = make(chan struct{})
+}
+
 func (s *batchStrategy) Flush(ctx context.Context) {
 	select {
 	case <-ctx.Done():
@@ -83,7 +89,7 @@ func (s *batchStrategy) Flush(ctx context.Context) {
 	}
 }
 
-func (s *batchStrategy) syncFlush(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte) error) {
+func (s *batchStrategy) syncFlush(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte, []*message.Message) error) {
 	defer func() {
 		s.flushBuffer(outputChan, send)
 		s.pendingSends.Wait()
@@ -102,7 +108,7 @@ func (s *batchStrategy) syncFlush(inputChan chan *message.Message, outputChan ch
 }
 
 // Send accumulates messages to a buffer and sends them when the buffer is full or outdated.
-func (s *batchStrategy) Send(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte) error) {
+func (s *batchStrategy) Send(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte, []*message.Message) error) {
 	flushTicker := s.clock.Ticker(s.batchWait)
 	defer func() {
 		s.flushBuffer(outputChan, send)
@@ -136,7 +142,7 @@ func (s *batchStrategy) Send(inputChan chan *message.Message, outputChan chan *m
 	}
 }
 
-func (s *batchStrategy) processMessage(m *message.Message, outputChan chan *message.Message, send func([]byte) error) {
+func (s *batchStrategy) processMessage(m *message.Message, outputChan chan *message.Message, send func([]byte, []*message.Message) error) {
 	if m.Origin != nil {
 		m.Origin.LogSource.LatencyStats.Add(m.GetLatency())
 	}
@@ -156,7 +162,7 @@ func (s *batchStrategy) processMessage(m *message.Message, outputChan chan *mess
 
 // flushBuffer sends all the messages that are stored in the buffer and forwards them
 // to the next stage of the pipeline.
-func (s *batchStrategy) flushBuffer(outputChan chan *message.Message, send func([]byte) error) {
+func (s *batchStrategy) flushBuffer(outputChan chan *message.Message, send func([]byte, []*message.Message) error) {
 	if s.buffer.IsEmpty() {
 		return
 	}
@@ -176,9 +182,12 @@ func (s *batchStrategy) flushBuffer(outputChan chan *message.Message, send func(
 	}()
 }
 
-func (s *batchStrategy) sendMessages(messages []*message.Message, outputChan chan *message.Message, send func([]byte) error) {
-	err := send(s.serializer.Serialize(messages))
+func (s *batchStrategy) sendMessages(messages []*message.Message, outputChan chan *message.Message, send func([]byte, []*message.Message) error) {
+	err := send(s.serializer.Serialize(messages), messages)
 	if err != nil {
+		if isSpooled(err) {
+			return
+		}
 		if shouldStopSending(err) {
 			return
 		}
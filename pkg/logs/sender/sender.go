@@ -7,42 +7,71 @@ package sender
 
 import (
 	"context"
+	"errors"
+	"sync"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
 	"github.com/DataDog/datadog-agent/pkg/logs/message"
 	"github.com/DataDog/datadog-agent/pkg/logs/metrics"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
 // Strategy should contain all logic to send logs to a remote destination
 // and forward them the next stage of the pipeline.
+// The messages of a payload are not forwarded when send returns errSpooled,
+// the sender forwards them once the payload is sent.
 type Strategy interface {
-	Send(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte) error)
+	Send(inputChan chan *message.Message, outputChan chan *message.Message, send func(payload []byte, messages []*message.Message) error)
 	Flush(ctx context.Context)
 }
 
+// errSpooled is returned when a payload was stored in the spool to be sent later.
+var errSpooled = errors.New("payload spooled")
+
 // Sender sends logs to different destinations.
 type Sender struct {
 	inputChan    chan *message.Message
 	outputChan   chan *message.Message
 	destinations *client.Destinations
 	strategy     Strategy
-	done         chan struct{}
+	serializer   Serializer
+	spool        *Spool
+	// spoolMu orders the payloads sent directly with the spooled ones, so that their
+	// messages are forwarded to the auditor in order
+	spoolMu   sync.Mutex
+	done      chan struct{}
+	spoolDone chan struct{}
 }
 
 // NewSender returns a new sender.
-func NewSender(inputChan chan *message.Message, outputChan chan *message.Message, destinations *client.Destinations, strategy Strategy) *Sender {
+// When `spool` is not nil, the payloads which cannot be sent to the main destination
+// are stored in the spool instead of blocking the pipeline.
+// When the destinations are routed, each destination receives a payload of the messages
+// matching its route.
+// The payloads are sent one at a time when there's a spool, the registry of the auditor
+// would go backward if the messages of concurrent sends were forwarded out of order.
+func NewSender(inputChan chan *message.Message, outputChan chan *message.Message, destinations *client.Destinations, strategy Strategy, spool *Spool) *Sender {
+	if s, ok := strategy.(*batchStrategy); ok && spool != nil {
+		s.disableConcurrentSends()
+	}
 	return &Sender{
 		inputChan:    inputChan,
 		outputChan:   outputChan,
 		destinations: destinations,
 		strategy:     strategy,
+		serializer:   serializerOf(strategy),
+		spool:        spool,
 		done:         make(chan struct{}),
+		spoolDone:    make(chan struct{}),
 	}
 }
 
 // Start starts the sender.
 func (s *Sender) Start() {
 	go s.run()
+	if s.spool != nil {
+		go s.sendSpooled()
+	}
 }
 
 // Stop stops the sender,
@@ -50,6 +79,10 @@ func (s *Sender) Start() {
 func (s *Sender) Stop() {
 	close(s.inputChan)
 	<-s.done
+	if s.spool != nil {
+		s.spool.Stop()
+		<-s.spoolDone
+	}
 }
 
 // Flush sends synchronously the messages that this sender has to send.
@@ -67,29 +100,200 @@ func (s *Sender) run() {
 // send sends a payload to multiple destinations,
 // it will forever retry for the main destination unless the error is not retryable
 // and only try once for additionnal destinations.
-func (s *Sender) send(payload []byte) error {
+// When the sender has a spool, the payload is spooled instead of being retried, and it is
+// spooled as well while older payloads are waiting in the spool to keep them in order.
+func (s *Sender) send(payload []byte, messages []*message.Message) error {
+	if s.destinations.IsRouted() {
+		return s.sendRouted(messages)
+	}
+	if s.spool != nil {
+		err := s.sendOrSpool(payload, messages)
+		if err == nil {
+			s.sendToAdditionals(payload)
+		}
+		return err
+	}
+
+	if err := s.sendToMain(payload, true); err != nil {
+		return err
+	}
+	s.sendToAdditionals(payload)
+	return nil
+}
+
+// sendRouted sends to each destination the payload of the messages matching its route.
+// The additional destinations don't depend on the main one, they receive their payloads right
+// away even when the payload of the main destination is spooled.
+func (s *Sender) sendRouted(messages []*message.Message) error {
+	main, additionals := s.destinations.Route(messages)
+	for i, routed := range additionals {
+		if len(routed) > 0 {
+			s.destinations.SendToAdditional(i, s.serializer.Serialize(routed))
+		}
+	}
+
+	var payload []byte
+	if len(main) > 0 {
+		payload = s.serializer.Serialize(main)
+	}
+	if s.spool != nil {
+		return s.sendOrSpool(payload, messages)
+	}
+	if payload == nil {
+		return nil
+	}
+	return s.sendToMain(payload, true)
+}
+
+// sendOrSpool sends a payload to the main destination, or spools it when it cannot be sent or
+// when older payloads are waiting in the spool. The payload is nil when none of the messages
+// is sent to the main destination, the messages are spooled anyway while older payloads are
+// waiting so that they are forwarded to the auditor in order.
+func (s *Sender) sendOrSpool(payload []byte, messages []*message.Message) error {
+	s.spoolMu.Lock()
+	defer s.spoolMu.Unlock()
+
+	if s.spool.IsEmpty() {
+		if payload == nil {
+			return nil
+		}
+		err := s.sendToMain(payload, false)
+		if !isRetryable(err) {
+			return err
+		}
+	}
+	if s.spoolPayload(payload, messages) {
+		return errSpooled
+	}
+
+	// the payload could not be spooled, it is sent once the older payloads are
+	s.spoolMu.Unlock()
+	s.waitSpoolDrained()
+	s.spoolMu.Lock()
+	if payload == nil {
+		return nil
+	}
+	return s.sendToMain(payload, true)
+}
+
+// waitSpoolDrained blocks until the spooled payloads are sent, or until they stop being sent.
+func (s *Sender) waitSpoolDrained() {
+	for !s.spool.IsEmpty() {
+		select {
+		case <-s.spool.drained:
+		case <-s.spoolDone:
+			return
+		}
+	}
+}
+
+// sendToMain sends a payload to the main destination, retrying as long as the error is retryable
+// when `retry` is true.
+func (s *Sender) sendToMain(payload []byte, retry bool) error {
 	for {
 		err := s.destinations.Main.Send(payload)
 		if err != nil {
 			metrics.DestinationErrors.Add(1)
 			metrics.TlmDestinationErrors.Inc()
-			if _, ok := err.(*client.RetryableError); ok {
+			if s.destinations.MainRoute != nil {
+				s.destinations.MainRoute.ReportError()
+			}
+			if isRetryable(err) && retry {
 				// could not send the payload because of a client issue,
 				// let's retry
 				continue
 			}
 			return err
 		}
-		break
+		if s.destinations.MainRoute != nil {
+			s.destinations.MainRoute.ReportSent()
+		}
+		return nil
 	}
+}
 
+func (s *Sender) sendToAdditionals(payload []byte) {
+	if s.destinations.IsRouted() {
+		// the routed destinations receive their payloads when the messages are routed
+		return
+	}
 	for _, destination := range s.destinations.Additionals {
 		// send in the background so that the agent does not fall behind
 		// for the main destination
 		destination.SendAsync(payload)
 	}
+}
 
-	return nil
+// spoolPayload stores a payload in the spool, it returns false when the payload could not be stored
+func (s *Sender) spoolPayload(payload []byte, messages []*message.Message) bool {
+	evicted, err := s.spool.Add(payload, messages)
+	s.forward(evicted)
+	if err != nil {
+		log.Warnf("Could not spool payload: %v", err)
+		return false
+	}
+	return true
+}
+
+// sendSpooled sends the spooled payloads in order until the spool is stopped.
+func (s *Sender) sendSpooled() {
+	defer close(s.spoolDone)
+	for {
+		entry, payload, ok := s.spool.next()
+		if !ok {
+			return
+		}
+		// the payload is empty when it cannot be read back, or when none of its messages
+		// was routed to the main destination
+		if len(payload) > 0 {
+			err := s.sendToMain(payload, false)
+			for isRetryable(err) && !s.spool.stopped() {
+				err = s.sendToMain(payload, false)
+			}
+			if isRetryable(err) || shouldStopSending(err) {
+				// the payload is kept on the disk to be sent on the next run
+				s.spool.release()
+				return
+			}
+			if err != nil {
+				log.Warnf("Could not send spooled payload: %v", err)
+			} else {
+				s.sendToAdditionals(payload)
+			}
+		}
+		metrics.LogsSent.Add(int64(entry.count))
+		metrics.TlmLogsSent.Add(float64(entry.count))
+		// the payloads sent directly once the spool is empty are forwarded after these acks
+		s.spoolMu.Lock()
+		s.forward(s.spool.done(entry))
+		s.spoolMu.Unlock()
+	}
+}
+
+// forward forwards messages to the next stage of the pipeline
+func (s *Sender) forward(messages []*message.Message) {
+	for _, msg := range messages {
+		s.outputChan <- msg
+	}
+}
+
+// serializerOf returns the serializer building the payloads of a strategy, the stream strategy
+// sends the content of one message at a time.
+func serializerOf(strategy Strategy) Serializer {
+	if s, ok := strategy.(*batchStrategy); ok {
+		return s.serializer
+	}
+	return LineSerializer
+}
+
+func isRetryable(err error) bool {
+	_, ok := err.(*client.RetryableError)
+	return ok
+}
+
+// isSpooled returns true if the messages of a payload are forwarded by the sender once it is sent.
+func isSpooled(err error) bool {
+	return err == errSpooled
 }
 
 // shouldStopSending returns true if a component should stop sending logs.
@@ -6,9 +6,13 @@
 package sender
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/DataDog/datadog-agent/pkg/logs/client"
 	"github.com/DataDog/datadog-agent/pkg/logs/client/mock"
@@ -36,7 +40,7 @@ func TestSender(t *testing.T) {
 	destination := tcp.AddrToDestination(l.Addr(), destinationsCtx)
 	destinations := client.NewDestinations(destination, nil)
 
-	sender := NewSender(input, output, destinations, StreamStrategy)
+	sender := NewSender(input, output, destinations, StreamStrategy, nil)
 	sender.Start()
 
 	expectedMessage := newMessage([]byte("fake line"), source, "")
@@ -69,7 +73,7 @@ func TestSenderNotBlockedByAdditional(t *testing.T) {
 	additionalDestination := tcp.NewDestination(config.Endpoint{Host: "dont.exist.local", Port: 0}, true, destinationsCtx)
 	destinations := client.NewDestinations(mainDestination, []client.Destination{additionalDestination})
 
-	sender := NewSender(input, output, destinations, StreamStrategy)
+	sender := NewSender(input, output, destinations, StreamStrategy, nil)
 	sender.Start()
 
 	expectedMessage1 := newMessage([]byte("fake line"), source, "")
@@ -87,3 +91,73 @@ func TestSenderNotBlockedByAdditional(t *testing.T) {
 	sender.Stop()
 	destinationsCtx.Stop()
 }
+
+func newRoutedDestinations(main client.Destination, mainRoute *config.Route, additional client.Destination, additionalRoute *config.Route) *client.Destinations {
+	return client.NewRoutedDestinations(
+		main, client.NewRoute("main", mainRoute),
+		[]client.Destination{additional}, []*client.Route{client.NewRoute("additional", additionalRoute)},
+	)
+}
+
+func TestSenderRoutesMessages(t *testing.T) {
+	main := &unavailableDestination{available: true}
+	security := &unavailableDestination{available: true}
+	destinations := newRoutedDestinations(
+		main, &config.Route{Exclude: config.RouteFilter{Statuses: []string{"debug"}}},
+		security, &config.Route{Include: config.RouteFilter{Sources: []string{"auditd"}}},
+	)
+	sender := NewSender(nil, nil, destinations, NewBatchStrategy(ArraySerializer, time.Second, 0, 10, 1000, "test", 0), nil)
+
+	auditd := config.NewLogSource("", &config.LogsConfig{Source: "auditd"})
+	nginx := config.NewLogSource("", &config.LogsConfig{Source: "nginx"})
+	audit := newMessage([]byte(`"audit"`), auditd, message.StatusInfo)
+	debug := newMessage([]byte(`"debug"`), nginx, message.StatusDebug)
+	access := newMessage([]byte(`"access"`), nginx, message.StatusInfo)
+
+	assert.NoError(t, sender.send(nil, []*message.Message{audit, debug, access}))
+	assert.Equal(t, []string{`["audit","access"]`}, main.sent())
+	assert.Eventually(t, func() bool { return len(security.sent()) == 1 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{`["audit"]`}, security.sent())
+
+	// nothing is sent to the destinations without matching messages
+	assert.NoError(t, sender.send(nil, []*message.Message{debug}))
+	assert.Len(t, main.sent(), 1)
+	assert.Len(t, security.sent(), 1)
+}
+
+func TestSenderRoutesMessagesWithSpool(t *testing.T) {
+	path, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+	spool := newTestSpool(t, path, 1000)
+
+	main := &unavailableDestination{}
+	debugFile := &unavailableDestination{available: true}
+	destinations := newRoutedDestinations(
+		main, &config.Route{Exclude: config.RouteFilter{Statuses: []string{"debug"}}},
+		debugFile, &config.Route{Include: config.RouteFilter{Statuses: []string{"debug"}}},
+	)
+	input := make(chan *message.Message, 10)
+	output := make(chan *message.Message, 10)
+	sender := NewSender(input, output, destinations, StreamStrategy, spool)
+	sender.Start()
+
+	info := newFileMessage("a", "1")
+	debug := newFileMessage("a", "2")
+	debug.SetStatus(message.StatusDebug)
+	input <- info
+	input <- debug
+
+	// the additional destination does not wait for the main one
+	assert.Eventually(t, func() bool { return len(debugFile.sent()) == 1 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, []string{"2"}, debugFile.sent())
+	assert.Len(t, output, 0)
+
+	// the messages are forwarded to the auditor in order once the main destination is available
+	main.setAvailable()
+	assert.Equal(t, "a:1", offsets([]*message.Message{<-output})[0])
+	assert.Equal(t, "a:2", offsets([]*message.Message{<-output})[0])
+	assert.Equal(t, []string{"1"}, main.sent())
+
+	sender.Stop()
+}
@@ -0,0 +1,335 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sender
+
+import (
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const spoolFileExtension = ".spool"
+const spoolFileFormat = "2006_01_02__15_04_05_"
+
+var (
+	spoolExpVars             = expvar.NewMap("logs_spool")
+	tlmSpoolSizeInBytes      = telemetry.NewGauge("logs_spool", "current_size_in_bytes", []string{"path"}, "The number of bytes used to store the logs payloads on the disk")
+	tlmSpoolFilesCount       = telemetry.NewGauge("logs_spool", "files_count", []string{"path"}, "The number of logs payloads stored on the disk")
+	tlmSpoolPayloadsSpooled  = telemetry.NewCounter("logs_spool", "payloads_spooled", []string{"path"}, "The number of logs payloads stored on the disk because the destination was unavailable")
+	tlmSpoolPayloadsEvicted  = telemetry.NewCounter("logs_spool", "payloads_evicted", []string{"path"}, "The number of logs payloads removed from the disk because the spool was full")
+	tlmSpoolPayloadsReloaded = telemetry.NewGauge("logs_spool", "startup_reloaded_payloads_count", []string{"path"}, "The number of logs payloads reloaded from a previous run of the Agent")
+)
+
+type diskUsageRetriever interface {
+	GetUsage(path string) (*filesystem.DiskUsage, error)
+}
+
+// spoolEntry is a payload stored on the disk
+type spoolEntry struct {
+	filename string
+	size     int64
+	// the number of messages of the payload
+	count int
+	// acks holds the last message of the payload for each origin, which is forwarded to the
+	// auditor once the payload is sent. The payloads reloaded from the disk have no acks.
+	acks []*message.Message
+}
+
+// Spool stores on the disk the payloads which could not be sent to the main destination, so
+// that an outage of the intake doesn't stall the tailers. The payloads are sent back in order
+// by the sender, and their messages are forwarded to the auditor only once they are sent. The
+// oldest payloads are removed when the spool is full.
+//
+// The payloads left in the spool when the agent stops are sent on the next run, the logs of
+// the files might then be sent twice as the registry of the auditor was not updated for them.
+type Spool struct {
+	storagePath    string
+	maxSizeInBytes int64
+	maxDiskRatio   float64
+	disk           diskUsageRetriever
+
+	mu                 sync.Mutex
+	entries            []*spoolEntry
+	sending            bool // true while the first entry is being sent
+	currentSizeInBytes int64
+	notify             chan struct{}
+	drained            chan struct{} // signaled when the last payload is sent
+	stop               chan struct{}
+	sizeInBytesExpVar  expvar.Int
+	filesCountExpVar   expvar.Int
+}
+
+// NewSpool returns a new spool storing the payloads in storagePath until maxSizeInBytes is
+// reached, or until the disk usage exceeds maxDiskRatio. The payloads left by a previous run
+// of the agent are reloaded.
+func NewSpool(storagePath string, maxSizeInBytes int64, maxDiskRatio float64) (*Spool, error) {
+	return newSpool(storagePath, maxSizeInBytes, maxDiskRatio, filesystem.NewDisk())
+}
+
+func newSpool(storagePath string, maxSizeInBytes int64, maxDiskRatio float64, disk diskUsageRetriever) (*Spool, error) {
+	if err := os.MkdirAll(storagePath, 0700); err != nil {
+		return nil, err
+	}
+	s := &Spool{
+		storagePath:    storagePath,
+		maxSizeInBytes: maxSizeInBytes,
+		maxDiskRatio:   maxDiskRatio,
+		disk:           disk,
+		notify:         make(chan struct{}, 1),
+		drained:        make(chan struct{}, 1),
+		stop:           make(chan struct{}),
+	}
+	expVars := &expvar.Map{}
+	expVars.Set("CurrentSizeInBytes", &s.sizeInBytesExpVar)
+	expVars.Set("FilesCount", &s.filesCountExpVar)
+	spoolExpVars.Set(storagePath, expVars)
+	if err := s.reloadExistingFiles(); err != nil {
+		return nil, err
+	}
+	// report the errors of the disk usage sooner than during an outage
+	if _, err := s.computeAvailableSpace(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// IsEmpty returns true when no payload is waiting to be sent
+func (s *Spool) IsEmpty() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries) == 0
+}
+
+// Add stores a payload on the disk, it returns the acks of the payloads removed to make room
+// for it, which must be forwarded to the auditor.
+func (s *Spool) Add(payload []byte, messages []*message.Message) ([]*message.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := int64(len(payload))
+	evicted, err := s.makeRoomFor(size)
+	if err != nil {
+		return evicted, err
+	}
+
+	filename := time.Now().UTC().Format(spoolFileFormat)
+	file, err := ioutil.TempFile(s.storagePath, filename+"*"+spoolFileExtension)
+	if err != nil {
+		return evicted, err
+	}
+	defer file.Close()
+	if _, err = file.Write(payload); err != nil {
+		_ = os.Remove(file.Name())
+		return evicted, err
+	}
+
+	s.entries = append(s.entries, &spoolEntry{
+		filename: file.Name(),
+		size:     size,
+		count:    len(messages),
+		acks:     compactAcks(nil, messages),
+	})
+	s.currentSizeInBytes += size
+	tlmSpoolPayloadsSpooled.Inc(s.storagePath)
+	s.updateTelemetry()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return evicted, nil
+}
+
+// next blocks until a payload is available and returns it, the payload is nil when it cannot
+// be read back. It returns false when the spool is stopped. The entry must then be marked as
+// sent with done, or released.
+func (s *Spool) next() (*spoolEntry, []byte, bool) {
+	for {
+		s.mu.Lock()
+		if len(s.entries) > 0 {
+			entry := s.entries[0]
+			s.sending = true
+			s.mu.Unlock()
+
+			payload, err := ioutil.ReadFile(entry.filename)
+			if err != nil {
+				// the entry is returned without payload so that its acks are forwarded
+				log.Errorf("Cannot read the spooled logs payload %s, skipping it: %v", entry.filename, err)
+			}
+			return entry, payload, true
+		}
+		s.mu.Unlock()
+
+		select {
+		case <-s.notify:
+		case <-s.stop:
+			return nil, nil, false
+		}
+	}
+}
+
+// done removes a payload which was sent and returns the messages to forward to the auditor
+func (s *Spool) done(entry *spoolEntry) []*message.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sending = false
+	if len(s.entries) == 0 || s.entries[0] != entry {
+		return nil
+	}
+	s.removeEntryAt(0)
+	s.updateTelemetry()
+	if len(s.entries) == 0 {
+		select {
+		case s.drained <- struct{}{}:
+		default:
+		}
+	}
+	return entry.acks
+}
+
+// release keeps a payload which could not be sent in the spool
+func (s *Spool) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sending = false
+}
+
+// Stop stops sending the spooled payloads, they are kept on the disk
+func (s *Spool) Stop() {
+	close(s.stop)
+}
+
+func (s *Spool) stopped() bool {
+	select {
+	case <-s.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// makeRoomFor removes the oldest payloads until size bytes can be stored. The payload being
+// sent is never removed and the acks of the removed payloads are merged into its acks, so
+// that the registry of the auditor never goes backward.
+func (s *Spool) makeRoomFor(size int64) ([]*message.Message, error) {
+	if size > s.maxSizeInBytes {
+		return nil, fmt.Errorf("the payload is too big. Current:%v Maximum:%v", size, s.maxSizeInBytes)
+	}
+	maxStorageInBytes, err := s.computeAvailableSpace()
+	if err != nil {
+		return nil, err
+	}
+
+	first := 0
+	if s.sending {
+		first = 1
+	}
+	var acks []*message.Message
+	for len(s.entries) > first && s.currentSizeInBytes+size > maxStorageInBytes {
+		entry := s.entries[first]
+		log.Errorf("Maximum disk space for the logs spool is reached. Removing %s", entry.filename)
+		s.removeEntryAt(first)
+		if first == 1 {
+			s.entries[0].acks = compactAcks(s.entries[0].acks, entry.acks)
+		} else {
+			acks = compactAcks(acks, entry.acks)
+		}
+		tlmSpoolPayloadsEvicted.Inc(s.storagePath)
+	}
+	if s.currentSizeInBytes+size > maxStorageInBytes {
+		return acks, fmt.Errorf("not enough disk space to spool the payload")
+	}
+	return acks, nil
+}
+
+func (s *Spool) computeAvailableSpace() (int64, error) {
+	usage, err := s.disk.GetUsage(s.storagePath)
+	if err != nil {
+		return 0, err
+	}
+	diskReserved := float64(usage.Total) * (1 - s.maxDiskRatio)
+	availableDiskUsage := int64(usage.Available) - int64(math.Ceil(diskReserved))
+	if available := s.currentSizeInBytes + availableDiskUsage; available < s.maxSizeInBytes {
+		return available, nil
+	}
+	return s.maxSizeInBytes, nil
+}
+
+func (s *Spool) removeEntryAt(index int) {
+	entry := s.entries[index]
+	s.entries = append(s.entries[:index], s.entries[index+1:]...)
+	s.currentSizeInBytes -= entry.size
+	if err := os.Remove(entry.filename); err != nil && !os.IsNotExist(err) {
+		log.Warnf("Cannot remove the spooled logs payload %s: %v", entry.filename, err)
+	}
+}
+
+func (s *Spool) reloadExistingFiles() error {
+	entries, err := ioutil.ReadDir(s.storagePath)
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ModTime().Before(entries[j].ModTime())
+	})
+	for _, entry := range entries {
+		if !entry.Mode().IsRegular() || filepath.Ext(entry.Name()) != spoolFileExtension {
+			continue
+		}
+		s.entries = append(s.entries, &spoolEntry{
+			filename: filepath.Join(s.storagePath, entry.Name()),
+			size:     entry.Size(),
+		})
+		s.currentSizeInBytes += entry.Size()
+	}
+	tlmSpoolPayloadsReloaded.Set(float64(len(s.entries)), s.storagePath)
+	s.updateTelemetry()
+	if len(s.entries) > 0 {
+		s.notify <- struct{}{}
+	}
+	return nil
+}
+
+func (s *Spool) updateTelemetry() {
+	tlmSpoolSizeInBytes.Set(float64(s.currentSizeInBytes), s.storagePath)
+	tlmSpoolFilesCount.Set(float64(len(s.entries)), s.storagePath)
+	s.sizeInBytesExpVar.Set(s.currentSizeInBytes)
+	s.filesCountExpVar.Set(int64(len(s.entries)))
+}
+
+// compactAcks keeps the origin of the last message of each identifier, which is all the
+// auditor needs to update its registry
+func compactAcks(acks []*message.Message, messages []*message.Message) []*message.Message {
+	for _, msg := range messages {
+		if msg.Origin == nil {
+			continue
+		}
+		ack := &message.Message{Origin: msg.Origin}
+		replaced := false
+		for i := range acks {
+			if acks[i].Origin.Identifier == msg.Origin.Identifier {
+				acks[i] = ack
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			acks = append(acks, ack)
+		}
+	}
+	return acks
+}
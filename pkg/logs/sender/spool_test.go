@@ -0,0 +1,300 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package sender
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/logs/client"
+	"github.com/DataDog/datadog-agent/pkg/logs/config"
+	"github.com/DataDog/datadog-agent/pkg/logs/message"
+	"github.com/DataDog/datadog-agent/pkg/util/filesystem"
+)
+
+type diskUsageRetrieverMock struct {
+	diskUsage *filesystem.DiskUsage
+}
+
+func (m diskUsageRetrieverMock) GetUsage(_ string) (*filesystem.DiskUsage, error) {
+	return m.diskUsage, nil
+}
+
+func newTestSpool(t *testing.T, path string, maxSizeInBytes int64) *Spool {
+	disk := diskUsageRetrieverMock{diskUsage: &filesystem.DiskUsage{Total: 10000, Available: 10000}}
+	spool, err := newSpool(path, maxSizeInBytes, 1, disk)
+	require.NoError(t, err)
+	return spool
+}
+
+func newFileMessage(identifier string, offset string) *message.Message {
+	msg := newMessage([]byte(offset), config.NewLogSource("", &config.LogsConfig{}), "")
+	msg.Origin.Identifier = identifier
+	msg.Origin.Offset = offset
+	return msg
+}
+
+func offsets(acks []*message.Message) []string {
+	var offsets []string
+	for _, ack := range acks {
+		offsets = append(offsets, ack.Origin.Identifier+":"+ack.Origin.Offset)
+	}
+	return offsets
+}
+
+func TestSpoolAddAndSend(t *testing.T) {
+	path, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+	spool := newTestSpool(t, path, 100)
+	assert.True(t, spool.IsEmpty())
+
+	_, err = spool.Add([]byte("payload1"), []*message.Message{newFileMessage("a", "1"), newFileMessage("b", "1"), newFileMessage("a", "2")})
+	require.NoError(t, err)
+	_, err = spool.Add([]byte("payload2"), []*message.Message{newFileMessage("a", "3")})
+	require.NoError(t, err)
+	assert.False(t, spool.IsEmpty())
+
+	entry, payload, ok := spool.next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("payload1"), payload)
+	assert.Equal(t, 3, entry.count)
+	// only the last offset of each origin is forwarded to the auditor
+	assert.Equal(t, []string{"a:2", "b:1"}, offsets(spool.done(entry)))
+
+	entry, payload, ok = spool.next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("payload2"), payload)
+	assert.Equal(t, []string{"a:3"}, offsets(spool.done(entry)))
+	assert.True(t, spool.IsEmpty())
+
+	files, err := ioutil.ReadDir(path)
+	require.NoError(t, err)
+	assert.Empty(t, files)
+
+	spool.Stop()
+	_, _, ok = spool.next()
+	assert.False(t, ok)
+}
+
+func TestSpoolEviction(t *testing.T) {
+	path, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+	spool := newTestSpool(t, path, 20)
+
+	_, err = spool.Add([]byte("payload1"), []*message.Message{newFileMessage("a", "1")})
+	require.NoError(t, err)
+	_, err = spool.Add([]byte("payload2"), []*message.Message{newFileMessage("a", "2")})
+	require.NoError(t, err)
+
+	// the oldest payload is removed, its offsets are forwarded right away
+	evicted, err := spool.Add([]byte("payload3"), []*message.Message{newFileMessage("a", "3")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a:1"}, offsets(evicted))
+
+	// the payload being sent is never removed
+	entry, payload, ok := spool.next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("payload2"), payload)
+	evicted, err = spool.Add([]byte("payload4"), []*message.Message{newFileMessage("b", "4")})
+	require.NoError(t, err)
+	assert.Empty(t, evicted)
+	assert.Equal(t, []string{"a:3"}, offsets(spool.done(entry)))
+
+	entry, payload, ok = spool.next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("payload4"), payload)
+	assert.Equal(t, []string{"b:4"}, offsets(spool.done(entry)))
+
+	_, err = spool.Add([]byte("a payload larger than the spool"), nil)
+	assert.Error(t, err)
+}
+
+func TestSpoolReload(t *testing.T) {
+	path, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+	spool := newTestSpool(t, path, 100)
+	_, err = spool.Add([]byte("payload1"), []*message.Message{newFileMessage("a", "1")})
+	require.NoError(t, err)
+	// make sure the files are ordered by modification time
+	time.Sleep(10 * time.Millisecond)
+	_, err = spool.Add([]byte("payload2"), []*message.Message{newFileMessage("a", "2")})
+	require.NoError(t, err)
+	spool.Stop()
+
+	spool = newTestSpool(t, path, 100)
+	entry, payload, ok := spool.next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("payload1"), payload)
+	assert.Empty(t, spool.done(entry))
+	entry, payload, ok = spool.next()
+	require.True(t, ok)
+	assert.Equal(t, []byte("payload2"), payload)
+	assert.Empty(t, spool.done(entry))
+}
+
+// unavailableDestination fails with a retryable error until it is made available
+type unavailableDestination struct {
+	sync.Mutex
+	available bool
+	payloads  []string
+}
+
+func (d *unavailableDestination) Send(payload []byte) error {
+	d.Lock()
+	defer d.Unlock()
+	if !d.available {
+		time.Sleep(time.Millisecond)
+		return client.NewRetryableError(errors.New("intake unavailable"))
+	}
+	d.payloads = append(d.payloads, string(payload))
+	return nil
+}
+
+func (d *unavailableDestination) SendAsync(payload []byte) {
+	d.Send(payload) //nolint:errcheck
+}
+
+func (d *unavailableDestination) setAvailable() {
+	d.Lock()
+	defer d.Unlock()
+	d.available = true
+}
+
+func (d *unavailableDestination) sent() []string {
+	d.Lock()
+	defer d.Unlock()
+	return append([]string{}, d.payloads...)
+}
+
+func TestSenderWithSpool(t *testing.T) {
+	path, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+	spool := newTestSpool(t, path, 1000)
+
+	input := make(chan *message.Message, 10)
+	output := make(chan *message.Message, 10)
+	destination := &unavailableDestination{}
+	sender := NewSender(input, output, client.NewDestinations(destination, nil), StreamStrategy, spool)
+	sender.Start()
+
+	// the pipeline is not blocked while the destination is unavailable,
+	// and the auditor is not notified until the logs are sent
+	input <- newFileMessage("a", "1")
+	input <- newFileMessage("a", "2")
+	assert.Eventually(t, func() bool { return len(input) == 0 }, 5*time.Second, time.Millisecond)
+	assert.Len(t, output, 0)
+
+	destination.setAvailable()
+	assert.Equal(t, "a:1", offsets([]*message.Message{<-output})[0])
+	assert.Equal(t, "a:2", offsets([]*message.Message{<-output})[0])
+	assert.Eventually(t, spool.IsEmpty, 5*time.Second, time.Millisecond)
+
+	// the logs are sent directly once the spool is empty
+	msg := newFileMessage("a", "3")
+	input <- msg
+	assert.Equal(t, msg, <-output)
+	assert.Equal(t, []string{"1", "2", "3"}, destination.sent())
+
+	sender.Stop()
+}
+
+func TestSenderStopKeepsSpooledPayloads(t *testing.T) {
+	path, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+	spool := newTestSpool(t, path, 1000)
+
+	input := make(chan *message.Message, 10)
+	output := make(chan *message.Message, 10)
+	destination := &unavailableDestination{}
+	sender := NewSender(input, output, client.NewDestinations(destination, nil), StreamStrategy, spool)
+	sender.Start()
+
+	input <- newFileMessage("a", "1")
+	assert.Eventually(t, func() bool { return !spool.IsEmpty() }, 5*time.Second, time.Millisecond)
+	sender.Stop()
+	assert.Len(t, output, 0)
+
+	files, err := ioutil.ReadDir(path)
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestSenderWithSpoolConcurrentSends(t *testing.T) {
+	path, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+	spool := newTestSpool(t, path, 1000)
+
+	input := make(chan *message.Message, 10)
+	output := make(chan *message.Message, 20)
+	destination := &unavailableDestination{}
+	// one message per payload, sent concurrently without a spool
+	strategy := NewBatchStrategy(LineSerializer, 10*time.Millisecond, 4, 1, 1000, "test", 0)
+	sender := NewSender(input, output, client.NewDestinations(destination, nil), strategy, spool)
+	sender.Start()
+
+	var expected []string
+	for i := 1; i <= 10; i++ {
+		input <- newFileMessage("a", strconv.Itoa(i))
+		expected = append(expected, strconv.Itoa(i))
+	}
+	assert.Eventually(t, func() bool { return len(input) == 0 }, 5*time.Second, time.Millisecond)
+
+	// the intake comes back while the logs keep coming
+	destination.setAvailable()
+	for i := 11; i <= 20; i++ {
+		input <- newFileMessage("a", strconv.Itoa(i))
+		expected = append(expected, strconv.Itoa(i))
+	}
+
+	var forwarded []string
+	for range expected {
+		forwarded = append(forwarded, (<-output).Origin.Offset)
+	}
+	assert.Equal(t, expected, forwarded)
+	assert.Equal(t, expected, destination.sent())
+
+	sender.Stop()
+}
+
+func TestSenderWithSpoolAddFailure(t *testing.T) {
+	path, err := ioutil.TempDir("", "spool")
+	require.NoError(t, err)
+	defer os.RemoveAll(path)
+	spool := newTestSpool(t, path, 5)
+
+	input := make(chan *message.Message, 10)
+	output := make(chan *message.Message, 10)
+	destination := &unavailableDestination{}
+	sender := NewSender(input, output, client.NewDestinations(destination, nil), StreamStrategy, spool)
+	sender.Start()
+
+	input <- newFileMessage("a", "1")
+	assert.Eventually(t, func() bool { return !spool.IsEmpty() }, 5*time.Second, time.Millisecond)
+	// this payload is too big to be spooled, it is sent after the spooled one
+	input <- newFileMessage("a", "123456")
+	assert.Eventually(t, func() bool { return len(input) == 0 }, 5*time.Second, time.Millisecond)
+
+	destination.setAvailable()
+	assert.Equal(t, "1", (<-output).Origin.Offset)
+	assert.Equal(t, "123456", (<-output).Origin.Offset)
+	assert.Equal(t, []string{"1", "123456"}, destination.sent())
+
+	sender.Stop()
+}
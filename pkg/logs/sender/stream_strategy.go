@@ -25,13 +25,16 @@ func (s *streamStrategy) Flush(ctx context.Context) {
 }
 
 // Send sends one message at a time and forwards them to the next stage of the pipeline.
-func (s *streamStrategy) Send(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte) error) {
-	for message := range inputChan {
-		if message.Origin != nil {
-			message.Origin.LogSource.LatencyStats.Add(message.GetLatency())
+func (s *streamStrategy) Send(inputChan chan *message.Message, outputChan chan *message.Message, send func([]byte, []*message.Message) error) {
+	for msg := range inputChan {
+		if msg.Origin != nil {
+			msg.Origin.LogSource.LatencyStats.Add(msg.GetLatency())
 		}
-		err := send(message.Content)
+		err := send(msg.Content, []*message.Message{msg})
 		if err != nil {
+			if isSpooled(err) {
+				continue
+			}
 			if shouldStopSending(err) {
 				return
 			}
@@ -39,6 +42,6 @@ func (s *streamStrategy) Send(inputChan chan *message.Message, outputChan chan *
 		}
 		metrics.LogsSent.Add(1)
 		metrics.TlmLogsSent.Inc()
-		outputChan <- message
+		outputChan <- msg
 	}
 }
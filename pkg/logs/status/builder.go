@@ -64,13 +64,38 @@ func (b *Builder) getUseHTTP() bool {
 func (b *Builder) getEndpoints() []string {
 	result := make([]string, 0)
 	result = append(result, b.formatEndpoint(b.endpoints.Main, ""))
-	for _, additional := range b.endpoints.Additionals {
+	if b.endpoints.IsRouted() {
+		result = append(result, b.formatRoute(b.endpoints.MainName()))
+	}
+	for i, additional := range b.endpoints.Additionals {
 		result = append(result, b.formatEndpoint(additional, "Additional: "))
+		if b.endpoints.IsRouted() {
+			result = append(result, b.formatRoute(b.endpoints.AdditionalName(i)))
+		}
 	}
 	return result
 }
 
+// formatRoute returns the delivery status of the route of an endpoint.
+func (b *Builder) formatRoute(name string) string {
+	return fmt.Sprintf("  Route %s: %d payloads sent, %d errors, %d logs filtered out", name,
+		b.getRouteMetric("RoutePayloadsSent", name), b.getRouteMetric("RouteErrors", name), b.getRouteMetric("RouteLogsFiltered", name))
+}
+
+func (b *Builder) getRouteMetric(metric string, name string) int64 {
+	if routes, ok := b.logsExpVars.Get(metric).(*expvar.Map); ok {
+		if value, ok := routes.Get(name).(*expvar.Int); ok {
+			return value.Value()
+		}
+	}
+	return 0
+}
+
 func (b *Builder) formatEndpoint(endpoint config.Endpoint, prefix string) string {
+	if endpoint.IsFile() {
+		return fmt.Sprintf("%sWriting logs to %s", prefix, endpoint.Path)
+	}
+
 	compression := "uncompressed"
 	if endpoint.UseCompression {
 		compression = "compressed"
@@ -92,13 +92,13 @@ func TestStatusDeduplicateErrorsAndWarnings(t *testing.T) {
 func TestMetrics(t *testing.T) {
 	defer Clear()
 	Clear()
-	var expected = `{"BytesSent": 0, "DestinationErrors": 0, "DestinationLogsDropped": {}, "EncodedBytesSent": 0, "Errors": "", "IsRunning": false, "LogsDecoded": 0, "LogsProcessed": 0, "LogsSent": 0, "SenderLatency": 0, "Warnings": ""}`
+	var expected = `{"BytesSent": 0, "DestinationErrors": 0, "DestinationLogsDropped": {}, "EncodedBytesSent": 0, "Errors": "", "IsRunning": false, "LogsDecoded": 0, "LogsParsingErrors": 0, "LogsProcessed": 0, "LogsSent": 0, "LogsThrottled": 0, "RouteErrors": {}, "RouteLogsFiltered": {}, "RoutePayloadsSent": {}, "SenderLatency": 0, "Warnings": ""}`
 	assert.Equal(t, expected, metrics.LogsExpvars.String())
 
 	initStatus()
 	AddGlobalWarning("bar", "Unique Warning")
 	AddGlobalError("bar", "I am an error")
-	expected = `{"BytesSent": 0, "DestinationErrors": 0, "DestinationLogsDropped": {}, "EncodedBytesSent": 0, "Errors": "I am an error", "IsRunning": true, "LogsDecoded": 0, "LogsProcessed": 0, "LogsSent": 0, "SenderLatency": 0, "Warnings": "Unique Warning"}`
+	expected = `{"BytesSent": 0, "DestinationErrors": 0, "DestinationLogsDropped": {}, "EncodedBytesSent": 0, "Errors": "I am an error", "IsRunning": true, "LogsDecoded": 0, "LogsParsingErrors": 0, "LogsProcessed": 0, "LogsSent": 0, "LogsThrottled": 0, "RouteErrors": {}, "RouteLogsFiltered": {}, "RoutePayloadsSent": {}, "SenderLatency": 0, "Warnings": "Unique Warning"}`
 	assert.Equal(t, expected, metrics.LogsExpvars.String())
 }
 
@@ -136,3 +136,29 @@ func TestStatusEndpoints(t *testing.T) {
 	status := Get()
 	assert.Equal(t, "Sending uncompressed logs in SSL encrypted TCP to agent-intake.logs.datadoghq.com on port 10516", status.Endpoints[0])
 }
+
+func TestStatusRoutedEndpoints(t *testing.T) {
+	var isRunning int32 = 1
+	endpoints := config.NewEndpoints(
+		config.Endpoint{Host: "agent-http-intake.logs.datadoghq.com", UseSSL: true, Route: &config.Route{Exclude: config.RouteFilter{Statuses: []string{"debug"}}}},
+		[]config.Endpoint{{Path: "/var/log/datadog/debug.log", Route: &config.Route{Include: config.RouteFilter{Statuses: []string{"debug"}}}}},
+		false, true)
+	Init(&isRunning, endpoints, config.NewLogSources(), metrics.LogsExpvars)
+	defer Clear()
+
+	metrics.RoutePayloadsSent.Add("main", 3)
+	metrics.RouteErrors.Add("main", 1)
+	metrics.RouteLogsFiltered.Add("additional_1", 10)
+	defer func() {
+		metrics.RoutePayloadsSent.Init()
+		metrics.RouteErrors.Init()
+		metrics.RouteLogsFiltered.Init()
+	}()
+
+	assert.Equal(t, []string{
+		"Sending uncompressed logs in HTTPS to agent-http-intake.logs.datadoghq.com on port 443",
+		"  Route main: 3 payloads sent, 1 errors, 0 logs filtered out",
+		"Additional: Writing logs to /var/log/datadog/debug.log",
+		"  Route additional_1: 0 payloads sent, 0 errors, 10 logs filtered out",
+	}, Get().Endpoints)
+}
@@ -13,16 +13,16 @@ func TestCheckMetrics(t *testing.T) {
 	cm := NewCheckMetrics(true, 1000*time.Second)
 	t0 := 16_0000_0000.0
 
-	cm.AddSample(1, &MetricSample{Mtype: GaugeType}, t0, 1)
+	cm.AddSample(1, &MetricSample{Mtype: GaugeType}, t0, 1, nil)
 	assert.Contains(t, cm.metrics, ckey.ContextKey(1))
 
-	cm.AddSample(2, &MetricSample{Mtype: MonotonicCountType}, t0, 1)
+	cm.AddSample(2, &MetricSample{Mtype: MonotonicCountType}, t0, 1, nil)
 	assert.Contains(t, cm.metrics, ckey.ContextKey(2))
 
-	cm.AddSample(3, &MetricSample{Mtype: MonotonicCountType}, t0, 1)
+	cm.AddSample(3, &MetricSample{Mtype: MonotonicCountType}, t0, 1, nil)
 	assert.Contains(t, cm.metrics, ckey.ContextKey(3))
 
-	cm.AddSample(4, &MetricSample{Mtype: GaugeType}, t0, 1)
+	cm.AddSample(4, &MetricSample{Mtype: GaugeType}, t0, 1, nil)
 	assert.Contains(t, cm.metrics, ckey.ContextKey(4))
 
 	cm.Expire([]ckey.ContextKey{1, 2}, t0+100)
@@ -51,16 +51,16 @@ func TestCheckMetricsNoExpiry(t *testing.T) {
 	cm := NewCheckMetrics(false, 1000*time.Second)
 	t0 := 16_0000_0000.0
 
-	cm.AddSample(1, &MetricSample{Mtype: GaugeType}, t0, 1)
+	cm.AddSample(1, &MetricSample{Mtype: GaugeType}, t0, 1, nil)
 	assert.Contains(t, cm.metrics, ckey.ContextKey(1))
 
-	cm.AddSample(2, &MetricSample{Mtype: MonotonicCountType}, t0, 1)
+	cm.AddSample(2, &MetricSample{Mtype: MonotonicCountType}, t0, 1, nil)
 	assert.Contains(t, cm.metrics, ckey.ContextKey(2))
 
-	cm.AddSample(3, &MetricSample{Mtype: MonotonicCountType}, t0, 1)
+	cm.AddSample(3, &MetricSample{Mtype: MonotonicCountType}, t0, 1, nil)
 	assert.Contains(t, cm.metrics, ckey.ContextKey(3))
 
-	cm.AddSample(4, &MetricSample{Mtype: GaugeType}, t0, 1)
+	cm.AddSample(4, &MetricSample{Mtype: GaugeType}, t0, 1, nil)
 	assert.Contains(t, cm.metrics, ckey.ContextKey(4))
 
 	cm.Expire([]ckey.ContextKey{1, 2}, t0+100)
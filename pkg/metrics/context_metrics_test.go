@@ -26,7 +26,7 @@ func TestContextMetricsGaugeSampling(t *testing.T) {
 		Mtype: GaugeType,
 	}
 
-	metrics.AddSample(contextKey, &mSample, 1, 10, nil)
+	metrics.AddSample(contextKey, &mSample, 1, 10, nil, nil)
 	series, err := metrics.Flush(12345)
 
 	assert.Len(t, err, 0)
@@ -52,7 +52,7 @@ func TestContextMetricsGaugeSamplingNoSample(t *testing.T) {
 		Mtype: GaugeType,
 	}
 
-	metrics.AddSample(contextKey, &mSample, 1, 10, nil)
+	metrics.AddSample(contextKey, &mSample, 1, 10, nil, nil)
 	series, err := metrics.Flush(12345)
 
 	assert.Len(t, err, 0)
@@ -80,8 +80,8 @@ func TestContextMetricsGaugeSamplingInvalidSamples(t *testing.T) {
 		Mtype: GaugeType,
 	}
 
-	metrics.AddSample(contextKey1, &mSample1, 1, 10, nil)
-	metrics.AddSample(contextKey2, &mSample2, 1, 10, nil)
+	metrics.AddSample(contextKey1, &mSample1, 1, 10, nil, nil)
+	metrics.AddSample(contextKey2, &mSample2, 1, 10, nil, nil)
 	series, err := metrics.Flush(20)
 	assert.Len(t, err, 0)
 	assert.Equal(t, 0, len(series))
@@ -91,7 +91,7 @@ func TestContextMetricsGaugeSamplingInvalidSamples(t *testing.T) {
 		Value: math.NaN(),
 		Mtype: GaugeType,
 	}
-	metrics.AddSample(contextKey1, &mSample3, 1, 30, nil)
+	metrics.AddSample(contextKey1, &mSample3, 1, 30, nil, nil)
 	series, err = metrics.Flush(40)
 	assert.Len(t, err, 0)
 	assert.Equal(t, 0, len(series))
@@ -101,7 +101,7 @@ func TestContextMetricsGaugeSamplingInvalidSamples(t *testing.T) {
 		Value: 1,
 		Mtype: GaugeType,
 	}
-	metrics.AddSample(contextKey1, &mSample4, 1, 50, nil)
+	metrics.AddSample(contextKey1, &mSample4, 1, 50, nil, nil)
 	series, err = metrics.Flush(60)
 	assert.Len(t, err, 0)
 	expectedSerie := &Serie{
@@ -120,14 +120,14 @@ func TestContextMetricsSingleRateSampling(t *testing.T) {
 	metrics := MakeContextMetrics()
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
-	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 1}, 12340, 10, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 1}, 12340, 10, nil, nil)
 	series, err := metrics.Flush(12345)
 
 	assert.Len(t, err, 0)
 	// No series flushed since the rate was sampled once only
 	assert.Equal(t, 0, len(series))
 
-	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 2}, 12350, 10, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 2}, 12350, 10, nil, nil)
 	series, err = metrics.Flush(12351)
 
 	assert.Len(t, err, 0)
@@ -149,8 +149,8 @@ func TestContextMetricsNegativeRateSampling(t *testing.T) {
 	metrics := MakeContextMetrics()
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
-	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 2}, 12340, 10, nil)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 1}, 12350, 10, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 2}, 12340, 10, nil, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: RateType, Value: 1}, 12350, 10, nil, nil)
 	series, err := metrics.Flush(12351)
 
 	assert.Len(t, series, 0)
@@ -162,8 +162,8 @@ func TestContextMetricsCountSampling(t *testing.T) {
 	metrics := MakeContextMetrics()
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
-	metrics.AddSample(contextKey, &MetricSample{Mtype: CountType, Value: 1}, 12340, 10, nil)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: CountType, Value: 5}, 12345, 10, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: CountType, Value: 1}, 12340, 10, nil, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: CountType, Value: 5}, 12345, 10, nil, nil)
 	series, err := metrics.Flush(12350)
 
 	assert.Len(t, err, 0)
@@ -183,8 +183,8 @@ func TestContextMetricsMonotonicCountSampling(t *testing.T) {
 	metrics := MakeContextMetrics()
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
-	metrics.AddSample(contextKey, &MetricSample{Mtype: MonotonicCountType, Value: 1}, 12340, 10, nil)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: MonotonicCountType, Value: 5}, 12345, 10, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: MonotonicCountType, Value: 1}, 12340, 10, nil, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: MonotonicCountType, Value: 5}, 12345, 10, nil, nil)
 	series, err := metrics.Flush(12350)
 
 	assert.Len(t, err, 0)
@@ -204,10 +204,10 @@ func TestContextMetricsHistogramSampling(t *testing.T) {
 	metrics := MakeContextMetrics()
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 1}, 12340, 10, nil)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 2}, 12342, 10, nil)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 1}, 12350, 10, nil)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 6}, 12350, 10, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 1}, 12340, 10, nil, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 2}, 12342, 10, nil, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 1}, 12350, 10, nil, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: 6}, 12350, 10, nil, nil)
 	series, err := metrics.Flush(12351)
 
 	assert.Len(t, err, 0)
@@ -251,14 +251,55 @@ func TestContextMetricsHistogramSampling(t *testing.T) {
 	}
 }
 
+func TestContextMetricsHistogramSettings(t *testing.T) {
+	metrics := MakeContextMetrics()
+	contextKey := ckey.ContextKey(0xffffffffffffffff)
+	settings := &HistogramSettings{Aggregates: []string{"max"}, Percentiles: []int{99, 50}}
+
+	for i := 1; i <= 100; i++ {
+		metrics.AddSample(contextKey, &MetricSample{Mtype: HistogramType, Value: float64(i)}, 12340, 10, nil, settings)
+	}
+	series, err := metrics.Flush(12351)
+
+	assert.Len(t, err, 0)
+	expectedSeries := []*Serie{
+		{
+			ContextKey: contextKey,
+			Points:     []Point{{12351.0, 100.}},
+			MType:      APIGaugeType,
+			NameSuffix: ".max",
+		},
+		{
+			ContextKey: contextKey,
+			Points:     []Point{{12351.0, 50.}},
+			MType:      APIGaugeType,
+			NameSuffix: ".50percentile",
+		},
+		{
+			ContextKey: contextKey,
+			Points:     []Point{{12351.0, 99.}},
+			MType:      APIGaugeType,
+			NameSuffix: ".99percentile",
+		},
+	}
+
+	if assert.Len(t, series, len(expectedSeries)) {
+		for i := range expectedSeries {
+			AssertSerieEqual(t, expectedSeries[i], series[i])
+		}
+	}
+	// the settings are left untouched
+	assert.Equal(t, []int{99, 50}, settings.Percentiles)
+}
+
 func TestContextMetricsHistorateSampling(t *testing.T) {
 	metrics := MakeContextMetrics()
 	contextKey := ckey.ContextKey(0xffffffffffffffff)
 
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 1}, 12340, 10, nil)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 2}, 12341, 10, nil)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 4}, 12342, 10, nil)
-	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 4}, 12343, 10, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 1}, 12340, 10, nil, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 2}, 12341, 10, nil, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 4}, 12342, 10, nil, nil)
+	metrics.AddSample(contextKey, &MetricSample{Mtype: HistorateType, Value: 4}, 12343, 10, nil, nil)
 	series, err := metrics.Flush(12351)
 
 	assert.Len(t, err, 0)
@@ -55,15 +55,22 @@ type histogramPercentilesConfig struct {
 }
 
 func (h *histogramPercentilesConfig) percentiles() []int {
+	return ParseHistogramPercentiles(h.Percentiles, "histogram_percentiles")
+}
+
+// ParseHistogramPercentiles parses percentiles given as strings in the 0-1 range,
+// as used in the configuration under the given option name. The invalid percentiles
+// are skipped.
+func ParseHistogramPercentiles(percentiles []string, option string) []int {
 	res := []int{}
-	for _, p := range h.Percentiles {
+	for _, p := range percentiles {
 		i, err := strconv.ParseFloat(p, 64)
 		if err != nil {
-			log.Errorf("Could not parse '%s' from 'histogram_percentiles' (skipping): %s", p, err)
+			log.Errorf("Could not parse '%s' from '%s' (skipping): %s", p, option, err)
 			continue
 		}
 		if i < 0 || i > 1 {
-			log.Errorf("histogram_percentiles must be between 0 and 1: skipping %f", i)
+			log.Errorf("%s must be between 0 and 1: skipping %f", option, i)
 			continue
 		}
 		// in some cases the '*100' will lower the number resulting in
@@ -98,6 +105,32 @@ func NewHistogram(interval int64) *Histogram {
 	}
 }
 
+// HistogramSettings overrides the aggregates and percentiles computed by some histograms.
+// A nil field means the default setting.
+type HistogramSettings struct {
+	Aggregates  []string
+	Percentiles []int // each in the 1-100 range
+}
+
+// NewHistogramWithSettings returns a newly initialized histogram using the given
+// settings, or the default ones if settings is nil
+func NewHistogramWithSettings(interval int64, settings *HistogramSettings) *Histogram {
+	h := NewHistogram(interval)
+	if settings == nil {
+		return h
+	}
+
+	aggregates, percentiles := h.aggregates, h.percentiles
+	if settings.Aggregates != nil {
+		aggregates = settings.Aggregates
+	}
+	if settings.Percentiles != nil {
+		percentiles = append([]int{}, settings.Percentiles...)
+	}
+	h.configure(aggregates, percentiles)
+	return h
+}
+
 func (h *Histogram) configure(aggregates []string, percentiles []int) {
 	h.aggregates = aggregates
 	sort.Ints(percentiles)
@@ -249,7 +249,7 @@ func (series Series) MarshalSplitCompress(bufferContext *marshaler.BufferContext
 		bufferContext.CompressorInput.Reset()
 		bufferContext.CompressorOutput.Reset()
 
-		compressor, err = stream.NewCompressor(bufferContext.CompressorInput, bufferContext.CompressorOutput, []byte{}, []byte{}, []byte{})
+		compressor, err = stream.NewCompressor(bufferContext.Compressor, bufferContext.CompressorInput, bufferContext.CompressorOutput, []byte{}, []byte{}, []byte{})
 		if err != nil {
 			return err
 		}
@@ -11,20 +11,45 @@ package secrets
 
 import (
 	"fmt"
+	"time"
 )
 
 // SecretBackendOutputMaxSize defines max size of the JSON output from a secrets reader backend
 var SecretBackendOutputMaxSize = 1024 * 1024
 
 // Init placeholder when compiled without the 'secrets' build tag
-func Init(command string, arguments []string, timeout int, maxSize int, groupExecPerm bool) {}
+func Init(command string, arguments []string, timeout int, maxSize int, groupExecPerm bool, builtinResolvers []string, allowBuiltinResolversForChecks bool) {
+}
 
 // Decrypt encrypted secrets are not available on windows
 func Decrypt(data []byte, origin string) ([]byte, error) {
 	return data, nil
 }
 
+// DecryptAgentConfig encrypted secrets are not available on windows
+func DecryptAgentConfig(data []byte, origin string) ([]byte, error) {
+	return data, nil
+}
+
 // GetDebugInfo exposes debug informations about secrets to be included in a flare
 func GetDebugInfo() (*SecretInfo, error) {
 	return nil, fmt.Errorf("Secret feature is not available in this version of the agent")
 }
+
+// RefreshHandler is called with the handles of the secrets whose value changed when
+// the secrets are refreshed
+type RefreshHandler func(handles []string)
+
+// RegisterRefreshHandler placeholder when compiled without the 'secrets' build tag
+func RegisterRefreshHandler(handler RefreshHandler) {}
+
+// StartRefresh placeholder when compiled without the 'secrets' build tag
+func StartRefresh(interval time.Duration) {}
+
+// StopRefresh placeholder when compiled without the 'secrets' build tag
+func StopRefresh() {}
+
+// Refresh placeholder when compiled without the 'secrets' build tag
+func Refresh() ([]string, error) {
+	return nil, nil
+}
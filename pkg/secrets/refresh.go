@@ -0,0 +1,177 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build secrets
+
+package secrets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// RefreshHandler is called with the handles of the secrets whose value changed when
+// the secrets are refreshed
+type RefreshHandler func(handles []string)
+
+var (
+	refreshHandlers     []RefreshHandler
+	refreshHandlersLock sync.Mutex
+
+	refreshStop     chan struct{}
+	refreshStopped  chan struct{}
+	refreshLoopLock sync.Mutex
+
+	tlmSecretsRotated = telemetry.NewCounter("secret_backend", "rotated_secrets", []string{"resolver"}, "Number of secrets whose value changed when refreshed")
+	tlmRefreshErrors  = telemetry.NewCounter("secret_backend", "refresh_errors", []string{"resolver"}, "Number of errors while refreshing the secrets")
+)
+
+// RegisterRefreshHandler registers a handler notified of the rotated secrets. The handlers are
+// called in the order of their registration.
+func RegisterRefreshHandler(handler RefreshHandler) {
+	refreshHandlersLock.Lock()
+	defer refreshHandlersLock.Unlock()
+	refreshHandlers = append(refreshHandlers, handler)
+}
+
+// StartRefresh fetches the value of the secrets every interval and notifies the registered
+// handlers of the rotated secrets. It does nothing when the interval is not positive or when
+// the refresh is already running.
+func StartRefresh(interval time.Duration) {
+	refreshLoopLock.Lock()
+	defer refreshLoopLock.Unlock()
+	if interval <= 0 || refreshStop != nil {
+		return
+	}
+
+	refreshStop = make(chan struct{})
+	refreshStopped = make(chan struct{})
+	go refreshLoop(interval, refreshStop, refreshStopped)
+	log.Infof("Refreshing the secrets every %s", interval)
+}
+
+// StopRefresh stops the periodic refresh of the secrets
+func StopRefresh() {
+	refreshLoopLock.Lock()
+	defer refreshLoopLock.Unlock()
+	if refreshStop == nil {
+		return
+	}
+	close(refreshStop)
+	<-refreshStopped
+	refreshStop = nil
+	refreshStopped = nil
+}
+
+func refreshLoop(interval time.Duration, stop chan struct{}, stopped chan struct{}) {
+	defer close(stopped)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := Refresh(); err != nil {
+				log.Errorf("Unable to refresh the secrets: %s", err)
+			}
+		}
+	}
+}
+
+// Refresh fetches again the value of the secrets already decrypted, updates the cache and
+// notifies the registered handlers of the secrets whose value changed. It returns the rotated
+// handles. The secrets which cannot be fetched keep their previous value.
+func Refresh() ([]string, error) {
+	rotated, err := refreshSecrets()
+	if len(rotated) == 0 {
+		return nil, err
+	}
+
+	refreshHandlersLock.Lock()
+	handlers := make([]RefreshHandler, len(refreshHandlers))
+	copy(handlers, refreshHandlers)
+	refreshHandlersLock.Unlock()
+
+	for _, handler := range handlers {
+		handler(rotated)
+	}
+	return rotated, err
+}
+
+// refreshSecrets updates the cache with the current value of the secrets and returns the
+// handles whose value changed
+func refreshSecrets() ([]string, error) {
+	// the secrets are fetched without holding the lock since the backend command can be slow
+	secretsLock.Lock()
+	var builtinHandles, commandHandles []string
+	for handle := range secretCache {
+		if name, _ := builtinResolver(handle); name != "" {
+			builtinHandles = append(builtinHandles, handle)
+		} else if secretBackendCommand != "" {
+			commandHandles = append(commandHandles, handle)
+		}
+	}
+	secretsLock.Unlock()
+
+	var errs []string
+	values := map[string]string{}
+	for _, handle := range builtinHandles {
+		value, err := resolveBuiltinSecret(handle)
+		if err != nil {
+			name, _ := builtinResolver(handle)
+			tlmRefreshErrors.Inc(name)
+			errs = append(errs, err.Error())
+			continue
+		}
+		values[handle] = value
+	}
+	if len(commandHandles) != 0 {
+		sort.Strings(commandHandles)
+		fetched, err := fetchSecretValues(commandHandles)
+		if err != nil {
+			tlmRefreshErrors.Inc(BackendCommandResolver)
+			errs = append(errs, err.Error())
+		}
+		for handle, value := range fetched {
+			values[handle] = value
+		}
+	}
+
+	secretsLock.Lock()
+	var rotated []string
+	for handle, value := range values {
+		previous, found := secretCache[handle]
+		if !found || previous == value {
+			continue
+		}
+		secretCache[handle] = value
+		rotated = append(rotated, handle)
+
+		// audit log of the rotation, the values of the secrets are never logged
+		resolver := resolverName(handle)
+		tlmSecretsRotated.Inc(resolver)
+		var origins []string
+		if origin, found := secretOrigin[handle]; found {
+			origins = origin.GetAll()
+			sort.Strings(origins)
+		}
+		log.Infof("Secret '%s' was rotated by the %s resolver, it is used by: %s", handle, resolver, strings.Join(origins, ", "))
+	}
+	secretsLock.Unlock()
+	sort.Strings(rotated)
+
+	if len(errs) != 0 {
+		return rotated, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return rotated, nil
+}
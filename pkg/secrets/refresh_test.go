@@ -0,0 +1,104 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build secrets
+
+package secrets
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefresh(t *testing.T) {
+	defer resetBuiltinResolvers()
+	defer func() {
+		runCommand = execCommand
+		refreshHandlers = nil
+	}()
+	setEnabledResolvers([]string{"env"})
+	secretBackendCommand = "some_command"
+
+	os.Setenv("DD_TEST_SECRET", "password1")
+	defer os.Unsetenv("DD_TEST_SECRET")
+	commandSecret := "password2"
+	runCommand = func(string) ([]byte, error) {
+		return []byte(fmt.Sprintf(`{"pass2":{"value":"%s"}}`, commandSecret)), nil
+	}
+
+	var notified [][]string
+	RegisterRefreshHandler(func(handles []string) {
+		notified = append(notified, handles)
+	})
+
+	conf := []byte("password: ENC[env@DD_TEST_SECRET]\nuser: ENC[pass2]\n")
+	_, err := DecryptAgentConfig(conf, "test")
+	require.NoError(t, err)
+
+	// nothing changed
+	rotated, err := Refresh()
+	require.NoError(t, err)
+	assert.Empty(t, rotated)
+	assert.Empty(t, notified)
+
+	os.Setenv("DD_TEST_SECRET", "rotated1")
+	commandSecret = "rotated2"
+	rotated, err = Refresh()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"env@DD_TEST_SECRET", "pass2"}, rotated)
+	assert.Equal(t, [][]string{{"env@DD_TEST_SECRET", "pass2"}}, notified)
+
+	newConf, err := DecryptAgentConfig(conf, "test")
+	require.NoError(t, err)
+	assert.Equal(t, "password: rotated1\nuser: rotated2\n", string(newConf))
+
+	// the secrets which cannot be fetched keep their value
+	os.Unsetenv("DD_TEST_SECRET")
+	commandSecret = "rotated3"
+	rotated, err = Refresh()
+	assert.Error(t, err)
+	assert.Equal(t, []string{"pass2"}, rotated)
+	assert.Equal(t, "rotated1", secretCache["env@DD_TEST_SECRET"])
+	assert.Equal(t, "rotated3", secretCache["pass2"])
+}
+
+func TestStartStopRefresh(t *testing.T) {
+	defer resetBuiltinResolvers()
+	defer func() { refreshHandlers = nil }()
+	setEnabledResolvers([]string{"env"})
+
+	os.Setenv("DD_TEST_SECRET", "password1")
+	defer os.Unsetenv("DD_TEST_SECRET")
+	_, err := DecryptAgentConfig([]byte("password: ENC[env@DD_TEST_SECRET]\n"), "test")
+	require.NoError(t, err)
+
+	notified := make(chan []string, 10)
+	RegisterRefreshHandler(func(handles []string) {
+		notified <- handles
+	})
+
+	// a non positive interval disables the refresh
+	StartRefresh(0)
+	assert.Nil(t, refreshStop)
+
+	os.Setenv("DD_TEST_SECRET", "password2")
+	StartRefresh(10 * time.Millisecond)
+	defer StopRefresh()
+
+	select {
+	case handles := <-notified:
+		assert.Equal(t, []string{"env@DD_TEST_SECRET"}, handles)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the secrets were not refreshed")
+	}
+
+	StopRefresh()
+	assert.Nil(t, refreshStop)
+}
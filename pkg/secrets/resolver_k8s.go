@@ -0,0 +1,113 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build secrets
+
+package secrets
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// The Kubernetes secrets are read from the API server with the service account of the
+// Agent pod, the client-go library isn't used to keep the secrets package lightweight since
+// it is imported by the config package.
+var (
+	kubernetesServiceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount"
+	kubernetesAPIServerURL       = func() string {
+		host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if host == "" || port == "" {
+			return ""
+		}
+		return "https://" + net.JoinHostPort(host, port)
+	}
+)
+
+type kubernetesSecret struct {
+	Data map[string]string `json:"data"`
+}
+
+// resolveKubernetesSecret returns the value of a key of a Kubernetes secret, the reference
+// of the secret is `<namespace>/<name>/<key>`
+func resolveKubernetesSecret(reference string) (string, error) {
+	parts := strings.Split(reference, "/")
+	if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return "", fmt.Errorf("invalid format, use '<namespace>/<name>/<key>'")
+	}
+	namespace, name, key := parts[0], parts[1], parts[2]
+
+	apiServerURL := kubernetesAPIServerURL()
+	if apiServerURL == "" {
+		return "", fmt.Errorf("the Agent is not running in a Kubernetes cluster: KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT are not set")
+	}
+
+	token, err := ioutil.ReadFile(filepath.Join(kubernetesServiceAccountPath, "token"))
+	if err != nil {
+		return "", fmt.Errorf("unable to read the service account token: %s", err)
+	}
+	caCert, err := ioutil.ReadFile(filepath.Join(kubernetesServiceAccountPath, "ca.crt"))
+	if err != nil {
+		return "", fmt.Errorf("unable to read the service account CA certificate: %s", err)
+	}
+	caPool := x509.NewCertPool()
+	if !caPool.AppendCertsFromPEM(caCert) {
+		return "", fmt.Errorf("unable to parse the service account CA certificate")
+	}
+
+	client := &http.Client{
+		Timeout: time.Duration(secretBackendTimeout) * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: caPool},
+		},
+	}
+
+	secretURL := fmt.Sprintf("%s/api/v1/namespaces/%s/secrets/%s", apiServerURL, url.PathEscape(namespace), url.PathEscape(name))
+	req, err := http.NewRequest("GET", secretURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unable to get the secret %s/%s from the API server: %s", namespace, name, resp.Status)
+	}
+
+	var secret kubernetesSecret
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("unable to decode the secret %s/%s: %s", namespace, name, err)
+	}
+	encoded, found := secret.Data[key]
+	if !found {
+		return "", fmt.Errorf("key %s not found in the secret %s/%s", key, namespace, name)
+	}
+	value, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("unable to decode the key %s of the secret %s/%s: %s", key, namespace, name, err)
+	}
+	return string(value), nil
+}
@@ -0,0 +1,159 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build secrets
+
+package secrets
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DataDog/datadog-agent/pkg/util/common"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+const (
+	// resolverSeparator separates the resolver from the reference of the secret in a handle,
+	// `file@/etc/secrets/password` for instance
+	resolverSeparator = "@"
+
+	// BackendCommandResolver is the name of the resolver of the handles sent to `secret_backend_command`
+	BackendCommandResolver = "secret_backend_command"
+
+	maxSecretFileSize = 8192
+)
+
+// resolver returns the value of the secret its reference points to
+type resolver func(reference string) (string, error)
+
+// builtinResolvers are the resolvers which don't need an external executable, selected by the
+// prefix of the handles
+var builtinResolvers = map[string]resolver{
+	"file": resolveFileSecret,
+	"env":  resolveEnvSecret,
+	"k8s":  resolveKubernetesSecret,
+}
+
+// enabledResolvers are the built-in resolvers enabled by the configuration
+var enabledResolvers = map[string]resolver{}
+
+// secretResolver holds the name of the resolver which fetched each handle
+var secretResolver = map[string]string{}
+
+func setEnabledResolvers(names []string) {
+	enabledResolvers = map[string]resolver{}
+	for _, name := range names {
+		if r, found := builtinResolvers[name]; found {
+			enabledResolvers[name] = r
+		} else {
+			log.Warnf("Unknown built-in secret resolver '%s', known resolvers are file, env and k8s", name)
+		}
+	}
+}
+
+// builtinResolver returns the name of the built-in resolver of the handle, empty when the
+// handle is resolved by `secret_backend_command`
+func builtinResolver(handle string) (string, string) {
+	i := strings.Index(handle, resolverSeparator)
+	if i <= 0 {
+		return "", ""
+	}
+	name := handle[:i]
+	if _, found := enabledResolvers[name]; !found {
+		return "", ""
+	}
+	return name, handle[i+len(resolverSeparator):]
+}
+
+// fetchBuiltinSecrets resolves the handles with the built-in resolvers, it fails if one of
+// the handles cannot be resolved
+func fetchBuiltinSecrets(handles []string, origin string) (map[string]string, error) {
+	res := map[string]string{}
+	for _, handle := range handles {
+		value, err := resolveBuiltinSecret(handle)
+		if err != nil {
+			return nil, err
+		}
+
+		// add it to the cache
+		secretCache[handle] = value
+		// keep track of place where a handle was found
+		secretOrigin[handle] = common.NewStringSet(origin)
+		name, _ := builtinResolver(handle)
+		secretResolver[handle] = name
+		res[handle] = value
+	}
+	return res, nil
+}
+
+// resolveBuiltinSecret resolves a handle with its built-in resolver
+func resolveBuiltinSecret(handle string) (string, error) {
+	name, reference := builtinResolver(handle)
+	value, err := enabledResolvers[name](reference)
+	if err != nil {
+		return "", fmt.Errorf("an error occurred while resolving '%s' with the %s resolver: %s", handle, name, err)
+	}
+	if value == "" {
+		return "", fmt.Errorf("resolved secret for '%s' is empty", handle)
+	}
+	return value, nil
+}
+
+// resolveFileSecret returns the content of a file. Like the files read by the `secret-helper`
+// command, the symlinks can only point to files of the same directory, to support the
+// Kubernetes secrets and the Docker secrets mounted in the container.
+func resolveFileSecret(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("the path of the file must be absolute")
+	}
+
+	fi, err := os.Lstat(path)
+	if err != nil {
+		return "", err
+	}
+	if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := filepath.EvalSymlinks(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read symlink target: %v", err)
+		}
+		dir, err := filepath.EvalSymlinks(filepath.Dir(path))
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve the directory of the file: %v", err)
+		}
+		if !strings.HasPrefix(target, dir+string(filepath.Separator)) {
+			return "", fmt.Errorf("not following symlink %q outside of %q", target, filepath.Dir(path))
+		}
+	}
+
+	fi, err = os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !fi.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", path)
+	}
+	if fi.Size() > maxSecretFileSize {
+		return "", fmt.Errorf("secret exceeds max allowed size")
+	}
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// resolveEnvSecret returns the value of an environment variable
+func resolveEnvSecret(name string) (string, error) {
+	value, found := os.LookupEnv(name)
+	if !found {
+		return "", fmt.Errorf("the environment variable %s is not set", name)
+	}
+	return value, nil
+}
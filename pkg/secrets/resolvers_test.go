@@ -0,0 +1,231 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build secrets
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/util/common"
+)
+
+func resetBuiltinResolvers() {
+	secretBackendCommand = ""
+	secretCache = map[string]string{}
+	secretOrigin = map[string]common.StringSet{}
+	secretResolver = map[string]string{}
+	secretFetcher = fetchSecret
+	setEnabledResolvers(nil)
+	builtinResolversForChecks = false
+}
+
+func TestBuiltinResolver(t *testing.T) {
+	defer resetBuiltinResolvers()
+	setEnabledResolvers([]string{"file", "env", "unknown"})
+
+	name, reference := builtinResolver("file@/etc/secret")
+	assert.Equal(t, "file", name)
+	assert.Equal(t, "/etc/secret", reference)
+
+	name, reference = builtinResolver("env@MY_VAR")
+	assert.Equal(t, "env", name)
+	assert.Equal(t, "MY_VAR", reference)
+
+	for _, handle := range []string{"k8s@ns/name/key", "vault@path", "pass1", "@file"} {
+		name, _ = builtinResolver(handle)
+		assert.Empty(t, name, handle)
+	}
+}
+
+func TestResolveFileSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	other, err := ioutil.TempDir("", "secrets")
+	require.NoError(t, err)
+	defer os.RemoveAll(other)
+
+	path := filepath.Join(dir, "password")
+	require.NoError(t, ioutil.WriteFile(path, []byte("secret\n"), 0600))
+	value, err := resolveFileSecret(path)
+	require.NoError(t, err)
+	assert.Equal(t, "secret\n", value)
+
+	// symlinks in the same directory are followed, like the Kubernetes secrets mounted in a pod
+	require.NoError(t, os.Symlink(path, filepath.Join(dir, "link")))
+	value, err = resolveFileSecret(filepath.Join(dir, "link"))
+	require.NoError(t, err)
+	assert.Equal(t, "secret\n", value)
+
+	outside := filepath.Join(other, "password")
+	require.NoError(t, ioutil.WriteFile(outside, []byte("secret"), 0600))
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "outside")))
+	_, err = resolveFileSecret(filepath.Join(dir, "outside"))
+	assert.Error(t, err)
+
+	big := filepath.Join(dir, "big")
+	require.NoError(t, ioutil.WriteFile(big, bytes.Repeat([]byte("a"), maxSecretFileSize+1), 0600))
+	_, err = resolveFileSecret(big)
+	assert.Error(t, err)
+
+	_, err = resolveFileSecret("password")
+	assert.Error(t, err)
+	_, err = resolveFileSecret(filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestResolveEnvSecret(t *testing.T) {
+	os.Setenv("DD_TEST_SECRET", "password")
+	defer os.Unsetenv("DD_TEST_SECRET")
+
+	value, err := resolveEnvSecret("DD_TEST_SECRET")
+	require.NoError(t, err)
+	assert.Equal(t, "password", value)
+
+	_, err = resolveEnvSecret("DD_TEST_SECRET_MISSING")
+	assert.Error(t, err)
+}
+
+func TestResolveKubernetesSecret(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer my-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/v1/namespaces/default/secrets/db" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"kind":"Secret","data":{"password":"cGFzc3dvcmQ=","invalid":"!!"}}`))
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "serviceaccount")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	caCert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "ca.crt"), caCert, 0600))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "token"), []byte("my-token\n"), 0600))
+
+	defer func(path string, url func() string) {
+		kubernetesServiceAccountPath = path
+		kubernetesAPIServerURL = url
+	}(kubernetesServiceAccountPath, kubernetesAPIServerURL)
+	kubernetesServiceAccountPath = dir
+	kubernetesAPIServerURL = func() string { return server.URL }
+
+	value, err := resolveKubernetesSecret("default/db/password")
+	require.NoError(t, err)
+	assert.Equal(t, "password", value)
+
+	for _, reference := range []string{"default/db/missing", "default/db/invalid", "default/other/password", "default/db", "default//password"} {
+		_, err = resolveKubernetesSecret(reference)
+		assert.Error(t, err, reference)
+	}
+
+	kubernetesAPIServerURL = func() string { return "" }
+	_, err = resolveKubernetesSecret("default/db/password")
+	assert.Error(t, err)
+}
+
+func TestDecryptBuiltinResolvers(t *testing.T) {
+	defer resetBuiltinResolvers()
+	setEnabledResolvers([]string{"file", "env", "k8s"})
+	secretBackendCommand = "some_command"
+
+	os.Setenv("DD_TEST_SECRET", "password1")
+	defer os.Unsetenv("DD_TEST_SECRET")
+
+	defer func() { runCommand = execCommand }()
+	runCommand = func(input string) ([]byte, error) {
+		assert.Contains(t, input, `"secrets":["pass2"]`)
+		return []byte(`{"pass2":{"value":"password2"}}`), nil
+	}
+
+	conf := []byte("instances:\n- password: ENC[env@DD_TEST_SECRET]\n  user: ENC[pass2]\n")
+	newConf, err := DecryptAgentConfig(conf, "test")
+	require.NoError(t, err)
+	assert.Equal(t, "instances:\n- password: password1\n  user: password2\n", string(newConf))
+
+	// the secrets of the built-in resolvers are cached like the others
+	os.Setenv("DD_TEST_SECRET", "changed")
+	newConf, err = DecryptAgentConfig(conf, "test2")
+	require.NoError(t, err)
+	assert.Equal(t, "instances:\n- password: password1\n  user: password2\n", string(newConf))
+
+	info, err := GetDebugInfo()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"env@DD_TEST_SECRET": "env",
+		"pass2":              BackendCommandResolver,
+	}, info.SecretsResolvers)
+
+	var output bytes.Buffer
+	info.Print(&output)
+	assert.Contains(t, output.String(), "- env@DD_TEST_SECRET: from test, test2, resolved by env\n")
+}
+
+func TestDecryptBuiltinResolversNoCommand(t *testing.T) {
+	defer resetBuiltinResolvers()
+	setEnabledResolvers([]string{"env"})
+
+	os.Setenv("DD_TEST_SECRET", "password1")
+	defer os.Unsetenv("DD_TEST_SECRET")
+
+	// without secret_backend_command the other handles are left untouched
+	conf := []byte("password: ENC[env@DD_TEST_SECRET]\nuser: ENC[pass2]\n")
+	newConf, err := DecryptAgentConfig(conf, "test")
+	require.NoError(t, err)
+	assert.Equal(t, "password: password1\nuser: ENC[pass2]\n", string(newConf))
+
+	info, err := GetDebugInfo()
+	require.NoError(t, err)
+	assert.Empty(t, info.ExecutablePath)
+	var output bytes.Buffer
+	info.Print(&output)
+	assert.Contains(t, output.String(), "only the built-in resolvers are used")
+
+	_, err = DecryptAgentConfig([]byte("password: ENC[env@DD_TEST_SECRET_MISSING]\n"), "test")
+	assert.Error(t, err)
+}
+
+func TestDecryptBuiltinResolversChecks(t *testing.T) {
+	defer resetBuiltinResolvers()
+	setEnabledResolvers([]string{"env"})
+
+	os.Setenv("DD_TEST_SECRET", "password1")
+	defer os.Unsetenv("DD_TEST_SECRET")
+
+	// the configurations of the checks, from the annotations of the pods for instance,
+	// cannot use the built-in resolvers
+	conf := []byte("password: ENC[env@DD_TEST_SECRET]\n")
+	newConf, err := Decrypt(conf, "redis")
+	assert.Error(t, err)
+	assert.Nil(t, newConf)
+
+	// even when the secret was resolved for the configuration of the Agent
+	newConf, err = DecryptAgentConfig(conf, "datadog.yaml")
+	require.NoError(t, err)
+	assert.Equal(t, "password: password1\n", string(newConf))
+	_, err = Decrypt(conf, "redis")
+	assert.Error(t, err)
+
+	builtinResolversForChecks = true
+	newConf, err = Decrypt(conf, "redis")
+	require.NoError(t, err)
+	assert.Equal(t, "password: password1\n", string(newConf))
+}
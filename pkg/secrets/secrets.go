@@ -9,7 +9,9 @@ package secrets
 
 import (
 	"fmt"
+	"sort"
 	"strings"
+	"sync"
 
 	yaml "gopkg.in/yaml.v2"
 
@@ -18,6 +20,9 @@ import (
 )
 
 var (
+	// secretsLock protects the cache of the secrets, which is updated by Decrypt and by the
+	// periodic refresh of the secrets
+	secretsLock sync.Mutex
 	secretCache map[string]string
 	// list of handles and where they were found
 	secretOrigin map[string]common.StringSet
@@ -26,6 +31,9 @@ var (
 	secretBackendArguments             []string
 	secretBackendTimeout               = 5
 	secretBackendCommandAllowGroupExec bool
+	// builtinResolversForChecks allows the built-in resolvers in the configurations of the checks,
+	// which can come from the annotations of the pods or the labels of the containers
+	builtinResolversForChecks bool
 
 	// SecretBackendOutputMaxSize defines max size of the JSON output from a secrets reader backend
 	SecretBackendOutputMaxSize = 1024 * 1024
@@ -39,7 +47,7 @@ func init() {
 // Init initializes the command and other options of the secrets package. Since
 // this package is used by the 'config' package to decrypt itself we can't
 // directly use it.
-func Init(command string, arguments []string, timeout int, maxSize int, groupExecPerm bool) {
+func Init(command string, arguments []string, timeout int, maxSize int, groupExecPerm bool, builtinResolvers []string, allowBuiltinResolversForChecks bool) {
 	secretBackendCommand = command
 	secretBackendArguments = arguments
 	secretBackendTimeout = timeout
@@ -48,6 +56,8 @@ func Init(command string, arguments []string, timeout int, maxSize int, groupExe
 	if secretBackendCommandAllowGroupExec {
 		log.Warnf("Agent configuration relax permissions constraint on the secret backend cmd, Group can read and exec")
 	}
+	setEnabledResolvers(builtinResolvers)
+	builtinResolversForChecks = allowBuiltinResolversForChecks
 }
 
 type walkerCallback func(string) (string, error)
@@ -126,13 +136,30 @@ func isEnc(str string) (bool, string) {
 // testing purpose
 var secretFetcher = fetchSecret
 
-// Decrypt replaces all encrypted secrets in data by executing
+// Decrypt replaces all encrypted secrets in the configuration of a check by executing
 // "secret_backend_command" once if all secrets aren't present in the cache.
+// The handles prefixed by the name of a built-in resolver, `file@`, `env@` or `k8s@`,
+// are rejected unless `secret_backend_builtin_resolvers_for_checks` is set: the
+// configurations of the checks can be written by the owners of the workloads.
 func Decrypt(data []byte, origin string) ([]byte, error) {
-	if data == nil || secretBackendCommand == "" {
+	return decrypt(data, origin, builtinResolversForChecks)
+}
+
+// DecryptAgentConfig replaces all encrypted secrets in a configuration file of the Agent,
+// datadog.yaml for instance. The handles prefixed by the name of a built-in resolver are
+// resolved by the Agent itself.
+func DecryptAgentConfig(data []byte, origin string) ([]byte, error) {
+	return decrypt(data, origin, true)
+}
+
+func decrypt(data []byte, origin string, allowBuiltinResolvers bool) ([]byte, error) {
+	if data == nil || (secretBackendCommand == "" && len(enabledResolvers) == 0) {
 		return data, nil
 	}
 
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+
 	var config interface{}
 	err := yaml.Unmarshal(data, &config)
 	if err != nil {
@@ -141,10 +168,15 @@ func Decrypt(data []byte, origin string) ([]byte, error) {
 
 	// First we collect all new handles in the config
 	newHandles := []string{}
+	newBuiltinHandles := []string{}
 	haveSecret := false
 	err = walk(&config, func(str string) (string, error) {
 		if ok, handle := isEnc(str); ok {
 			haveSecret = true
+			// checked before the cache, which holds the secrets resolved for the Agent configuration
+			if name, _ := builtinResolver(handle); name != "" && !allowBuiltinResolvers {
+				return str, fmt.Errorf("the %s resolver of '%s' is not allowed in the configuration of %s, see secret_backend_builtin_resolvers_for_checks", name, handle, origin)
+			}
 			// Check if we already know this secret
 			if secret, ok := secretCache[handle]; ok {
 				log.Debugf("Secret '%s' was retrieved from cache", handle)
@@ -152,7 +184,11 @@ func Decrypt(data []byte, origin string) ([]byte, error) {
 				secretOrigin[handle].Add(origin)
 				return secret, nil
 			}
-			newHandles = append(newHandles, handle)
+			if name, _ := builtinResolver(handle); name != "" {
+				newBuiltinHandles = append(newBuiltinHandles, handle)
+			} else if secretBackendCommand != "" {
+				newHandles = append(newHandles, handle)
+			}
 		}
 		return str, nil
 	})
@@ -166,19 +202,36 @@ func Decrypt(data []byte, origin string) ([]byte, error) {
 	}
 
 	// check if any new secrets need to be fetch
-	if len(newHandles) != 0 {
-		secrets, err := secretFetcher(newHandles, origin)
-		if err != nil {
-			return nil, err
+	if len(newHandles) != 0 || len(newBuiltinHandles) != 0 {
+		secrets := map[string]string{}
+		if len(newBuiltinHandles) != 0 {
+			secrets, err = fetchBuiltinSecrets(newBuiltinHandles, origin)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if len(newHandles) != 0 {
+			fetched, err := secretFetcher(newHandles, origin)
+			if err != nil {
+				return nil, err
+			}
+			for handle, secret := range fetched {
+				secrets[handle] = secret
+			}
 		}
 
 		// Replace all new encrypted secrets in the config
 		err = walk(&config, func(str string) (string, error) {
 			if ok, handle := isEnc(str); ok {
 				if secret, ok := secrets[handle]; ok {
-					log.Debugf("Secret '%s' was retrieved from executable", handle)
+					log.Debugf("Secret '%s' was retrieved from %s", handle, resolverName(handle))
 					return secret, nil
 				}
+				if name, _ := builtinResolver(handle); name == "" && secretBackendCommand == "" {
+					// without secret_backend_command the handles of the other
+					// backends are left untouched
+					return str, nil
+				}
 				// This should never happen since fetchSecret will return an error
 				// if not every handles have been fetched.
 				return str, fmt.Errorf("unknown secret '%s'", handle)
@@ -199,15 +252,32 @@ func Decrypt(data []byte, origin string) ([]byte, error) {
 
 // GetDebugInfo exposes debug informations about secrets to be included in a flare
 func GetDebugInfo() (*SecretInfo, error) {
-	if secretBackendCommand == "" {
-		return nil, fmt.Errorf("No secret_backend_command set: secrets feature is not enabled")
+	if secretBackendCommand == "" && len(enabledResolvers) == 0 {
+		return nil, fmt.Errorf("No secret_backend_command set and no built-in resolver enabled: secrets feature is not enabled")
 	}
+	secretsLock.Lock()
+	defer secretsLock.Unlock()
+
 	info := &SecretInfo{ExecutablePath: secretBackendCommand}
-	info.populateRights()
+	if secretBackendCommand != "" {
+		info.populateRights()
+	}
 
 	info.SecretsHandles = map[string][]string{}
+	info.SecretsResolvers = map[string]string{}
 	for handle, originNames := range secretOrigin {
-		info.SecretsHandles[handle] = originNames.GetAll()
+		origins := originNames.GetAll()
+		sort.Strings(origins)
+		info.SecretsHandles[handle] = origins
+		info.SecretsResolvers[handle] = resolverName(handle)
 	}
 	return info, nil
 }
+
+// resolverName returns the name of the resolver which fetched the handle
+func resolverName(handle string) string {
+	if name, found := secretResolver[handle]; found {
+		return name
+	}
+	return BackendCommandResolver
+}
@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package serializer
+
+import (
+	"net/http"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// compressorSelector selects the compressor of the payloads of each endpoint
+type compressorSelector struct {
+	defaultCompressor compression.Compressor
+	byEndpoint        map[string]compression.Compressor
+	// fallback compresses the payloads of the endpoints which rejected their compressor
+	fallback compression.Compressor
+}
+
+// newCompressorSelectorFromConfig returns the compressorSelector configured with
+// `serializer_compressor_kind`, `serializer_compressor_level` and `serializer_compressor_kind_by_endpoint`.
+// The compressor selected at build time is used when they are not set or invalid.
+func newCompressorSelectorFromConfig() *compressorSelector {
+	level := config.Datadog.GetInt("serializer_compressor_level")
+	s := &compressorSelector{
+		defaultCompressor: newCompressorFromConfig("serializer_compressor_kind", config.Datadog.GetString("serializer_compressor_kind"), level),
+		byEndpoint:        make(map[string]compression.Compressor),
+	}
+	for endpoint, kind := range config.Datadog.GetStringMapString("serializer_compressor_kind_by_endpoint") {
+		s.byEndpoint[endpoint] = newCompressorFromConfig("serializer_compressor_kind_by_endpoint", kind, level)
+	}
+
+	// the default level of zlib is always valid
+	s.fallback, _ = compression.NewCompressor(compression.ZlibKind, 0)
+	return s
+}
+
+func newCompressorFromConfig(key string, kind string, level int) compression.Compressor {
+	if kind == "" {
+		return compression.DefaultCompressor()
+	}
+	compressor, err := compression.NewCompressor(kind, level)
+	if err != nil {
+		log.Errorf("Invalid %s: %s, the default compression will be used", key, err)
+		return compression.DefaultCompressor()
+	}
+	return compressor
+}
+
+// forEndpoint returns the compressor of the payloads sent to the endpoint of the given name,
+// zlib if the endpoint rejected its compressor
+func (s *compressorSelector) forEndpoint(endpoint string) compression.Compressor {
+	compressor, found := s.byEndpoint[endpoint]
+	if !found {
+		compressor = s.defaultCompressor
+	}
+	if compressor.ContentEncoding() != "" && compression.IsUnsupported(endpoint, compressor.ContentEncoding()) {
+		return s.fallback
+	}
+	return compressor
+}
+
+// withContentEncoding returns the extra headers of the payloads compressed with compressor
+func withContentEncoding(extraHeaders http.Header, compressor compression.Compressor) http.Header {
+	if extraHeaders.Get("Content-Encoding") == compressor.ContentEncoding() {
+		return extraHeaders
+	}
+	headers := extraHeaders.Clone()
+	if compressor.ContentEncoding() == "" {
+		headers.Del("Content-Encoding")
+	} else {
+		headers.Set("Content-Encoding", compressor.ContentEncoding())
+	}
+	return headers
+}
@@ -0,0 +1,106 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+//go:build test
+// +build test
+
+package serializer
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/forwarder"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
+)
+
+func TestCompressorSelector(t *testing.T) {
+	mockConfig := config.Mock()
+	mockConfig.Set("serializer_compressor_kind", "zstd")
+	mockConfig.Set("serializer_compressor_level", 5)
+	mockConfig.Set("serializer_compressor_kind_by_endpoint", map[string]string{"series_v1": "zlib"})
+	defer mockConfig.Set("serializer_compressor_kind", "")
+	defer mockConfig.Set("serializer_compressor_level", 0)
+	defer mockConfig.Set("serializer_compressor_kind_by_endpoint", map[string]string{})
+	defer compression.ResetUnsupported()
+
+	s := newCompressorSelectorFromConfig()
+	assert.Equal(t, compression.ZstdKind, s.forEndpoint("sketches_v2").Kind())
+	assert.Equal(t, compression.ZstdKind, s.forEndpoint("intake").Kind())
+	assert.Equal(t, compression.ZlibKind, s.forEndpoint("series_v1").Kind())
+
+	// the payloads of the endpoints rejecting zstd are compressed with zlib
+	compression.MarkUnsupported("sketches_v2", "zstd")
+	assert.Equal(t, compression.ZlibKind, s.forEndpoint("sketches_v2").Kind())
+	assert.Equal(t, compression.ZstdKind, s.forEndpoint("intake").Kind())
+}
+
+func TestCompressorSelectorInvalidConfig(t *testing.T) {
+	mockConfig := config.Mock()
+	mockConfig.Set("serializer_compressor_kind", "lz4")
+	mockConfig.Set("serializer_compressor_kind_by_endpoint", map[string]string{"series_v1": "zstd"})
+	mockConfig.Set("serializer_compressor_level", 42)
+	defer mockConfig.Set("serializer_compressor_kind", "")
+	defer mockConfig.Set("serializer_compressor_level", 0)
+	defer mockConfig.Set("serializer_compressor_kind_by_endpoint", map[string]string{})
+
+	s := newCompressorSelectorFromConfig()
+	assert.Equal(t, compression.DefaultCompressor(), s.forEndpoint("intake"))
+	assert.Equal(t, compression.DefaultCompressor(), s.forEndpoint("series_v1"))
+}
+
+func TestWithContentEncoding(t *testing.T) {
+	zstd, err := compression.NewCompressor(compression.ZstdKind, 0)
+	require.NoError(t, err)
+	none, err := compression.NewCompressor(compression.NoneKind, 0)
+	require.NoError(t, err)
+
+	extraHeaders := make(http.Header)
+	extraHeaders.Set("Content-Type", jsonContentType)
+	extraHeaders.Set("Content-Encoding", "deflate")
+
+	headers := withContentEncoding(extraHeaders, zstd)
+	assert.Equal(t, "zstd", headers.Get("Content-Encoding"))
+	assert.Equal(t, jsonContentType, headers.Get("Content-Type"))
+	// the shared headers are left untouched
+	assert.Equal(t, "deflate", extraHeaders.Get("Content-Encoding"))
+
+	headers = withContentEncoding(extraHeaders, none)
+	assert.NotContains(t, headers, "Content-Encoding")
+}
+
+func TestSendSketchZstd(t *testing.T) {
+	mockConfig := config.Mock()
+	mockConfig.Set("serializer_compressor_kind", "zstd")
+	mockConfig.Set("enable_sketch_stream_payload_serialization", false)
+	defer mockConfig.Set("serializer_compressor_kind", "")
+	defer mockConfig.Set("enable_sketch_stream_payload_serialization", true)
+
+	zstd, err := compression.NewCompressor(compression.ZstdKind, 0)
+	require.NoError(t, err)
+
+	var payloads forwarder.Payloads
+	f := &forwarder.MockedForwarder{}
+	f.On("SubmitSketchSeries", mock.Anything, mock.MatchedBy(func(headers http.Header) bool {
+		return headers.Get("Content-Encoding") == "zstd" && headers.Get("Content-Type") == protobufContentType
+	})).Run(func(args mock.Arguments) {
+		payloads = args.Get(0).(forwarder.Payloads)
+	}).Return(nil).Times(1)
+
+	s := NewSerializer(f, nil)
+	err = s.SendSketch(&testPayload{})
+	require.NoError(t, err)
+	f.AssertExpectations(t)
+
+	require.Len(t, payloads, 1)
+	decompressed, err := zstd.Decompress(*payloads[0])
+	require.NoError(t, err)
+	assert.Equal(t, protobufString, decompressed)
+}
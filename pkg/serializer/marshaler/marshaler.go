@@ -9,6 +9,8 @@ import (
 	"bytes"
 
 	jsoniter "github.com/json-iterator/go"
+
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 // Marshaler is an interface for metrics that are able to serialize themselves to JSON and protobuf
@@ -57,13 +59,22 @@ type BufferContext struct {
 	CompressorInput   *bytes.Buffer
 	CompressorOutput  *bytes.Buffer
 	PrecompressionBuf *bytes.Buffer
+	// Compressor compresses the payloads
+	Compressor compression.Compressor
 }
 
-// DefaultBufferContext initialize the default compression buffers
+// DefaultBufferContext initialize the default compression buffers, with the compressor
+// selected at build time, or zlib if it cannot compress streams
 func DefaultBufferContext() *BufferContext {
+	return NewBufferContext(compression.ForStreams(compression.DefaultCompressor()))
+}
+
+// NewBufferContext initialize the compression buffers of payloads compressed with compressor
+func NewBufferContext(compressor compression.Compressor) *BufferContext {
 	return &BufferContext{
-		bytes.NewBuffer(make([]byte, 0, 1024)),
-		bytes.NewBuffer(make([]byte, 0, 1024)),
-		bytes.NewBuffer(make([]byte, 0, 1024)),
+		CompressorInput:   bytes.NewBuffer(make([]byte, 0, 1024)),
+		CompressorOutput:  bytes.NewBuffer(make([]byte, 0, 1024)),
+		PrecompressionBuf: bytes.NewBuffer(make([]byte, 0, 1024)),
+		Compressor:        compressor,
 	}
 }
@@ -16,6 +16,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
+	"github.com/DataDog/datadog-agent/pkg/forwarder/endpoints"
 	"github.com/DataDog/datadog-agent/pkg/process/util/api/headers"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
 	"github.com/DataDog/datadog-agent/pkg/serializer/split"
@@ -107,6 +108,7 @@ type Serializer struct {
 	orchestratorForwarder forwarder.Forwarder
 
 	seriesJSONPayloadBuilder *stream.JSONPayloadBuilder
+	compressors              *compressorSelector
 
 	// Those variables allow users to blacklist any kind of payload
 	// from being sent by the agent. This was introduced for
@@ -131,6 +133,7 @@ func NewSerializer(forwarder forwarder.Forwarder, orchestratorForwarder forwarde
 		Forwarder:                     forwarder,
 		orchestratorForwarder:         orchestratorForwarder,
 		seriesJSONPayloadBuilder:      stream.NewJSONPayloadBuilder(config.Datadog.GetBool("enable_json_stream_shared_compressor_buffers")),
+		compressors:                   newCompressorSelectorFromConfig(),
 		enableEvents:                  config.Datadog.GetBool("enable_payloads.events"),
 		enableSeries:                  config.Datadog.GetBool("enable_payloads.series"),
 		enableServiceChecks:           config.Datadog.GetBool("enable_payloads.service_checks"),
@@ -161,37 +164,38 @@ func NewSerializer(forwarder forwarder.Forwarder, orchestratorForwarder forwarde
 	return s
 }
 
-func (s Serializer) serializePayload(payload marshaler.Marshaler, compress bool, useV1API bool) (forwarder.Payloads, http.Header, error) {
+// serializePayload serializes the payload, compressed with compressor if not nil
+func (s Serializer) serializePayload(payload marshaler.Marshaler, compressor compression.Compressor, useV1API bool) (forwarder.Payloads, http.Header, error) {
 	if useV1API {
-		return s.serializePayloadJSON(payload, compress)
+		return s.serializePayloadJSON(payload, compressor)
 	}
-	return s.serializePayloadProto(payload, compress)
+	return s.serializePayloadProto(payload, compressor)
 }
 
-func (s Serializer) serializePayloadJSON(payload marshaler.JSONMarshaler, compress bool) (forwarder.Payloads, http.Header, error) {
+func (s Serializer) serializePayloadJSON(payload marshaler.JSONMarshaler, compressor compression.Compressor) (forwarder.Payloads, http.Header, error) {
 	var extraHeaders http.Header
 
-	if compress {
-		extraHeaders = jsonExtraHeadersWithCompression
+	if compressor != nil {
+		extraHeaders = withContentEncoding(jsonExtraHeadersWithCompression, compressor)
 	} else {
 		extraHeaders = jsonExtraHeaders
 	}
 
-	return s.serializePayloadInternal(payload, compress, extraHeaders, split.JSONMarshalFct)
+	return s.serializePayloadInternal(payload, compressor, extraHeaders, split.JSONMarshalFct)
 }
 
-func (s Serializer) serializePayloadProto(payload marshaler.ProtoMarshaler, compress bool) (forwarder.Payloads, http.Header, error) {
+func (s Serializer) serializePayloadProto(payload marshaler.ProtoMarshaler, compressor compression.Compressor) (forwarder.Payloads, http.Header, error) {
 	var extraHeaders http.Header
-	if compress {
-		extraHeaders = protobufExtraHeadersWithCompression
+	if compressor != nil {
+		extraHeaders = withContentEncoding(protobufExtraHeadersWithCompression, compressor)
 	} else {
 		extraHeaders = protobufExtraHeaders
 	}
-	return s.serializePayloadInternal(payload, compress, extraHeaders, split.ProtoMarshalFct)
+	return s.serializePayloadInternal(payload, compressor, extraHeaders, split.ProtoMarshalFct)
 }
 
-func (s Serializer) serializePayloadInternal(payload marshaler.AbstractMarshaler, compress bool, extraHeaders http.Header, marshalFct split.MarshalFct) (forwarder.Payloads, http.Header, error) {
-	payloads, err := split.Payloads(payload, compress, marshalFct)
+func (s Serializer) serializePayloadInternal(payload marshaler.AbstractMarshaler, compressor compression.Compressor, extraHeaders http.Header, marshalFct split.MarshalFct) (forwarder.Payloads, http.Header, error) {
+	payloads, err := split.Payloads(payload, compressor, marshalFct)
 
 	if err != nil {
 		return nil, nil, fmt.Errorf("could not split payload into small enough chunks: %s", err)
@@ -200,9 +204,10 @@ func (s Serializer) serializePayloadInternal(payload marshaler.AbstractMarshaler
 	return payloads, extraHeaders, nil
 }
 
-func (s Serializer) serializeStreamablePayload(payload marshaler.StreamJSONMarshaler, policy stream.OnErrItemTooBigPolicy) (forwarder.Payloads, http.Header, error) {
-	payloads, err := s.seriesJSONPayloadBuilder.BuildWithOnErrItemTooBigPolicy(payload, policy)
-	return payloads, jsonExtraHeadersWithCompression, err
+func (s Serializer) serializeStreamablePayload(payload marshaler.StreamJSONMarshaler, compressor compression.Compressor, policy stream.OnErrItemTooBigPolicy) (forwarder.Payloads, http.Header, error) {
+	compressor = compression.ForStreams(compressor)
+	payloads, err := s.seriesJSONPayloadBuilder.BuildWithOnErrItemTooBigPolicy(payload, compressor, policy)
+	return payloads, withContentEncoding(jsonExtraHeadersWithCompression, compressor), err
 }
 
 // As events are gathered by SourceType, the serialization logic is more complex than for the other serializations.
@@ -215,9 +220,9 @@ func (s Serializer) serializeStreamablePayload(payload marshaler.StreamJSONMarsh
 //
 // If none of the previous methods work, we fallback to the old serialization method (Serializer.serializePayload).
 func (s Serializer) serializeEventsStreamJSONMarshalerPayload(
-	eventsStreamJSONMarshaler EventsStreamJSONMarshaler, useV1API bool) (forwarder.Payloads, http.Header, error) {
+	eventsStreamJSONMarshaler EventsStreamJSONMarshaler, compressor compression.Compressor, useV1API bool) (forwarder.Payloads, http.Header, error) {
 	marshaler := eventsStreamJSONMarshaler.CreateSingleMarshaler()
-	eventPayloads, extraHeaders, err := s.serializeStreamablePayload(marshaler, stream.FailOnErrItemTooBig)
+	eventPayloads, extraHeaders, err := s.serializeStreamablePayload(marshaler, compressor, stream.FailOnErrItemTooBig)
 
 	if err == stream.ErrItemTooBig {
 		expvarsSendEventsErrItemTooBigs.Add(1)
@@ -225,12 +230,12 @@ func (s Serializer) serializeEventsStreamJSONMarshalerPayload(
 		// Do not use CreateMarshalersBySourceType when there are too many source types (Performance issue).
 		if marshaler.Len() > maxItemCountForCreateMarshalersBySourceType {
 			expvarsSendEventsErrItemTooBigsFallback.Add(1)
-			eventPayloads, extraHeaders, err = s.serializePayload(eventsStreamJSONMarshaler, true, useV1API)
+			eventPayloads, extraHeaders, err = s.serializePayload(eventsStreamJSONMarshaler, compressor, useV1API)
 		} else {
 			eventPayloads = nil
 			for _, v := range eventsStreamJSONMarshaler.CreateMarshalersBySourceType() {
 				var eventPayloadsForSourceType forwarder.Payloads
-				eventPayloadsForSourceType, extraHeaders, err = s.serializeStreamablePayload(v, stream.DropItemOnErrItemTooBig)
+				eventPayloadsForSourceType, extraHeaders, err = s.serializeStreamablePayload(v, compressor, stream.DropItemOnErrItemTooBig)
 				if err != nil {
 					return nil, nil, err
 				}
@@ -253,10 +258,16 @@ func (s *Serializer) SendEvents(e EventsStreamJSONMarshaler) error {
 	var extraHeaders http.Header
 	var err error
 
+	endpoint := endpoints.EventsEndpoint
+	if useV1API {
+		endpoint = endpoints.V1IntakeEndpoint
+	}
+	compressor := s.compressors.forEndpoint(endpoint.Name)
+
 	if useV1API && s.enableEventsJSONStream {
-		eventPayloads, extraHeaders, err = s.serializeEventsStreamJSONMarshalerPayload(e, useV1API)
+		eventPayloads, extraHeaders, err = s.serializeEventsStreamJSONMarshalerPayload(e, compressor, useV1API)
 	} else {
-		eventPayloads, extraHeaders, err = s.serializePayload(e, true, useV1API)
+		eventPayloads, extraHeaders, err = s.serializePayload(e, compressor, useV1API)
 	}
 	if err != nil {
 		return fmt.Errorf("dropping event payload: %s", err)
@@ -281,10 +292,16 @@ func (s *Serializer) SendServiceChecks(sc marshaler.StreamJSONMarshaler) error {
 	var extraHeaders http.Header
 	var err error
 
+	endpoint := endpoints.ServiceChecksEndpoint
+	if useV1API {
+		endpoint = endpoints.V1CheckRunsEndpoint
+	}
+	compressor := s.compressors.forEndpoint(endpoint.Name)
+
 	if useV1API && s.enableServiceChecksJSONStream {
-		serviceCheckPayloads, extraHeaders, err = s.serializeStreamablePayload(sc, stream.DropItemOnErrItemTooBig)
+		serviceCheckPayloads, extraHeaders, err = s.serializeStreamablePayload(sc, compressor, stream.DropItemOnErrItemTooBig)
 	} else {
-		serviceCheckPayloads, extraHeaders, err = s.serializePayloadJSON(sc, true)
+		serviceCheckPayloads, extraHeaders, err = s.serializePayloadJSON(sc, compressor)
 	}
 	if err != nil {
 		return fmt.Errorf("dropping service check payload: %s", err)
@@ -309,13 +326,20 @@ func (s *Serializer) SendSeries(series marshaler.StreamJSONMarshaler) error {
 	var extraHeaders http.Header
 	var err error
 
+	endpoint := endpoints.SeriesEndpoint
+	if useV1API {
+		endpoint = endpoints.V1SeriesEndpoint
+	}
+	compressor := s.compressors.forEndpoint(endpoint.Name)
+
 	if useV1API && s.enableJSONStream {
-		seriesPayloads, extraHeaders, err = s.serializeStreamablePayload(series, stream.DropItemOnErrItemTooBig)
+		seriesPayloads, extraHeaders, err = s.serializeStreamablePayload(series, compressor, stream.DropItemOnErrItemTooBig)
 	} else if useV1API && !s.enableJSONStream {
-		seriesPayloads, extraHeaders, err = s.serializePayloadJSON(series, true)
+		seriesPayloads, extraHeaders, err = s.serializePayloadJSON(series, compressor)
 	} else {
-		seriesPayloads, err = series.MarshalSplitCompress(marshaler.DefaultBufferContext())
-		extraHeaders = protobufExtraHeadersWithCompression
+		compressor = compression.ForStreams(compressor)
+		seriesPayloads, err = series.MarshalSplitCompress(marshaler.NewBufferContext(compressor))
+		extraHeaders = withContentEncoding(protobufExtraHeadersWithCompression, compressor)
 	}
 
 	if err != nil {
@@ -335,17 +359,18 @@ func (s *Serializer) SendSketch(sketches marshaler.Marshaler) error {
 		return nil
 	}
 
+	compressor := s.compressors.forEndpoint(endpoints.SketchSeriesEndpoint.Name)
 	if s.enableSketchProtobufStream {
-		payloads, err := sketches.MarshalSplitCompress(marshaler.DefaultBufferContext())
+		streamCompressor := compression.ForStreams(compressor)
+		payloads, err := sketches.MarshalSplitCompress(marshaler.NewBufferContext(streamCompressor))
 		if err == nil {
-			return s.Forwarder.SubmitSketchSeries(payloads, protobufExtraHeadersWithCompression)
+			return s.Forwarder.SubmitSketchSeries(payloads, withContentEncoding(protobufExtraHeadersWithCompression, streamCompressor))
 		}
 		log.Warnf("Error: %v trying to stream compress SketchSeriesList - falling back to split/compress method", err)
 	}
 
-	compress := true
 	useV1API := false // Sketches only have a v2 endpoint
-	splitSketches, extraHeaders, err := s.serializePayload(sketches, compress, useV1API)
+	splitSketches, extraHeaders, err := s.serializePayload(sketches, compressor, useV1API)
 	if err != nil {
 		return fmt.Errorf("dropping sketch payload: %s", err)
 	}
@@ -369,7 +394,9 @@ func (s *Serializer) SendAgentchecksMetadata(m marshaler.JSONMarshaler) error {
 }
 
 func (s *Serializer) sendMetadata(m marshaler.JSONMarshaler, submit func(payload forwarder.Payloads, extra http.Header) error) error {
-	mustSplit, compressedPayload, payload, err := split.CheckSizeAndSerialize(m, true, split.JSONMarshalFct)
+	// metadata payloads are sent to the v1 intake endpoint
+	compressor := s.compressors.forEndpoint(endpoints.V1IntakeEndpoint.Name)
+	mustSplit, compressedPayload, payload, err := split.CheckSizeAndSerialize(m, compressor, split.JSONMarshalFct)
 	if err != nil {
 		return fmt.Errorf("could not determine size of metadata payload: %s", err)
 	}
@@ -380,7 +407,7 @@ func (s *Serializer) sendMetadata(m marshaler.JSONMarshaler, submit func(payload
 		return fmt.Errorf("metadata payload was too big to send (%d bytes compressed, %d bytes uncompressed), metadata payloads cannot be split", len(compressedPayload), len(payload))
 	}
 
-	if err := submit(forwarder.Payloads{&compressedPayload}, jsonExtraHeadersWithCompression); err != nil {
+	if err := submit(forwarder.Payloads{&compressedPayload}, withContentEncoding(jsonExtraHeadersWithCompression, compressor)); err != nil {
 		return err
 	}
 
@@ -400,11 +427,12 @@ func (s *Serializer) SendProcessesMetadata(data interface{}) error {
 	if err != nil {
 		return fmt.Errorf("could not serialize processes metadata payload: %s", err)
 	}
-	compressedPayload, err := compression.Compress(nil, payload)
+	compressor := s.compressors.forEndpoint(endpoints.V1IntakeEndpoint.Name)
+	compressedPayload, err := compressor.Compress(payload)
 	if err != nil {
 		return fmt.Errorf("could not compress processes metadata payload: %s", err)
 	}
-	if err := s.Forwarder.SubmitV1Intake(forwarder.Payloads{&compressedPayload}, jsonExtraHeadersWithCompression); err != nil {
+	if err := s.Forwarder.SubmitV1Intake(forwarder.Payloads{&compressedPayload}, withContentEncoding(jsonExtraHeadersWithCompression, compressor)); err != nil {
 		return err
 	}
 
@@ -314,7 +314,9 @@ func TestSendV1Series(t *testing.T) {
 
 func TestSendSeries(t *testing.T) {
 	f := &forwarder.MockedForwarder{}
-	f.On("SubmitSeries", protobufPayloads, protobufExtraHeadersWithCompression).Return(nil).Times(1)
+	// the series are compressed by streams
+	extraHeaders := withContentEncoding(protobufExtraHeadersWithCompression, compression.ForStreams(compression.DefaultCompressor()))
+	f.On("SubmitSeries", protobufPayloads, extraHeaders).Return(nil).Times(1)
 	config.Datadog.Set("use_v2_api.series", true)
 	defer config.Datadog.Set("use_v2_api.series", false)
 
@@ -30,20 +30,25 @@ func TestSplitPayloadsSeries(t *testing.T) {
 	defer func() { maxPayloadSizeUnCompressed = prevMaxPayloadSizeUnCompressed }()
 
 	t.Run("both compressed and uncompressed series payload under limits", func(t *testing.T) {
-		testSplitPayloadsSeries(t, 2, false)
+		testSplitPayloadsSeries(t, 2, nil)
 	})
 	t.Run("compressed series payload over limit but uncompressed under limit", func(t *testing.T) {
-		testSplitPayloadsSeries(t, 5, false)
+		testSplitPayloadsSeries(t, 5, nil)
 	})
 	t.Run("both compressed and uncompressed series payload over limits", func(t *testing.T) {
-		testSplitPayloadsSeries(t, 8, false)
+		testSplitPayloadsSeries(t, 8, nil)
 	})
 	t.Run("compressed series payload under limit and uncompressed series payload over limit", func(t *testing.T) {
-		testSplitPayloadsSeries(t, 8, true)
+		testSplitPayloadsSeries(t, 8, compression.DefaultCompressor())
+	})
+	t.Run("zstd compressed series payload under limit and uncompressed series payload over limit", func(t *testing.T) {
+		zstd, err := compression.NewCompressor(compression.ZstdKind, 0)
+		require.NoError(t, err)
+		testSplitPayloadsSeries(t, 8, zstd)
 	})
 }
 
-func testSplitPayloadsSeries(t *testing.T, numPoints int, compress bool) {
+func testSplitPayloadsSeries(t *testing.T, numPoints int, compressor compression.Compressor) {
 	testSeries := metrics.Series{}
 	for i := 0; i < numPoints; i++ {
 		point := metrics.Serie{
@@ -69,7 +74,7 @@ func testSplitPayloadsSeries(t *testing.T, numPoints int, compress bool) {
 		testSeries = append(testSeries, &point)
 	}
 
-	payloads, err := Payloads(testSeries, compress, JSONMarshalFct)
+	payloads, err := Payloads(testSeries, compressor, JSONMarshalFct)
 	require.Nil(t, err)
 
 	originalLength := len(testSeries)
@@ -77,8 +82,8 @@ func testSplitPayloadsSeries(t *testing.T, numPoints int, compress bool) {
 	for _, payload := range payloads {
 		var s = map[string]metrics.Series{}
 
-		if compress {
-			*payload, err = compression.Decompress(nil, *payload)
+		if compressor != nil {
+			*payload, err = compressor.Decompress(*payload)
 			require.Nil(t, err)
 		}
 
@@ -119,7 +124,7 @@ func BenchmarkSplitPayloadsSeries(b *testing.B) {
 	for n := 0; n < b.N; n++ {
 		// always record the result of Payloads to prevent
 		// the compiler eliminating the function call.
-		r, _ = Payloads(testSeries, true, JSONMarshalFct)
+		r, _ = Payloads(testSeries, compression.DefaultCompressor(), JSONMarshalFct)
 
 	}
 	// ensure we actually had to split
@@ -153,20 +158,20 @@ func TestSplitPayloadsEvents(t *testing.T) {
 	defer func() { maxPayloadSizeUnCompressed = prevMaxPayloadSizeUnCompressed }()
 
 	t.Run("both compressed and uncompressed event payload under limits", func(t *testing.T) {
-		testSplitPayloadsEvents(t, 2, false)
+		testSplitPayloadsEvents(t, 2, nil)
 	})
 	t.Run("compressed event payload over limit but uncompressed under limit", func(t *testing.T) {
-		testSplitPayloadsEvents(t, 6, false)
+		testSplitPayloadsEvents(t, 6, nil)
 	})
 	t.Run("both compressed and uncompressed event payload over limits", func(t *testing.T) {
-		testSplitPayloadsEvents(t, 15, false)
+		testSplitPayloadsEvents(t, 15, nil)
 	})
 	t.Run("compressed event payload under limit and uncompressed event payload over limit", func(t *testing.T) {
-		testSplitPayloadsEvents(t, 15, true)
+		testSplitPayloadsEvents(t, 15, compression.DefaultCompressor())
 	})
 }
 
-func testSplitPayloadsEvents(t *testing.T, numPoints int, compress bool) {
+func testSplitPayloadsEvents(t *testing.T, numPoints int, compressor compression.Compressor) {
 	testEvent := metrics.Events{}
 	for i := 0; i < numPoints; i++ {
 		event := metrics.Event{
@@ -181,7 +186,7 @@ func testSplitPayloadsEvents(t *testing.T, numPoints int, compress bool) {
 		testEvent = append(testEvent, &event)
 	}
 
-	payloads, err := Payloads(testEvent, compress, JSONMarshalFct)
+	payloads, err := Payloads(testEvent, compressor, JSONMarshalFct)
 	require.Nil(t, err)
 
 	originalLength := len(testEvent)
@@ -189,8 +194,8 @@ func testSplitPayloadsEvents(t *testing.T, numPoints int, compress bool) {
 	for _, payload := range payloads {
 		var s map[string]interface{}
 
-		if compress {
-			*payload, err = compression.Decompress(nil, *payload)
+		if compressor != nil {
+			*payload, err = compressor.Decompress(*payload)
 			require.Nil(t, err)
 		}
 
@@ -219,20 +224,20 @@ func TestSplitPayloadsServiceChecks(t *testing.T) {
 	defer func() { maxPayloadSizeUnCompressed = prevMaxPayloadSizeUnCompressed }()
 
 	t.Run("both compressed and uncompressed service checks payload under limits", func(t *testing.T) {
-		testSplitPayloadsServiceChecks(t, 5, false)
+		testSplitPayloadsServiceChecks(t, 5, nil)
 	})
 	t.Run("compressed service checks payload over limit but uncompressed under limit", func(t *testing.T) {
-		testSplitPayloadsServiceChecks(t, 10, false)
+		testSplitPayloadsServiceChecks(t, 10, nil)
 	})
 	t.Run("both compressed and uncompressed service checks payload over limits", func(t *testing.T) {
-		testSplitPayloadsServiceChecks(t, 20, false)
+		testSplitPayloadsServiceChecks(t, 20, nil)
 	})
 	t.Run("compressed service checks payload under limit and uncompressed service checks payload over limit", func(t *testing.T) {
-		testSplitPayloadsServiceChecks(t, 20, true)
+		testSplitPayloadsServiceChecks(t, 20, compression.DefaultCompressor())
 	})
 }
 
-func testSplitPayloadsServiceChecks(t *testing.T, numPoints int, compress bool) {
+func testSplitPayloadsServiceChecks(t *testing.T, numPoints int, compressor compression.Compressor) {
 	testServiceChecks := metrics.ServiceChecks{}
 	for i := 0; i < numPoints; i++ {
 		sc := metrics.ServiceCheck{
@@ -246,7 +251,7 @@ func testSplitPayloadsServiceChecks(t *testing.T, numPoints int, compress bool)
 		testServiceChecks = append(testServiceChecks, &sc)
 	}
 
-	payloads, err := Payloads(testServiceChecks, compress, JSONMarshalFct)
+	payloads, err := Payloads(testServiceChecks, compressor, JSONMarshalFct)
 	require.Nil(t, err)
 
 	originalLength := len(testServiceChecks)
@@ -254,8 +259,8 @@ func testSplitPayloadsServiceChecks(t *testing.T, numPoints int, compress bool)
 	for _, payload := range payloads {
 		var s []interface{}
 
-		if compress {
-			*payload, err = compression.Decompress(nil, *payload)
+		if compressor != nil {
+			*payload, err = compressor.Decompress(*payload)
 			require.Nil(t, err)
 		}
 
@@ -282,34 +287,34 @@ func TestSplitPayloadsSketches(t *testing.T) {
 	defer func() { maxPayloadSizeUnCompressed = prevMaxPayloadSizeUnCompressed }()
 
 	t.Run("both compressed and uncompressed sketch payload under limits", func(t *testing.T) {
-		testSplitPayloadsSketches(t, 2, false)
+		testSplitPayloadsSketches(t, 2, nil)
 	})
 	t.Run("compressed sketch payload over limit but uncompressed under limit", func(t *testing.T) {
-		testSplitPayloadsSketches(t, 3, false)
+		testSplitPayloadsSketches(t, 3, nil)
 	})
 	t.Run("both compressed and uncompressed sketch payload over limits", func(t *testing.T) {
-		testSplitPayloadsSketches(t, 8, false)
+		testSplitPayloadsSketches(t, 8, nil)
 	})
 	t.Run("compressed sketch payload under limit and uncompressed sketch payload over limit", func(t *testing.T) {
-		testSplitPayloadsSketches(t, 8, true)
+		testSplitPayloadsSketches(t, 8, compression.DefaultCompressor())
 	})
 }
 
-func testSplitPayloadsSketches(t *testing.T, numPoints int, compress bool) {
+func testSplitPayloadsSketches(t *testing.T, numPoints int, compressor compression.Compressor) {
 	testSketchSeries := make(metrics.SketchSeriesList, numPoints)
 	for i := 0; i < numPoints; i++ {
 		testSketchSeries[i] = metrics.Makeseries(i)
 	}
 
-	payloads, err := Payloads(testSketchSeries, compress, JSONMarshalFct)
+	payloads, err := Payloads(testSketchSeries, compressor, JSONMarshalFct)
 	require.Nil(t, err)
 
 	var splitSketches = []metrics.SketchSeriesList{}
 	for _, payload := range payloads {
 		var s = map[string]metrics.SketchSeriesList{}
 
-		if compress {
-			*payload, err = compression.Decompress(nil, *payload)
+		if compressor != nil {
+			*payload, err = compressor.Decompress(*payload)
 			require.Nil(t, err)
 		}
 
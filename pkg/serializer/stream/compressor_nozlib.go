@@ -11,6 +11,8 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 const (
@@ -30,7 +32,7 @@ var (
 type Compressor struct{}
 
 // NewCompressor not implemented
-func NewCompressor(input, output *bytes.Buffer, header, footer []byte, separator []byte) (*Compressor, error) {
+func NewCompressor(compressor compression.Compressor, input, output *bytes.Buffer, header, footer []byte, separator []byte) (*Compressor, error) {
 	return nil, fmt.Errorf("not implemented")
 }
 
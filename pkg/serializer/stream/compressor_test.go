@@ -20,6 +20,7 @@ import (
 
 	"github.com/DataDog/datadog-agent/pkg/config"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 )
 
 var (
@@ -104,7 +105,7 @@ func payloadToString(payload []byte) string {
 }
 
 func TestCompressorSimple(t *testing.T) {
-	c, err := NewCompressor(&bytes.Buffer{}, &bytes.Buffer{}, []byte("{["), []byte("]}"), []byte(","))
+	c, err := NewCompressor(compression.DefaultCompressor(), &bytes.Buffer{}, &bytes.Buffer{}, []byte("{["), []byte("]}"), []byte(","))
 	require.NoError(t, err)
 
 	for i := 0; i < 5; i++ {
@@ -183,3 +184,50 @@ func TestLockedCompressorProducesSamePayloads(t *testing.T) {
 
 	require.Equal(t, payloadToString(*payloads1[0]), payloadToString(*payloads2[0]))
 }
+
+func TestCompressorGzip(t *testing.T) {
+	gzip, err := compression.NewCompressor(compression.GzipKind, 3)
+	require.NoError(t, err)
+
+	c, err := NewCompressor(gzip, &bytes.Buffer{}, &bytes.Buffer{}, []byte("{["), []byte("]}"), []byte(","))
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		c.AddItem([]byte("A"))
+	}
+
+	p, err := c.Close()
+	require.NoError(t, err)
+	decompressed, err := gzip.Decompress(p)
+	require.NoError(t, err)
+	require.Equal(t, "{[A,A,A,A,A]}", string(decompressed))
+}
+
+func TestTwoPayloadCompressorLevel(t *testing.T) {
+	m := &dummyMarshaller{
+		items:  []string{"A", "B", "C", "D", "E", "F"},
+		header: "{[",
+		footer: "]}",
+	}
+	config.Datadog.SetDefault("serializer_max_payload_size", 22)
+	defer resetDefaults()
+
+	zlib, err := compression.NewCompressor(compression.ZlibKind, 9)
+	require.NoError(t, err)
+
+	builder := NewJSONPayloadBuilder(true)
+	payloads, err := builder.BuildWithOnErrItemTooBigPolicy(m, zlib, DropItemOnErrItemTooBig)
+	require.NoError(t, err)
+	require.True(t, len(payloads) > 1)
+
+	var items []byte
+	for _, payload := range payloads {
+		decompressed, err := zlib.Decompress(*payload)
+		require.NoError(t, err)
+		require.True(t, bytes.HasPrefix(decompressed, []byte("{[")))
+		require.True(t, bytes.HasSuffix(decompressed, []byte("]}")))
+		items = append(items, bytes.Trim(decompressed, "{[]}")...)
+		items = append(items, ',')
+	}
+	require.Equal(t, "A,B,C,D,E,F,", string(items))
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/DataDog/datadog-agent/pkg/forwarder"
 	"github.com/DataDog/datadog-agent/pkg/serializer/marshaler"
 	"github.com/DataDog/datadog-agent/pkg/telemetry"
+	"github.com/DataDog/datadog-agent/pkg/util/compression"
 	"github.com/DataDog/datadog-agent/pkg/util/log"
 )
 
@@ -96,14 +97,16 @@ const (
 	FailOnErrItemTooBig
 )
 
-// Build serializes a metadata payload and sends it to the forwarder
+// Build serializes a metadata payload, compressed with the default compressor or zlib if it cannot
+// compress streams, and sends it to the forwarder
 func (b *JSONPayloadBuilder) Build(m marshaler.StreamJSONMarshaler) (forwarder.Payloads, error) {
-	return b.BuildWithOnErrItemTooBigPolicy(m, DropItemOnErrItemTooBig)
+	return b.BuildWithOnErrItemTooBigPolicy(m, compression.ForStreams(compression.DefaultCompressor()), DropItemOnErrItemTooBig)
 }
 
-// BuildWithOnErrItemTooBigPolicy serializes a metadata payload and sends it to the forwarder
+// BuildWithOnErrItemTooBigPolicy serializes a metadata payload, compressed with compressor, and sends it to the forwarder
 func (b *JSONPayloadBuilder) BuildWithOnErrItemTooBigPolicy(
 	m marshaler.StreamJSONMarshaler,
+	compressor compression.Compressor,
 	policy OnErrItemTooBigPolicy) (forwarder.Payloads, error) {
 
 	var input, output *bytes.Buffer
@@ -151,7 +154,7 @@ func (b *JSONPayloadBuilder) BuildWithOnErrItemTooBigPolicy(
 		return nil, err
 	}
 
-	compressor, err := NewCompressor(input, output, header.Bytes(), footer.Bytes(), []byte(","))
+	payloadCompressor, err := NewCompressor(compressor, input, output, header.Bytes(), footer.Bytes(), []byte(","))
 	if err != nil {
 		return nil, err
 	}
@@ -169,19 +172,19 @@ func (b *JSONPayloadBuilder) BuildWithOnErrItemTooBigPolicy(
 			continue
 		}
 
-		switch compressor.AddItem(jsonStream.Buffer()) {
+		switch payloadCompressor.AddItem(jsonStream.Buffer()) {
 		case ErrPayloadFull:
 			expvarsPayloadFulls.Add(1)
 			tlmPayloadFull.Inc()
 			// payload is full, we need to create a new one
-			payload, err := compressor.Close()
+			payload, err := payloadCompressor.Close()
 			if err != nil {
 				return payloads, err
 			}
 			payloads = append(payloads, &payload)
 			input.Reset()
 			output.Reset()
-			compressor, err = NewCompressor(input, output, header.Bytes(), footer.Bytes(), []byte(","))
+			payloadCompressor, err = NewCompressor(compressor, input, output, header.Bytes(), footer.Bytes(), []byte(","))
 			if err != nil {
 				return nil, err
 			}
@@ -207,7 +210,7 @@ func (b *JSONPayloadBuilder) BuildWithOnErrItemTooBigPolicy(
 	}
 
 	// Close last payload
-	payload, err := compressor.Close()
+	payload, err := payloadCompressor.Close()
 	if err != nil {
 		return payloads, err
 	}
@@ -0,0 +1,213 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// Kinds of compression which can be selected at runtime
+const (
+	ZlibKind = "zlib"
+	ZstdKind = "zstd"
+	GzipKind = "gzip"
+	NoneKind = "none"
+)
+
+// Compressor compresses payloads with an algorithm selected at runtime, unlike the
+// Compress and Decompress functions of this package which use the algorithm selected
+// at build time.
+type Compressor interface {
+	// Kind returns the kind of compression, ZlibKind for instance
+	Kind() string
+	// ContentEncoding returns the value of the Content-Encoding HTTP header of the
+	// compressed payloads, empty if they are not compressed
+	ContentEncoding() string
+	Compress(src []byte) ([]byte, error)
+	Decompress(src []byte) ([]byte, error)
+	// CompressBound returns the worst case size of the compressed data
+	CompressBound(sourceLen int) int
+	// NewStreamCompressor returns a StreamCompressor writing the compressed data to output
+	NewStreamCompressor(output *bytes.Buffer) (StreamCompressor, error)
+}
+
+// StreamCompressor compresses the data written to it
+type StreamCompressor interface {
+	io.WriteCloser
+	// Flush writes the data compressed so far to the output
+	Flush() error
+}
+
+// NewCompressor returns the Compressor of the given kind. level is specific to the
+// algorithm, 0 selects its default level.
+func NewCompressor(kind string, level int) (Compressor, error) {
+	switch kind {
+	case ZlibKind:
+		if level == 0 {
+			level = zlib.DefaultCompression
+		} else if level < zlib.HuffmanOnly || level > zlib.BestCompression {
+			return nil, fmt.Errorf("invalid zlib compression level %d", level)
+		}
+		return &zlibCompressor{level: level}, nil
+	case ZstdKind:
+		return newZstdCompressor(level)
+	case GzipKind:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return NewGzipCompressor(level)
+	case NoneKind:
+		return noneCompressor{}, nil
+	default:
+		return nil, fmt.Errorf("unknown compression %q, expected %q, %q, %q or %q", kind, ZlibKind, ZstdKind, GzipKind, NoneKind)
+	}
+}
+
+// NewGzipCompressor returns a gzip Compressor of the given level, unlike NewCompressor
+// 0 is gzip.NoCompression.
+func NewGzipCompressor(level int) (Compressor, error) {
+	if level < gzip.HuffmanOnly || level > gzip.BestCompression {
+		return nil, fmt.Errorf("invalid gzip compression level %d", level)
+	}
+	return &gzipCompressor{level: level}, nil
+}
+
+// NewCompressorForContentEncoding returns a Compressor able to decompress the payloads
+// sent with the given Content-Encoding HTTP header
+func NewCompressorForContentEncoding(contentEncoding string) (Compressor, error) {
+	switch contentEncoding {
+	case "deflate":
+		return NewCompressor(ZlibKind, 0)
+	case "zstd":
+		return NewCompressor(ZstdKind, 0)
+	case "gzip":
+		return NewCompressor(GzipKind, 0)
+	case "", "identity":
+		return NewCompressor(NoneKind, 0)
+	default:
+		return nil, fmt.Errorf("unknown content encoding %q", contentEncoding)
+	}
+}
+
+// streamlessCompressor is implemented by the Compressors which cannot compress streams
+type streamlessCompressor interface {
+	noStreams()
+}
+
+// ForStreams returns compressor if it can compress streams, and a zlib Compressor otherwise.
+// The payloads compressed by streams must use the ContentEncoding of the returned Compressor.
+func ForStreams(compressor Compressor) Compressor {
+	if _, streamless := compressor.(streamlessCompressor); streamless {
+		// the default level of zlib is always valid
+		zlibCompressor, _ := NewCompressor(ZlibKind, 0)
+		return zlibCompressor
+	}
+	return compressor
+}
+
+type zlibCompressor struct {
+	level int
+}
+
+func (c *zlibCompressor) Kind() string            { return ZlibKind }
+func (c *zlibCompressor) ContentEncoding() string { return "deflate" }
+
+func (c *zlibCompressor) Compress(src []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := zlib.NewWriterLevel(&b, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (c *zlibCompressor) Decompress(src []byte) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (c *zlibCompressor) CompressBound(sourceLen int) int {
+	// From https://code.woboq.org/gcc/zlib/compress.c.html#compressBound
+	return sourceLen + (sourceLen >> 12) + (sourceLen >> 14) + (sourceLen >> 25) + 13
+}
+
+func (c *zlibCompressor) NewStreamCompressor(output *bytes.Buffer) (StreamCompressor, error) {
+	return zlib.NewWriterLevel(output, c.level)
+}
+
+type gzipCompressor struct {
+	level int
+}
+
+func (c *gzipCompressor) Kind() string            { return GzipKind }
+func (c *gzipCompressor) ContentEncoding() string { return "gzip" }
+
+func (c *gzipCompressor) Compress(src []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := gzip.NewWriterLevel(&b, c.level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(src); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (c *gzipCompressor) Decompress(src []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func (c *gzipCompressor) CompressBound(sourceLen int) int {
+	// the deflate bound plus the gzip header and trailer
+	return sourceLen + (sourceLen >> 12) + (sourceLen >> 14) + (sourceLen >> 25) + 13 + 18
+}
+
+func (c *gzipCompressor) NewStreamCompressor(output *bytes.Buffer) (StreamCompressor, error) {
+	return gzip.NewWriterLevel(output, c.level)
+}
+
+type noneCompressor struct{}
+
+func (noneCompressor) Kind() string                          { return NoneKind }
+func (noneCompressor) ContentEncoding() string               { return "" }
+func (noneCompressor) Compress(src []byte) ([]byte, error)   { return src, nil }
+func (noneCompressor) Decompress(src []byte) ([]byte, error) { return src, nil }
+func (noneCompressor) CompressBound(sourceLen int) int       { return sourceLen }
+
+func (noneCompressor) NewStreamCompressor(output *bytes.Buffer) (StreamCompressor, error) {
+	return nopStreamCompressor{output}, nil
+}
+
+type nopStreamCompressor struct {
+	io.Writer
+}
+
+func (nopStreamCompressor) Flush() error { return nil }
+func (nopStreamCompressor) Close() error { return nil }
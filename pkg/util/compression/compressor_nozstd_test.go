@@ -0,0 +1,21 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build !cgo
+
+package compression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZstdCompressorUnsupported(t *testing.T) {
+	_, err := NewCompressor(ZstdKind, 0)
+	assert.Error(t, err)
+	_, err = NewCompressorForContentEncoding("zstd")
+	assert.Error(t, err)
+}
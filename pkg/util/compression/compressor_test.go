@@ -0,0 +1,122 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"bytes"
+	"compress/gzip"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressors(t *testing.T) {
+	payload := []byte(strings.Repeat(`{"metric":"system.cpu.user","points":[[1600000000,12.5]]},`, 100))
+
+	for _, kind := range []string{ZlibKind, GzipKind, NoneKind} {
+		t.Run(kind, func(t *testing.T) {
+			c, err := NewCompressor(kind, 0)
+			require.NoError(t, err)
+			assert.Equal(t, kind, c.Kind())
+
+			compressed, err := c.Compress(payload)
+			require.NoError(t, err)
+			assert.True(t, len(compressed) <= c.CompressBound(len(payload)))
+
+			decompressed, err := c.Decompress(compressed)
+			require.NoError(t, err)
+			assert.Equal(t, payload, decompressed)
+
+			// the payloads can be decompressed from their Content-Encoding
+			d, err := NewCompressorForContentEncoding(c.ContentEncoding())
+			require.NoError(t, err)
+			decompressed, err = d.Decompress(compressed)
+			require.NoError(t, err)
+			assert.Equal(t, payload, decompressed)
+		})
+	}
+}
+
+func TestStreamCompressors(t *testing.T) {
+	for _, kind := range []string{ZlibKind, GzipKind, NoneKind} {
+		t.Run(kind, func(t *testing.T) {
+			c, err := NewCompressor(kind, 1)
+			require.NoError(t, err)
+
+			var output bytes.Buffer
+			w, err := c.NewStreamCompressor(&output)
+			require.NoError(t, err)
+			_, err = w.Write([]byte("hello "))
+			require.NoError(t, err)
+			require.NoError(t, w.Flush())
+			_, err = w.Write([]byte("world"))
+			require.NoError(t, err)
+			require.NoError(t, w.Close())
+
+			decompressed, err := c.Decompress(output.Bytes())
+			require.NoError(t, err)
+			assert.Equal(t, "hello world", string(decompressed))
+		})
+	}
+}
+
+func TestForStreams(t *testing.T) {
+	c := ForStreams(DefaultCompressor())
+
+	var output bytes.Buffer
+	w, err := c.NewStreamCompressor(&output)
+	require.NoError(t, err)
+	_, err = w.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	d, err := NewCompressorForContentEncoding(c.ContentEncoding())
+	require.NoError(t, err)
+	decompressed, err := d.Decompress(output.Bytes())
+	require.NoError(t, err)
+	assert.Equal(t, "hello", string(decompressed))
+
+	// the compressors able to compress streams are kept
+	gzip, err := NewCompressor(GzipKind, 0)
+	require.NoError(t, err)
+	assert.Equal(t, gzip, ForStreams(gzip))
+}
+
+func TestGzipNoCompression(t *testing.T) {
+	payload := []byte(strings.Repeat("a", 1000))
+	c, err := NewGzipCompressor(gzip.NoCompression)
+	require.NoError(t, err)
+	compressed, err := c.Compress(payload)
+	require.NoError(t, err)
+	// the data is stored as is
+	assert.True(t, len(compressed) > len(payload))
+}
+
+func TestNewCompressorErrors(t *testing.T) {
+	_, err := NewCompressor("lz4", 0)
+	assert.Error(t, err)
+	_, err = NewCompressor(ZlibKind, 10)
+	assert.Error(t, err)
+	_, err = NewCompressor(ZstdKind, 21)
+	assert.Error(t, err)
+	_, err = NewCompressor(GzipKind, -3)
+	assert.Error(t, err)
+	_, err = NewCompressorForContentEncoding("br")
+	assert.Error(t, err)
+}
+
+func TestUnsupported(t *testing.T) {
+	defer ResetUnsupported()
+
+	assert.False(t, IsUnsupported("series_v1", "zstd"))
+	assert.True(t, MarkUnsupported("series_v1", "zstd"))
+	assert.False(t, MarkUnsupported("series_v1", "zstd"))
+	assert.True(t, IsUnsupported("series_v1", "zstd"))
+	assert.False(t, IsUnsupported("series_v1", "gzip"))
+	assert.False(t, IsUnsupported("sketches_v2", "zstd"))
+}
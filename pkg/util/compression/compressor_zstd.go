@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build cgo
+
+package compression
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	zstd_0 "github.com/DataDog/zstd_0"
+)
+
+// newZstdCompressor returns a zstd Compressor of the given level, 0 selects the default level
+func newZstdCompressor(level int) (Compressor, error) {
+	if level == 0 {
+		level = zstd_0.DefaultCompression
+	} else if level < zstd_0.BestSpeed || level > zstd_0.BestCompression {
+		return nil, fmt.Errorf("invalid zstd compression level %d", level)
+	}
+	return &zstd0Compressor{level: level}, nil
+}
+
+// zstd0Compressor uses the pre-v1 zstd format, the only one the intake understands
+// under the zstd Content-Encoding. It cannot compress streams: ForStreams replaces it
+// with zlib.
+type zstd0Compressor struct {
+	level int
+}
+
+func (c *zstd0Compressor) noStreams() {}
+
+func (c *zstd0Compressor) Kind() string            { return ZstdKind }
+func (c *zstd0Compressor) ContentEncoding() string { return "zstd" }
+
+func (c *zstd0Compressor) Compress(src []byte) ([]byte, error) {
+	return zstd_0.CompressLevel(nil, src, c.level)
+}
+
+func (c *zstd0Compressor) Decompress(src []byte) ([]byte, error) {
+	return zstd_0.Decompress(nil, src)
+}
+
+func (c *zstd0Compressor) CompressBound(sourceLen int) int {
+	return zstd_0.CompressBound(sourceLen)
+}
+
+func (c *zstd0Compressor) NewStreamCompressor(output *bytes.Buffer) (StreamCompressor, error) {
+	return nil, errors.New("the pre-v1 zstd format cannot compress streams")
+}
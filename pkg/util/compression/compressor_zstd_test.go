@@ -0,0 +1,47 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build cgo
+
+package compression
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	zstd_0 "github.com/DataDog/zstd_0"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZstdCompressor(t *testing.T) {
+	payload := []byte(strings.Repeat(`{"metric":"system.cpu.user","points":[[1600000000,12.5]]},`, 100))
+
+	c, err := NewCompressor(ZstdKind, 3)
+	require.NoError(t, err)
+	assert.Equal(t, ZstdKind, c.Kind())
+	assert.Equal(t, "zstd", c.ContentEncoding())
+
+	compressed, err := c.Compress(payload)
+	require.NoError(t, err)
+	assert.True(t, len(compressed) <= c.CompressBound(len(payload)))
+
+	// the payloads use the pre-v1 format, like the ones of the zstd builds
+	decompressed, err := zstd_0.Decompress(nil, compressed)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decompressed)
+
+	d, err := NewCompressorForContentEncoding("zstd")
+	require.NoError(t, err)
+	decompressed, err = d.Decompress(compressed)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decompressed)
+
+	// the pre-v1 format cannot compress streams
+	_, err = c.NewStreamCompressor(&bytes.Buffer{})
+	assert.Error(t, err)
+	assert.Equal(t, ZlibKind, ForStreams(c).Kind())
+}
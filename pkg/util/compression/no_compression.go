@@ -12,6 +12,11 @@ package compression
 // var instead of const to ease testing
 var ContentEncoding = ""
 
+// DefaultCompressor returns the Compressor selected at build time
+func DefaultCompressor() Compressor {
+	return noneCompressor{}
+}
+
 // Compress will not compress anything
 func Compress(dst []byte, src []byte) ([]byte, error) {
 	dst = src
@@ -0,0 +1,52 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+package compression
+
+import (
+	"sync"
+)
+
+var (
+	// unsupported holds, by endpoint name, the content encodings rejected by the endpoint
+	unsupported   = make(map[string]map[string]struct{})
+	unsupportedMu sync.RWMutex
+)
+
+// MarkUnsupported records that an endpoint rejected the payloads sent with the given
+// Content-Encoding, so that the next payloads are compressed with another algorithm.
+// It returns false if the content encoding was already known to be unsupported.
+func MarkUnsupported(endpoint string, contentEncoding string) bool {
+	unsupportedMu.Lock()
+	defer unsupportedMu.Unlock()
+
+	encodings, found := unsupported[endpoint]
+	if !found {
+		encodings = make(map[string]struct{})
+		unsupported[endpoint] = encodings
+	}
+	if _, found := encodings[contentEncoding]; found {
+		return false
+	}
+	encodings[contentEncoding] = struct{}{}
+	return true
+}
+
+// IsUnsupported returns whether an endpoint rejected the payloads sent with the given Content-Encoding
+func IsUnsupported(endpoint string, contentEncoding string) bool {
+	unsupportedMu.RLock()
+	defer unsupportedMu.RUnlock()
+
+	_, found := unsupported[endpoint][contentEncoding]
+	return found
+}
+
+// ResetUnsupported forgets the content encodings rejected by the endpoints, used in tests
+func ResetUnsupported() {
+	unsupportedMu.Lock()
+	defer unsupportedMu.Unlock()
+
+	unsupported = make(map[string]map[string]struct{})
+}
@@ -17,6 +17,11 @@ import (
 // var instead of const to ease testing
 var ContentEncoding = "deflate"
 
+// DefaultCompressor returns the Compressor selected at build time
+func DefaultCompressor() Compressor {
+	return &zlibCompressor{level: zlib.DefaultCompression}
+}
+
 // Compress will compress the data with zlib
 func Compress(dst []byte, src []byte) ([]byte, error) {
 	var b bytes.Buffer
@@ -18,6 +18,11 @@ import (
 // var instead of const to ease testing
 var ContentEncoding = "zstd"
 
+// DefaultCompressor returns the Compressor selected at build time
+func DefaultCompressor() Compressor {
+	return &zstd0Compressor{level: zstd_0.DefaultCompression}
+}
+
 // Compress will compress the data with zstd
 func Compress(dst []byte, src []byte) ([]byte, error) {
 	return zstd_0.Compress(dst, src)
@@ -9,11 +9,13 @@ package util
 
 import (
 	"context"
+	"errors"
 	"expvar"
 	"fmt"
 	"net"
 	"os"
 	"runtime"
+	"time"
 
 	"github.com/DataDog/datadog-agent/pkg/metadata/inventories"
 	"github.com/DataDog/datadog-agent/pkg/util/containers"
@@ -125,9 +127,12 @@ func saveHostnameData(cacheHostnameKey string, hostname string, provider string)
 	return hostnameData
 }
 
-func saveAndValidateHostnameData(ctx context.Context, cacheHostnameKey string, hostname string, provider string) HostnameData {
+func saveAndValidateHostnameData(ctx context.Context, cacheHostnameKey string, hostname string, provider string, trace *HostnameTrace) HostnameData {
 	hostnameData := saveHostnameData(cacheHostnameKey, hostname, HostnameProviderConfiguration)
-	if !isHostnameCanonicalForIntake(ctx, hostname) && !config.Datadog.GetBool("hostname_force_config_as_canonical") {
+	if config.Datadog.GetBool("hostname_force_config_as_canonical") {
+		trace.addDecision("'%s' from the %s is used as the in-app hostname as `hostname_force_config_as_canonical` is set", hostname, provider)
+	} else if !isHostnameCanonicalForIntake(ctx, hostname) {
+		trace.addDecision("'%s' from the %s is a default EC2 hostname, the intake uses the EC2 instance ID as the in-app hostname instead", hostname, provider)
 		log.Warnf(
 			"Hostname '%s' defined in configuration will not be used as the in-app hostname. "+
 				"For more information: https://dtdg.co/agent-hostname-force-config-as-canonical",
@@ -153,6 +158,15 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 		return cacheHostname.(HostnameData), nil
 	}
 
+	trace := newHostnameTrace()
+	hostnameData, err := resolveHostnameData(ctx, cacheHostnameKey, trace)
+	trace.finish(ctx, hostnameData, err)
+	setHostnameTrace(trace)
+	return hostnameData, err
+}
+
+// resolveHostnameData goes through the hostname providers and records their results in trace
+func resolveHostnameData(ctx context.Context, cacheHostnameKey string, trace *HostnameTrace) (HostnameData, error) {
 	var hostName string
 	var err error
 	var provider string
@@ -160,12 +174,14 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 	// Try the name provided in the configuration file
 	configName := config.Datadog.GetString("hostname")
 	err = validate.ValidHostname(configName)
+	trace.addAttempt(HostnameProviderConfiguration, time.Now(), configName, err)
 	if err == nil {
 		return saveAndValidateHostnameData(
 			ctx,
 			cacheHostnameKey,
 			configName,
 			HostnameProviderConfiguration,
+			trace,
 		), nil
 	}
 
@@ -180,13 +196,16 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 	if configHostnameFilepath != "" {
 		log.Debug("GetHostname trying `hostname_file` config option...")
 		if fileHostnameProvider := hostname.GetProvider("file"); fileHostnameProvider != nil {
-			if hostname, err := fileHostnameProvider(
+			start := time.Now()
+			hostname, err := fileHostnameProvider(
 				ctx,
 				map[string]interface{}{
 					"filename": configHostnameFilepath,
 				},
-			); err == nil {
-				return saveAndValidateHostnameData(ctx, cacheHostnameKey, hostname, "file"), nil
+			)
+			trace.addAttempt("file", start, hostname, err)
+			if err == nil {
+				return saveAndValidateHostnameData(ctx, cacheHostnameKey, hostname, "file", trace), nil
 			}
 
 			expErr := new(expvar.String)
@@ -194,20 +213,28 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 			hostnameErrors.Set("configuration/environment", expErr)
 			log.Debugf("Unable to get hostname from file '%s': %s", configHostnameFilepath, err)
 		}
+	} else {
+		trace.addSkipped("file", "`hostname_file` is not set")
 	}
 
 	log.Debug("Trying to determine a reliable host name automatically...")
 
 	// If fargate we strip the hostname
+	start := time.Now()
 	if fargate.IsFargateInstance(ctx) {
+		trace.addAttempt("fargate", start, "", nil)
+		trace.addDecision("the Agent runs on Fargate, it doesn't report a hostname")
 		hostnameData := saveHostnameData(cacheHostnameKey, "", "")
 		return hostnameData, nil
 	}
+	trace.addAttempt("fargate", start, "", errors.New("not a Fargate instance"))
 
 	// GCE metadata
 	log.Debug("GetHostname trying GCE metadata...")
 	if getGCEHostname := hostname.GetProvider("gce"); getGCEHostname != nil {
+		start := time.Now()
 		gceName, err := getGCEHostname(ctx, nil)
+		trace.addAttempt("gce", start, gceName, err)
 		if err == nil {
 			hostnameData := saveHostnameData(cacheHostnameKey, gceName, "gce")
 			return hostnameData, err
@@ -223,7 +250,9 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 	canUseOSHostname := isOSHostnameUsable(ctx)
 	if canUseOSHostname {
 		log.Debug("GetHostname trying FQDN/`hostname -f`...")
+		start := time.Now()
 		fqdn, err = getSystemFQDN()
+		trace.addAttempt("fqdn", start, fqdn, err)
 		if config.Datadog.GetBool("hostname_fqdn") && err == nil {
 			hostName = fqdn
 			provider = "fqdn"
@@ -235,24 +264,36 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 			}
 			log.Debug("Unable to get FQDN from system: ", err)
 		}
+	} else {
+		trace.addSkipped("fqdn", "the Agent runs in a container which doesn't share the UTS namespace of the host")
 	}
 
 	if config.IsContainerized() {
+		start := time.Now()
 		containerName := getContainerHostname(ctx)
 		if containerName != "" {
+			trace.addAttempt("container", start, containerName, nil)
+			if hostName != "" {
+				trace.addDecision("the container hostname '%s' overrides the FQDN '%s'", containerName, hostName)
+			}
 			hostName = containerName
 			provider = "container"
 		} else {
 			expErr := new(expvar.String)
 			expErr.Set("Unable to get hostname from container API")
 			hostnameErrors.Set("container", expErr)
+			trace.addAttempt("container", start, "", errors.New("Unable to get hostname from container API"))
 		}
+	} else {
+		trace.addSkipped("container", "the Agent is not containerized")
 	}
 
 	if canUseOSHostname && hostName == "" {
 		// os
 		log.Debug("GetHostname trying os...")
+		start := time.Now()
 		systemName, err := os.Hostname()
+		trace.addAttempt("os", start, systemName, err)
 		if err == nil {
 			hostName = systemName
 			provider = "os"
@@ -262,6 +303,10 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 			hostnameErrors.Set("os", expErr)
 			log.Debug("Unable to get hostname from OS: ", err)
 		}
+	} else if !canUseOSHostname {
+		trace.addSkipped("os", "the Agent runs in a container which doesn't share the UTS namespace of the host")
+	} else {
+		trace.addSkipped("os", fmt.Sprintf("the hostname was already resolved by the %s provider", provider))
 	}
 
 	// at this point we've either the hostname from the os or an empty string
@@ -273,9 +318,14 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 		log.Debug("GetHostname trying EC2 metadata...")
 
 		if ecs.IsECSInstance() || ec2.IsDefaultHostname(hostName) {
+			start := time.Now()
 			ec2Hostname, err := getValidEC2Hostname(ctx, getEC2Hostname)
+			trace.addAttempt("aws", start, ec2Hostname, err)
 
 			if err == nil {
+				if hostName != "" {
+					trace.addDecision("'%s' is a default EC2 hostname or the host is an ECS instance, the EC2 instance ID '%s' is used instead", hostName, ec2Hostname)
+				}
 				hostName = ec2Hostname
 				provider = "aws"
 			} else {
@@ -286,6 +336,7 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 			}
 		} else {
 			err := fmt.Errorf("not retrieving hostname from AWS: the host is not an ECS instance and other providers already retrieve non-default hostnames")
+			trace.addSkipped("aws", err.Error())
 			log.Debug(err.Error())
 			expErr := new(expvar.String)
 			expErr.Set(err.Error())
@@ -299,6 +350,7 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 
 				// Check if we get a valid hostname when enabling `ec2_use_windows_prefix_detection` and the hostnames are different.
 				if err == nil && ec2Hostname != hostName {
+					trace.addDecision("'%s' is a default Windows hostname, the EC2 instance ID '%s' would be used if `ec2_use_windows_prefix_detection` was enabled", hostName, ec2Hostname)
 					// REMOVEME: This should be removed if/when the default `ec2_use_windows_prefix_detection` is set to true
 					log.Infof("The agent resolved your hostname as '%s'. You may want to use the EC2 instance-id ('%s') for the in-app hostname."+
 						" For more information: https://docs.datadoghq.com/ec2-use-win-prefix-detection", hostName, ec2Hostname)
@@ -310,8 +362,13 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 	if getAzureHostname := hostname.GetProvider("azure"); getAzureHostname != nil {
 		log.Debug("GetHostname trying Azure metadata...")
 
+		start := time.Now()
 		azureHostname, err := getAzureHostname(ctx, nil)
+		trace.addAttempt("azure", start, azureHostname, err)
 		if err == nil {
+			if hostName != "" {
+				trace.addDecision("the Azure hostname '%s' overrides '%s' from the %s provider", azureHostname, hostName, provider)
+			}
 			hostName = azureHostname
 			provider = "azure"
 		} else {
@@ -327,6 +384,7 @@ func GetHostnameData(ctx context.Context) (HostnameData, error) {
 	// field `hostname_fqdn` isn't set -> we display a warning message about
 	// the future behavior
 	if err == nil && !config.Datadog.GetBool("hostname_fqdn") && fqdn != "" && hostName == h && h != fqdn {
+		trace.addDecision("the legacy OS hostname '%s' is used instead of the FQDN '%s' as `hostname_fqdn` is not enabled", h, fqdn)
 		if runtime.GOOS != "windows" {
 			// REMOVEME: This should be removed when the default `hostname_fqdn` is set to true
 			log.Warnf("DEPRECATION NOTICE: The agent resolved your hostname as '%s'. However in a future version, it will be resolved as '%s' by default. To enable the future behavior, please enable the `hostname_fqdn` flag in the configuration. For more information: https://dtdg.co/flag-hostname-fqdn", h, fqdn)
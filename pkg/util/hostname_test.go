@@ -72,6 +72,104 @@ func TestGetHostnameFromHostnameFileConfig(t *testing.T) {
 	assert.Equal(t, "expectedfilehostname", hostname)
 }
 
+func TestGetHostnameTrace(t *testing.T) {
+	hostnameFile, err := writeTempHostnameFile("expectedfilehostname")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(hostnameFile)
+
+	clearCache()
+	config.Datadog.Set("hostname", "")
+	config.Datadog.Set("hostname_file", hostnameFile)
+	defer cleanUpConfigValues()
+
+	hostname, err := GetHostname(context.TODO())
+	assert.Nil(t, err)
+
+	trace := GetHostnameTrace()
+	if !assert.NotNil(t, trace) {
+		return
+	}
+	assert.Equal(t, "expectedfilehostname", trace.Hostname)
+	assert.Equal(t, HostnameProviderConfiguration, trace.Provider)
+	assert.Equal(t, hostname, trace.Hostname)
+	assert.Empty(t, trace.Error)
+	assert.True(t, trace.CanonicalForIntake)
+	if assert.Len(t, trace.Attempts, 2) {
+		assert.Equal(t, HostnameProviderConfiguration, trace.Attempts[0].Provider)
+		assert.NotEmpty(t, trace.Attempts[0].Error)
+		assert.Equal(t, "file", trace.Attempts[1].Provider)
+		assert.Equal(t, "expectedfilehostname", trace.Attempts[1].Hostname)
+		assert.Empty(t, trace.Attempts[1].Error)
+	}
+	assert.Contains(t, trace.String(), "file          'expectedfilehostname' in ")
+
+	// the cached hostname keeps the trace of its resolution
+	config.Datadog.Set("hostname", "newhostname")
+	GetHostname(context.TODO())
+	assert.Equal(t, "expectedfilehostname", GetHostnameTrace().Hostname)
+
+	// explaining the hostname resolves it again
+	trace = ExplainHostname(context.TODO())
+	assert.Equal(t, "newhostname", trace.Hostname)
+	if assert.Len(t, trace.Attempts, 1) {
+		assert.Equal(t, HostnameProviderConfiguration, trace.Attempts[0].Provider)
+		assert.Equal(t, "newhostname", trace.Attempts[0].Hostname)
+	}
+}
+
+func TestPersistHostnameDecision(t *testing.T) {
+	runPath, err := ioutil.TempDir("", "run")
+	if !assert.Nil(t, err) {
+		return
+	}
+	defer os.RemoveAll(runPath)
+
+	clearCache()
+	config.Datadog.Set("run_path", runPath)
+	config.Datadog.Set("hostname", "firsthostname")
+	defer config.Datadog.Set("run_path", "")
+	defer cleanUpConfigValues()
+
+	GetHostname(context.TODO())
+	assert.Nil(t, PersistHostnameDecision())
+	trace := GetHostnameTrace()
+	assert.Nil(t, trace.Previous)
+	assert.False(t, trace.Changed)
+
+	// same hostname on the next run
+	clearCache()
+	GetHostname(context.TODO())
+	assert.Nil(t, PersistHostnameDecision())
+	trace = GetHostnameTrace()
+	if assert.NotNil(t, trace.Previous) {
+		assert.Equal(t, "firsthostname", trace.Previous.Hostname)
+		assert.Equal(t, HostnameProviderConfiguration, trace.Previous.Provider)
+	}
+	assert.False(t, trace.Changed)
+
+	// the hostname changed
+	clearCache()
+	config.Datadog.Set("hostname", "secondhostname")
+	GetHostname(context.TODO())
+	assert.Nil(t, PersistHostnameDecision())
+	trace = GetHostnameTrace()
+	if assert.NotNil(t, trace.Previous) {
+		assert.Equal(t, "firsthostname", trace.Previous.Hostname)
+	}
+	assert.True(t, trace.Changed)
+	assert.Contains(t, trace.String(), "Previous hostname: firsthostname (provider: configuration")
+
+	// explain compares the hostname to the persisted one
+	config.Datadog.Set("hostname", "thirdhostname")
+	trace = ExplainHostname(context.TODO())
+	if assert.NotNil(t, trace.Previous) {
+		assert.Equal(t, "secondhostname", trace.Previous.Hostname)
+	}
+	assert.True(t, trace.Changed)
+}
+
 func writeTempHostnameFile(content string) (string, error) {
 	destFile, err := ioutil.TempFile("", "test-hostname-file-config-")
 	if err != nil {
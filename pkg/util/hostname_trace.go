@@ -0,0 +1,227 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the Apache License Version 2.0.
+// This product includes software developed at Datadog (https://www.datadoghq.com/).
+// Copyright 2016-present Datadog, Inc.
+
+// +build !serverless
+
+package util
+
+import (
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/DataDog/datadog-agent/pkg/config"
+	"github.com/DataDog/datadog-agent/pkg/util/cache"
+	"github.com/DataDog/datadog-agent/pkg/util/log"
+)
+
+// hostnameDecisionFile is the file of the run path where the last hostname decision is persisted
+const hostnameDecisionFile = "hostname.json"
+
+var (
+	lastHostnameTrace     *HostnameTrace
+	lastHostnameTraceLock sync.RWMutex
+)
+
+func init() {
+	hostnameExpvars.Set("trace", expvar.Func(func() interface{} {
+		return GetHostnameTrace()
+	}))
+}
+
+// HostnameProviderAttempt is the result of a hostname provider
+type HostnameProviderAttempt struct {
+	Provider string `json:"provider"`
+	Hostname string `json:"hostname,omitempty"`
+	Error    string `json:"error,omitempty"`
+	// Skipped is true when the provider was not tried, Error holds the reason
+	Skipped  bool          `json:"skipped,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// HostnameDecision is a hostname resolved by the Agent
+type HostnameDecision struct {
+	Hostname   string    `json:"hostname"`
+	Provider   string    `json:"provider"`
+	ResolvedAt time.Time `json:"resolved_at"`
+}
+
+// HostnameTrace records how the hostname was resolved: the attempt of each provider and the
+// reasons why the hostname of a provider was preferred to the others.
+type HostnameTrace struct {
+	HostnameDecision
+	Error string `json:"error,omitempty"`
+	// CanonicalForIntake is false when the intake uses another hostname, the EC2 instance ID
+	// of a default EC2 hostname for instance
+	CanonicalForIntake bool                      `json:"canonical_for_intake"`
+	Attempts           []HostnameProviderAttempt `json:"attempts"`
+	Decisions          []string                  `json:"decisions,omitempty"`
+	Duration           time.Duration             `json:"duration_ns"`
+	// Previous is the hostname decision persisted by the previous run of the Agent, if any
+	Previous *HostnameDecision `json:"previous,omitempty"`
+	// Changed is true when the hostname differs from the one of the previous run
+	Changed bool `json:"changed"`
+
+	start time.Time
+}
+
+func newHostnameTrace() *HostnameTrace {
+	return &HostnameTrace{start: time.Now()}
+}
+
+func (t *HostnameTrace) addAttempt(provider string, start time.Time, hostname string, err error) {
+	attempt := HostnameProviderAttempt{
+		Provider: provider,
+		Hostname: hostname,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	t.Attempts = append(t.Attempts, attempt)
+}
+
+func (t *HostnameTrace) addSkipped(provider string, reason string) {
+	t.Attempts = append(t.Attempts, HostnameProviderAttempt{
+		Provider: provider,
+		Error:    reason,
+		Skipped:  true,
+	})
+}
+
+func (t *HostnameTrace) addDecision(format string, args ...interface{}) {
+	t.Decisions = append(t.Decisions, fmt.Sprintf(format, args...))
+}
+
+func (t *HostnameTrace) finish(ctx context.Context, hostnameData HostnameData, err error) {
+	t.Hostname = hostnameData.Hostname
+	t.Provider = hostnameData.Provider
+	t.ResolvedAt = time.Now()
+	t.Duration = t.ResolvedAt.Sub(t.start)
+	if err != nil {
+		t.Error = err.Error()
+	}
+	if t.Hostname != "" {
+		t.CanonicalForIntake = (config.Datadog.GetBool("hostname_force_config_as_canonical") && t.Provider == HostnameProviderConfiguration) ||
+			isHostnameCanonicalForIntake(ctx, t.Hostname)
+	}
+}
+
+// String returns a human readable version of the trace
+func (t *HostnameTrace) String() string {
+	s := fmt.Sprintf("Hostname: %s\nProvider: %s\nCanonical for the intake: %t\nResolved in: %s\n", t.Hostname, t.Provider, t.CanonicalForIntake, t.Duration)
+	if t.Error != "" {
+		s += fmt.Sprintf("Error: %s\n", t.Error)
+	}
+
+	s += "\nProviders:\n"
+	for _, attempt := range t.Attempts {
+		switch {
+		case attempt.Skipped:
+			s += fmt.Sprintf("  %-13s skipped: %s\n", attempt.Provider, attempt.Error)
+		case attempt.Error != "":
+			s += fmt.Sprintf("  %-13s failed in %s: %s\n", attempt.Provider, attempt.Duration, attempt.Error)
+		default:
+			s += fmt.Sprintf("  %-13s '%s' in %s\n", attempt.Provider, attempt.Hostname, attempt.Duration)
+		}
+	}
+
+	if len(t.Decisions) > 0 {
+		s += "\nDecisions:\n"
+		for _, decision := range t.Decisions {
+			s += fmt.Sprintf("  - %s\n", decision)
+		}
+	}
+
+	if t.Previous != nil {
+		s += fmt.Sprintf("\nPrevious hostname: %s (provider: %s, resolved at %s)\n", t.Previous.Hostname, t.Previous.Provider, t.Previous.ResolvedAt.Format(time.RFC3339))
+		if t.Changed {
+			s += "The hostname differs from the previous hostname.\n"
+		}
+	}
+	return s
+}
+
+func setHostnameTrace(trace *HostnameTrace) {
+	lastHostnameTraceLock.Lock()
+	defer lastHostnameTraceLock.Unlock()
+	lastHostnameTrace = trace
+}
+
+// GetHostnameTrace returns how the hostname was resolved by this process, nil if it wasn't
+// resolved yet.
+func GetHostnameTrace() *HostnameTrace {
+	lastHostnameTraceLock.RLock()
+	defer lastHostnameTraceLock.RUnlock()
+	if lastHostnameTrace == nil {
+		return nil
+	}
+	trace := *lastHostnameTrace
+	return &trace
+}
+
+// ExplainHostname resolves the hostname again, ignoring the cached one, and returns how it was
+// resolved compared to the hostname persisted by the Agent.
+func ExplainHostname(ctx context.Context) *HostnameTrace {
+	cache.Cache.Delete(cache.BuildAgentKey("hostname"))
+	_, _ = GetHostnameData(ctx)
+	trace := GetHostnameTrace()
+	if previous, err := readHostnameDecision(); err == nil {
+		trace.Previous = previous
+		trace.Changed = previous.Hostname != trace.Hostname
+	}
+	return trace
+}
+
+// PersistHostnameDecision persists the hostname resolved by this process so that a change of
+// hostname is detected and reported by the next run of the Agent. The hostname persisted by
+// the previous run is added to the trace of the hostname resolution.
+func PersistHostnameDecision() error {
+	lastHostnameTraceLock.Lock()
+	defer lastHostnameTraceLock.Unlock()
+	trace := lastHostnameTrace
+	if trace == nil {
+		return fmt.Errorf("the hostname was not resolved")
+	}
+
+	if previous, err := readHostnameDecision(); err == nil {
+		trace.Previous = previous
+		trace.Changed = previous.Hostname != trace.Hostname
+		if trace.Changed {
+			log.Warnf("The hostname changed from '%s' (provider: %s) to '%s' (provider: %s) since the previous run of the Agent, run `agent hostname --explain` for more details",
+				previous.Hostname, previous.Provider, trace.Hostname, trace.Provider)
+		}
+	} else if !os.IsNotExist(err) {
+		log.Debugf("Unable to read the previous hostname: %s", err)
+	}
+
+	data, err := json.Marshal(trace.HostnameDecision)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(hostnameDecisionPath(), data, 0644)
+}
+
+func readHostnameDecision() (*HostnameDecision, error) {
+	data, err := ioutil.ReadFile(hostnameDecisionPath())
+	if err != nil {
+		return nil, err
+	}
+	var decision HostnameDecision
+	if err := json.Unmarshal(data, &decision); err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+func hostnameDecisionPath() string {
+	return filepath.Join(config.Datadog.GetString("run_path"), hostnameDecisionFile)
+}
@@ -69,6 +69,10 @@ func dumpRetryFile(file string) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
+	content, err = skipHeader(content)
+	if err != nil {
+		return nil, err
+	}
 	collection := HttpTransactionProtoCollection{}
 
 	if err := proto.Unmarshal(content, &collection); err != nil {
@@ -90,6 +94,23 @@ func dumpRetryFile(file string) ([]byte, error) {
 	return json.MarshalIndent(jsonTrs, "", "  ")
 }
 
+// skipHeader removes the header written by the recent versions of the Agent, see
+// pkg/forwarder/internal/retry/retry_file_codec.go. The checksum is not verified.
+func skipHeader(content []byte) ([]byte, error) {
+	const magic = "\xfeDDR"
+	const headerSize = len(magic) + 2 + 32
+	if !bytes.HasPrefix(content, []byte(magic)) {
+		return content, nil
+	}
+	if len(content) < headerSize {
+		return nil, errors.New("Truncated header")
+	}
+	if flags := content[len(magic)+1]; flags&1 != 0 {
+		return nil, errors.New("The file is encrypted and cannot be dumped")
+	}
+	return content[headerSize:], nil
+}
+
 type JsonHttpTransaction struct {
 	*HttpTransactionProto
 	Payload string // Same as HttpTransactionProto.Payload but the type is string instead of []byte